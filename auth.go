@@ -0,0 +1,59 @@
+package featureprobe
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+// AuthProvider supplies the Authorization header of every request to
+// FeatureProbe, for credentials other than a static server SDK key, such as
+// OAuth2 tokens or short-lived credentials issued by a gateway.
+type AuthProvider interface {
+	// Authorization returns the current header value.
+	Authorization() (string, error)
+	// Refresh renews the credentials after the server rejected them. The
+	// request is retried once when it returns nil.
+	Refresh() error
+}
+
+// WithAuthProvider authenticates requests with provider instead of the
+// server SDK key.
+func WithAuthProvider(provider AuthProvider) Option {
+	return func(fpConfig *FPConfig) {
+		fpConfig.AuthProvider = provider
+	}
+}
+
+// staticAuth is the server SDK key, which can't be refreshed.
+type staticAuth string
+
+func (a staticAuth) Authorization() (string, error) {
+	return string(a), nil
+}
+
+func (a staticAuth) Refresh() error {
+	return errors.New("server sdk key can not be refreshed")
+}
+
+// sendAuthorized sends the request built with the authorization of auth,
+// refreshing it and sending again once when the server answers 401.
+func sendAuthorized(auth AuthProvider, send func(authorization string) (*http.Response, error)) (*http.Response, error) {
+	authorization, err := auth.Authorization()
+	if err != nil {
+		return nil, fmt.Errorf("get authorization fails: %w", err)
+	}
+	resp, err := send(authorization)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized || auth.Refresh() != nil {
+		return resp, err
+	}
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+	_ = resp.Body.Close()
+	authorization, err = auth.Authorization()
+	if err != nil {
+		return nil, fmt.Errorf("get authorization fails: %w", err)
+	}
+	return send(authorization)
+}
@@ -0,0 +1,76 @@
+package featureprobe
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type rotatingAuth struct {
+	token     atomic.Value
+	refreshes int32
+}
+
+func (a *rotatingAuth) Authorization() (string, error) {
+	return "Bearer " + a.token.Load().(string), nil
+}
+
+func (a *rotatingAuth) Refresh() error {
+	atomic.AddInt32(&a.refreshes, 1)
+	a.token.Store("new")
+	return nil
+}
+
+func newAuthServer(accepted string, requests *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(requests, 1)
+		if r.Header.Get("Authorization") != accepted {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(`{"toggles": {}}`))
+	}))
+}
+
+func TestAuthProviderRefresh(t *testing.T) {
+	var requests int32
+	server := newAuthServer("Bearer new", &requests)
+	defer server.Close()
+
+	auth := &rotatingAuth{}
+	auth.token.Store("old")
+	fp, err := NewFeatureProbe(server.URL, "sdk_key", WithServerless(true), WithAuthProvider(auth))
+	assert.Nil(t, err)
+	fp.Syncer.fetchRemoteRepo()
+
+	assert.NotNil(t, fp.Repo.Load().Toggles)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&auth.refreshes))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&requests))
+}
+
+func TestStaticAuthIsNotRetried(t *testing.T) {
+	var requests int32
+	server := newAuthServer("other_key", &requests)
+	defer server.Close()
+
+	fp, _ := NewFeatureProbe(server.URL, "sdk_key", WithServerless(true))
+	fp.Syncer.fetchRemoteRepo()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+}
+
+type failingAuth struct{}
+
+func (failingAuth) Authorization() (string, error) { return "", errors.New("gateway down") }
+func (failingAuth) Refresh() error                 { return nil }
+
+func TestAuthProviderFails(t *testing.T) {
+	_, err := sendAuthorized(failingAuth{}, func(string) (*http.Response, error) {
+		t.Fatal("sent without authorization")
+		return nil, nil
+	})
+	assert.EqualError(t, err, "get authorization fails: gateway down")
+}
@@ -0,0 +1,128 @@
+package featureprobe
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultBigSegmentCacheTime is how long the big segments of a user are
+	// cached before the BigSegmentStore is queried again.
+	DefaultBigSegmentCacheTime = 5 * time.Second
+	// DefaultBigSegmentStaleAfter is how long after its last update a
+	// BigSegmentStore is reported stale.
+	DefaultBigSegmentStaleAfter = 2 * time.Minute
+	bigSegmentCacheSize         = 1000
+)
+
+// BigSegmentStore looks up the membership of big segments, those marked
+// unbounded whose users are too many to ship in the repository. It is
+// populated out of band, e.g. by a relay, and queried during evaluation.
+type BigSegmentStore interface {
+	// Membership returns the keys of the big segments userKey is included
+	// in, mapped to true.
+	Membership(userKey string) (map[string]bool, error)
+	// LastUpdated returns when the store was last populated.
+	LastUpdated() (time.Time, error)
+}
+
+// BigSegmentStatus tells whether big segment memberships can be trusted.
+// Users of unavailable stores are in no big segment.
+type BigSegmentStatus struct {
+	Available bool
+	Stale     bool
+}
+
+// WithBigSegmentStore queries store for the users of unbounded segments. A
+// user is in such a segment when the store includes them or when they match
+// its rules.
+func WithBigSegmentStore(store BigSegmentStore) Option {
+	return func(fpConfig *FPConfig) {
+		fpConfig.BigSegmentStore = store
+	}
+}
+
+// WithBigSegmentStaleAfter reports the big segment store stale when it was
+// last updated more than d ago.
+func WithBigSegmentStaleAfter(d time.Duration) Option {
+	return func(fpConfig *FPConfig) {
+		fpConfig.BigSegmentStaleAfter = d
+	}
+}
+
+type cachedMembership struct {
+	segments map[string]bool
+	expires  time.Time
+}
+
+type bigSegments struct {
+	store  BigSegmentStore
+	clock  Clock
+	report func(error)
+	mu     sync.Mutex
+	cache  map[string]cachedMembership
+	// order holds the cached user keys, oldest first.
+	order []string
+}
+
+func newBigSegments(config FPConfig) *bigSegments {
+	if config.BigSegmentStore == nil {
+		return nil
+	}
+	return &bigSegments{
+		store:  config.BigSegmentStore,
+		clock:  clockOrSystem(config.Clock),
+		report: config.reportError,
+		cache:  map[string]cachedMembership{},
+	}
+}
+
+// contains reports whether the store includes userKey in segment. Store
+// failures are reported and cached like a membership in no segment.
+func (b *bigSegments) contains(segment string, userKey string) bool {
+	if b == nil {
+		return false
+	}
+	now := b.clock.Now()
+	b.mu.Lock()
+	cached, ok := b.cache[userKey]
+	b.mu.Unlock()
+	if ok && now.Before(cached.expires) {
+		return cached.segments[segment]
+	}
+
+	segments, err := b.store.Membership(userKey)
+	if err != nil {
+		b.report(fmt.Errorf("query big segments of user %s fails: %w", userKey, err))
+	}
+	b.mu.Lock()
+	if _, ok := b.cache[userKey]; !ok {
+		b.order = append(b.order, userKey)
+	}
+	b.cache[userKey] = cachedMembership{segments: segments, expires: now.Add(DefaultBigSegmentCacheTime)}
+	for len(b.order) > bigSegmentCacheSize {
+		delete(b.cache, b.order[0])
+		b.order = b.order[1:]
+	}
+	b.mu.Unlock()
+	return segments[segment]
+}
+
+// BigSegmentStatus queries the big segment store for its last update. Without
+// a store, the status is unavailable.
+func (fp *FeatureProbe) BigSegmentStatus() BigSegmentStatus {
+	b := fp.bigSegments
+	if b == nil {
+		return BigSegmentStatus{}
+	}
+	updated, err := b.store.LastUpdated()
+	if err != nil {
+		return BigSegmentStatus{}
+	}
+	staleAfter := fp.Config.BigSegmentStaleAfter
+	if staleAfter <= 0 {
+		staleAfter = DefaultBigSegmentStaleAfter
+	}
+	return BigSegmentStatus{Available: true, Stale: b.clock.Now().Sub(updated) > staleAfter}
+}
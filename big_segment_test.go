@@ -0,0 +1,105 @@
+package featureprobe
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type memoryBigSegmentStore struct {
+	members map[string]map[string]bool
+	updated time.Time
+	err     error
+	queries int
+}
+
+func (s *memoryBigSegmentStore) Membership(userKey string) (map[string]bool, error) {
+	s.queries++
+	return s.members[userKey], s.err
+}
+
+func (s *memoryBigSegmentStore) LastUpdated() (time.Time, error) {
+	return s.updated, s.err
+}
+
+func newBigSegmentClientForTest(store BigSegmentStore, clock Clock) FeatureProbe {
+	fp := NewFeatureProbeForTest(map[string]interface{}{})
+	fp.Config = FPConfig{BigSegmentStore: store, Clock: clock, ErrorListener: func(error) {}}
+	fp.bigSegments = newBigSegments(fp.Config)
+
+	index, other := 1, 0
+	toggle := Toggle{
+		Key:           "toggle",
+		Enabled:       true,
+		DisabledServe: Serve{Select: &other},
+		DefaultServe:  Serve{Select: &other},
+		Rules: []Rule{{
+			Serve:      Serve{Select: &index},
+			Conditions: []Condition{{Type: "segment", Predicate: "is in", Objects: []string{"big"}}},
+		}},
+		Variations: []interface{}{"out", "in"},
+	}
+	repo := &Repository{
+		Toggles: map[string]Toggle{"toggle": toggle},
+		Segments: map[string]Segment{"big": {
+			Key:       "big",
+			Unbounded: true,
+			Rules: []Rule{{Conditions: []Condition{
+				{Type: "string", Subject: "city", Predicate: "is one of", Objects: []string{"paris"}},
+			}}},
+		}},
+	}
+	repo.compile(nil)
+	fp.Repo.Store(repo)
+	return fp
+}
+
+func TestBigSegmentMembership(t *testing.T) {
+	clock := NewManualClock(time.Unix(1000, 0))
+	store := &memoryBigSegmentStore{members: map[string]map[string]bool{"member": {"big": true}}}
+	fp := newBigSegmentClientForTest(store, clock)
+
+	assert.Equal(t, "in", fp.StrValue("toggle", NewUser().StableRollout("member"), "default"))
+	assert.Equal(t, "out", fp.StrValue("toggle", NewUser().StableRollout("other"), "default"))
+	assert.Equal(t, "in", fp.StrValue("toggle", NewUser().StableRollout("other").With("city", "paris"), "default"))
+
+	detail := fp.StrDetail("toggle", NewUser().StableRollout("member"), "default")
+	assert.Equal(t, []MatchedSegment{{Key: "big", Contains: true}}, detail.Segments)
+
+	queries := store.queries
+	fp.StrValue("toggle", NewUser().StableRollout("member"), "default")
+	assert.Equal(t, queries, store.queries)
+	clock.Advance(DefaultBigSegmentCacheTime)
+	fp.StrValue("toggle", NewUser().StableRollout("member"), "default")
+	assert.Equal(t, queries+1, store.queries)
+}
+
+func TestBigSegmentStoreFailure(t *testing.T) {
+	store := &memoryBigSegmentStore{err: errors.New("connection refused")}
+	fp := newBigSegmentClientForTest(store, NewManualClock(time.Unix(1000, 0)))
+	var reported []error
+	fp.bigSegments.report = func(err error) { reported = append(reported, err) }
+
+	assert.Equal(t, "out", fp.StrValue("toggle", NewUser().StableRollout("member"), "default"))
+	assert.Equal(t, "out", fp.StrValue("toggle", NewUser().StableRollout("member"), "default"))
+	assert.Len(t, reported, 1)
+	assert.Equal(t, BigSegmentStatus{}, fp.BigSegmentStatus())
+}
+
+func TestBigSegmentStatus(t *testing.T) {
+	clock := NewManualClock(time.Unix(1000, 0))
+	store := &memoryBigSegmentStore{updated: clock.Now()}
+	fp := newBigSegmentClientForTest(store, clock)
+	assert.Equal(t, BigSegmentStatus{Available: true}, fp.BigSegmentStatus())
+
+	clock.Advance(DefaultBigSegmentStaleAfter + time.Second)
+	assert.Equal(t, BigSegmentStatus{Available: true, Stale: true}, fp.BigSegmentStatus())
+
+	fp.Config.BigSegmentStaleAfter = time.Hour
+	assert.Equal(t, BigSegmentStatus{Available: true}, fp.BigSegmentStatus())
+
+	fp = NewFeatureProbeForTest(nil)
+	assert.Equal(t, BigSegmentStatus{}, fp.BigSegmentStatus())
+}
@@ -0,0 +1,44 @@
+package featureprobe
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+// WithBootstrapRepository serves the toggles payload data, as answered by
+// the toggles API, from the start, so evaluations return real values at once
+// instead of defaults until the first sync, which is not waited for and
+// replaces it in the background.
+func WithBootstrapRepository(data []byte) Option {
+	return WithBootstrapReader(bytes.NewReader(data))
+}
+
+// WithBootstrapReader is WithBootstrapRepository reading the payload from r,
+// e.g. a file embedded in the binary.
+func WithBootstrapReader(r io.Reader) Option {
+	return func(fpConfig *FPConfig) {
+		fpConfig.Bootstrap = r
+	}
+}
+
+// bootstrapRepository returns the repository given with WithRepository or
+// a bootstrap option, or nil.
+func bootstrapRepository(config FPConfig) (*Repository, error) {
+	if config.Bootstrap != nil {
+		data, err := ioutil.ReadAll(config.Bootstrap)
+		if err != nil {
+			return nil, fmt.Errorf("read bootstrap repository fails: %w", err)
+		}
+		repo, err := buildRepositoryWith(config.JSONCodec, data, nil)
+		if err != nil {
+			return nil, fmt.Errorf("bootstrap repository is invalid: %w", err)
+		}
+		return repo, nil
+	}
+	if config.Repository != nil {
+		return localRepository(config.Repository), nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,64 @@
+package featureprobe
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBootstrapRepository(t *testing.T) {
+	synced := newToggleForTest("toggle", "synced")
+	synced.Version = 2
+	server := NewMockServer(Repository{Toggles: map[string]Toggle{"toggle": synced}})
+	defer server.Close()
+	bootstrap := newToggleForTest("toggle", "bootstrap")
+	bootstrap.Version = 1
+	data, err := json.Marshal(Repository{Toggles: map[string]Toggle{"toggle": bootstrap}})
+	assert.Nil(t, err)
+
+	clock := NewManualClock(time.Unix(1000, 0))
+	fp, err := NewFeatureProbe(server.URL(), "sdk_key", WithBootstrapRepository(data), WithClock(clock))
+	assert.Nil(t, err)
+	defer fp.Close()
+
+	detail := fp.StrDetail("toggle", NewUser(), "default")
+	assert.Equal(t, "bootstrap", detail.Value)
+	assert.Equal(t, SourceBootstrap, detail.Source)
+	assert.False(t, fp.Fresh())
+
+	clock.Advance(2 * time.Second)
+	waitForValue(fp, "toggle", "synced")
+	detail = fp.StrDetail("toggle", NewUser(), "default")
+	assert.Equal(t, "synced", detail.Value)
+	assert.Equal(t, SourceNetwork, detail.Source)
+	assert.True(t, fp.Fresh())
+}
+
+func TestBootstrapReader(t *testing.T) {
+	fp, err := NewFeatureProbe("http://127.0.0.1:1", "sdk_key", WithOfflineMode(true),
+		WithBootstrapReader(strings.NewReader(`{"toggles": {"toggle": {"key": "toggle", "enabled": true,
+			"disabledServe": {"select": 0}, "defaultServe": {"select": 0}, "variations": [true]}}}`)))
+	assert.Nil(t, err)
+	defer fp.Close()
+	assert.True(t, fp.BoolValue("toggle", NewUser(), false))
+
+	fp, err = NewFeatureProbe("http://127.0.0.1:1", "sdk_key", WithOfflineMode(true),
+		WithRepository(&Repository{Toggles: map[string]Toggle{"toggle": newToggleForTest("toggle", true)}}))
+	assert.Nil(t, err)
+	defer fp.Close()
+	assert.Equal(t, SourceBootstrap, fp.BoolDetail("toggle", NewUser(), false).Source)
+}
+
+func TestInvalidBootstrapRepository(t *testing.T) {
+	_, err := NewFeatureProbe("http://127.0.0.1:1", "sdk_key", WithOfflineMode(true), WithBootstrapRepository([]byte("{")))
+	assert.Contains(t, err.Error(), "bootstrap repository is invalid")
+
+	_, err = NewFeatureProbe("http://127.0.0.1:1", "sdk_key", WithOfflineMode(true),
+		WithBootstrapRepository([]byte(`{"toggles": {"toggle": {"key": "toggle", "disabledServe": {"select": 1}, "defaultServe": {"select": 0}, "variations": [true]}}}`)))
+	var invalid *InvalidRepositoryError
+	assert.True(t, errors.As(err, &invalid))
+}
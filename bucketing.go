@@ -0,0 +1,106 @@
+package featureprobe
+
+import (
+	"fmt"
+	"strings"
+)
+
+// bucketSize is the default number of buckets of a split, so each bucket is
+// one basis point (0.01%) of the users.
+const bucketSize = 10000
+
+type BucketResult struct {
+	HashKey   string
+	Bucket    int
+	Variation int
+}
+
+// BucketFor reports which bucket and variation user falls into when this
+// split is served by toggleKey.
+func (s *Split) BucketFor(toggleKey string, user FPUser) (BucketResult, error) {
+	params := evalParams{Key: toggleKey, User: user}
+	hashKey, err := s.hashKey(params)
+	if err != nil {
+		return BucketResult{}, err
+	}
+	bucket := saltHash(hashKey, s.salt(toggleKey), s.buckets())
+	return BucketResult{
+		HashKey:   hashKey,
+		Bucket:    bucket,
+		Variation: s.getVariation(bucket),
+	}, nil
+}
+
+// UserForVariation searches for a user guaranteed to be bucketed into
+// variation by this split, trying at most maxTries generated keys.
+func (s *Split) UserForVariation(toggleKey string, variation int, maxTries int) (FPUser, error) {
+	for i := 0; i < maxTries; i++ {
+		key := fmt.Sprintf("user-%d", i)
+		user := NewUser().StableRollout(key)
+		if len(s.BucketBy) != 0 {
+			user = user.With(s.BucketBy, key)
+		}
+		for _, attribute := range s.BucketByAll {
+			user = user.With(attribute, key)
+		}
+		result, err := s.BucketFor(toggleKey, user)
+		if err != nil {
+			return FPUser{}, err
+		}
+		if result.Variation == variation {
+			return user, nil
+		}
+	}
+	return FPUser{}, fmt.Errorf("no user found for variation %d in %d tries", variation, maxTries)
+}
+
+// compositeKeySeparator joins the attribute values of a composite bucketing
+// key.
+const compositeKeySeparator = "|"
+
+// WithCompositeBucketBy makes splits bucketing by name hash the values of
+// attributes joined with "|" instead, so callers don't concatenate keys
+// themselves.
+func WithCompositeBucketBy(name string, attributes ...string) Option {
+	return func(fpConfig *FPConfig) {
+		if fpConfig.CompositeBucketBy == nil {
+			fpConfig.CompositeBucketBy = map[string][]string{}
+		}
+		fpConfig.CompositeBucketBy[name] = attributes
+	}
+}
+
+func (s *Split) compositeAttributes(params evalParams) []string {
+	if len(s.BucketByAll) != 0 {
+		return s.BucketByAll
+	}
+	if len(s.BucketBy) == 0 || params.compositeKeys == nil {
+		return nil
+	}
+	return params.compositeKeys[s.BucketBy]
+}
+
+func compositeKey(user FPUser, attributes []string) (string, error) {
+	values := make([]string, len(attributes))
+	for i, attribute := range attributes {
+		values[i] = user.Get(attribute)
+		if len(values[i]) == 0 {
+			return "", fmt.Errorf("user with id: %s does not have attribute named: [%s]", user.Key(), attribute)
+		}
+	}
+	return strings.Join(values, compositeKeySeparator), nil
+}
+
+func (s *Split) salt(toggleKey string) string {
+	if len(s.Salt) == 0 {
+		return toggleKey
+	}
+	return s.Salt
+}
+
+func (s *Split) buckets() uint32 {
+	if s.BucketSize == 0 {
+		return bucketSize
+	}
+	return s.BucketSize
+}
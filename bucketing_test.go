@@ -0,0 +1,126 @@
+package featureprobe
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newHalfSplit(bucketBy string) Split {
+	return Split{
+		Distribution: [][]Range{
+			{{Lower: 0, Upper: 5000}},
+			{{Lower: 5000, Upper: 10000}},
+		},
+		BucketBy: bucketBy,
+	}
+}
+
+func TestBucketFor(t *testing.T) {
+	split := newHalfSplit("")
+	user := NewUser().StableRollout("key")
+
+	result, err := split.BucketFor("salt", user)
+	assert.NoError(t, err)
+	assert.Equal(t, "key", result.HashKey)
+	assert.Equal(t, saltHash("key", "salt", 10000), result.Bucket)
+
+	index, err := split.findIndex(evalParams{Key: "salt", User: user})
+	assert.NoError(t, err)
+	assert.Equal(t, index, result.Variation)
+}
+
+func TestBucketForMissingAttribute(t *testing.T) {
+	split := newHalfSplit("email")
+	_, err := split.BucketFor("toggle", NewUser().StableRollout("key"))
+	assert.Error(t, err)
+}
+
+func TestUserForVariation(t *testing.T) {
+	for _, bucketBy := range []string{"", "email"} {
+		split := newHalfSplit(bucketBy)
+		for variation := 0; variation < 2; variation++ {
+			user, err := split.UserForVariation("toggle", variation, 100)
+			assert.NoError(t, err)
+			index, err := split.findIndex(evalParams{Key: "toggle", User: user})
+			assert.NoError(t, err)
+			assert.Equal(t, variation, index)
+		}
+	}
+
+	split := newHalfSplit("")
+	_, err := split.UserForVariation("toggle", 2, 100)
+	assert.Error(t, err)
+}
+
+func TestBucketSize(t *testing.T) {
+	var split Split
+	err := json.Unmarshal([]byte(`{"distribution": [[[0, 25]], [[25, 1000000]]], "bucketSize": 1000000}`), &split)
+	assert.NoError(t, err)
+
+	user := NewUser().StableRollout("key")
+	result, err := split.BucketFor("salt", user)
+	assert.NoError(t, err)
+	assert.Equal(t, saltHash("key", "salt", 1000000), result.Bucket)
+
+	canary, err := split.UserForVariation("salt", 0, 1000000)
+	assert.NoError(t, err)
+	index, err := split.findIndex(evalParams{Key: "salt", User: canary})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, index)
+
+	half := newHalfSplit("")
+	assert.Equal(t, uint32(bucketSize), half.buckets())
+}
+
+func TestCompositeBucketBy(t *testing.T) {
+	split := newHalfSplit("")
+	split.BucketByAll = []string{"org_id", "app_id"}
+	user := NewUser().StableRollout("key").With("org_id", "org").With("app_id", "app")
+
+	result, err := split.BucketFor("salt", user)
+	assert.NoError(t, err)
+	assert.Equal(t, "org|app", result.HashKey)
+	assert.Equal(t, saltHash("org|app", "salt", 10000), result.Bucket)
+
+	_, err = split.BucketFor("salt", NewUser().StableRollout("key").With("org_id", "org"))
+	assert.Error(t, err)
+
+	found, err := split.UserForVariation("salt", 1, 100)
+	assert.NoError(t, err)
+	result, err = split.BucketFor("salt", found)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, result.Variation)
+
+	var decoded Split
+	assert.NoError(t, json.Unmarshal([]byte(`{"distribution": [], "bucketByAll": ["org_id", "app_id"]}`), &decoded))
+	assert.Equal(t, []string{"org_id", "app_id"}, decoded.BucketByAll)
+}
+
+func TestWithCompositeBucketBy(t *testing.T) {
+	config := FPConfig{}
+	WithCompositeBucketBy("unit", "org_id", "app_id")(&config)
+	split := newHalfSplit("unit")
+	user := NewUser().StableRollout("key").With("org_id", "org").With("app_id", "app")
+
+	hashKey, err := split.hashKey(evalParams{User: user, compositeKeys: config.CompositeBucketBy})
+	assert.NoError(t, err)
+	assert.Equal(t, "org|app", hashKey)
+
+	fp := NewFeatureProbeForTest(nil)
+	fp.Config = config
+	toggle := newToggleForTest("toggle", "a")
+	toggle.Variations = []interface{}{"a", "b"}
+	toggle.DefaultServe = Serve{Split: &split}
+	repo := &Repository{Toggles: map[string]Toggle{"toggle": toggle}}
+	repo.compile(nil)
+	fp.Repo.Store(repo)
+
+	expected := "a"
+	if saltHash("org|app", "toggle", 10000) >= 5000 {
+		expected = "b"
+	}
+	assert.Equal(t, expected, fp.StrValue("toggle", user, "default"))
+	assert.Equal(t, "default", fp.StrValue("toggle", NewUser().StableRollout("key").With("unit", "x"), "default"))
+}
@@ -0,0 +1,162 @@
+package featureprobe
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Clock is the time source used for event timestamps, datetime rules and
+// background tickers. Inject one with WithClock to test time-dependent
+// behavior without sleeping.
+type Clock interface {
+	Now() time.Time
+	NewTicker(d time.Duration) Ticker
+}
+
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+type systemClock struct{}
+
+type systemTicker struct {
+	ticker *time.Ticker
+}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) NewTicker(d time.Duration) Ticker {
+	return systemTicker{ticker: time.NewTicker(d)}
+}
+
+func (t systemTicker) C() <-chan time.Time {
+	return t.ticker.C
+}
+
+func (t systemTicker) Stop() {
+	t.ticker.Stop()
+}
+
+func clockOrSystem(clock Clock) Clock {
+	if clock == nil {
+		return systemClock{}
+	}
+	return clock
+}
+
+const coarseClockResolution = time.Millisecond
+
+// coarseClock caches the current unix time in milliseconds, refreshed by a
+// ticker, so timestamping an access event is an atomic load instead of a
+// call to Now on every evaluation.
+type coarseClock struct {
+	millis   int64
+	stopChan chan struct{}
+	stopOnce sync.Once
+}
+
+func newCoarseClock(clock Clock, resolution time.Duration) *coarseClock {
+	c := &coarseClock{
+		millis:   unixMillis(clock.Now()),
+		stopChan: make(chan struct{}),
+	}
+	ticker := clock.NewTicker(resolution)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-c.stopChan:
+				return
+			case <-ticker.C():
+				atomic.StoreInt64(&c.millis, unixMillis(clock.Now()))
+			}
+		}
+	}()
+	return c
+}
+
+func (c *coarseClock) nowMillis() int64 {
+	return atomic.LoadInt64(&c.millis)
+}
+
+func (c *coarseClock) Stop() {
+	if c == nil {
+		return
+	}
+	c.stopOnce.Do(func() {
+		close(c.stopChan)
+	})
+}
+
+func unixMillis(t time.Time) int64 {
+	return t.UnixNano() / 1e6
+}
+
+// ManualClock is a Clock that only moves when Advance is called, firing any
+// tickers whose interval elapsed.
+type ManualClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	tickers []*manualTicker
+}
+
+type manualTicker struct {
+	clock    *ManualClock
+	interval time.Duration
+	next     time.Time
+	c        chan time.Time
+	stopped  bool
+}
+
+func NewManualClock(now time.Time) *ManualClock {
+	return &ManualClock{now: now}
+}
+
+func (m *ManualClock) Now() time.Time {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.now
+}
+
+func (m *ManualClock) NewTicker(d time.Duration) Ticker {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t := &manualTicker{
+		clock:    m,
+		interval: d,
+		next:     m.now.Add(d),
+		c:        make(chan time.Time, 1),
+	}
+	m.tickers = append(m.tickers, t)
+	return t
+}
+
+func (m *ManualClock) Advance(d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.now = m.now.Add(d)
+	for _, t := range m.tickers {
+		for !t.stopped && !t.next.After(m.now) {
+			// like time.Ticker, drop ticks for slow receivers
+			select {
+			case t.c <- t.next:
+			default:
+			}
+			t.next = t.next.Add(t.interval)
+		}
+	}
+}
+
+func (t *manualTicker) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *manualTicker) Stop() {
+	t.clock.mu.Lock()
+	t.stopped = true
+	t.clock.mu.Unlock()
+}
@@ -0,0 +1,129 @@
+package featureprobe
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestManualClockTicker(t *testing.T) {
+	clock := NewManualClock(time.Unix(1000, 0))
+	ticker := clock.NewTicker(time.Second)
+
+	clock.Advance(500 * time.Millisecond)
+	select {
+	case <-ticker.C():
+		t.Fatal("ticker fired early")
+	default:
+	}
+
+	clock.Advance(500 * time.Millisecond)
+	tick := <-ticker.C()
+	assert.Equal(t, time.Unix(1001, 0), tick)
+	assert.Equal(t, time.Unix(1001, 0), clock.Now())
+
+	ticker.Stop()
+	clock.Advance(time.Second)
+	select {
+	case <-ticker.C():
+		t.Fatal("stopped ticker fired")
+	default:
+	}
+}
+
+func TestClockDatetimeCondition(t *testing.T) {
+	clock := NewManualClock(time.Unix(1000, 0))
+	condition := Condition{
+		Type:      "datetime",
+		Subject:   "datetime",
+		Predicate: "after",
+		Objects:   []string{fmt.Sprintf("%d", 2000)},
+	}
+
+	params := evalParams{User: NewUser(), Clock: clock}
+	assert.False(t, condition.meet(params))
+	clock.Advance(1000 * time.Second)
+	assert.True(t, condition.meet(params))
+}
+
+func TestClockEventTime(t *testing.T) {
+	clock := NewManualClock(time.Unix(1000, 0))
+	recorder := NewEventRecorder("", 1000, "sdk_key")
+	fp := NewFeatureProbeForTest(map[string]interface{}{"toggle": true})
+	fp.Config.Clock = clock
+	fp.Recorder = &recorder
+
+	fp.BoolValue("toggle", NewUser(), false)
+	assert.Equal(t, int64(1000000), recorder.takeEvents()[0].Time)
+}
+
+func TestClockSynchronizerTicker(t *testing.T) {
+	repo, _ := setup(t)
+	server := NewMockServer(repo)
+	defer server.Close()
+	clock := NewManualClock(time.Now())
+
+	repo2 := NewRepositoryStore(&Repository{})
+	synchronizer := NewSynchronizer(server.URL()+"api/server-sdk/toggles", 60000, "sdk_key", repo2)
+	synchronizer.clock = clock
+	synchronizer.Start()
+	defer synchronizer.Stop()
+
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, 0, server.TogglesRequests())
+
+	clock.Advance(time.Minute)
+	for i := 0; i < 50 && server.TogglesRequests() == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(t, 1, server.TogglesRequests())
+}
+
+func TestCoarseClock(t *testing.T) {
+	clock := NewManualClock(time.Unix(1000, 0))
+	coarse := newCoarseClock(clock, time.Millisecond)
+	defer coarse.Stop()
+	assert.Equal(t, int64(1000000), coarse.nowMillis())
+
+	clock.Advance(5 * time.Millisecond)
+	deadline := time.Now().Add(time.Second)
+	for coarse.nowMillis() == 1000000 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	assert.Equal(t, int64(1000005), coarse.nowMillis())
+
+	coarse.Stop()
+	var stopped *coarseClock
+	stopped.Stop()
+}
+
+func TestCoarseClockEventTime(t *testing.T) {
+	clock := NewManualClock(time.Unix(1000, 0))
+	recorder := NewEventRecorder("", 1000, "sdk_key")
+	fp := NewFeatureProbeForTest(map[string]interface{}{"toggle": true})
+	fp.Recorder = &recorder
+	fp.coarse = newCoarseClock(clock, time.Millisecond)
+	defer fp.Close()
+
+	fp.BoolValue("toggle", NewUser(), false)
+	assert.Equal(t, int64(1000000), recorder.takeEvents()[0].Time)
+}
+
+func BenchmarkCoarseClock(b *testing.B) {
+	coarse := newCoarseClock(systemClock{}, time.Millisecond)
+	defer coarse.Stop()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		coarse.nowMillis()
+	}
+}
+
+func BenchmarkSystemClockMillis(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		unixMillis(time.Now())
+	}
+}
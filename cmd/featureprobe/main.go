@@ -0,0 +1,133 @@
+// Command featureprobe evaluates a toggle for a user outside of any service,
+// from a fetched or saved toggles payload, and prints the detail and the
+// explain trace. Support engineers use it to reproduce reported evaluations:
+//
+//	featureprobe -url https://featureprobe.io/server -sdk-key $KEY -toggle promo -user-key u1 -attr city=1
+//	featureprobe -file toggles.json -toggle promo -user '{"key": "u1", "attrs": {"city": "1"}}'
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	featureprobe "github.com/featureprobe/server-sdk-go"
+)
+
+type attrFlags map[string]string
+
+func (a attrFlags) String() string {
+	return fmt.Sprint(map[string]string(a))
+}
+
+func (a attrFlags) Set(value string) error {
+	parts := strings.SplitN(value, "=", 2)
+	if len(parts) != 2 {
+		return fmt.Errorf("attribute %q is not name=value", value)
+	}
+	a[parts[0]] = parts[1]
+	return nil
+}
+
+type userJson struct {
+	Key   string            `json:"key"`
+	Attrs map[string]string `json:"attrs"`
+}
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("featureprobe", flag.ContinueOnError)
+	remoteUrl := flags.String("url", "", "FeatureProbe server url to fetch toggles from")
+	sdkKey := flags.String("sdk-key", "", "server SDK key used with -url")
+	file := flags.String("file", "", "toggles payload to read instead of fetching")
+	toggle := flags.String("toggle", "", "toggle key to evaluate")
+	userKey := flags.String("user-key", "", "user key used for stable rollout")
+	user := flags.String("user", "", `user as JSON: {"key": "u1", "attrs": {"city": "1"}}`)
+	attrs := attrFlags{}
+	flags.Var(attrs, "attr", "user attribute as name=value, may be repeated")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *toggle == "" {
+		return errors.New("-toggle is required")
+	}
+
+	data, err := load(*remoteUrl, *sdkKey, *file)
+	if err != nil {
+		return err
+	}
+	repo, err := featureprobe.ParseRepository(data)
+	if err != nil {
+		return err
+	}
+	fpUser, err := buildUser(*user, *userKey, attrs)
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(repo.Explain(*toggle, fpUser, nil))
+}
+
+func load(remoteUrl, sdkKey, file string) ([]byte, error) {
+	if file != "" {
+		return ioutil.ReadFile(file)
+	}
+	if remoteUrl == "" {
+		return nil, errors.New("one of -url or -file is required")
+	}
+	if !strings.HasSuffix(remoteUrl, "/") {
+		remoteUrl += "/"
+	}
+	req, err := http.NewRequest(http.MethodGet, remoteUrl+"api/server-sdk/toggles", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("Authorization", sdkKey)
+	req.Header.Add("User-Agent", featureprobe.USER_AGENT)
+	client := http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch toggles fails: %s", resp.Status)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+func buildUser(user string, key string, attrs attrFlags) (featureprobe.FPUser, error) {
+	fpUser := featureprobe.NewUser()
+	if user != "" {
+		var u userJson
+		if err := json.Unmarshal([]byte(user), &u); err != nil {
+			return fpUser, err
+		}
+		fpUser = fpUser.StableRollout(u.Key)
+		for k, v := range u.Attrs {
+			fpUser = fpUser.With(k, v)
+		}
+	}
+	if key != "" {
+		fpUser = fpUser.StableRollout(key)
+	}
+	for k, v := range attrs {
+		fpUser = fpUser.With(k, v)
+	}
+	return fpUser, nil
+}
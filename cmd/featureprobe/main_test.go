@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"testing"
+
+	featureprobe "github.com/featureprobe/server-sdk-go"
+	"github.com/stretchr/testify/assert"
+)
+
+const fixture = "../../resources/fixtures/repo.json"
+
+func TestRunFromFile(t *testing.T) {
+	var out bytes.Buffer
+	err := run([]string{"-file", fixture, "-toggle", "bool_toggle", "-user-key", "key11", "-attr", "city=4"}, &out)
+	assert.Nil(t, err)
+
+	var explanation featureprobe.Explanation
+	assert.Nil(t, json.Unmarshal(out.Bytes(), &explanation))
+	assert.Equal(t, "bool_toggle", explanation.Toggle)
+	assert.NotEmpty(t, explanation.Reason)
+}
+
+func TestRunFromServer(t *testing.T) {
+	data, _ := ioutil.ReadFile(fixture)
+	repo, _ := featureprobe.ParseRepository(data)
+	server := featureprobe.NewMockServer(*repo)
+	defer server.Close()
+
+	var out bytes.Buffer
+	err := run([]string{"-url", server.URL(), "-sdk-key", "key", "-toggle", "bool_toggle",
+		"-user", `{"key": "key11", "attrs": {"city": "4"}}`}, &out)
+	assert.Nil(t, err)
+
+	var fromServer, fromFile featureprobe.Explanation
+	assert.Nil(t, json.Unmarshal(out.Bytes(), &fromServer))
+	out.Reset()
+	_ = run([]string{"-file", fixture, "-toggle", "bool_toggle", "-user-key", "key11", "-attr", "city=4"}, &out)
+	assert.Nil(t, json.Unmarshal(out.Bytes(), &fromFile))
+	assert.Equal(t, fromFile, fromServer)
+}
+
+func TestRunErrors(t *testing.T) {
+	var out bytes.Buffer
+	assert.Error(t, run([]string{"-file", fixture}, &out))
+	assert.Error(t, run([]string{"-toggle", "bool_toggle"}, &out))
+	assert.Error(t, run([]string{"-file", fixture, "-toggle", "t", "-attr", "city"}, &out))
+	assert.Error(t, run([]string{"-file", fixture, "-toggle", "t", "-user", "{"}, &out))
+}
@@ -0,0 +1,86 @@
+package featureprobe
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// JSONCodec replaces encoding/json for parsing synced repositories and
+// encoding flushed events, which dominate CPU when large repositories sync
+// often. Its functions must behave like json.Marshal and json.Unmarshal,
+// honoring the json tags and Marshaler implementations of the SDK types.
+type JSONCodec struct {
+	Marshal   func(v interface{}) ([]byte, error)
+	Unmarshal func(data []byte, v interface{}) error
+}
+
+// codecRepository is a Repository decoded entirely by a codec, since one
+// honoring json.Unmarshaler would hand toggles back to encoding/json through
+// Toggle's UnmarshalJSON.
+type codecRepository struct {
+	Toggles       map[string]codecToggle `json:"toggles"`
+	Segments      map[string]Segment     `json:"segments"`
+	SchemaVersion int                    `json:"schemaVersion,omitempty"`
+}
+
+// toggleFields is Toggle without its UnmarshalJSON.
+type toggleFields Toggle
+
+type codecToggle struct {
+	toggleFields
+	Variations []json.RawMessage `json:"variations"`
+}
+
+// decodeRepository decodes a toggles payload with c, or encoding/json when
+// nil.
+func (c *JSONCodec) decodeRepository(data []byte, repo *Repository) error {
+	if c == nil || c.Unmarshal == nil {
+		return json.Unmarshal(data, repo)
+	}
+	var decoded codecRepository
+	if err := c.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	repo.Segments, repo.SchemaVersion = decoded.Segments, decoded.SchemaVersion
+	repo.Toggles = nil
+	if decoded.Toggles != nil {
+		repo.Toggles = make(map[string]Toggle, len(decoded.Toggles))
+	}
+	for key, t := range decoded.Toggles {
+		toggle := Toggle(t.toggleFields)
+		variations, err := decodeVariations(c, t.Variations)
+		if err != nil {
+			return err
+		}
+		toggle.Variations = variations
+		repo.Toggles[key] = toggle
+	}
+	return nil
+}
+
+// WithJSONCodec parses repositories and encodes events with marshal and
+// unmarshal, e.g. jsoniter.ConfigCompatibleWithStandardLibrary's.
+func WithJSONCodec(marshal func(v interface{}) ([]byte, error), unmarshal func(data []byte, v interface{}) error) Option {
+	return func(fpConfig *FPConfig) {
+		fpConfig.JSONCodec = &JSONCodec{Marshal: marshal, Unmarshal: unmarshal}
+	}
+}
+
+func (c *JSONCodec) unmarshal(data []byte, v interface{}) error {
+	if c == nil || c.Unmarshal == nil {
+		return json.Unmarshal(data, v)
+	}
+	return c.Unmarshal(data, v)
+}
+
+func (c *JSONCodec) encode(w io.Writer, v interface{}) error {
+	if c == nil || c.Marshal == nil {
+		return json.NewEncoder(w).Encode(v)
+	}
+	data, err := c.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
@@ -0,0 +1,68 @@
+package featureprobe
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithJSONCodec(t *testing.T) {
+	server := NewMockServer(Repository{Toggles: map[string]Toggle{"toggle": newToggleForTest("toggle", "synced")}})
+	defer server.Close()
+
+	var marshaled, unmarshaled int32
+	fp, err := NewFeatureProbe(server.URL(), "sdk_key", WithRefreshInterval(50), WithJSONCodec(
+		func(v interface{}) ([]byte, error) {
+			atomic.AddInt32(&marshaled, 1)
+			return json.Marshal(v)
+		},
+		func(data []byte, v interface{}) error {
+			atomic.AddInt32(&unmarshaled, 1)
+			return json.Unmarshal(data, v)
+		},
+	))
+	assert.Nil(t, err)
+	defer fp.Close()
+
+	assert.Equal(t, "synced", fp.StrValue("toggle", NewUser(), "default"))
+	assert.True(t, atomic.LoadInt32(&unmarshaled) >= 1)
+
+	assert.Nil(t, fp.FlushAtEnd(context.Background()))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&marshaled))
+	events := server.Events()
+	assert.Len(t, events, 1)
+	assert.Equal(t, "toggle", events[0].Events[0].Key)
+}
+
+func TestJSONCodecDecodesToggles(t *testing.T) {
+	repo := Repository{Toggles: map[string]Toggle{}}
+	for i := 0; i < 50; i++ {
+		toggle := newToggleForTest(fmt.Sprintf("toggle_%d", i), "a")
+		toggle.Variations = []interface{}{"a", "b", map[string]interface{}{"c": 1}}
+		toggle.Version = 3
+		repo.Toggles[toggle.Key] = toggle
+	}
+	body, err := json.Marshal(repo)
+	assert.Nil(t, err)
+
+	var calls int
+	codec := &JSONCodec{Unmarshal: func(data []byte, v interface{}) error {
+		calls++
+		return json.Unmarshal(data, v)
+	}}
+	decoded, err := buildRepositoryWith(codec, body, nil)
+	assert.Nil(t, err)
+	// the payload, then the two scalar variations of each toggle
+	assert.Equal(t, 1+50*2, calls)
+	assert.Len(t, decoded.Toggles, 50)
+
+	toggle := decoded.Toggles["toggle_0"]
+	assert.Equal(t, "b", toggle.Variations[1])
+	assert.Equal(t, map[string]interface{}{"c": float64(1)}, variationValue(toggle.Variations[2]))
+	assert.Equal(t, 1+50*2+1, calls)
+	assert.Equal(t, uint64(3), toggle.Version)
+}
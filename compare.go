@@ -0,0 +1,92 @@
+package featureprobe
+
+import (
+	"reflect"
+	"sync"
+)
+
+// Mismatch is a Compare call whose toggles served different values.
+type Mismatch struct {
+	OldToggle string
+	NewToggle string
+	User      FPUser
+	Old       EvalDetail
+	New       EvalDetail
+}
+
+// ComparePair identifies the toggles compared by Compare.
+type ComparePair struct {
+	Old string
+	New string
+}
+
+// CompareStats counts the Compare calls of a pair and how many mismatched.
+type CompareStats struct {
+	Compared   uint64
+	Mismatched uint64
+}
+
+// WithMismatchListener receives every mismatch found by Compare.
+func WithMismatchListener(listener func(m Mismatch)) Option {
+	return func(fpConfig *FPConfig) {
+		fpConfig.MismatchListener = listener
+	}
+}
+
+type comparisons struct {
+	mu    sync.Mutex
+	stats map[ComparePair]CompareStats
+}
+
+func newComparisons() *comparisons {
+	return &comparisons{stats: map[ComparePair]CompareStats{}}
+}
+
+func (c *comparisons) count(pair ComparePair, mismatched bool) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	stats := c.stats[pair]
+	stats.Compared++
+	if mismatched {
+		stats.Mismatched++
+	}
+	c.stats[pair] = stats
+	c.mu.Unlock()
+}
+
+// Compare evaluates oldToggle and newToggle for user and returns the value of
+// oldToggle, for migrating from one toggle, or one system, to another: the
+// new toggle is evaluated in the shadow of the old one and mismatches are
+// counted and sent to the mismatch listener.
+func (fp *FeatureProbe) Compare(oldToggle, newToggle string, user FPUser, defaultValue interface{}) interface{} {
+	old := fp.genericDetail(oldToggle, user, defaultValue)
+	shadow := fp.genericDetail(newToggle, user, defaultValue)
+	mismatched := !reflect.DeepEqual(old.value, shadow.value)
+	fp.comparisons.count(ComparePair{Old: oldToggle, New: newToggle}, mismatched)
+	if mismatched && fp.Config.MismatchListener != nil {
+		fp.Config.MismatchListener(Mismatch{
+			OldToggle: oldToggle,
+			NewToggle: newToggle,
+			User:      user,
+			Old:       old.evalDetail(),
+			New:       shadow.evalDetail(),
+		})
+	}
+	return old.value
+}
+
+// CompareStats returns the counts of the Compare calls made by this client.
+func (fp *FeatureProbe) CompareStats() map[ComparePair]CompareStats {
+	stats := map[ComparePair]CompareStats{}
+	if fp.comparisons == nil {
+		return stats
+	}
+	fp.comparisons.mu.Lock()
+	defer fp.comparisons.mu.Unlock()
+	for pair, s := range fp.comparisons.stats {
+		stats[pair] = s
+	}
+	return stats
+}
@@ -0,0 +1,32 @@
+package featureprobe
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompare(t *testing.T) {
+	fp := NewFeatureProbeForTest(map[string]interface{}{"old": "a", "same": "a", "new": "b"})
+	var mismatches []Mismatch
+	fp.Config.MismatchListener = func(m Mismatch) { mismatches = append(mismatches, m) }
+	user := NewUser()
+
+	assert.Equal(t, "a", fp.Compare("old", "same", user, "d"))
+	assert.Equal(t, "a", fp.Compare("old", "new", user, "d"))
+	assert.Equal(t, "a", fp.Compare("old", "new", user, "d"))
+
+	assert.Len(t, mismatches, 2)
+	assert.Equal(t, "b", mismatches[0].New.Value)
+	assert.Equal(t, "a", mismatches[0].Old.Value)
+	assert.Equal(t, map[ComparePair]CompareStats{
+		{Old: "old", New: "same"}: {Compared: 1},
+		{Old: "old", New: "new"}:  {Compared: 2, Mismatched: 2},
+	}, fp.CompareStats())
+}
+
+func TestCompareWithoutStats(t *testing.T) {
+	fp := FeatureProbe{}
+	assert.Equal(t, "d", fp.Compare("old", "new", NewUser(), "d"))
+	assert.Empty(t, fp.CompareStats())
+}
@@ -0,0 +1,179 @@
+package featureprobe
+
+import (
+	"regexp"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/masterminds/semver"
+)
+
+// compiledCondition holds the parsed objects of a condition, so evaluation
+// only matches. Objects are parsed up to the first invalid one, which makes
+// the whole condition fail, as it does when parsing on every evaluation.
+type compiledCondition struct {
+	regexps   []*regexp.Regexp
+	numbers   []float64
+	semvers   []*semver.Version
+	datetimes []int64
+}
+
+// compile parses every condition and decodes the variations of the
+// repository. Toggles and segments whose version did not change since
+// previous reuse its compiled rules and variations.
+func (repo *Repository) compile(previous *Repository) {
+	for key, t := range repo.Toggles {
+		t.usage = &toggleUsage{}
+		if p, ok := previous.GetToggle(key); ok && p.usage != nil {
+			t.usage = p.usage
+			if p.Version != t.Version {
+				atomic.StoreUint32(&t.usage.panics, 0)
+			}
+		}
+		if p, ok := previous.GetToggle(key); ok && p.Version == t.Version && p.typed != nil {
+			t.Rules = p.Rules
+			t.typed = p.typed
+			t.refs = p.refs
+			t.repeatSegment = p.repeatSegment
+			t.matchedRules = p.matchedRules
+		} else {
+			compileRules(t.Rules)
+			t.typed = newTypedVariations(t.Variations)
+			t.refs = newEventRefs(&t)
+			t.repeatSegment = referencesSegmentTwice(t.Rules)
+			t.matchedRules = make([]*MatchedRule, len(t.Rules))
+			for i := range t.Rules {
+				t.matchedRules[i] = newMatchedRule(i, &t.Rules[i])
+			}
+		}
+		repo.Toggles[key] = t
+	}
+	for key, s := range repo.Segments {
+		if p, ok := previous.GetSegment(key); ok && p.Version == s.Version {
+			s.Rules = p.Rules
+		} else {
+			compileRules(s.Rules)
+		}
+		repo.Segments[key] = s
+	}
+}
+
+func compileRules(rules []Rule) {
+	for i := range rules {
+		for j := range rules[i].Conditions {
+			rules[i].Conditions[j].compile()
+		}
+	}
+}
+
+func referencesSegmentTwice(rules []Rule) bool {
+	seen := map[string]bool{}
+	for _, rule := range rules {
+		for _, c := range rule.Conditions {
+			if c.Type != "segment" {
+				continue
+			}
+			for _, key := range c.Objects {
+				if seen[key] {
+					return true
+				}
+				seen[key] = true
+			}
+		}
+	}
+	return false
+}
+
+func (c *Condition) compile() {
+	compiled := &compiledCondition{}
+	switch c.Type {
+	case "string":
+		if c.Predicate == "matches regex" || c.Predicate == "does not match regex" {
+			compiled.regexps = parseRegexObjects(c.Objects)
+		}
+	case "number":
+		compiled.numbers = parseNumberObjects(c.Objects)
+	case "semver":
+		compiled.semvers = parseSemverObjects(c.Objects)
+	case "datetime":
+		compiled.datetimes = parseDatetimeObjects(c.Objects)
+	}
+	c.compiled = compiled
+}
+
+func (c *Condition) regexObjects() []*regexp.Regexp {
+	if c.compiled != nil {
+		return c.compiled.regexps
+	}
+	return parseRegexObjects(c.Objects)
+}
+
+func (c *Condition) numberObjects() []float64 {
+	if c.compiled != nil {
+		return c.compiled.numbers
+	}
+	return parseNumberObjects(c.Objects)
+}
+
+func (c *Condition) semverObjects() []*semver.Version {
+	if c.compiled != nil {
+		return c.compiled.semvers
+	}
+	return parseSemverObjects(c.Objects)
+}
+
+func (c *Condition) datetimeObjects() []int64 {
+	if c.compiled != nil {
+		return c.compiled.datetimes
+	}
+	return parseDatetimeObjects(c.Objects)
+}
+
+// parseRegexObjects keeps a nil entry for an invalid regex, which never matches.
+func parseRegexObjects(objects []string) []*regexp.Regexp {
+	regexps := make([]*regexp.Regexp, 0, len(objects))
+	for _, o := range objects {
+		re, err := regexp.Compile(o)
+		if err != nil {
+			re = nil
+		}
+		regexps = append(regexps, re)
+	}
+	return regexps
+}
+
+func parseNumberObjects(objects []string) []float64 {
+	numbers := make([]float64, 0, len(objects))
+	for _, o := range objects {
+		n, err := strconv.ParseFloat(o, 32)
+		if err != nil {
+			break
+		}
+		numbers = append(numbers, n)
+	}
+	return numbers
+}
+
+func parseSemverObjects(objects []string) []*semver.Version {
+	versions := make([]*semver.Version, 0, len(objects))
+	for _, o := range objects {
+		v, err := semver.NewVersion(o)
+		if err != nil {
+			break
+		}
+		versions = append(versions, v)
+	}
+	return versions
+}
+
+func parseDatetimeObjects(objects []string) []int64 {
+	datetimes := make([]int64, 0, len(objects))
+	for _, o := range objects {
+		d, err := strconv.ParseInt(o, 10, 64)
+		if err != nil {
+			break
+		}
+		datetimes = append(datetimes, d)
+	}
+	return datetimes
+}
@@ -0,0 +1,58 @@
+package featureprobe
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompiledConditionMatchesParsed(t *testing.T) {
+	conditions := []Condition{
+		{Type: "string", Subject: "name", Predicate: "matches regex", Objects: []string{"(", "^ab+c$"}},
+		{Type: "string", Subject: "name", Predicate: "does not match regex", Objects: []string{"^x"}},
+		{Type: "number", Subject: "age", Predicate: ">", Objects: []string{"10", "a", "1"}},
+		{Type: "semver", Subject: "version", Predicate: ">=", Objects: []string{"1.2.0", "x"}},
+		{Type: "datetime", Subject: "time", Predicate: "after", Objects: []string{"100", "a"}},
+	}
+	users := []FPUser{
+		NewUser().With("name", "abbc").With("age", "11").With("version", "1.2.1").With("time", "200"),
+		NewUser().With("name", "xyz").With("age", "5").With("version", "1.0.0").With("time", "50"),
+	}
+
+	for _, c := range conditions {
+		compiled := c
+		compiled.compile()
+		assert.NotNil(t, compiled.compiled)
+		for _, user := range users {
+			params := evalParams{User: user}
+			assert.Equal(t, c.meet(params), compiled.meet(params), c.Type+" "+c.Predicate)
+		}
+	}
+}
+
+func TestCompiledInvalidObjectStopsMatching(t *testing.T) {
+	c := Condition{Type: "number", Subject: "age", Predicate: "=", Objects: []string{"a", "11"}}
+	c.compile()
+	assert.Equal(t, 0, len(c.compiled.numbers))
+	assert.False(t, c.meet(evalParams{User: NewUser().With("age", "11")}))
+}
+
+func TestCompileReusesUnchangedVersion(t *testing.T) {
+	_, jsonStr := setup(t)
+	previous, err := buildRepository([]byte(jsonStr), nil)
+	assert.NoError(t, err)
+
+	next, err := buildRepository([]byte(jsonStr), previous)
+	assert.NoError(t, err)
+	assert.True(t, previous.Toggles["bool_toggle"].Rules[0].Conditions[0].compiled ==
+		next.Toggles["bool_toggle"].Rules[0].Conditions[0].compiled)
+
+	changed := next.Snapshot()
+	toggle := changed.Toggles["bool_toggle"]
+	toggle.Version += 1
+	toggle.Rules = []Rule{{Conditions: []Condition{{Type: "string", Predicate: "is one of"}}}}
+	changed.Toggles["bool_toggle"] = toggle
+	changed.compile(previous)
+	assert.NotNil(t, changed.Toggles["bool_toggle"].Rules[0].Conditions[0].compiled)
+	assert.Equal(t, 1, len(changed.Toggles["bool_toggle"].Rules))
+}
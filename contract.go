@@ -0,0 +1,118 @@
+package featureprobe
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+type ContractDiff struct {
+	Scenario string
+	Case     string
+	Field    string
+	Expected interface{}
+	Actual   interface{}
+}
+
+func (d ContractDiff) String() string {
+	return fmt.Sprintf("[%s] %s: %s expected %v, got %v", d.Scenario, d.Case, d.Field, d.Expected, d.Actual)
+}
+
+func ParseContractTests(data []byte) (ContractTests, error) {
+	var tests ContractTests
+	err := json.Unmarshal(data, &tests)
+	return tests, err
+}
+
+// RunContractTests runs every case of the spec against a client built from
+// the scenario fixture, and returns the differences from the expected results.
+// An empty result means the client is spec compliant.
+func RunContractTests(tests ContractTests, newClient func(fixture Repository) FPClient) []ContractDiff {
+	diffs := []ContractDiff{}
+	for _, scenario := range tests.Tests {
+		client := newClient(scenario.Fixture)
+		for _, c := range scenario.Cases {
+			diffs = append(diffs, runContractCase(client, scenario.Scenario, c)...)
+		}
+	}
+	return diffs
+}
+
+type contractResult struct {
+	value     interface{}
+	ruleIndex *int
+	version   *uint64
+	reason    string
+	detail    bool
+}
+
+func runContractCase(client FPClient, scenario string, c Case) []ContractDiff {
+	diff := func(field string, expected, actual interface{}) ContractDiff {
+		return ContractDiff{Scenario: scenario, Case: c.Name, Field: field, Expected: expected, Actual: actual}
+	}
+
+	user := NewUser().StableRollout(c.User.Key)
+	for _, kv := range c.User.CustomValues {
+		user = user.With(kv.Key, kv.Value)
+	}
+
+	result, ok := callContractFunction(client, c.Function, user)
+	if !ok {
+		return []ContractDiff{diff("function", c.Function.Name, fmt.Sprintf("%T default", c.Function.Default))}
+	}
+
+	diffs := []ContractDiff{}
+	expect := c.ExpectResult
+	if !reflect.DeepEqual(expect.Value, result.value) {
+		diffs = append(diffs, diff("value", expect.Value, result.value))
+	}
+	if !result.detail {
+		return diffs
+	}
+	if expect.Reason != nil && !strings.Contains(result.reason, *expect.Reason) {
+		diffs = append(diffs, diff("reason", *expect.Reason, result.reason))
+	}
+	if expect.RuleIndex != nil && (result.ruleIndex == nil || *expect.RuleIndex != *result.ruleIndex) {
+		diffs = append(diffs, diff("ruleIndex", *expect.RuleIndex, result.ruleIndex))
+	}
+	if expect.NoRuleIndex != nil && *expect.NoRuleIndex != (result.ruleIndex == nil) {
+		diffs = append(diffs, diff("noRuleIndex", *expect.NoRuleIndex, result.ruleIndex == nil))
+	}
+	if expect.Version != nil && (result.version == nil || *expect.Version != *result.version) {
+		diffs = append(diffs, diff("version", *expect.Version, result.version))
+	}
+	return diffs
+}
+
+func callContractFunction(client FPClient, f CaseFunction, user FPUser) (contractResult, bool) {
+	switch f.Name {
+	case "bool_value":
+		d, ok := f.Default.(bool)
+		return contractResult{value: client.BoolValue(f.Toggle, user, d)}, ok
+	case "string_value":
+		d, ok := f.Default.(string)
+		return contractResult{value: client.StrValue(f.Toggle, user, d)}, ok
+	case "number_value":
+		d, ok := f.Default.(float64)
+		return contractResult{value: client.NumberValue(f.Toggle, user, d)}, ok
+	case "json_value":
+		return contractResult{value: client.JsonValue(f.Toggle, user, f.Default)}, true
+	case "bool_detail":
+		d, ok := f.Default.(bool)
+		r := client.BoolDetail(f.Toggle, user, d)
+		return contractResult{r.Value, r.RuleIndex, r.Version, r.Reason, true}, ok
+	case "string_detail":
+		d, ok := f.Default.(string)
+		r := client.StrDetail(f.Toggle, user, d)
+		return contractResult{r.Value, r.RuleIndex, r.Version, r.Reason, true}, ok
+	case "number_detail":
+		d, ok := f.Default.(float64)
+		r := client.NumberDetail(f.Toggle, user, d)
+		return contractResult{r.Value, r.RuleIndex, r.Version, r.Reason, true}, ok
+	case "json_detail":
+		r := client.JsonDetail(f.Toggle, user, f.Default)
+		return contractResult{r.Value, r.RuleIndex, r.Version, r.Reason, true}, true
+	}
+	return contractResult{}, false
+}
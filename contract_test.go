@@ -0,0 +1,74 @@
+package featureprobe
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func recordedContractTests(t *testing.T) ContractTests {
+	repo, _ := setup(t)
+	recorder := NewScenarioRecorder("recorded")
+	fp := FeatureProbe{Repo: NewRepositoryStore(&repo), Config: FPConfig{Scenarios: recorder}}
+
+	for _, city := range []string{"1", "4", "100"} {
+		user := NewUser().StableRollout("key11").With("city", city)
+		fp.BoolValue("bool_toggle", user, true)
+		fp.BoolDetail("bool_toggle", user, true)
+		fp.StrDetail("string_toggle", user, "x")
+		fp.NumberDetail("number_toggle", user, 0)
+		fp.JsonDetail("json_toggle", user, nil)
+		fp.StrValue("not_exist_toggle", user, "x")
+	}
+	return ContractTests{Tests: []Scenario{recorder.Scenario()}}
+}
+
+func TestRunContractTests(t *testing.T) {
+	tests := recordedContractTests(t)
+	diffs := RunContractTests(tests, func(fixture Repository) FPClient {
+		return &FeatureProbe{Repo: NewRepositoryStore(&fixture)}
+	})
+	assert.Equal(t, 0, len(diffs))
+}
+
+func TestRunContractTestsReportsDiffs(t *testing.T) {
+	tests := recordedContractTests(t)
+	diffs := RunContractTests(tests, func(fixture Repository) FPClient {
+		fp := NewFeatureProbeForTest(map[string]interface{}{"bool_toggle": false})
+		return &fp
+	})
+	assert.True(t, len(diffs) > 0)
+
+	found := false
+	for _, d := range diffs {
+		if d.Field == "version" {
+			found = true
+			assert.Contains(t, d.String(), "version expected")
+		}
+	}
+	assert.True(t, found)
+}
+
+func TestRunContractTestsUnknownFunction(t *testing.T) {
+	tests := ContractTests{Tests: []Scenario{{
+		Scenario: "unknown",
+		Cases: []Case{
+			{Name: "unknown function", Function: CaseFunction{Name: "int_value", Toggle: "t"}},
+			{Name: "wrong default", Function: CaseFunction{Name: "bool_value", Toggle: "t", Default: "x"}},
+		},
+	}}}
+	diffs := RunContractTests(tests, func(fixture Repository) FPClient {
+		return &FeatureProbe{Repo: NewRepositoryStore(&fixture)}
+	})
+	assert.Equal(t, 2, len(diffs))
+	assert.Equal(t, "function", diffs[0].Field)
+}
+
+func TestParseContractTests(t *testing.T) {
+	_, err := ParseContractTests([]byte("{"))
+	assert.Error(t, err)
+
+	tests, err := ParseContractTests([]byte(`{"tests": [{"scenario": "s", "cases": [], "fixture": {"toggles": {}, "segments": {}}}]}`))
+	assert.NoError(t, err)
+	assert.Equal(t, "s", tests.Tests[0].Scenario)
+}
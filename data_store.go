@@ -0,0 +1,235 @@
+package featureprobe
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// DataStore holds the toggles and segments of a repository outside the
+// process, so instances sharing it serve the same toggles and a restarted
+// instance serves the last ones stored while FeatureProbe is unreachable.
+// Evaluations never query it: they read the repository in memory, which the
+// store only replaces at start and while syncs fail.
+type DataStore interface {
+	GetToggle(key string) (Toggle, bool, error)
+	SetToggle(toggle Toggle) error
+	AllToggles() (map[string]Toggle, error)
+	GetSegment(key string) (Segment, bool, error)
+	SetSegment(segment Segment) error
+	AllSegments() (map[string]Segment, error)
+	// Init replaces everything stored with the toggles and segments of repo.
+	Init(repo *Repository) error
+}
+
+// NamespacedDataStore is a DataStore holding several repositories apart, one
+// per namespace.
+type NamespacedDataStore interface {
+	DataStore
+	Namespace(namespace string) DataStore
+}
+
+// WithDataStore writes every synced repository to store, and reads the
+// repository from it at start and whenever FeatureProbe is unreachable, so
+// the toggles another instance synced are served. Projects and environments
+// use the namespace of their server sdk key in a NamespacedDataStore, and no
+// store otherwise.
+func WithDataStore(store DataStore) Option {
+	return func(fpConfig *FPConfig) {
+		fpConfig.DataStore = store
+	}
+}
+
+// InMemoryDataStore is a DataStore local to the process, for tests.
+type InMemoryDataStore struct {
+	mu         sync.RWMutex
+	toggles    map[string]Toggle
+	segments   map[string]Segment
+	namespaces map[string]*InMemoryDataStore
+}
+
+func NewInMemoryDataStore() *InMemoryDataStore {
+	return &InMemoryDataStore{toggles: map[string]Toggle{}, segments: map[string]Segment{}}
+}
+
+func (s *InMemoryDataStore) GetToggle(key string) (Toggle, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	t, ok := s.toggles[key]
+	return t, ok, nil
+}
+
+func (s *InMemoryDataStore) SetToggle(toggle Toggle) error {
+	s.mu.Lock()
+	s.toggles[toggle.Key] = toggle
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *InMemoryDataStore) AllToggles() (map[string]Toggle, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	toggles := make(map[string]Toggle, len(s.toggles))
+	for k, t := range s.toggles {
+		toggles[k] = t
+	}
+	return toggles, nil
+}
+
+func (s *InMemoryDataStore) GetSegment(key string) (Segment, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	segment, ok := s.segments[key]
+	return segment, ok, nil
+}
+
+func (s *InMemoryDataStore) SetSegment(segment Segment) error {
+	s.mu.Lock()
+	s.segments[segment.Key] = segment
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *InMemoryDataStore) AllSegments() (map[string]Segment, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	segments := make(map[string]Segment, len(s.segments))
+	for k, segment := range s.segments {
+		segments[k] = segment
+	}
+	return segments, nil
+}
+
+func (s *InMemoryDataStore) Namespace(namespace string) DataStore {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.namespaces == nil {
+		s.namespaces = map[string]*InMemoryDataStore{}
+	}
+	ns, ok := s.namespaces[namespace]
+	if !ok {
+		ns = NewInMemoryDataStore()
+		s.namespaces[namespace] = ns
+	}
+	return ns
+}
+
+func (s *InMemoryDataStore) Init(repo *Repository) error {
+	snapshot := repo.detached()
+	s.mu.Lock()
+	s.toggles, s.segments = snapshot.Toggles, snapshot.Segments
+	s.mu.Unlock()
+	return nil
+}
+
+// dataStoreSource moves repositories between the synchronizer and a
+// DataStore, never writing back one it read.
+type dataStoreSource struct {
+	store  DataStore
+	report func(error)
+	mu     sync.Mutex
+	// digest is the hash of the repository last written or read.
+	digest [sha1.Size]byte
+	read   *Repository
+}
+
+func newDataStoreSource(config FPConfig) *dataStoreSource {
+	store := config.DataStore
+	if store == nil {
+		return nil
+	}
+	if config.subClient {
+		namespaced, ok := store.(NamespacedDataStore)
+		if !ok {
+			return nil
+		}
+		store = namespaced.Namespace(dataStoreNamespace(config.ServerSdkKey))
+	}
+	return &dataStoreSource{store: store, report: config.reportError}
+}
+
+// dataStoreNamespace is the namespace of a sub client's repository, derived
+// from its server sdk key so the key itself is not stored.
+func dataStoreNamespace(serverSdkKey string) string {
+	digest := sha1.Sum([]byte(serverSdkKey))
+	return hex.EncodeToString(digest[:8])
+}
+
+func repositoryDigest(repo *Repository) ([sha1.Size]byte, error) {
+	data, err := json.Marshal(repo)
+	if err != nil {
+		return [sha1.Size]byte{}, err
+	}
+	return sha1.Sum(data), nil
+}
+
+// write is an onUpdate hook storing the synced repositories.
+func (d *dataStoreSource) write(_, repo *Repository) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if repo == d.read {
+		return
+	}
+	digest, err := repositoryDigest(repo)
+	if err == nil {
+		err = d.store.Init(repo)
+	}
+	if err != nil {
+		d.report(fmt.Errorf("write data store fails: %w", err))
+		return
+	}
+	d.digest = digest
+}
+
+// load reads the stored repository, compiled against previous. It is nil
+// when the store is empty or holds the repository last written or read.
+func (d *dataStoreSource) load(previous *Repository) (*Repository, error) {
+	toggles, err := d.store.AllToggles()
+	if err != nil {
+		return nil, fmt.Errorf("read data store fails: %w", err)
+	}
+	segments, err := d.store.AllSegments()
+	if err != nil {
+		return nil, fmt.Errorf("read data store fails: %w", err)
+	}
+	if len(toggles) == 0 && len(segments) == 0 {
+		return nil, nil
+	}
+	repo := &Repository{Toggles: toggles, Segments: segments}
+	digest, err := repositoryDigest(repo)
+	if err != nil {
+		return nil, fmt.Errorf("read data store fails: %w", err)
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if digest == d.digest {
+		return nil, nil
+	}
+	if err := validateRepository(repo); err != nil {
+		return nil, fmt.Errorf("read data store fails: %w", err)
+	}
+	repo.compile(previous)
+	d.digest, d.read = digest, repo
+	return repo, nil
+}
+
+// loadDataStore stores the repository held by the data store, reporting
+// whether there was one.
+func (fp *FeatureProbe) loadDataStore() bool {
+	if fp.Syncer == nil || fp.Syncer.store == nil {
+		return false
+	}
+	repo, err := fp.Syncer.store.load(fp.Repo.Load())
+	if err != nil {
+		fp.Config.reportError(err)
+		return false
+	}
+	if repo == nil {
+		return false
+	}
+	fp.Syncer.filter.apply(repo)
+	fp.Repo.Store(repo)
+	return true
+}
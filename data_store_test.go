@@ -0,0 +1,168 @@
+package featureprobe
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDataStoreWrittenOnSync(t *testing.T) {
+	store := NewInMemoryDataStore()
+	server := NewMockServer(Repository{Toggles: map[string]Toggle{"toggle": newToggleForTest("toggle", "network")}})
+	defer server.Close()
+	fp, err := NewFeatureProbe(server.URL(), "sdk_key", WithClock(NewManualClock(time.Unix(0, 0))),
+		WithWaitFirstResp(false), WithDataStore(store))
+	assert.Nil(t, err)
+	defer fp.Close()
+
+	fp.Syncer.fetchRemoteRepo()
+	toggle, ok, err := store.GetToggle("toggle")
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "toggle", toggle.Key)
+	_, ok, _ = store.GetToggle("missing")
+	assert.False(t, ok)
+}
+
+func TestDataStoreServedAtStart(t *testing.T) {
+	store := NewInMemoryDataStore()
+	assert.Nil(t, store.SetToggle(newToggleForTest("toggle", "stored")))
+	server := NewMockServer(Repository{})
+	server.Close()
+
+	// the remote is gone and the manual clock never ticks, so this would
+	// block without a stored repository
+	fp, err := NewFeatureProbe(server.URL(), "sdk_key", WithClock(NewManualClock(time.Unix(0, 0))), WithDataStore(store))
+	assert.Nil(t, err)
+	defer fp.Close()
+	assert.Equal(t, "stored", fp.StrValue("toggle", NewUser(), "d"))
+}
+
+func TestDataStoreReadWhenSyncFails(t *testing.T) {
+	store := NewInMemoryDataStore()
+	server := NewMockServer(Repository{})
+	server.Close()
+	var changes int
+	fp, err := NewFeatureProbe(server.URL(), "sdk_key", WithClock(NewManualClock(time.Unix(0, 0))),
+		WithWaitFirstResp(false), WithDataStore(store),
+		WithChangeListener(func(RepositoryDiff) { changes++ }))
+	assert.Nil(t, err)
+	defer fp.Close()
+
+	// another instance syncs the toggles to the shared store
+	synced := newToggleForTest("toggle", "shared")
+	synced.Version = 1
+	assert.Nil(t, store.Init(&Repository{Toggles: map[string]Toggle{"toggle": synced}}))
+	fp.Syncer.fetchRemoteRepo()
+	assert.Equal(t, "shared", fp.StrValue("toggle", NewUser(), "d"))
+	assert.Equal(t, 1, changes)
+
+	repo := fp.Repo.Load()
+	fp.Syncer.fetchRemoteRepo()
+	assert.True(t, repo == fp.Repo.Load())
+	toggle, _, _ := store.GetToggle("toggle")
+	assert.Equal(t, uint64(1), toggle.Version)
+}
+
+type failingDataStore struct {
+	*InMemoryDataStore
+}
+
+func (failingDataStore) Init(*Repository) error {
+	return errors.New("store down")
+}
+
+func (failingDataStore) AllToggles() (map[string]Toggle, error) {
+	return nil, errors.New("store down")
+}
+
+func TestDataStoreErrors(t *testing.T) {
+	server := NewMockServer(Repository{Toggles: map[string]Toggle{"toggle": newToggleForTest("toggle", "network")}})
+	defer server.Close()
+	var errs []error
+	fp, err := NewFeatureProbe(server.URL(), "sdk_key", WithClock(NewManualClock(time.Unix(0, 0))),
+		WithWaitFirstResp(false), WithDataStore(failingDataStore{NewInMemoryDataStore()}),
+		WithErrorListener(func(err error) { errs = append(errs, err) }))
+	assert.Nil(t, err)
+	defer fp.Close()
+
+	fp.Syncer.fetchRemoteRepo()
+	assert.Equal(t, "network", fp.StrValue("toggle", NewUser(), "d"))
+	assert.Len(t, errs, 2)
+	assert.EqualError(t, errs[0], "read data store fails: store down")
+	assert.EqualError(t, errs[1], "write data store fails: store down")
+}
+
+type countingDataStore struct {
+	*InMemoryDataStore
+	reads int
+}
+
+func (s *countingDataStore) AllToggles() (map[string]Toggle, error) {
+	s.reads++
+	return s.InMemoryDataStore.AllToggles()
+}
+
+func TestDataStoreNotReadForRejectedPayload(t *testing.T) {
+	server := NewMockServer(Repository{Toggles: map[string]Toggle{"toggle": newToggleForTest("toggle", "network")}})
+	defer server.Close()
+	store := &countingDataStore{InMemoryDataStore: NewInMemoryDataStore()}
+	fp, err := NewFeatureProbe(server.URL(), "sdk_key", WithClock(NewManualClock(time.Unix(0, 0))),
+		WithWaitFirstResp(false), WithDataStore(store))
+	assert.Nil(t, err)
+	defer fp.Close()
+	fp.Syncer.fetchRemoteRepo()
+	reads := store.reads
+
+	// rolled back from
+	fp.Syncer.rejectCurrent()
+	fp.Syncer.fetchRemoteRepo()
+	assert.Equal(t, reads, store.reads)
+
+	invalid := newToggleForTest("toggle", "invalid")
+	missing := 5
+	invalid.DefaultServe = Serve{Select: &missing}
+	server.SetRepository(Repository{Toggles: map[string]Toggle{"toggle": invalid}})
+	fp.Syncer.fetchRemoteRepo()
+	fp.Syncer.fetchRemoteRepo()
+	assert.Equal(t, reads, store.reads)
+	assert.Equal(t, "network", fp.StrValue("toggle", NewUser(), "d"))
+}
+
+func TestDataStoreNamespacesProjects(t *testing.T) {
+	repos := map[string]Repository{
+		"main_key":    {Toggles: map[string]Toggle{"toggle": newToggleForTest("toggle", "main")}},
+		"billing_key": {Toggles: map[string]Toggle{"billing": newToggleForTest("billing", "billing")}},
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(repos[r.Header.Get("Authorization")])
+	}))
+	defer server.Close()
+	store := NewInMemoryDataStore()
+	fp, err := NewFeatureProbe(server.URL, "main_key", WithClock(NewManualClock(time.Unix(0, 0))),
+		WithWaitFirstResp(false), WithDataStore(store), WithProject("billing", "billing_key"))
+	assert.Nil(t, err)
+	defer fp.Close()
+
+	fp.Syncer.fetchRemoteRepo()
+	fp.Project("billing").Syncer.fetchRemoteRepo()
+	toggles, _ := store.AllToggles()
+	assert.Len(t, toggles, 1)
+	assert.Contains(t, toggles, "toggle")
+	toggles, _ = store.Namespace(dataStoreNamespace("billing_key")).AllToggles()
+	assert.Len(t, toggles, 1)
+	assert.Contains(t, toggles, "billing")
+
+	// stores without namespaces are not shared with projects
+	fp, err = NewFeatureProbe(server.URL, "main_key", WithClock(NewManualClock(time.Unix(0, 0))),
+		WithWaitFirstResp(false), WithDataStore(struct{ DataStore }{NewInMemoryDataStore()}), WithProject("billing", "billing_key"))
+	assert.Nil(t, err)
+	defer fp.Close()
+	assert.NotNil(t, fp.Syncer.store)
+	assert.Nil(t, fp.Project("billing").Syncer.store)
+}
@@ -0,0 +1,123 @@
+package featureprobe
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DegradationReason is why an evaluation fell back to the call-site default.
+type DegradationReason string
+
+const (
+	DegradedNotFound     DegradationReason = "not found"
+	DegradedTypeMismatch DegradationReason = "type mismatch"
+	DegradedStale        DegradationReason = "stale"
+	DegradedError        DegradationReason = "error"
+)
+
+// DegradationReport counts the evaluations answered with the call-site
+// default since the client started, by reason and by toggle, to quantify how
+// much traffic ran blind during an incident. Kill switch and override values
+// are deliberate and not counted.
+type DegradationReport struct {
+	Since     time.Time
+	Defaulted uint64
+	ByReason  map[DegradationReason]uint64
+	ByToggle  map[string]map[DegradationReason]uint64
+}
+
+type degradation struct {
+	since    time.Time
+	mu       sync.Mutex
+	byToggle map[string]map[DegradationReason]uint64
+}
+
+func newDegradation(now time.Time) *degradation {
+	return &degradation{since: now, byToggle: map[string]map[DegradationReason]uint64{}}
+}
+
+func (d *degradation) count(toggle string, reason DegradationReason) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	counts, ok := d.byToggle[toggle]
+	if !ok {
+		counts = map[DegradationReason]uint64{}
+		d.byToggle[toggle] = counts
+	}
+	counts[reason]++
+	d.mu.Unlock()
+}
+
+// countResult counts result when it is a default served for a failure.
+func (d *degradation) countResult(toggle string, result evalResult) {
+	if d == nil {
+		return
+	}
+	switch {
+	case result.reason == staleReason:
+		d.count(toggle, DegradedStale)
+	case errors.Is(result.err, ErrToggleNotFound):
+		d.count(toggle, DegradedNotFound)
+	case result.err != nil || result.reason == ValidationReason:
+		d.count(toggle, DegradedError)
+	}
+}
+
+// DegradationReport returns the defaulted evaluations counted so far.
+func (fp *FeatureProbe) DegradationReport() DegradationReport {
+	report := DegradationReport{
+		ByReason: map[DegradationReason]uint64{},
+		ByToggle: map[string]map[DegradationReason]uint64{},
+	}
+	d := fp.degradation
+	if d == nil {
+		return report
+	}
+	report.Since = d.since
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for toggle, counts := range d.byToggle {
+		copied := make(map[DegradationReason]uint64, len(counts))
+		for reason, n := range counts {
+			copied[reason] = n
+			report.ByReason[reason] += n
+			report.Defaulted += n
+		}
+		report.ByToggle[toggle] = copied
+	}
+	return report
+}
+
+// WriteMetrics writes the report in the Prometheus text format, as the
+// featureprobe_defaulted_evaluations_total counter labeled by toggle and
+// reason.
+func (r DegradationReport) WriteMetrics(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("# HELP featureprobe_defaulted_evaluations_total Evaluations answered with the call-site default.\n")
+	b.WriteString("# TYPE featureprobe_defaulted_evaluations_total counter\n")
+	toggles := make([]string, 0, len(r.ByToggle))
+	for toggle := range r.ByToggle {
+		toggles = append(toggles, toggle)
+	}
+	sort.Strings(toggles)
+	for _, toggle := range toggles {
+		reasons := make([]string, 0, len(r.ByToggle[toggle]))
+		for reason := range r.ByToggle[toggle] {
+			reasons = append(reasons, string(reason))
+		}
+		sort.Strings(reasons)
+		for _, reason := range reasons {
+			fmt.Fprintf(&b, "featureprobe_defaulted_evaluations_total{toggle=%q,reason=%q} %d\n",
+				toggle, reason, r.ByToggle[toggle][DegradationReason(reason)])
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
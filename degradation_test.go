@@ -0,0 +1,64 @@
+package featureprobe
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDegradationReport(t *testing.T) {
+	fp := NewFeatureProbeForTest(map[string]interface{}{"toggle": "value"})
+	assert.Equal(t, uint64(0), fp.DegradationReport().Defaulted)
+
+	fp.StrValue("toggle", NewUser(), "default")
+	fp.BoolValue("toggle", NewUser(), false)
+	fp.BoolDetail("toggle", NewUser(), false)
+	fp.StrValue("missing", NewUser(), "default")
+	fp.EvalOnly("missing", NewUser(), "default")
+	fp.Override("overridden", "value")
+	fp.StrValue("overridden", NewUser(), "default")
+
+	report := fp.DegradationReport()
+	assert.Equal(t, uint64(4), report.Defaulted)
+	assert.Equal(t, map[DegradationReason]uint64{DegradedTypeMismatch: 2, DegradedNotFound: 2}, report.ByReason)
+	assert.Equal(t, map[string]map[DegradationReason]uint64{
+		"toggle":  {DegradedTypeMismatch: 2},
+		"missing": {DegradedNotFound: 2},
+	}, report.ByToggle)
+	assert.False(t, report.Since.IsZero())
+
+	report.ByToggle["toggle"][DegradedTypeMismatch] = 100
+	assert.Equal(t, uint64(2), fp.DegradationReport().ByToggle["toggle"][DegradedTypeMismatch])
+}
+
+func TestDegradationStaleAndError(t *testing.T) {
+	clock := NewManualClock(time.Unix(1000, 0))
+	fp := NewFeatureProbeForTest(map[string]interface{}{"toggle": "value"})
+	fp.Config.Clock = clock
+	fp.Config.MaxDataAge = time.Minute
+	fp.Syncer = &Synchronizer{lastSynced: unixMillis(clock.Now())}
+	clock.Advance(2 * time.Minute)
+	fp.StrValue("toggle", NewUser(), "default")
+
+	fp.Close()
+	fp.StrValue("toggle", NewUser(), "default")
+
+	assert.Equal(t, map[DegradationReason]uint64{DegradedStale: 1, DegradedError: 1}, fp.DegradationReport().ByReason)
+}
+
+func TestDegradationMetrics(t *testing.T) {
+	report := DegradationReport{ByToggle: map[string]map[DegradationReason]uint64{
+		"b": {DegradedStale: 1, DegradedError: 2},
+		"a": {DegradedNotFound: 3},
+	}}
+	var b strings.Builder
+	assert.Nil(t, report.WriteMetrics(&b))
+	assert.Equal(t, `# HELP featureprobe_defaulted_evaluations_total Evaluations answered with the call-site default.
+# TYPE featureprobe_defaulted_evaluations_total counter
+featureprobe_defaulted_evaluations_total{toggle="a",reason="not found"} 3
+featureprobe_defaulted_evaluations_total{toggle="b",reason="error"} 2
+featureprobe_defaulted_evaluations_total{toggle="b",reason="stale"} 1
+`, b.String())
+}
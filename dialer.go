@@ -0,0 +1,48 @@
+package featureprobe
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// Dialer opens the connections of the requests to FeatureProbe, like
+// net.Dialer.DialContext.
+type Dialer func(ctx context.Context, network, addr string) (net.Conn, error)
+
+const unixScheme = "unix://"
+
+// WithDialer connects to FeatureProbe with dial instead of TCP, for relays
+// reachable through a custom transport. Remote urls like
+// unix:///var/run/featureprobe.sock already dial the socket.
+func WithDialer(dial Dialer) Option {
+	return func(fpConfig *FPConfig) {
+		fpConfig.Dialer = dial
+	}
+}
+
+// unixSocket turns a unix:// remote url into a plain http one, returning a
+// dialer to its socket. Other urls are returned as is with a nil dialer.
+func unixSocket(remoteUrl string) (string, Dialer) {
+	if !strings.HasPrefix(remoteUrl, unixScheme) {
+		return remoteUrl, nil
+	}
+	path := strings.TrimSuffix(strings.TrimPrefix(remoteUrl, unixScheme), "/")
+	var d net.Dialer
+	return "http://unix/", func(ctx context.Context, _, _ string) (net.Conn, error) {
+		return d.DialContext(ctx, "unix", path)
+	}
+}
+
+// useDialer makes client dial with dial, without proxy, as the address it
+// dials is not the one of the request.
+func useDialer(client *http.Client, dial Dialer) {
+	if dial == nil {
+		return
+	}
+	if transport, ok := client.Transport.(*http.Transport); ok {
+		transport.Proxy = nil
+		transport.DialContext = dial
+	}
+}
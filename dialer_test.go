@@ -0,0 +1,61 @@
+package featureprobe
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnixSocketRemoteUrl(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fp")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "fp.sock")
+
+	mock := NewMockServer(Repository{Toggles: map[string]Toggle{"toggle": newToggleForTest("toggle", true)}})
+	defer mock.Close()
+	listener, err := net.Listen("unix", socket)
+	assert.Nil(t, err)
+	server := httptest.NewUnstartedServer(mock.server.Config.Handler)
+	server.Listener = listener
+	server.Start()
+	defer server.Close()
+
+	fp, err := NewFeatureProbe("unix://"+socket, "sdk_key")
+	assert.Nil(t, err)
+	defer fp.Close()
+	assert.True(t, fp.BoolValue("toggle", NewUser(), false))
+	assert.Equal(t, 1, mock.TogglesRequests())
+}
+
+func TestWithDialer(t *testing.T) {
+	server := NewMockServer(Repository{Toggles: map[string]Toggle{"toggle": newToggleForTest("toggle", true)}})
+	defer server.Close()
+
+	dialed := 0
+	var d net.Dialer
+	dial := func(ctx context.Context, network, _ string) (net.Conn, error) {
+		dialed++
+		return d.DialContext(ctx, network, server.server.Listener.Addr().String())
+	}
+	fp, err := NewFeatureProbe("http://relay.invalid", "sdk_key", WithServerless(true), WithDialer(dial))
+	assert.Nil(t, err)
+	assert.True(t, fp.BoolValue("toggle", NewUser(), false))
+	assert.Equal(t, 1, dialed)
+}
+
+func TestUnixSocketUrl(t *testing.T) {
+	url, dial := unixSocket("http://localhost:4007")
+	assert.Equal(t, "http://localhost:4007", url)
+	assert.Nil(t, dial)
+
+	url, dial = unixSocket("unix:///var/run/fp.sock")
+	assert.Equal(t, "http://unix/", url)
+	assert.NotNil(t, dial)
+}
@@ -0,0 +1,108 @@
+package featureprobe
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+)
+
+// RepositoryDiff is what changed in toggles between two repositories, with
+// keys sorted.
+type RepositoryDiff struct {
+	Added   []string
+	Removed []string
+	Changed []ToggleChange
+}
+
+// ToggleChange is a toggle present in both repositories whose version
+// changed. Fields lists the JSON names of the fields that differ.
+type ToggleChange struct {
+	Key        string
+	OldVersion uint64
+	NewVersion uint64
+	Fields     []string
+}
+
+func (d RepositoryDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// WithChangeListener receives the diff of every repository update applied by
+// the synchronizer, e.g. for an audit log of toggle changes. The first sync
+// adds every toggle.
+func WithChangeListener(listener func(diff RepositoryDiff)) Option {
+	return func(fpConfig *FPConfig) {
+		fpConfig.ChangeListener = listener
+	}
+}
+
+func changeNotifier(listener func(RepositoryDiff)) func(previous, repo *Repository) {
+	return func(previous, repo *Repository) {
+		if diff := DiffRepositories(previous, repo); !diff.Empty() {
+			listener(diff)
+		}
+	}
+}
+
+func DiffRepositories(old, new *Repository) RepositoryDiff {
+	var diff RepositoryDiff
+	for key, t := range new.toggles() {
+		o, ok := old.GetToggle(key)
+		if !ok {
+			diff.Added = append(diff.Added, key)
+			continue
+		}
+		// like compile, a toggle whose version did not change is unchanged
+		if o.Version != t.Version {
+			diff.Changed = append(diff.Changed, ToggleChange{
+				Key:        key,
+				OldVersion: o.Version,
+				NewVersion: t.Version,
+				Fields:     changedFields(o, t),
+			})
+		}
+	}
+	for key := range old.toggles() {
+		if _, ok := new.GetToggle(key); !ok {
+			diff.Removed = append(diff.Removed, key)
+		}
+	}
+	sort.Strings(diff.Added)
+	sort.Strings(diff.Removed)
+	sort.Slice(diff.Changed, func(i, j int) bool { return diff.Changed[i].Key < diff.Changed[j].Key })
+	return diff
+}
+
+func (repo *Repository) toggles() map[string]Toggle {
+	if repo == nil {
+		return nil
+	}
+	return repo.Toggles
+}
+
+// changedFields compares fields by their JSON encoding, which ignores what
+// compiling a toggle adds.
+func changedFields(old, new Toggle) []string {
+	fields := []struct {
+		name     string
+		old, new interface{}
+	}{
+		{"enabled", old.Enabled, new.Enabled},
+		{"forClient", old.ForClient, new.ForClient},
+		{"disabledServe", old.DisabledServe, new.DisabledServe},
+		{"defaultServe", old.DefaultServe, new.DefaultServe},
+		{"rules", old.Rules, new.Rules},
+		{"variations", old.Variations, new.Variations},
+		{"prerequisites", old.Prerequisites, new.Prerequisites},
+		{"metadata", old.Metadata, new.Metadata},
+	}
+	var changed []string
+	for _, f := range fields {
+		o, err1 := json.Marshal(f.old)
+		n, err2 := json.Marshal(f.new)
+		if err1 != nil || err2 != nil || !bytes.Equal(o, n) {
+			changed = append(changed, f.name)
+		}
+	}
+	return changed
+}
@@ -0,0 +1,53 @@
+package featureprobe
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffRepositories(t *testing.T) {
+	old := Repository{Toggles: map[string]Toggle{
+		"kept":    newToggleForTest("kept", true),
+		"changed": newToggleForTest("changed", "a"),
+		"removed": newToggleForTest("removed", 1.0),
+	}}
+	changed := newToggleForTest("changed", "b")
+	changed.Version = 2
+	changed.Enabled = false
+	new := Repository{Toggles: map[string]Toggle{
+		"kept":    newToggleForTest("kept", true),
+		"changed": changed,
+		"added":   newToggleForTest("added", 1.0),
+	}}
+
+	assert.Equal(t, RepositoryDiff{
+		Added:   []string{"added"},
+		Removed: []string{"removed"},
+		Changed: []ToggleChange{{Key: "changed", OldVersion: 0, NewVersion: 2, Fields: []string{"enabled", "variations"}}},
+	}, DiffRepositories(&old, &new))
+	assert.True(t, DiffRepositories(&new, &new).Empty())
+	assert.Equal(t, []string{"added", "changed", "kept"}, DiffRepositories(nil, &new).Added)
+}
+
+func TestChangeListener(t *testing.T) {
+	repo, _ := setup(t)
+	server := NewMockServer(repo)
+	defer server.Close()
+
+	var mu sync.Mutex
+	var diffs []RepositoryDiff
+	fp, err := NewFeatureProbe(server.URL(), "sdk_key", WithRefreshInterval(50), WithChangeListener(func(diff RepositoryDiff) {
+		mu.Lock()
+		diffs = append(diffs, diff)
+		mu.Unlock()
+	}))
+	assert.Nil(t, err)
+	defer fp.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Len(t, diffs, 1)
+	assert.Len(t, diffs[0].Added, len(repo.Toggles))
+}
@@ -0,0 +1,69 @@
+package featureprobe
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+const (
+	// EnvOverridePrefix starts the name of environment variables overriding a
+	// toggle, like FP_OVERRIDE_NEW_CHECKOUT for toggle "new-checkout".
+	EnvOverridePrefix = "FP_OVERRIDE_"
+	EnvOverrideReason = "environment override"
+)
+
+// WithEnvOverrides lets FP_OVERRIDE_<TOGGLE_KEY> environment variables decide
+// evaluations ahead of overrides and repository data, as a last resort for
+// operators who can't ship code. Toggle keys are upper cased, with anything
+// but letters and digits as '_'. Values are read as json, or else taken as
+// strings, once when the client starts.
+func WithEnvOverrides(enabled bool) Option {
+	return func(fpConfig *FPConfig) {
+		fpConfig.EnvOverrides = enabled
+	}
+}
+
+func loadEnvOverrides(environ []string) map[string]interface{} {
+	var overrides map[string]interface{}
+	for _, kv := range environ {
+		if !strings.HasPrefix(kv, EnvOverridePrefix) {
+			continue
+		}
+		i := strings.IndexByte(kv, '=')
+		name, raw := kv[len(EnvOverridePrefix):i], kv[i+1:]
+		if name == "" {
+			continue
+		}
+		var value interface{}
+		if err := json.Unmarshal([]byte(raw), &value); err != nil {
+			value = raw
+		}
+		if overrides == nil {
+			overrides = map[string]interface{}{}
+		}
+		overrides[name] = value
+	}
+	return overrides
+}
+
+func envOverrideName(toggle string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		}
+		return '_'
+	}, toggle)
+}
+
+// envOverride only builds the variable name when some are set, keeping
+// evaluations free of allocations otherwise.
+func (fp *FeatureProbe) envOverride(toggle string) (interface{}, bool) {
+	if len(fp.envOverrides) == 0 {
+		return nil, false
+	}
+	value, ok := fp.envOverrides[envOverrideName(toggle)]
+	return value, ok
+}
@@ -0,0 +1,68 @@
+package featureprobe
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadEnvOverrides(t *testing.T) {
+	overrides := loadEnvOverrides([]string{
+		"PATH=/bin",
+		"FP_OVERRIDE_NEW_CHECKOUT=true",
+		"FP_OVERRIDE_COLOR=red",
+		"FP_OVERRIDE_LIMIT=10",
+		"FP_OVERRIDE_=ignored",
+	})
+	assert.Equal(t, map[string]interface{}{"NEW_CHECKOUT": true, "COLOR": "red", "LIMIT": float64(10)}, overrides)
+	assert.Nil(t, loadEnvOverrides([]string{"PATH=/bin"}))
+}
+
+func TestEnvOverrideName(t *testing.T) {
+	assert.Equal(t, "NEW_CHECKOUT", envOverrideName("new-checkout"))
+	assert.Equal(t, "A_B_C1", envOverrideName("a.b_c1"))
+}
+
+func TestEnvOverrides(t *testing.T) {
+	os.Setenv("FP_OVERRIDE_ENV_TOGGLE", "false")
+	defer os.Unsetenv("FP_OVERRIDE_ENV_TOGGLE")
+	server := NewMockServer(Repository{Toggles: map[string]Toggle{"env-toggle": newToggleForTest("env-toggle", true)}})
+	defer server.Close()
+
+	fp, err := NewFeatureProbe(server.URL(), "sdk_key", WithServerless(true))
+	assert.Nil(t, err)
+	assert.True(t, fp.BoolValue("env-toggle", NewUser(), false))
+
+	fp, err = NewFeatureProbe(server.URL(), "sdk_key", WithServerless(true), WithEnvOverrides(true))
+	assert.Nil(t, err)
+	fp.Override("env-toggle", true)
+	detail := fp.BoolDetail("env-toggle", NewUser(), true)
+	assert.False(t, detail.Value)
+	assert.Equal(t, EnvOverrideReason, detail.Reason)
+}
+
+func TestEnvOverridesInRemoteBatch(t *testing.T) {
+	os.Setenv("FP_OVERRIDE_ENV_TOGGLE", "false")
+	defer os.Unsetenv("FP_OVERRIDE_ENV_TOGGLE")
+	server := NewMockServer(Repository{Toggles: map[string]Toggle{
+		"env-toggle":   newToggleForTest("env-toggle", true),
+		"other-toggle": newToggleForTest("other-toggle", true),
+	}})
+	defer server.Close()
+
+	fp, err := NewFeatureProbe(server.URL(), "sdk_key", WithRemoteEvaluation(true), WithEnvOverrides(true))
+	assert.Nil(t, err)
+	defer fp.Close()
+	batch, err := fp.EvaluateBatch(NewUser(), []string{"env-toggle", "other-toggle"})
+	assert.Nil(t, err)
+	assert.Equal(t, false, batch["env-toggle"].Detail.Value)
+	assert.Equal(t, EnvOverrideReason, batch["env-toggle"].Detail.Reason)
+	assert.Equal(t, true, batch["other-toggle"].Detail.Value)
+	assert.Equal(t, 1, server.EvaluateRequests())
+
+	batch, err = fp.EvaluateBatch(NewUser(), []string{"env-toggle"})
+	assert.Nil(t, err)
+	assert.Equal(t, false, batch["env-toggle"].Detail.Value)
+	assert.Equal(t, 1, server.EvaluateRequests())
+}
@@ -0,0 +1,39 @@
+package featureprobe
+
+// WithEnvironment adds an environment whose toggles are synced with its own
+// server sdk key, to evaluate them on a single call with BoolValueIn and the
+// like, for tools comparing toggles across environments side by side.
+// Environment clients share every other option.
+func WithEnvironment(name, serverSdkKey string) Option {
+	return func(fpConfig *FPConfig) {
+		if fpConfig.Environments == nil {
+			fpConfig.Environments = map[string]string{}
+		}
+		fpConfig.Environments[name] = serverSdkKey
+	}
+}
+
+// Environment returns the client of an environment added by WithEnvironment.
+// Toggles of an unknown environment are not found, serving their defaults.
+func (fp *FeatureProbe) Environment(name string) *FeatureProbe {
+	if environment, ok := fp.environments[name]; ok {
+		return environment
+	}
+	return fp.unknownClient("environment", name)
+}
+
+func (fp *FeatureProbe) BoolValueIn(environment, toggle string, user FPUser, defaultValue bool) bool {
+	return fp.Environment(environment).BoolValue(toggle, user, defaultValue)
+}
+
+func (fp *FeatureProbe) StrValueIn(environment, toggle string, user FPUser, defaultValue string) string {
+	return fp.Environment(environment).StrValue(toggle, user, defaultValue)
+}
+
+func (fp *FeatureProbe) NumberValueIn(environment, toggle string, user FPUser, defaultValue float64) float64 {
+	return fp.Environment(environment).NumberValue(toggle, user, defaultValue)
+}
+
+func (fp *FeatureProbe) JsonValueIn(environment, toggle string, user FPUser, defaultValue interface{}) interface{} {
+	return fp.Environment(environment).JsonValue(toggle, user, defaultValue)
+}
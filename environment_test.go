@@ -0,0 +1,41 @@
+package featureprobe
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnvironments(t *testing.T) {
+	repos := map[string]Repository{
+		"prod_key":    {Toggles: map[string]Toggle{"toggle": newToggleForTest("toggle", true)}},
+		"staging_key": {Toggles: map[string]Toggle{"toggle": newToggleForTest("toggle", false)}},
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(repos[r.Header.Get("Authorization")])
+	}))
+	defer server.Close()
+
+	var errs []error
+	fp, err := NewFeatureProbe(server.URL, "prod_key", WithEnvironment("staging", "staging_key"), WithProject("billing", "prod_key"),
+		WithErrorListener(func(err error) { errs = append(errs, err) }))
+	assert.Nil(t, err)
+
+	user := NewUser()
+	assert.True(t, fp.BoolValue("toggle", user, false))
+	assert.False(t, fp.BoolValueIn("staging", "toggle", user, true))
+	assert.Equal(t, false, fp.JsonValueIn("staging", "toggle", user, nil))
+	assert.Nil(t, fp.Environment("staging").environments)
+	assert.Nil(t, fp.Project("billing").environments)
+	assert.Empty(t, errs)
+
+	assert.Equal(t, "d", fp.StrValueIn("unknown", "toggle", user, "d"))
+	assert.Equal(t, 1.0, fp.NumberValueIn("unknown", "toggle", user, 1.0))
+	assert.Len(t, errs, 2)
+
+	fp.Close()
+	assert.True(t, fp.Environment("staging").Closed())
+}
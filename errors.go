@@ -0,0 +1,40 @@
+package featureprobe
+
+import "errors"
+
+// Errors returned by the SDK, often wrapped in a ToggleError, to be matched
+// with errors.Is rather than by message or reason.
+var (
+	ErrToggleNotFound    = errors.New("toggle not found")
+	ErrTypeMismatch      = errors.New("value type mismatch")
+	ErrNotInitialized    = errors.New("FeatureProbe not initialized")
+	ErrVariationOverflow = errors.New("variation index overflow")
+	ErrInternal          = errors.New("internal error")
+	ErrQuarantined       = errors.New("toggle quarantined")
+)
+
+// ToggleError is an error evaluating or looking up Toggle.
+type ToggleError struct {
+	Toggle string
+	Err    error
+}
+
+func (e *ToggleError) Error() string {
+	return "toggle " + e.Toggle + ": " + e.Err.Error()
+}
+
+func (e *ToggleError) Unwrap() error {
+	return e.Err
+}
+
+func toggleError(toggle string, err error) error {
+	return &ToggleError{Toggle: toggle, Err: err}
+}
+
+// lookupError tells a repository not synced yet from a missing toggle.
+func lookupError(repo *Repository, toggle string) error {
+	if repo == nil || repo.Toggles == nil {
+		return ErrNotInitialized
+	}
+	return toggleError(toggle, ErrToggleNotFound)
+}
@@ -0,0 +1,53 @@
+package featureprobe
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDetailErrors(t *testing.T) {
+	fp := NewFeatureProbeForTest(map[string]interface{}{"toggle": "on"})
+	user := NewUser()
+
+	assert.Nil(t, fp.StrDetail("toggle", user, "off").Err)
+
+	err := fp.StrDetail("missing", user, "off").Err
+	assert.True(t, errors.Is(err, ErrToggleNotFound))
+	var toggleErr *ToggleError
+	assert.True(t, errors.As(err, &toggleErr))
+	assert.Equal(t, "missing", toggleErr.Toggle)
+	assert.Equal(t, "toggle missing: toggle not found", err.Error())
+
+	err = fp.BoolDetail("toggle", user, false).Err
+	assert.True(t, errors.Is(err, ErrTypeMismatch))
+
+	fp.Close()
+	assert.True(t, errors.Is(fp.StrDetail("toggle", user, "off").Err, ErrClientClosed))
+}
+
+func TestNotInitializedErrors(t *testing.T) {
+	fp := FeatureProbe{Repo: NewRepositoryStore(&Repository{})}
+	assert.True(t, errors.Is(fp.StrDetail("toggle", NewUser(), "off").Err, ErrNotInitialized))
+	_, err := fp.Simulate("toggle", nil)
+	assert.True(t, errors.Is(err, ErrNotInitialized))
+
+	empty := FeatureProbe{}
+	assert.True(t, errors.Is(empty.StrDetail("toggle", NewUser(), "off").Err, ErrNotInitialized))
+	assert.Equal(t, ErrNotInitialized, empty.RollbackRepository(1))
+}
+
+func TestVariationOverflowError(t *testing.T) {
+	s := 5
+	toggle := newToggleForTest("toggle", true)
+	toggle.DefaultServe = Serve{Select: &s}
+	fp := NewFeatureProbeForTest(nil)
+	fp.setRepoForTest(Repository{Toggles: map[string]Toggle{"toggle": toggle}})
+
+	detail := fp.BoolDetail("toggle", NewUser(), false)
+	assert.True(t, errors.Is(detail.Err, ErrVariationOverflow))
+	var toggleErr *ToggleError
+	assert.True(t, errors.As(detail.Err, &toggleErr))
+	assert.Equal(t, "toggle", toggleErr.Toggle)
+}
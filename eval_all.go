@@ -0,0 +1,36 @@
+package featureprobe
+
+// sharedSegments resolves each segment once for one user across the toggles
+// evaluated for them. Memberships are dropped when the repository changes
+// between two evaluations.
+type sharedSegments struct {
+	repo *Repository
+	in   map[string]bool
+}
+
+func (s *sharedSegments) memo(repo *Repository) map[string]bool {
+	if s == nil {
+		return nil
+	}
+	if s.repo != repo {
+		s.repo, s.in = repo, map[string]bool{}
+	}
+	return s.in
+}
+
+// EvalAll evaluates every toggle of the repository for user, as bootstrap
+// data or a relay answers, without recording access events. Each segment the
+// toggles reference is resolved once for all of them.
+func (fp *FeatureProbe) EvalAll(user FPUser) map[string]EvalDetail {
+	repo := fp.Repo.Load()
+	if repo == nil {
+		return map[string]EvalDetail{}
+	}
+	details := make(map[string]EvalDetail, len(repo.Toggles))
+	shared := &sharedSegments{}
+	for key := range repo.Toggles {
+		result, _ := fp.evaluateShared(key, user, nil, shared)
+		details[key] = result.evalDetail()
+	}
+	return details
+}
@@ -0,0 +1,76 @@
+package featureprobe
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// countingClock counts the datetime conditions evaluated.
+type countingClock struct {
+	systemClock
+	now int
+}
+
+func (c *countingClock) Now() time.Time {
+	c.now++
+	return time.Unix(2000, 0)
+}
+
+func segmentToggles(n int) Repository {
+	segments := map[string]Segment{"launched": {Key: "launched", Rules: []Rule{{Conditions: []Condition{
+		{Type: "datetime", Predicate: "after", Objects: []string{"1000"}},
+	}}}}}
+	repo := Repository{Toggles: map[string]Toggle{}, Segments: segments}
+	select1 := 1
+	for i := 0; i < n; i++ {
+		t := newToggleForTest(fmt.Sprintf("toggle_%d", i), "off")
+		t.Variations = append(t.Variations, "on")
+		t.Rules = []Rule{{Serve: Serve{Select: &select1}, Conditions: []Condition{{Type: "segment", Predicate: "is in", Objects: []string{"launched"}}}}}
+		repo.Toggles[t.Key] = t
+	}
+	repo.compile(nil)
+	return repo
+}
+
+func TestEvalAllResolvesSegmentsOnce(t *testing.T) {
+	clock := &countingClock{}
+	fp := NewFeatureProbeForTest(nil)
+	fp.Config.Clock = clock
+	fp.setRepoForTest(segmentToggles(10))
+
+	details := fp.EvalAll(NewUser())
+	assert.Len(t, details, 10)
+	for key, detail := range details {
+		assert.Equal(t, "on", detail.Value, key)
+		assert.Equal(t, fp.StrDetail(key, NewUser(), "d").Value, detail.Value)
+	}
+	clock.now = 0
+	fp.EvalAll(NewUser())
+	assert.Equal(t, 1, clock.now)
+}
+
+func TestEvaluateBatchSharesSegments(t *testing.T) {
+	clock := &countingClock{}
+	fp := NewFeatureProbeForTest(nil)
+	fp.Config.Clock = clock
+	fp.setRepoForTest(segmentToggles(3))
+
+	batch, err := fp.EvaluateBatch(NewUser(), []string{"toggle_0", "toggle_1", "toggle_2"})
+	assert.Nil(t, err)
+	assert.Equal(t, "on", batch["toggle_2"].Detail.Value)
+	assert.Equal(t, 1, clock.now)
+}
+
+func TestSharedSegmentsDroppedOnRepositoryChange(t *testing.T) {
+	var shared *sharedSegments
+	assert.Nil(t, shared.memo(&Repository{}))
+
+	shared = &sharedSegments{}
+	first, second := &Repository{}, &Repository{}
+	shared.memo(first)["segment"] = true
+	assert.Equal(t, map[string]bool{"segment": true}, shared.memo(first))
+	assert.Empty(t, shared.memo(second))
+}
@@ -4,6 +4,7 @@ import (
 	"crypto/sha1"
 	"encoding/binary"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"regexp"
 	"strconv"
@@ -16,6 +17,9 @@ import (
 type Repository struct {
 	Toggles  map[string]Toggle  `json:"toggles"`
 	Segments map[string]Segment `json:"segments"`
+	// SchemaVersion is the payload schema the server answered with, unset
+	// for version 1 payloads.
+	SchemaVersion int `json:"schemaVersion,omitempty"`
 }
 
 type Toggles struct {
@@ -32,6 +36,18 @@ type Toggle struct {
 	DefaultServe  Serve         `json:"defaultServe"`
 	Rules         []Rule        `json:"rules"`
 	Variations    []interface{} `json:"variations"`
+	// Prerequisites and Metadata come with schema version 2.
+	Prerequisites []Prerequisite         `json:"prerequisites,omitempty"`
+	Metadata      map[string]interface{} `json:"metadata,omitempty"`
+	// Archived and PlannedEndTime, in unix millis, mark toggles due for
+	// cleanup, reported when still evaluated.
+	Archived       bool  `json:"archived,omitempty"`
+	PlannedEndTime int64 `json:"plannedEndTime,omitempty"`
+	typed          *typedVariations
+	refs           *eventRefs
+	repeatSegment  bool
+	usage          *toggleUsage
+	matchedRules   []*MatchedRule
 }
 
 type Segment struct {
@@ -39,6 +55,9 @@ type Segment struct {
 	UniqId  string `json:"uniqueId"`
 	Version uint64 `json:"version"`
 	Rules   []Rule `json:"rules"`
+	// Unbounded segments are big segments, whose users are looked up in the
+	// BigSegmentStore rather than listed in Rules.
+	Unbounded bool `json:"unbounded,omitempty"`
 }
 
 type Serve struct {
@@ -54,8 +73,23 @@ type Rule struct {
 type Split struct {
 	Distribution [][]Range `json:"distribution"`
 	BucketBy     string    `json:"bucketBy,omitempty"`
-	Salt         string    `json:"salt,omitempty"`
-}
+	// BucketByAll buckets on the composite of these attributes instead of
+	// BucketBy, e.g. to randomize by org_id and app_id together.
+	BucketByAll []string `json:"bucketByAll,omitempty"`
+	Salt        string   `json:"salt,omitempty"`
+	// BucketSize is the number of buckets Distribution ranges cover, for
+	// rollouts finer than the default basis points, e.g. 1000000 for 0.0001%
+	// steps.
+	BucketSize uint32 `json:"bucketSize,omitempty"`
+	// Remainder is the variation served to buckets no Distribution range
+	// covers. Without it, a rule serving the split falls back to the default
+	// serve for them.
+	Remainder *int `json:"remainder,omitempty"`
+}
+
+// errBucketNotCovered is returned by a split without remainder for buckets
+// its distribution doesn't cover.
+var errBucketNotCovered = errors.New("not find hash_bucket in distribution")
 
 type Range struct {
 	Lower int `json:"-"`
@@ -67,6 +101,7 @@ type Condition struct {
 	Subject   string   `json:"subject"`
 	Predicate string   `json:"predicate"`
 	Objects   []string `json:"objects"`
+	compiled  *compiledCondition
 }
 
 type evalParams struct {
@@ -74,7 +109,18 @@ type evalParams struct {
 	IsDetail   bool
 	User       FPUser
 	Variations []interface{}
-	Segments   map[string]Segment
+	Repo       *Repository
+	Clock      Clock
+	// segmentMemo caches segment membership of User during one evaluation,
+	// only for toggles referencing a segment more than once, or across the
+	// toggles of one EvalAll.
+	segmentMemo map[string]bool
+	// depth counts the prerequisites being evaluated above this toggle.
+	depth       int
+	bigSegments *bigSegments
+	// compositeKeys are the attributes bucketBy names stand for, set with
+	// WithCompositeBucketBy.
+	compositeKeys map[string][]string
 }
 
 type EvalDetail struct {
@@ -83,14 +129,86 @@ type EvalDetail struct {
 	VariationIndex *int
 	Version        *uint64
 	Reason         string
+	Rule           *MatchedRule
+	// Err is why the default was served instead of a variation, if it was
+	// for an error
+	Err    error
+	Source Source
+}
+
+const noIndex = -1
+
+// evalResult is the pointer-free form of EvalDetail used on the evaluation
+// hot path, so pointers are only allocated when a caller asks for details.
+type evalResult struct {
+	ruleIndex      int
+	variationIndex int
+	version        uint64
+	hasVersion     bool
+	value          interface{}
+	reason         string
+	typed          *typedVariations
+	refs           *eventRefs
+	rule           *MatchedRule
+	// repo is the repository evaluated, to find the segments of rule
+	repo     *Repository
+	segments []MatchedSegment
+	err      error
+	source   Source
+}
+
+func (r evalResult) evalDetail() EvalDetail {
+	return EvalDetail{
+		Value:          r.value,
+		RuleIndex:      r.ruleIndexPtr(),
+		VariationIndex: intPtr(r.variationIndex),
+		Version:        r.versionPtr(),
+		Reason:         r.reason,
+		Rule:           r.rule,
+		Err:            r.err,
+		Source:         r.valueSource(),
+	}
+}
+
+func (r evalResult) ruleIndexPtr() *int {
+	return intPtr(r.ruleIndex)
+}
+
+func (r evalResult) versionPtr() *uint64 {
+	if !r.hasVersion {
+		return nil
+	}
+	v := r.version
+	return &v
+}
+
+func intPtr(i int) *int {
+	if i == noIndex {
+		return nil
+	}
+	return &i
+}
+
+var ruleReasons = func() [16]string {
+	var reasons [16]string
+	for i := range reasons {
+		reasons[i] = "rule " + strconv.Itoa(i) + " "
+	}
+	return reasons
+}()
+
+func ruleReason(index int) string {
+	if index < len(ruleReasons) {
+		return ruleReasons[index]
+	}
+	return "rule " + strconv.Itoa(index) + " "
 }
 
 func saltHash(key string, salt string, bucketSize uint32) int {
-	h := sha1.New()
-	h.Write([]byte(key + salt))
-	bytes := h.Sum(nil)
-	size := len(bytes)
-	value := binary.BigEndian.Uint32(bytes[size-4 : size])
+	var buf [128]byte
+	data := append(append(buf[:0], key...), salt...)
+	sum := sha1.Sum(data)
+	value := binary.BigEndian.Uint32(sum[sha1.Size-4:])
 	// avoid negative number mod
 	mod := int64(value) % int64(bucketSize)
 	return int(mod)
@@ -113,10 +231,14 @@ func (r *Range) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+func (r Range) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]int{r.Lower, r.Upper})
+}
+
 func (t *Toggle) Eval(user FPUser, segments map[string]Segment) (interface{}, error) {
 	params := evalParams{
 		User:       user,
-		Segments:   segments,
+		Repo:       &Repository{Segments: segments},
 		Variations: t.Variations,
 		Key:        t.Key,
 	}
@@ -138,88 +260,110 @@ func (t *Toggle) Eval(user FPUser, segments map[string]Segment) (interface{}, er
 }
 
 func (t *Toggle) evalDetail(user FPUser, segments map[string]Segment) (EvalDetail, error) {
-	params := evalParams{
+	result, err := t.detail(evalParams{
 		User:       user,
-		Segments:   segments,
+		Repo:       &Repository{Segments: segments},
 		Variations: t.Variations,
 		Key:        t.Key,
-	}
+	})
+	return result.evalDetail(), err
+}
 
+func (t *Toggle) detail(params evalParams) (evalResult, error) {
+	result := evalResult{
+		ruleIndex:      noIndex,
+		variationIndex: noIndex,
+		version:        t.Version,
+		hasVersion:     true,
+		typed:          t.typed,
+		refs:           t.refs,
+	}
+	if t.repeatSegment && params.segmentMemo == nil {
+		params.segmentMemo = map[string]bool{}
+	}
 	if !t.Enabled {
 		serve, index, err := t.DisabledServe.selectVariation(params)
 		if err != nil {
-			return EvalDetail{
-				Value:     nil,
-				Version:   &t.Version,
-				RuleIndex: nil,
-				Reason:    err.Error(),
-			}, err
+			result.reason = err.Error()
+			return result, err
+		}
+		result.value, result.variationIndex, result.reason = serve, index, "disabled"
+		return result, nil
+	}
+	if len(t.Prerequisites) > 0 {
+		met, err := t.prerequisitesMet(params)
+		if err != nil {
+			result.reason = err.Error()
+			return result, err
+		}
+		if !met {
+			serve, index, err := t.DisabledServe.selectVariation(params)
+			if err != nil {
+				result.reason = err.Error()
+				return result, err
+			}
+			result.value, result.variationIndex, result.reason = serve, index, prerequisiteReason
+			return result, nil
 		}
-		return EvalDetail{
-			Value:          serve,
-			VariationIndex: index,
-			Version:        &t.Version,
-			RuleIndex:      nil,
-			Reason:         "disabled",
-		}, nil
 	}
 
-	for ruleIndex, rule := range t.Rules {
-		serve, vi, err := rule.serveVariation(params)
+	for ruleIndex := range t.Rules {
+		serve, vi, err := t.Rules[ruleIndex].serveVariation(params)
+		if err == errBucketNotCovered {
+			return t.remainder(result, ruleIndex, params)
+		}
 		if err != nil {
-			return EvalDetail{
-				Value:     nil,
-				Version:   &t.Version,
-				RuleIndex: &ruleIndex,
-				Reason:    err.Error(),
-			}, err
+			result.ruleIndex, result.reason = ruleIndex, err.Error()
+			result.rule = t.matchedRule(ruleIndex)
+			return result, err
 		}
 		if serve != nil {
-			return EvalDetail{
-				Value:          serve,
-				VariationIndex: vi,
-				RuleIndex:      &ruleIndex,
-				Version:        &t.Version,
-				Reason:         fmt.Sprintf("rule %d ", ruleIndex),
-			}, nil
+			result.value, result.variationIndex = serve, vi
+			result.ruleIndex, result.reason = ruleIndex, ruleReason(ruleIndex)
+			result.rule = t.matchedRule(ruleIndex)
+			return result, nil
 		}
 	}
 
 	serve, vi, err := t.DefaultServe.selectVariation(params)
 	if err != nil {
-		return EvalDetail{
-			Value:     nil,
-			RuleIndex: nil,
-			Version:   &t.Version,
-			Reason:    err.Error(),
-		}, err
+		result.reason = err.Error()
+		return result, err
 	}
-	return EvalDetail{
-		Value:          serve,
-		VariationIndex: vi,
-		RuleIndex:      nil,
-		Version:        &t.Version,
-		Reason:         "default",
-	}, nil
+	result.value, result.variationIndex, result.reason = serve, vi, "default"
+	return result, nil
+}
+
+// remainder serves the default to a user matching rule ruleIndex whose
+// bucket the rule's split does not cover.
+func (t *Toggle) remainder(result evalResult, ruleIndex int, params evalParams) (evalResult, error) {
+	serve, vi, err := t.DefaultServe.selectVariation(params)
+	if err != nil {
+		result.reason = err.Error()
+		return result, err
+	}
+	result.value, result.variationIndex = serve, vi
+	result.reason = fmt.Sprintf("split remainder of rule %d", ruleIndex)
+	return result, nil
 }
 
-func (s *Serve) selectVariation(params evalParams) (interface{}, *int, error) {
-	var index *int = nil
+func (s *Serve) selectVariation(params evalParams) (interface{}, int, error) {
+	index := noIndex
 	if s.Select != nil {
-		index = s.Select
+		index = *s.Select
 	} else {
 		i, err := s.Split.findIndex(params)
 		if err != nil {
-			return nil, nil, err
+			return nil, noIndex, err
 		}
-		index = &i
+		index = i
 	}
 
 	length := len(params.Variations)
-	if *index >= length {
-		return nil, nil, fmt.Errorf("index %d overflow, variations count is %d", index, length)
+	if index >= length {
+		return nil, noIndex, fmt.Errorf("%w: index %d, variations count is %d", ErrVariationOverflow, index, length)
 	}
-	return params.Variations[*index], index, nil
+	return variationValue(params.Variations[index]), index, nil
 }
 
 func (s *Serve) selectVariationValue(params evalParams) (interface{}, error) {
@@ -233,19 +377,12 @@ func (s *Split) findIndex(params evalParams) (int, error) {
 		return -1, err
 	}
 
-	var salt string
-	if len(s.Salt) == 0 {
-		salt = params.Key
-	} else {
-		salt = s.Salt
-	}
-
-	bucketIndex := saltHash(hashKey, salt, 10000)
+	bucketIndex := saltHash(hashKey, s.salt(params.Key), s.buckets())
 
 	variation := s.getVariation(bucketIndex)
 
 	if variation == -1 {
-		return variation, fmt.Errorf("not find hash_bucket in distribution")
+		return variation, errBucketNotCovered
 	}
 
 	return variation, nil
@@ -259,12 +396,18 @@ func (s *Split) getVariation(bucketIndex int) int {
 			}
 		}
 	}
+	if s.Remainder != nil {
+		return *s.Remainder
+	}
 	return -1
 }
 
 func (s *Split) hashKey(params evalParams) (string, error) {
 	var hashKey string
 	user := params.User
+	if attributes := s.compositeAttributes(params); len(attributes) != 0 {
+		return compositeKey(user, attributes)
+	}
 	if len(s.BucketBy) == 0 {
 		hashKey = user.Key()
 	} else {
@@ -279,23 +422,39 @@ func (s *Split) hashKey(params evalParams) (string, error) {
 	return hashKey, nil
 }
 
-func (r *Rule) serveVariation(params evalParams) (interface{}, *int, error) {
-	for _, c := range r.Conditions {
-		if !c.meet(params.User, params.Segments) {
-			return nil, nil, nil
+func (r *Rule) serveVariation(params evalParams) (interface{}, int, error) {
+	for i := range r.Conditions {
+		if !r.Conditions[i].meet(params) {
+			return nil, noIndex, nil
 		}
 	}
 	return r.Serve.selectVariation(params)
 }
 
-func (c *Condition) meet(user FPUser, segments map[string]Segment) bool {
+// Predicates of any attribute condition type, matching on whether the user
+// has the subject attribute rather than on its value.
+const (
+	attributeExists = "attribute exists"
+	attributeNotSet = "attribute not set"
+)
+
+func (c *Condition) meet(params evalParams) bool {
+	user := params.User
+	if c.Type != "segment" {
+		switch c.Predicate {
+		case attributeExists:
+			return user.Contains(c.Subject)
+		case attributeNotSet:
+			return !user.Contains(c.Subject)
+		}
+	}
 	switch c.Type {
 	case "string":
 		return c.matchStringCondition(user, c.Predicate)
 	case "segment":
-		return c.matchSegmentCondition(user, c.Predicate, segments)
+		return c.matchSegmentCondition(params, c.Predicate)
 	case "datetime":
-		return c.matchDatetimeCondition(user, c.Predicate)
+		return c.matchDatetimeCondition(user, c.Predicate, clockOrSystem(params.Clock).Now())
 	case "semver":
 		return c.matchSemverCondition(user, c.Predicate)
 	case "number":
@@ -321,13 +480,7 @@ func (c *Condition) matchStringCondition(user FPUser, predicate string) bool {
 	case "contains":
 		return c.matchObjects(func(o string) bool { return strings.Contains(customValue, o) })
 	case "matches regex":
-		return c.matchObjects(func(o string) bool {
-			matched, err := regexp.Match(o, []byte(customValue))
-			if err != nil {
-				return false
-			}
-			return matched
-		})
+		return c.matchRegexObjects(func(re *regexp.Regexp) bool { return re.MatchString(customValue) })
 	case "is not any of":
 		return !c.matchStringCondition(user, "is one of")
 	case "does not start with":
@@ -343,29 +496,31 @@ func (c *Condition) matchStringCondition(user FPUser, predicate string) bool {
 	return false
 }
 
-func (c *Condition) matchSegmentCondition(user FPUser, predicate string, segments map[string]Segment) bool {
-	if segments == nil {
+func (c *Condition) matchSegmentCondition(params evalParams, predicate string) bool {
+	// segments are not resolved within segment rules; a repository without
+	// segments still answers "is not in"
+	if params.Repo == nil {
 		return false
 	}
 	switch predicate {
 	case "is in":
-		return c.userInSegments(user, segments)
+		return c.userInSegments(params)
 	case "is not in":
-		return !c.userInSegments(user, segments)
+		return !c.userInSegments(params)
 	}
 	return false
 }
 
-func (c *Condition) userDatetime(user FPUser) (int64, error) {
+func (c *Condition) userDatetime(user FPUser, now time.Time) (int64, error) {
 	customValue := user.Get(c.Subject)
 	if len(customValue) == 0 {
-		return time.Now().Unix(), nil
+		return now.Unix(), nil
 	}
 	return strconv.ParseInt(customValue, 10, 64)
 }
 
-func (c *Condition) matchDatetimeCondition(user FPUser, predicate string) bool {
-	cv, err := c.userDatetime(user)
+func (c *Condition) matchDatetimeCondition(user FPUser, predicate string, now time.Time) bool {
+	cv, err := c.userDatetime(user, now)
 	if err != nil {
 		return false
 	}
@@ -435,13 +590,10 @@ func (c *Condition) matchNumberCondition(user FPUser, predicate string) bool {
 	return false
 }
 
-func (c *Condition) userInSegments(user FPUser, segments map[string]Segment) bool {
+func (c *Condition) userInSegments(params evalParams) bool {
 	for _, segmentKey := range c.Objects {
-		segment, ok := segments[segmentKey]
-		if ok {
-			if segment.contains(user) {
-				return true
-			}
+		if params.segmentContains(segmentKey) {
+			return true
 		}
 	}
 	return false
@@ -456,12 +608,17 @@ func (c *Condition) matchObjects(f func(string) bool) bool {
 	return false
 }
 
-func (c *Condition) matchDatetimeObjects(f func(int64) bool) bool {
-	for _, o := range c.Objects {
-		co, err := strconv.ParseInt(o, 10, 64)
-		if err != nil {
-			return false
+func (c *Condition) matchRegexObjects(f func(*regexp.Regexp) bool) bool {
+	for _, re := range c.regexObjects() {
+		if re != nil && f(re) {
+			return true
 		}
+	}
+	return false
+}
+
+func (c *Condition) matchDatetimeObjects(f func(int64) bool) bool {
+	for _, co := range c.datetimeObjects() {
 		if f(co) {
 			return true
 		}
@@ -470,11 +627,7 @@ func (c *Condition) matchDatetimeObjects(f func(int64) bool) bool {
 }
 
 func (c *Condition) matchNumberObjects(f func(float64) bool) bool {
-	for _, o := range c.Objects {
-		co, err := strconv.ParseFloat(o, 32)
-		if err != nil {
-			return false
-		}
+	for _, co := range c.numberObjects() {
 		if f(co) {
 			return true
 		}
@@ -483,11 +636,7 @@ func (c *Condition) matchNumberObjects(f func(float64) bool) bool {
 }
 
 func (c *Condition) matchSemVerObjects(f func(*semver.Version) bool) bool {
-	for _, o := range c.Objects {
-		co, err := semver.NewVersion(o)
-		if err != nil {
-			return false
-		}
+	for _, co := range c.semverObjects() {
 		if f(co) {
 			return true
 		}
@@ -495,18 +644,37 @@ func (c *Condition) matchSemVerObjects(f func(*semver.Version) bool) bool {
 	return false
 }
 
-func (s *Segment) contains(user FPUser) bool {
+func (params evalParams) segmentContains(segmentKey string) bool {
+	if in, ok := params.segmentMemo[segmentKey]; ok {
+		return in
+	}
+	in := false
+	if segment, ok := params.Repo.GetSegment(segmentKey); ok {
+		in = segment.contains(params)
+	}
+	if params.segmentMemo != nil {
+		params.segmentMemo[segmentKey] = in
+	}
+	return in
+}
+
+func (s *Segment) contains(params evalParams) bool {
+	if s.Unbounded && params.bigSegments.contains(s.Key, params.User.Key()) {
+		return true
+	}
+	// segment rules can not reference other segments
+	params.Repo = nil
 	for _, rule := range s.Rules {
-		if rule.allow(user) {
+		if rule.allow(params) {
 			return true
 		}
 	}
 	return false
 }
 
-func (r *Rule) allow(user FPUser) bool {
-	for _, condition := range r.Conditions {
-		if condition.meet(user, nil) {
+func (r *Rule) allow(params evalParams) bool {
+	for i := range r.Conditions {
+		if r.Conditions[i].meet(params) {
 			return true
 		}
 	}
@@ -72,10 +72,10 @@ func TestNoSegments(t *testing.T) {
 	}
 
 	user := NewUser().With("city", "100")
-	r := c.matchSegmentCondition(user, "is in", nil)
+	r := c.matchSegmentCondition(evalParams{User: user}, "is in")
 	assert.False(t, r)
 
-	r = c.matchSegmentCondition(user, "is not in", nil)
+	r = c.matchSegmentCondition(evalParams{User: user}, "is not in")
 	assert.False(t, r)
 }
 
@@ -90,7 +90,7 @@ func TestSegmentsUnknownPredicate(t *testing.T) {
 	segments := map[string]Segment{}
 
 	user := NewUser().StableRollout("key11").With("city", "100")
-	r := c.matchSegmentCondition(user, "unknown", segments)
+	r := c.matchSegmentCondition(evalParams{User: user, Repo: &Repository{Segments: segments}}, "unknown")
 	assert.False(t, r)
 }
 
@@ -160,7 +160,7 @@ func TestDistributionNoSalt(t *testing.T) {
 	params := evalParams{
 		User:       user,
 		Variations: nil,
-		Segments:   nil,
+		Repo:       nil,
 	}
 
 	index, _ := split.findIndex(params)
@@ -185,7 +185,7 @@ func TestDistributionInExactBucket(t *testing.T) {
 	params := evalParams{
 		User:       user,
 		Variations: nil,
-		Segments:   nil,
+		Repo:       nil,
 		Key:        "salt",
 	}
 
@@ -210,7 +210,7 @@ func TestDistributionInNoneBucket(t *testing.T) {
 	params := evalParams{
 		User:       user,
 		Variations: nil,
-		Segments:   nil,
+		Repo:       nil,
 	}
 
 	_, err := split.findIndex(params)
@@ -240,7 +240,7 @@ func TestSelectVariationFail(t *testing.T) {
 		Variations: []interface{}{
 			"a", "b",
 		},
-		Segments: nil,
+		Repo: nil,
 	}
 
 	v, _, err := serve.selectVariation(params)
@@ -580,15 +580,15 @@ func TestDatetimeBefore(t *testing.T) {
 	}
 
 	user := NewUser()
-	r := condition.meet(user, nil)
+	r := condition.meet(evalParams{User: user})
 	assert.True(t, r)
 
 	user.With("datetime", fmt.Sprintf("%d", now))
-	r = condition.meet(user, nil)
+	r = condition.meet(evalParams{User: user})
 	assert.True(t, r)
 
 	user.With("datetime", fmt.Sprintf("%d", now+1))
-	r = condition.meet(user, nil)
+	r = condition.meet(evalParams{User: user})
 	assert.False(t, r)
 }
 
@@ -604,19 +604,19 @@ func TestDatetimeAfter(t *testing.T) {
 	}
 
 	user := NewUser()
-	r := condition.meet(user, nil)
+	r := condition.meet(evalParams{User: user})
 	assert.True(t, r)
 
 	user.With("datetime", fmt.Sprintf("%d", now))
-	r = condition.meet(user, nil)
+	r = condition.meet(evalParams{User: user})
 	assert.True(t, r)
 
 	user.With("datetime", fmt.Sprintf("%d", now+1))
-	r = condition.meet(user, nil)
+	r = condition.meet(evalParams{User: user})
 	assert.True(t, r)
 
 	user.With("datetime", fmt.Sprintf("%d", now-1))
-	r = condition.meet(user, nil)
+	r = condition.meet(evalParams{User: user})
 	assert.False(t, r)
 }
 
@@ -629,7 +629,7 @@ func TestDatetimeInvalidCustomValue(t *testing.T) {
 	}
 
 	user := NewUser().With("datetime", "a")
-	r := condition.meet(user, nil)
+	r := condition.meet(evalParams{User: user})
 	assert.False(t, r)
 }
 
@@ -644,7 +644,7 @@ func TestDatetimeInvalid(t *testing.T) {
 	}
 
 	user := NewUser()
-	r := condition.meet(user, nil)
+	r := condition.meet(evalParams{User: user})
 	assert.False(t, r)
 }
 
@@ -659,7 +659,7 @@ func TestDatetimeUnknownPredicate(t *testing.T) {
 	}
 
 	user := NewUser()
-	r := condition.meet(user, nil)
+	r := condition.meet(evalParams{User: user})
 	assert.False(t, r)
 }
 
@@ -674,19 +674,19 @@ func TestNumberEqual(t *testing.T) {
 	}
 
 	user := NewUser().With("price", "1")
-	r := condition.meet(user, nil)
+	r := condition.meet(evalParams{User: user})
 	assert.True(t, r)
 
 	user = NewUser().With("price", "2")
-	r = condition.meet(user, nil)
+	r = condition.meet(evalParams{User: user})
 	assert.True(t, r)
 
 	user = NewUser().With("price", "3")
-	r = condition.meet(user, nil)
+	r = condition.meet(evalParams{User: user})
 	assert.True(t, r)
 
 	user = NewUser().With("price", "4")
-	r = condition.meet(user, nil)
+	r = condition.meet(evalParams{User: user})
 	assert.False(t, r)
 }
 
@@ -701,19 +701,19 @@ func TestNumberNotEqual(t *testing.T) {
 	}
 
 	user := NewUser().With("price", "1")
-	r := condition.meet(user, nil)
+	r := condition.meet(evalParams{User: user})
 	assert.False(t, r)
 
 	user = NewUser().With("price", "2")
-	r = condition.meet(user, nil)
+	r = condition.meet(evalParams{User: user})
 	assert.False(t, r)
 
 	user = NewUser().With("price", "3")
-	r = condition.meet(user, nil)
+	r = condition.meet(evalParams{User: user})
 	assert.False(t, r)
 
 	user = NewUser().With("price", "4")
-	r = condition.meet(user, nil)
+	r = condition.meet(evalParams{User: user})
 	assert.True(t, r)
 }
 
@@ -728,19 +728,19 @@ func TestNumberGreaterThan(t *testing.T) {
 	}
 
 	user := NewUser().With("price", "1")
-	r := condition.meet(user, nil)
+	r := condition.meet(evalParams{User: user})
 	assert.False(t, r)
 
 	user = NewUser().With("price", "2")
-	r = condition.meet(user, nil)
+	r = condition.meet(evalParams{User: user})
 	assert.True(t, r)
 
 	user = NewUser().With("price", "3")
-	r = condition.meet(user, nil)
+	r = condition.meet(evalParams{User: user})
 	assert.True(t, r)
 
 	user = NewUser().With("price", "4")
-	r = condition.meet(user, nil)
+	r = condition.meet(evalParams{User: user})
 	assert.True(t, r)
 }
 
@@ -755,23 +755,23 @@ func TestNumberGreaterThanOrEqualTo(t *testing.T) {
 	}
 
 	user := NewUser().With("price", "0")
-	r := condition.meet(user, nil)
+	r := condition.meet(evalParams{User: user})
 	assert.False(t, r)
 
 	user = NewUser().With("price", "1")
-	r = condition.meet(user, nil)
+	r = condition.meet(evalParams{User: user})
 	assert.True(t, r)
 
 	user = NewUser().With("price", "2")
-	r = condition.meet(user, nil)
+	r = condition.meet(evalParams{User: user})
 	assert.True(t, r)
 
 	user = NewUser().With("price", "3")
-	r = condition.meet(user, nil)
+	r = condition.meet(evalParams{User: user})
 	assert.True(t, r)
 
 	user = NewUser().With("price", "4")
-	r = condition.meet(user, nil)
+	r = condition.meet(evalParams{User: user})
 	assert.True(t, r)
 }
 
@@ -786,23 +786,23 @@ func TestNumberLessThan(t *testing.T) {
 	}
 
 	user := NewUser().With("price", "0")
-	r := condition.meet(user, nil)
+	r := condition.meet(evalParams{User: user})
 	assert.True(t, r)
 
 	user = NewUser().With("price", "1")
-	r = condition.meet(user, nil)
+	r = condition.meet(evalParams{User: user})
 	assert.True(t, r)
 
 	user = NewUser().With("price", "2")
-	r = condition.meet(user, nil)
+	r = condition.meet(evalParams{User: user})
 	assert.True(t, r)
 
 	user = NewUser().With("price", "3")
-	r = condition.meet(user, nil)
+	r = condition.meet(evalParams{User: user})
 	assert.False(t, r)
 
 	user = NewUser().With("price", "4")
-	r = condition.meet(user, nil)
+	r = condition.meet(evalParams{User: user})
 	assert.False(t, r)
 }
 
@@ -817,23 +817,23 @@ func TestNumberLessThanOrEqualTo(t *testing.T) {
 	}
 
 	user := NewUser().With("price", "0")
-	r := condition.meet(user, nil)
+	r := condition.meet(evalParams{User: user})
 	assert.True(t, r)
 
 	user = NewUser().With("price", "1")
-	r = condition.meet(user, nil)
+	r = condition.meet(evalParams{User: user})
 	assert.True(t, r)
 
 	user = NewUser().With("price", "2")
-	r = condition.meet(user, nil)
+	r = condition.meet(evalParams{User: user})
 	assert.True(t, r)
 
 	user = NewUser().With("price", "3")
-	r = condition.meet(user, nil)
+	r = condition.meet(evalParams{User: user})
 	assert.True(t, r)
 
 	user = NewUser().With("price", "4")
-	r = condition.meet(user, nil)
+	r = condition.meet(evalParams{User: user})
 	assert.False(t, r)
 }
 
@@ -848,11 +848,11 @@ func TestNumberInvalid(t *testing.T) {
 	}
 
 	user := NewUser().With("price", "a")
-	r := condition.meet(user, nil)
+	r := condition.meet(evalParams{User: user})
 	assert.False(t, r)
 
 	user = NewUser()
-	r = condition.meet(user, nil)
+	r = condition.meet(evalParams{User: user})
 	assert.False(t, r)
 
 	condition = Condition{
@@ -865,7 +865,7 @@ func TestNumberInvalid(t *testing.T) {
 	}
 
 	user = NewUser().With("price", "1")
-	r = condition.meet(user, nil)
+	r = condition.meet(evalParams{User: user})
 	assert.False(t, r)
 }
 
@@ -880,7 +880,7 @@ func TestNumberUnknownPredicate(t *testing.T) {
 	}
 
 	user := NewUser().With("price", "0")
-	r := condition.meet(user, nil)
+	r := condition.meet(evalParams{User: user})
 	assert.False(t, r)
 }
 
@@ -895,19 +895,19 @@ func TestSemVerEqual(t *testing.T) {
 	}
 
 	user := NewUser().With("version", "1.0.0")
-	r := condition.meet(user, nil)
+	r := condition.meet(evalParams{User: user})
 	assert.True(t, r)
 
 	user = NewUser().With("version", "1.1.0")
-	r = condition.meet(user, nil)
+	r = condition.meet(evalParams{User: user})
 	assert.False(t, r)
 
 	user = NewUser().With("version", "2.0.0")
-	r = condition.meet(user, nil)
+	r = condition.meet(evalParams{User: user})
 	assert.True(t, r)
 
 	user = NewUser().With("version", "4.1.0")
-	r = condition.meet(user, nil)
+	r = condition.meet(evalParams{User: user})
 	assert.False(t, r)
 }
 
@@ -922,19 +922,19 @@ func TestSemVerNotEqual(t *testing.T) {
 	}
 
 	user := NewUser().With("version", "1.0.0")
-	r := condition.meet(user, nil)
+	r := condition.meet(evalParams{User: user})
 	assert.False(t, r)
 
 	user = NewUser().With("version", "1.1.0")
-	r = condition.meet(user, nil)
+	r = condition.meet(evalParams{User: user})
 	assert.True(t, r)
 
 	user = NewUser().With("version", "2.0.0")
-	r = condition.meet(user, nil)
+	r = condition.meet(evalParams{User: user})
 	assert.False(t, r)
 
 	user = NewUser().With("version", "4.1.0")
-	r = condition.meet(user, nil)
+	r = condition.meet(evalParams{User: user})
 	assert.True(t, r)
 }
 
@@ -949,19 +949,19 @@ func TestSemVerGreaterThan(t *testing.T) {
 	}
 
 	user := NewUser().With("version", "1.0.0")
-	r := condition.meet(user, nil)
+	r := condition.meet(evalParams{User: user})
 	assert.False(t, r)
 
 	user = NewUser().With("version", "1.1.0")
-	r = condition.meet(user, nil)
+	r = condition.meet(evalParams{User: user})
 	assert.True(t, r)
 
 	user = NewUser().With("version", "2.0.0")
-	r = condition.meet(user, nil)
+	r = condition.meet(evalParams{User: user})
 	assert.True(t, r)
 
 	user = NewUser().With("version", "4.1.0")
-	r = condition.meet(user, nil)
+	r = condition.meet(evalParams{User: user})
 	assert.True(t, r)
 }
 
@@ -976,19 +976,19 @@ func TestSemVerGreaterThanOrEqualTo(t *testing.T) {
 	}
 
 	user := NewUser().With("version", "1.0.0")
-	r := condition.meet(user, nil)
+	r := condition.meet(evalParams{User: user})
 	assert.True(t, r)
 
 	user = NewUser().With("version", "1.1.0")
-	r = condition.meet(user, nil)
+	r = condition.meet(evalParams{User: user})
 	assert.True(t, r)
 
 	user = NewUser().With("version", "2.0.0")
-	r = condition.meet(user, nil)
+	r = condition.meet(evalParams{User: user})
 	assert.True(t, r)
 
 	user = NewUser().With("version", "4.1.0")
-	r = condition.meet(user, nil)
+	r = condition.meet(evalParams{User: user})
 	assert.True(t, r)
 }
 
@@ -1003,23 +1003,23 @@ func TestSemVerLessThan(t *testing.T) {
 	}
 
 	user := NewUser().With("version", "0.1.0")
-	r := condition.meet(user, nil)
+	r := condition.meet(evalParams{User: user})
 	assert.True(t, r)
 
 	user = NewUser().With("version", "1.0.0")
-	r = condition.meet(user, nil)
+	r = condition.meet(evalParams{User: user})
 	assert.True(t, r)
 
 	user = NewUser().With("version", "1.1.0")
-	r = condition.meet(user, nil)
+	r = condition.meet(evalParams{User: user})
 	assert.True(t, r)
 
 	user = NewUser().With("version", "3.0.0")
-	r = condition.meet(user, nil)
+	r = condition.meet(evalParams{User: user})
 	assert.False(t, r)
 
 	user = NewUser().With("version", "4.1.0")
-	r = condition.meet(user, nil)
+	r = condition.meet(evalParams{User: user})
 	assert.False(t, r)
 }
 
@@ -1034,23 +1034,23 @@ func TestSemVerLessThanOrEqualTo(t *testing.T) {
 	}
 
 	user := NewUser().With("version", "0.1.0")
-	r := condition.meet(user, nil)
+	r := condition.meet(evalParams{User: user})
 	assert.True(t, r)
 
 	user = NewUser().With("version", "1.0.0")
-	r = condition.meet(user, nil)
+	r = condition.meet(evalParams{User: user})
 	assert.True(t, r)
 
 	user = NewUser().With("version", "1.1.0")
-	r = condition.meet(user, nil)
+	r = condition.meet(evalParams{User: user})
 	assert.True(t, r)
 
 	user = NewUser().With("version", "2.0.0")
-	r = condition.meet(user, nil)
+	r = condition.meet(evalParams{User: user})
 	assert.True(t, r)
 
 	user = NewUser().With("version", "4.1.0")
-	r = condition.meet(user, nil)
+	r = condition.meet(evalParams{User: user})
 	assert.False(t, r)
 }
 
@@ -1065,20 +1065,20 @@ func TestSemVerInvalid(t *testing.T) {
 	}
 
 	user := NewUser().With("version", "0.1.0")
-	r := condition.meet(user, nil)
+	r := condition.meet(evalParams{User: user})
 	assert.False(t, r)
 
 	condition.Predicate = "?"
 	user = NewUser().With("version", "0.1.0")
-	r = condition.meet(user, nil)
+	r = condition.meet(evalParams{User: user})
 	assert.False(t, r)
 
 	user = NewUser()
-	r = condition.meet(user, nil)
+	r = condition.meet(evalParams{User: user})
 	assert.False(t, r)
 
 	user = NewUser().With("version", "invalid_version")
-	r = condition.meet(user, nil)
+	r = condition.meet(evalParams{User: user})
 	assert.False(t, r)
 }
 
@@ -1090,7 +1090,7 @@ func TestUnknownConditionType(t *testing.T) {
 		Objects:   nil,
 	}
 	u := NewUser()
-	b := c.meet(u, nil)
+	b := c.meet(evalParams{User: u})
 	assert.False(t, b)
 }
 
@@ -1241,3 +1241,116 @@ func TestClearRepo(t *testing.T) {
 	assert.Equal(t, 0, len(repo.Segments))
 	assert.Equal(t, 0, len(repo.Toggles))
 }
+
+func TestSegmentMembershipMemoized(t *testing.T) {
+	segments := map[string]Segment{
+		"some_segment": {
+			Key: "some_segment",
+			Rules: []Rule{{Conditions: []Condition{
+				{Type: "string", Subject: "city", Predicate: "is one of", Objects: []string{"1"}},
+			}}},
+		},
+	}
+	inSegment := Condition{Type: "segment", Predicate: "is in", Objects: []string{"some_segment"}}
+	select0, select1 := 0, 1
+	toggle := Toggle{
+		Key:     "toggle",
+		Enabled: true,
+		Rules: []Rule{
+			{Serve: Serve{Select: &select0}, Conditions: []Condition{inSegment, {Type: "string", Subject: "city", Predicate: "is one of", Objects: []string{"2"}}}},
+			{Serve: Serve{Select: &select1}, Conditions: []Condition{inSegment}},
+		},
+		DefaultServe: Serve{Select: &select0},
+		Variations:   []interface{}{"a", "b"},
+	}
+	repo := Repository{Toggles: map[string]Toggle{"toggle": toggle}, Segments: segments}
+	repo.compile(nil)
+	compiled := repo.Toggles["toggle"]
+	assert.True(t, compiled.repeatSegment)
+
+	params := evalParams{User: NewUser().With("city", "1"), Repo: &repo, Variations: compiled.Variations, Key: "toggle"}
+	result, err := compiled.detail(params)
+	assert.Nil(t, err)
+	assert.Equal(t, "b", result.value)
+	assert.Equal(t, 1, result.ruleIndex)
+
+	params.segmentMemo = map[string]bool{"some_segment": false}
+	assert.False(t, inSegment.meet(params))
+}
+
+func TestSegmentConditionInToggleRules(t *testing.T) {
+	select0, select1 := 0, 1
+	toggle := Toggle{
+		Key:     "toggle",
+		Enabled: true,
+		Version: 1,
+		Rules: []Rule{
+			{Serve: Serve{Select: &select1}, Conditions: []Condition{{Type: "segment", Predicate: "is not in", Objects: []string{"beta"}}}},
+		},
+		DefaultServe: Serve{Select: &select0},
+		Variations:   []interface{}{"in beta", "not in beta"},
+	}
+	fp := FeatureProbe{Repo: NewRepositoryStore(&Repository{Toggles: map[string]Toggle{"toggle": toggle}})}
+	detail := fp.StrDetail("toggle", NewUser(), "d")
+	assert.Equal(t, "not in beta", detail.Value)
+	assert.Equal(t, 0, *detail.RuleIndex)
+
+	segments := map[string]Segment{"beta": {Key: "beta", Rules: []Rule{{Conditions: []Condition{
+		{Type: "string", Subject: "city", Predicate: "is one of", Objects: []string{"1"}},
+	}}}}}
+	fp.Repo.Store(&Repository{Toggles: map[string]Toggle{"toggle": toggle}, Segments: segments})
+	assert.Equal(t, "in beta", fp.StrValue("toggle", NewUser().With("city", "1"), "d"))
+	assert.Equal(t, "not in beta", fp.StrValue("toggle", NewUser().With("city", "2"), "d"))
+}
+
+func TestAttributeExistencePredicates(t *testing.T) {
+	exists := Condition{Type: "string", Subject: "plan", Predicate: "attribute exists"}
+	notSet := Condition{Type: "string", Subject: "plan", Predicate: "attribute not set"}
+
+	for _, user := range []FPUser{NewUser().With("plan", "pro"), NewUser().With("plan", "")} {
+		assert.True(t, exists.meet(evalParams{User: user}))
+		assert.False(t, notSet.meet(evalParams{User: user}))
+	}
+	user := NewUser().With("city", "1")
+	assert.False(t, exists.meet(evalParams{User: user}))
+	assert.True(t, notSet.meet(evalParams{User: user}))
+
+	number := Condition{Type: "number", Subject: "age", Predicate: "attribute not set"}
+	assert.True(t, number.meet(evalParams{User: user}))
+	assert.Equal(t, "plan attribute not set", notSet.summary())
+}
+
+func TestSplitRemainder(t *testing.T) {
+	select0 := 0
+	uncovered := &Split{Distribution: [][]Range{{{Lower: 0, Upper: 0}}, {{Lower: 0, Upper: 0}}}}
+	toggle := Toggle{
+		Key:          "toggle",
+		Enabled:      true,
+		Version:      1,
+		Rules:        []Rule{{Serve: Serve{Split: uncovered}}},
+		DefaultServe: Serve{Select: &select0},
+		Variations:   []interface{}{"default", "remainder"},
+	}
+	fp := FeatureProbe{Repo: NewRepositoryStore(&Repository{Toggles: map[string]Toggle{"toggle": toggle}})}
+	detail := fp.StrDetail("toggle", NewUser(), "d")
+	assert.Equal(t, "default", detail.Value)
+	assert.Equal(t, "split remainder of rule 0", detail.Reason)
+
+	remainder := 1
+	withRemainder := *uncovered
+	withRemainder.Remainder = &remainder
+	toggle.Rules = []Rule{{Serve: Serve{Split: &withRemainder}}}
+	toggle.Version = 2
+	fp.Repo.Store(&Repository{Toggles: map[string]Toggle{"toggle": toggle}})
+	detail = fp.StrDetail("toggle", NewUser(), "d")
+	assert.Equal(t, "remainder", detail.Value)
+	assert.Equal(t, 0, *detail.RuleIndex)
+
+	toggle.Rules = nil
+	toggle.DefaultServe = Serve{Split: uncovered}
+	toggle.Version = 3
+	fp.Repo.Store(&Repository{Toggles: map[string]Toggle{"toggle": toggle}})
+	detail = fp.StrDetail("toggle", NewUser(), "d")
+	assert.Equal(t, "d", detail.Value)
+	assert.Equal(t, "not find hash_bucket in distribution", detail.Reason)
+}
@@ -0,0 +1,45 @@
+package featureprobe
+
+// Evaluator binds a client to the user of one request, so handlers evaluate
+// toggles without passing the user around. The zero Evaluator returns the
+// defaults, which lets integrations hand one out when no client is set up.
+type Evaluator struct {
+	client FPClient
+	user   FPUser
+}
+
+func NewEvaluator(client FPClient, user FPUser) Evaluator {
+	return Evaluator{client: client, user: user}
+}
+
+func (e Evaluator) User() FPUser {
+	return e.user
+}
+
+func (e Evaluator) BoolValue(toggle string, defaultValue bool) bool {
+	if e.client == nil {
+		return defaultValue
+	}
+	return e.client.BoolValue(toggle, e.user, defaultValue)
+}
+
+func (e Evaluator) StrValue(toggle string, defaultValue string) string {
+	if e.client == nil {
+		return defaultValue
+	}
+	return e.client.StrValue(toggle, e.user, defaultValue)
+}
+
+func (e Evaluator) NumberValue(toggle string, defaultValue float64) float64 {
+	if e.client == nil {
+		return defaultValue
+	}
+	return e.client.NumberValue(toggle, e.user, defaultValue)
+}
+
+func (e Evaluator) JsonValue(toggle string, defaultValue interface{}) interface{} {
+	if e.client == nil {
+		return defaultValue
+	}
+	return e.client.JsonValue(toggle, e.user, defaultValue)
+}
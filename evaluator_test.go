@@ -0,0 +1,27 @@
+package featureprobe
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEvaluator(t *testing.T) {
+	fp := NewFeatureProbeForTest(map[string]interface{}{"bool": true, "str": "s", "num": 1.5, "json": "j"})
+	user := NewUser().StableRollout("user1")
+	e := NewEvaluator(&fp, user)
+
+	assert.Equal(t, "user1", e.User().Key())
+	assert.True(t, e.BoolValue("bool", false))
+	assert.Equal(t, "s", e.StrValue("str", ""))
+	assert.Equal(t, 1.5, e.NumberValue("num", 0))
+	assert.Equal(t, "j", e.JsonValue("json", nil))
+}
+
+func TestZeroEvaluator(t *testing.T) {
+	var e Evaluator
+	assert.True(t, e.BoolValue("bool", true))
+	assert.Equal(t, "d", e.StrValue("str", "d"))
+	assert.Equal(t, 2.0, e.NumberValue("num", 2))
+	assert.Equal(t, "d", e.JsonValue("json", "d"))
+}
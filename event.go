@@ -2,26 +2,75 @@ package featureprobe
 
 import (
 	"bytes"
-	"encoding/json"
+	"context"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"net/http"
+	"runtime"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
+// DefaultMaxCounters bounds the distinct counters built per flush. Events past
+// it are summed into a single counter under OverflowCounterKey.
+const (
+	DefaultMaxCounters = 10000
+	OverflowCounterKey = "__overflow__"
+)
+
+// DefaultMaxEventPayloadBytes is the default size above which a flush is
+// split into several requests.
+const DefaultMaxEventPayloadBytes = 1 << 20
+
+// maxRetainedEvents bounds the individual events kept across failed flushes;
+// the oldest are dropped first. Counters are always kept, merged.
+const maxRetainedEvents = 10000
+
 type EventRecorder struct {
-	auth           string
-	eventsUrl      string
-	flushInterval  time.Duration
-	incomingEvents []AccessEvent
-	packedData     []PackedData
-	httpClient     http.Client
-	mu             sync.Mutex
-	wg             sync.WaitGroup
-	startOnce      sync.Once
-	stopOnce       sync.Once
-	stopChan       chan struct{}
-	ticker         *time.Ticker
+	// first field, so it is 64-bit aligned for atomic access
+	overflowedEvents uint64
+	// interval is the flush interval in milliseconds set by UpdateConfig, 0
+	// until then.
+	interval      int64
+	auth          AuthProvider
+	eventsUrls    *endpoints
+	flushInterval time.Duration
+	shards        []eventShard
+	shardsOnce    sync.Once
+	log           *logger
+	nextShard     uint32
+	maxCounters   int
+	// packedData holds what failed flushes could not send, retried with
+	// the next flush, guarded by flushMu.
+	packedData   []PackedData
+	flushMu      sync.Mutex
+	httpClient   http.Client
+	wg           sync.WaitGroup
+	startOnce    sync.Once
+	stopOnce     sync.Once
+	stopChan     chan struct{}
+	intervalChan chan time.Duration
+	ticker       Ticker
+	clock        Clock
+	// summariesOnly drops the individual access events from flushes,
+	// sending only the access counters.
+	summariesOnly bool
+	paused        int32
+	offline       int32
+	quietHours    *QuietHours
+	metadata      SdkMetadata
+	signer        *requestSigner
+	// maxPayloadBytes is the encoded size a flush request stays under,
+	// unless a single event or counted toggle exceeds it.
+	maxPayloadBytes int
+	// summaryWindow is the length in milliseconds of the aligned windows
+	// accesses are counted in, or 0 to count each flush as one.
+	summaryWindow int64
+	sinks         *sinkForwarder
+	codec         *JSONCodec
 }
 
 type AccessEvent struct {
@@ -36,6 +85,7 @@ type AccessEvent struct {
 type PackedData struct {
 	Events []AccessEvent `json:"events"`
 	Access Access        `json:"access"`
+	Sdk    SdkMetadata   `json:"sdk"`
 }
 
 type Access struct {
@@ -62,31 +112,116 @@ type CountValue struct {
 	Value interface{} `json:"value"`
 }
 
+// eventsPool and bodyPool recycle the event buffers swapped out on flush and
+// the buffers their payload is encoded into, so a busy recorder does not
+// produce a new slice and body on every flush.
+var (
+	eventsPool = sync.Pool{New: func() interface{} {
+		events := make([]AccessEvent, 0, 64)
+		return &events
+	}}
+	bodyPool = sync.Pool{New: func() interface{} {
+		return new(bytes.Buffer)
+	}}
+)
+
+func getEvents() []AccessEvent {
+	return (*eventsPool.Get().(*[]AccessEvent))[:0]
+}
+
+func putEvents(events []AccessEvent) {
+	for i := range events {
+		events[i] = AccessEvent{}
+	}
+	events = events[:0]
+	eventsPool.Put(&events)
+}
+
+// eventRefs holds the index and version pointers shared by the access events
+// of one toggle version, so recording an event does not allocate them. They
+// are only read when events are encoded.
+type eventRefs struct {
+	version uint64
+	indexes []int
+}
+
+func newEventRefs(t *Toggle) *eventRefs {
+	refs := &eventRefs{version: t.Version, indexes: make([]int, len(t.Variations))}
+	for i := range refs.indexes {
+		refs.indexes[i] = i
+	}
+	return refs
+}
+
+func (r *eventRefs) index(i int) *int {
+	if i < 0 || i >= len(r.indexes) {
+		return nil
+	}
+	return &r.indexes[i]
+}
+
+func (r evalResult) eventIndex() *int {
+	if r.refs != nil {
+		return r.refs.index(r.variationIndex)
+	}
+	return intPtr(r.variationIndex)
+}
+
+func (r evalResult) eventVersion() *uint64 {
+	if r.refs != nil {
+		return &r.refs.version
+	}
+	return r.versionPtr()
+}
+
+// eventShard is one of the buffers RecordAccess spreads events over, so
+// parallel evaluations don't all wait on a single lock. The padding keeps
+// shards on separate cache lines.
+type eventShard struct {
+	mu     sync.Mutex
+	events []AccessEvent
+	_      [32]byte
+}
+
 func NewEventRecorder(eventsUrl string, flushInterval time.Duration, auth string) EventRecorder {
 	return EventRecorder{
-		auth:           auth,
-		eventsUrl:      eventsUrl,
-		flushInterval:  flushInterval,
-		incomingEvents: []AccessEvent{},
-		packedData:     []PackedData{},
-		httpClient:     newHttpClient(flushInterval),
-		stopChan:       make(chan struct{}),
+		auth:            staticAuth(auth),
+		eventsUrls:      newEndpoints([]string{eventsUrl}, nil),
+		flushInterval:   flushInterval,
+		shards:          make([]eventShard, runtime.GOMAXPROCS(0)),
+		maxCounters:     DefaultMaxCounters,
+		packedData:      []PackedData{},
+		httpClient:      newHttpClient(flushInterval),
+		stopChan:        make(chan struct{}),
+		intervalChan:    make(chan time.Duration, 1),
+		clock:           systemClock{},
+		metadata:        newSdkMetadata(),
+		maxPayloadBytes: DefaultMaxEventPayloadBytes,
 	}
 }
 
 func (e *EventRecorder) Start() {
 	e.wg.Add(1)
 	e.startOnce.Do(func() {
-		e.ticker = time.NewTicker(e.flushInterval * time.Millisecond)
+		e.ticker = e.clock.NewTicker(e.flushInterval * time.Millisecond)
 		go func() {
 			for {
 				select {
 				case <-e.stopChan:
 					e.doFlush()
+					if atomic.LoadInt32(&e.offline) == 1 {
+						e.discardHeld()
+					}
+					e.ticker.Stop()
 					e.wg.Done()
 					return
-				case <-e.ticker.C:
-					e.doFlush()
+				case interval := <-e.intervalChan:
+					e.ticker.Stop()
+					e.ticker = e.clock.NewTicker(interval * time.Millisecond)
+				case <-e.ticker.C():
+					if !e.holding() {
+						e.doFlush()
+					}
 				}
 			}
 		}()
@@ -94,33 +229,227 @@ func (e *EventRecorder) Start() {
 }
 
 func (e *EventRecorder) doFlush() {
-	events := make([]AccessEvent, 0)
-	e.mu.Lock()
-	events, e.incomingEvents = e.incomingEvents, events
-	e.mu.Unlock()
-	if len(events) == 0 {
-		return
+	if err := e.flush(context.Background()); err != nil {
+		e.log.errorf("Report event fails: %s", err)
 	}
-	packedData := e.buildPackedData(events)
-	body, _ := json.Marshal(packedData)
-	req, err := http.NewRequest(http.MethodPost, e.eventsUrl, bytes.NewBuffer(body))
-	if err != nil {
-		fmt.Printf("%s\n", err)
-		return
+}
+
+func (e *EventRecorder) flush(ctx context.Context) error {
+	e.flushMu.Lock()
+	defer e.flushMu.Unlock()
+	if atomic.LoadInt32(&e.offline) == 1 {
+		e.hold()
+		return nil
+	}
+	if err := e.sinks.flush(ctx); err != nil {
+		e.log.errorf("Forward events fails: %s", err)
+	}
+	events := e.takeEvents()
+	if len(events) == 0 && len(e.packedData) == 0 {
+		putEvents(events)
+		return nil
+	}
+	batches := e.packedData
+	if len(events) > 0 {
+		batches = append(batches, e.buildPackedData(events)...)
+	}
+	pending := e.mergeWindows(batches)
+	var rejected error
+	for len(pending) > 0 {
+		body := bodyPool.Get().(*bytes.Buffer)
+		body.Reset()
+		if err := e.codec.encode(body, []PackedData{pending[0]}); err != nil {
+			bodyPool.Put(body)
+			putEvents(events)
+			return err
+		}
+		if e.maxPayloadBytes > 0 && body.Len() > e.maxPayloadBytes {
+			if first, second, ok := splitPackedData(pending[0]); ok {
+				bodyPool.Put(body)
+				pending = append([]PackedData{first, second}, pending[1:]...)
+				continue
+			}
+		}
+		if retry, err := e.post(ctx, body); err != nil {
+			if retry {
+				e.retain(pending...)
+				putEvents(events)
+				return err
+			}
+			rejected = err
+		}
+		pending = pending[1:]
+	}
+	e.packedData = nil
+	putEvents(events)
+	return rejected
+}
+
+// hold keeps the events recorded while offline with those not sent yet, for
+// the first flush back online. Like failed flushes, it keeps at most
+// maxRetainedEvents events. Must be called with flushMu held.
+func (e *EventRecorder) hold() {
+	events := e.takeEvents()
+	if len(events) > 0 {
+		e.retain(e.mergeWindows(append(e.packedData, e.buildPackedData(events)...))...)
 	}
-	req.Header.Add("Authorization", e.auth)
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Add("User-Agent", USER_AGENT)
-	_, err = e.httpClient.Do(req)
+	putEvents(events)
+}
+
+// discardHeld reports the events held offline that a stopping recorder
+// never sends.
+func (e *EventRecorder) discardHeld() {
+	e.flushMu.Lock()
+	defer e.flushMu.Unlock()
+	held := 0
+	for _, p := range e.packedData {
+		held += len(p.Events)
+	}
+	if len(e.packedData) > 0 {
+		e.log.errorf("closed offline, %d events and their counters not reported", held)
+	}
+	e.packedData = nil
+}
+
+// post sends one encoded payload. On failure, retry reports whether sending
+// it again may succeed, as after 5xx and 429 but not other non-2xx answers.
+func (e *EventRecorder) post(ctx context.Context, body *bytes.Buffer) (retry bool, err error) {
+	resp, err := sendAuthorized(e.auth, func(authorization string) (*http.Response, error) {
+		return e.eventsUrls.do(&e.httpClient, func(url string) (*http.Request, error) {
+			req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body.Bytes()))
+			if err != nil {
+				return nil, err
+			}
+			req.Header.Add("Authorization", authorization)
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Add("User-Agent", USER_AGENT)
+			e.signer.sign(req, body.Bytes())
+			return req, nil
+		})
+	})
 	if err != nil {
-		fmt.Printf("Report event fails: %s\n", err)
+		// the transport may still be reading the body, so it is not reused
+		return true, err
+	}
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+	_ = resp.Body.Close()
+	bodyPool.Put(body)
+	switch {
+	case resp.StatusCode >= http.StatusInternalServerError || resp.StatusCode == http.StatusTooManyRequests:
+		return true, fmt.Errorf("report events fails: %s", resp.Status)
+	case resp.StatusCode < 200 || resp.StatusCode >= 300:
+		return false, fmt.Errorf("report events fails: %s, events dropped", resp.Status)
+	}
+	return false, nil
+}
+
+// splitPackedData halves the events and the counted toggles of p, for
+// payloads over the size limit. It fails when p has a single event and a
+// single counted toggle.
+func splitPackedData(p PackedData) (PackedData, PackedData, bool) {
+	if len(p.Events) < 2 && len(p.Access.Counters) < 2 {
+		return p, p, false
+	}
+	first, second := p, p
+	half := len(p.Events) / 2
+	first.Events, second.Events = p.Events[:half:half], p.Events[half:]
+	keys := make([]string, 0, len(p.Access.Counters))
+	for key := range p.Access.Counters {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	first.Access.Counters = map[string][]ToggleCounter{}
+	second.Access.Counters = map[string][]ToggleCounter{}
+	for i, key := range keys {
+		if i < (len(keys)+1)/2 {
+			first.Access.Counters[key] = p.Access.Counters[key]
+		} else {
+			second.Access.Counters[key] = p.Access.Counters[key]
+		}
 	}
+	return first, second, true
 }
 
+// retain keeps batches that could not be sent, so they are merged into the
+// next flush, dropping the oldest events over maxRetainedEvents. Events are
+// copied as the buffers they come from are pooled.
+func (e *EventRecorder) retain(batches ...PackedData) {
+	excess := -maxRetainedEvents
+	for _, p := range batches {
+		excess += len(p.Events)
+	}
+	if excess > 0 {
+		e.log.errorf("more than %d events not reported, %d oldest dropped", maxRetainedEvents, excess)
+	}
+	e.packedData = make([]PackedData, len(batches))
+	for i, p := range batches {
+		drop := 0
+		if excess > 0 {
+			drop = excess
+			if drop > len(p.Events) {
+				drop = len(p.Events)
+			}
+			excess -= drop
+		}
+		p.Events = append([]AccessEvent(nil), p.Events[drop:]...)
+		e.packedData[i] = p
+	}
+}
+
+// buildPackedData returns one batch, or one per summary window, in order.
 func (e *EventRecorder) buildPackedData(events []AccessEvent) []PackedData {
+	if e.summaryWindow <= 0 {
+		return []PackedData{e.packWindow(events, nil)}
+	}
+	windows := map[int64][]AccessEvent{}
+	starts := []int64{}
+	for _, event := range events {
+		start := event.Time - event.Time%e.summaryWindow
+		if _, ok := windows[start]; !ok {
+			starts = append(starts, start)
+		}
+		windows[start] = append(windows[start], event)
+	}
+	sort.Slice(starts, func(i, j int) bool { return starts[i] < starts[j] })
+	batches := make([]PackedData, len(starts))
+	for i, start := range starts {
+		batches[i] = e.packWindow(windows[start], &start)
+	}
+	return batches
+}
+
+// packWindow spans the window starting at start, or events when it is nil.
+func (e *EventRecorder) packWindow(events []AccessEvent, start *int64) PackedData {
 	access := e.buildAccess(events)
-	p := PackedData{Access: access, Events: events}
-	return []PackedData{p}
+	if start != nil {
+		access.StartTime, access.EndTime = *start, *start+e.summaryWindow-1
+	}
+	if e.summariesOnly {
+		events = []AccessEvent{}
+	}
+	return PackedData{Access: access, Events: events, Sdk: e.metadata}
+}
+
+// mergeWindows merges batches into one, or into one per summary window.
+func (e *EventRecorder) mergeWindows(batches []PackedData) []PackedData {
+	if e.summaryWindow <= 0 {
+		return []PackedData{mergePackedData(batches)}
+	}
+	windows := map[int64][]PackedData{}
+	starts := []int64{}
+	for _, batch := range batches {
+		start := batch.Access.StartTime
+		if _, ok := windows[start]; !ok {
+			starts = append(starts, start)
+		}
+		windows[start] = append(windows[start], batch)
+	}
+	sort.Slice(starts, func(i, j int) bool { return starts[i] < starts[j] })
+	merged := make([]PackedData, len(starts))
+	for i, start := range starts {
+		merged[i] = mergePackedData(windows[start])
+	}
+	return merged
 }
 
 func (e *EventRecorder) buildAccess(events []AccessEvent) Access {
@@ -148,34 +477,158 @@ func (e *EventRecorder) buildAccess(events []AccessEvent) Access {
 	return access
 }
 
+// mergePackedData merges batches into one, adding up the counters of the
+// same toggle, version and variation, and spanning all of their windows.
+func mergePackedData(batches []PackedData) PackedData {
+	if len(batches) == 1 {
+		return batches[0]
+	}
+	merged := PackedData{
+		Events: []AccessEvent{},
+		Access: Access{Counters: map[string][]ToggleCounter{}},
+	}
+	first := true
+	for _, batch := range batches {
+		merged.Events = append(merged.Events, batch.Events...)
+		merged.Sdk = batch.Sdk
+		if len(batch.Access.Counters) == 0 {
+			continue
+		}
+		if first || batch.Access.StartTime < merged.Access.StartTime {
+			merged.Access.StartTime = batch.Access.StartTime
+		}
+		if first || batch.Access.EndTime > merged.Access.EndTime {
+			merged.Access.EndTime = batch.Access.EndTime
+		}
+		first = false
+		for key, counters := range batch.Access.Counters {
+			merged.Access.Counters[key] = mergeCounters(merged.Access.Counters[key], counters)
+		}
+	}
+	return merged
+}
+
+func mergeCounters(into []ToggleCounter, counters []ToggleCounter) []ToggleCounter {
+	for _, counter := range counters {
+		found := false
+		for i := range into {
+			if sameInt(into[i].Index, counter.Index) && sameUint64(into[i].Version, counter.Version) {
+				into[i].Count += counter.Count
+				found = true
+				break
+			}
+		}
+		if !found {
+			into = append(into, counter)
+		}
+	}
+	return into
+}
+
+func sameInt(a, b *int) bool {
+	return a == b || (a != nil && b != nil && *a == *b)
+}
+
+func sameUint64(a, b *uint64) bool {
+	return a == b || (a != nil && b != nil && *a == *b)
+}
+
 func (e *EventRecorder) buildCounters(events []AccessEvent) (map[Variation]CountValue, int64, int64) {
-	var startTime *int64 = nil
-	var endTime *int64 = nil
+	var startTime, endTime int64
 	counters := map[Variation]CountValue{}
+	overflowed := uint64(0)
+	defer func() {
+		if overflowed > 0 {
+			atomic.AddUint64(&e.overflowedEvents, overflowed)
+			e.log.errorf("event counters exceed %d, %d events counted as %s", e.maxCounters, overflowed, OverflowCounterKey)
+		}
+	}()
 
-	for _, event := range events {
-		if startTime == nil || *startTime < event.Time {
-			startTime = &event.Time
+	for i, event := range events {
+		if i == 0 || event.Time < startTime {
+			startTime = event.Time
 		}
-		if endTime == nil || *endTime > event.Time {
-			endTime = &event.Time
+		if i == 0 || event.Time > endTime {
+			endTime = event.Time
 		}
 
 		v := Variation{Key: event.Key, Version: event.Version, Index: event.Index}
 		c, ok := counters[v]
+		if !ok && len(counters) >= e.maxCounters {
+			v = Variation{Key: OverflowCounterKey}
+			c, ok = counters[v]
+			overflowed++
+		}
 		if !ok {
 			counters[v] = CountValue{Count: 1, Value: event.Value}
 		} else {
 			c.Count += 1
+			counters[v] = c
 		}
 	}
-	return counters, *startTime, *endTime
+	return counters, startTime, endTime
+}
+
+// OverflowedEvents is the number of events counted under OverflowCounterKey
+// since the recorder was created.
+func (e *EventRecorder) OverflowedEvents() uint64 {
+	return atomic.LoadUint64(&e.overflowedEvents)
 }
 
 func (e *EventRecorder) RecordAccess(event AccessEvent) {
-	e.mu.Lock()
-	e.incomingEvents = append(e.incomingEvents, event)
-	e.mu.Unlock()
+	shard := e.shard()
+	shard.mu.Lock()
+	shard.events = append(shard.events, event)
+	shard.mu.Unlock()
+}
+
+// RecordAccessBatch records events under a single lock, for callers that
+// evaluate many toggles at once.
+func (e *EventRecorder) RecordAccessBatch(events []AccessEvent) {
+	if len(events) == 0 {
+		return
+	}
+	shard := e.shard()
+	shard.mu.Lock()
+	shard.events = append(shard.events, events...)
+	shard.mu.Unlock()
+}
+
+// eventShards returns the shards, giving a zero-value recorder a single one.
+func (e *EventRecorder) eventShards() []eventShard {
+	e.shardsOnce.Do(func() {
+		if len(e.shards) == 0 {
+			e.shards = make([]eventShard, 1)
+		}
+	})
+	return e.shards
+}
+
+// shard picks the shard of the next recorded events.
+func (e *EventRecorder) shard() *eventShard {
+	shards := e.eventShards()
+	n := atomic.AddUint32(&e.nextShard, 1)
+	return &shards[n%uint32(len(shards))]
+}
+
+// takeEvents drains every shard and merges their events.
+func (e *EventRecorder) takeEvents() []AccessEvent {
+	events := getEvents()
+	shards := e.eventShards()
+	for i := range shards {
+		shard := &shards[i]
+		shard.mu.Lock()
+		pending := shard.events
+		if len(pending) > 0 {
+			shard.events = getEvents()
+		}
+		shard.mu.Unlock()
+		if len(pending) > 0 {
+			events = append(events, pending...)
+			putEvents(pending)
+		}
+	}
+	return events
 }
 
 func (e *EventRecorder) Stop() {
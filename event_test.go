@@ -1,6 +1,13 @@
 package featureprobe
 
 import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
 	"testing"
 	"time"
 
@@ -119,3 +126,315 @@ func TestCloseEvent(t *testing.T) {
 	assert.Equal(t, 1, count)
 	defer httpmock.DeactivateAndReset()
 }
+
+func TestBuildCountersSharedRefs(t *testing.T) {
+	recorder := NewEventRecorder("", 1000, "sdk_key")
+	refs := newEventRefs(&Toggle{Version: 3, Variations: []interface{}{"a", "b"}})
+	events := []AccessEvent{
+		{Time: 1, Key: "toggle", Value: "b", Index: refs.index(1), Version: &refs.version},
+		{Time: 2, Key: "toggle", Value: "b", Index: refs.index(1), Version: &refs.version},
+		{Time: 3, Key: "toggle", Value: "a", Index: refs.index(0), Version: &refs.version},
+	}
+
+	access := recorder.buildAccess(events)
+	counters := access.Counters["toggle"]
+	assert.Len(t, counters, 2)
+	total := 0
+	for _, c := range counters {
+		total += c.Count
+		if *c.Index == 1 {
+			assert.Equal(t, 2, c.Count)
+		}
+	}
+	assert.Equal(t, 3, total)
+	assert.Nil(t, refs.index(2))
+}
+
+func TestFlushPostsPooledBody(t *testing.T) {
+	server := NewMockServer(Repository{})
+	defer server.Close()
+	recorder := NewEventRecorder(server.URL()+"api/events", 1000, "sdk_key")
+
+	for round := 0; round < 2; round++ {
+		recorder.RecordAccess(AccessEvent{Time: 1, Key: "toggle", Value: true, Reason: "default"})
+		recorder.doFlush()
+	}
+
+	events := server.Events()
+	assert.Len(t, events, 2)
+	for _, packed := range events {
+		assert.Len(t, packed.Events, 1)
+		assert.Equal(t, "toggle", packed.Events[0].Key)
+	}
+}
+
+func BenchmarkRecordAccessAndFlush(b *testing.B) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(ioutil.Discard, r.Body)
+	}))
+	defer server.Close()
+	recorder := NewEventRecorder(server.URL, 1000, "sdk_key")
+	refs := newEventRefs(&Toggle{Version: 1, Variations: []interface{}{true, false}})
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		recorder.RecordAccess(AccessEvent{
+			Time:    int64(i),
+			Key:     "toggle",
+			Value:   true,
+			Index:   refs.index(0),
+			Version: &refs.version,
+			Reason:  "default",
+		})
+		// 100k evaluations per second flushed every second
+		if i%100000 == 99999 {
+			recorder.doFlush()
+		}
+	}
+}
+
+func TestRecordAccessShards(t *testing.T) {
+	recorder := NewEventRecorder("", 1000, "sdk_key")
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				recorder.RecordAccess(AccessEvent{Time: int64(i), Key: "toggle"})
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Len(t, recorder.takeEvents(), 800)
+	assert.Len(t, recorder.takeEvents(), 0)
+}
+
+func BenchmarkRecordAccessParallel(b *testing.B) {
+	recorder := NewEventRecorder("", 1000, "sdk_key")
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			recorder.RecordAccess(AccessEvent{Key: "toggle", Reason: "default"})
+		}
+	})
+}
+
+func TestRecordAccessBatch(t *testing.T) {
+	recorder := NewEventRecorder("", 1000, "sdk_key")
+	recorder.RecordAccessBatch(nil)
+	recorder.RecordAccessBatch([]AccessEvent{
+		{Time: 1, Key: "toggle1"},
+		{Time: 2, Key: "toggle2"},
+	})
+	recorder.RecordAccess(AccessEvent{Time: 3, Key: "toggle3"})
+
+	events := recorder.takeEvents()
+	assert.Len(t, events, 3)
+}
+
+func BenchmarkRecordAccessBatch(b *testing.B) {
+	recorder := NewEventRecorder("", 1000, "sdk_key")
+	batch := make([]AccessEvent, 20)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		recorder.RecordAccessBatch(batch)
+		if i%1000 == 999 {
+			putEvents(recorder.takeEvents())
+		}
+	}
+}
+
+func TestBuildCountersOverflow(t *testing.T) {
+	recorder := NewEventRecorder("", 1000, "sdk_key")
+	recorder.maxCounters = 2
+	refs := newEventRefs(&Toggle{Version: 1, Variations: []interface{}{"a"}})
+	events := []AccessEvent{}
+	for _, key := range []string{"t1", "t2", "t3", "t4", "t1"} {
+		events = append(events, AccessEvent{Time: 1, Key: key, Value: "a", Index: refs.index(0), Version: &refs.version})
+	}
+
+	counters, _, _ := recorder.buildCounters(events)
+	assert.Len(t, counters, 3)
+	assert.Equal(t, 2, counters[Variation{Key: "t1", Index: refs.index(0), Version: &refs.version}].Count)
+	assert.Equal(t, 2, counters[Variation{Key: OverflowCounterKey}].Count)
+	assert.Equal(t, uint64(2), recorder.OverflowedEvents())
+}
+
+func TestFlushSummariesOnly(t *testing.T) {
+	server := NewMockServer(Repository{})
+	defer server.Close()
+	fp, _ := NewFeatureProbe(server.URL(), "sdk_key", WithTelemetry(false), WithServerless(true))
+	fp.Recorder.RecordAccess(AccessEvent{Time: 1, Key: "toggle", Value: true, Reason: "default"})
+	assert.Nil(t, fp.FlushAtEnd(context.Background()))
+
+	events := server.Events()
+	assert.Len(t, events, 1)
+	assert.Empty(t, events[0].Events)
+	assert.Equal(t, 1, events[0].Access.Counters["toggle"][0].Count)
+}
+
+func TestFlushRetainsFailedBatch(t *testing.T) {
+	server := NewMockServer(Repository{})
+	defer server.Close()
+	fp, _ := NewFeatureProbe(server.URL(), "sdk_key", WithServerless(true))
+	version := uint64(1)
+	index := 0
+	event := AccessEvent{Time: 1, Key: "toggle", Value: true, Index: &index, Version: &version, Reason: "default"}
+
+	server.FailEvents(http.StatusServiceUnavailable, 1)
+	fp.Recorder.RecordAccess(event)
+	assert.NotNil(t, fp.FlushAtEnd(context.Background()))
+	assert.Empty(t, server.Events())
+
+	event.Time = 5
+	fp.Recorder.RecordAccess(event)
+	other := 1
+	fp.Recorder.RecordAccess(AccessEvent{Time: 5, Key: "toggle", Value: false, Index: &other, Version: &version})
+	assert.Nil(t, fp.FlushAtEnd(context.Background()))
+
+	events := server.Events()
+	assert.Len(t, events, 1)
+	assert.Len(t, events[0].Events, 3)
+	counters := events[0].Access.Counters["toggle"]
+	assert.Len(t, counters, 2)
+	for _, c := range counters {
+		if *c.Index == 0 {
+			assert.Equal(t, 2, c.Count)
+		} else {
+			assert.Equal(t, 1, c.Count)
+		}
+	}
+	assert.Equal(t, int64(1), events[0].Access.StartTime)
+	assert.Equal(t, int64(5), events[0].Access.EndTime)
+
+	assert.Nil(t, fp.FlushAtEnd(context.Background()))
+	assert.Len(t, server.Events(), 1)
+}
+
+func TestFlushDropsRejectedBatch(t *testing.T) {
+	server := NewMockServer(Repository{})
+	defer server.Close()
+	fp, _ := NewFeatureProbe(server.URL(), "sdk_key", WithServerless(true))
+	version := uint64(1)
+	index := 0
+
+	server.FailEvents(http.StatusRequestEntityTooLarge, 1)
+	fp.Recorder.RecordAccess(AccessEvent{Time: 1, Key: "toggle", Value: true, Index: &index, Version: &version})
+	err := fp.FlushAtEnd(context.Background())
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "413")
+	assert.Empty(t, fp.Recorder.packedData)
+
+	assert.Nil(t, fp.FlushAtEnd(context.Background()))
+	assert.Empty(t, server.Events())
+}
+
+func TestRetainBoundsEvents(t *testing.T) {
+	recorder := NewEventRecorder("", 1000, "sdk_key")
+	events := make([]AccessEvent, maxRetainedEvents+5)
+	for i := range events {
+		events[i].Time = int64(i)
+	}
+	recorder.retain(PackedData{Events: events})
+	assert.Len(t, recorder.packedData[0].Events, maxRetainedEvents)
+	assert.Equal(t, int64(5), recorder.packedData[0].Events[0].Time)
+}
+
+func TestFlushSplitsLargePayloads(t *testing.T) {
+	server := NewMockServer(Repository{})
+	defer server.Close()
+	fp, _ := NewFeatureProbe(server.URL(), "sdk_key", WithServerless(true), WithMaxEventPayloadSize(2048))
+	version := uint64(1)
+	for i := 0; i < 40; i++ {
+		index := i % 2
+		fp.Recorder.RecordAccess(AccessEvent{Time: 1, Key: fmt.Sprintf("toggle_%d", i%8), Value: true, Index: &index, Version: &version})
+	}
+	assert.Nil(t, fp.FlushAtEnd(context.Background()))
+
+	events := server.Events()
+	assert.True(t, len(events) > 1)
+	sent, counted := 0, 0
+	for _, p := range events {
+		sent += len(p.Events)
+		for _, counters := range p.Access.Counters {
+			for _, c := range counters {
+				counted += c.Count
+			}
+		}
+	}
+	assert.Equal(t, 40, sent)
+	assert.Equal(t, 40, counted)
+}
+
+func TestSplitPackedData(t *testing.T) {
+	single := PackedData{Events: []AccessEvent{{Key: "a"}}, Access: Access{Counters: map[string][]ToggleCounter{"a": {{Count: 1}}}}}
+	_, _, ok := splitPackedData(single)
+	assert.False(t, ok)
+
+	single.Access.Counters["b"] = []ToggleCounter{{Count: 2}}
+	first, second, ok := splitPackedData(single)
+	assert.True(t, ok)
+	assert.Len(t, first.Events, 0)
+	assert.Len(t, second.Events, 1)
+	assert.Contains(t, first.Access.Counters, "a")
+	assert.Contains(t, second.Access.Counters, "b")
+}
+
+func TestBuildAccessSpansEvents(t *testing.T) {
+	recorder := NewEventRecorder("", 1000, "sdk_key")
+	access := recorder.buildAccess([]AccessEvent{{Time: 3, Key: "a"}, {Time: 1, Key: "a"}, {Time: 7, Key: "b"}, {Time: 2, Key: "b"}})
+	assert.Equal(t, int64(1), access.StartTime)
+	assert.Equal(t, int64(7), access.EndTime)
+}
+
+func TestSummaryWindow(t *testing.T) {
+	server := NewMockServer(Repository{})
+	defer server.Close()
+	fp, _ := NewFeatureProbe(server.URL(), "sdk_key", WithServerless(true), WithSummaryWindow(time.Minute))
+	version := uint64(1)
+	index := 0
+	record := func(ms int64) {
+		fp.Recorder.RecordAccess(AccessEvent{Time: ms, Key: "toggle", Value: true, Index: &index, Version: &version})
+	}
+
+	server.FailEvents(http.StatusServiceUnavailable, 1)
+	record(125000)
+	record(61000)
+	assert.NotNil(t, fp.FlushAtEnd(context.Background()))
+	record(65000)
+	record(119999)
+	assert.Nil(t, fp.FlushAtEnd(context.Background()))
+
+	events := server.Events()
+	assert.Len(t, events, 2)
+	assert.Equal(t, int64(60000), events[0].Access.StartTime)
+	assert.Equal(t, int64(119999), events[0].Access.EndTime)
+	assert.Equal(t, 3, events[0].Access.Counters["toggle"][0].Count)
+	assert.Len(t, events[0].Events, 3)
+	assert.Equal(t, int64(120000), events[1].Access.StartTime)
+	assert.Equal(t, int64(179999), events[1].Access.EndTime)
+	assert.Equal(t, 1, events[1].Access.Counters["toggle"][0].Count)
+}
+
+func TestRetainBoundsEventsAcrossBatches(t *testing.T) {
+	recorder := NewEventRecorder("", 1000, "sdk_key")
+	older := make([]AccessEvent, 10)
+	newer := make([]AccessEvent, maxRetainedEvents-5)
+	recorder.retain(PackedData{Events: older}, PackedData{Events: newer})
+	assert.Len(t, recorder.packedData, 2)
+	assert.Len(t, recorder.packedData[0].Events, 5)
+	assert.Len(t, recorder.packedData[1].Events, maxRetainedEvents-5)
+}
+
+func TestZeroValueEventRecorder(t *testing.T) {
+	var recorder EventRecorder
+	recorder.RecordAccess(AccessEvent{Key: "toggle"})
+	recorder.RecordAccessBatch([]AccessEvent{{Key: "other"}})
+	events := recorder.takeEvents()
+	assert.Len(t, events, 2)
+}
@@ -0,0 +1,104 @@
+package featureprobe
+
+import (
+	"fmt"
+	"sort"
+	"sync/atomic"
+)
+
+// ValueType is the type an application reads a toggle as.
+type ValueType int
+
+const (
+	ValueJson ValueType = iota
+	ValueBool
+	ValueString
+	ValueNumber
+)
+
+func (v ValueType) String() string {
+	switch v {
+	case ValueBool:
+		return "bool"
+	case ValueString:
+		return "string"
+	case ValueNumber:
+		return "number"
+	}
+	return "json"
+}
+
+// ExpectationError reports a toggle registered with WithExpectedToggles that
+// is missing from the synced repository, or whose variations are not of the
+// expected type.
+type ExpectationError struct {
+	Toggle   string
+	Missing  bool
+	Expected ValueType
+	Actual   ValueType
+}
+
+func (e ExpectationError) Error() string {
+	if e.Missing {
+		return "expected toggle " + e.Toggle + " not exist"
+	}
+	return fmt.Sprintf("expected toggle %s to be %s, but it is %s", e.Toggle, e.Expected, e.Actual)
+}
+
+// WithExpectedToggles registers the toggles the application evaluates and the
+// type it reads each as. Toggles missing from the first synced repository,
+// and every sync changing one to another type, are reported to the error
+// listener, catching drift between code and console early.
+func WithExpectedToggles(expected map[string]ValueType) Option {
+	return func(fpConfig *FPConfig) {
+		if fpConfig.ExpectedToggles == nil {
+			fpConfig.ExpectedToggles = map[string]ValueType{}
+		}
+		for k, v := range expected {
+			fpConfig.ExpectedToggles[k] = v
+		}
+	}
+}
+
+// checkExpected returns the errors of expected toggles missing from repo,
+// sorted by toggle. Types are checked by typeChecker.
+func (repo *Repository) checkExpected(expected map[string]ValueType) []error {
+	keys := make([]string, 0, len(expected))
+	for k := range expected {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var errs []error
+	for _, k := range keys {
+		if _, ok := repo.GetToggle(k); !ok {
+			errs = append(errs, ExpectationError{Toggle: k, Missing: true, Expected: expected[k]})
+		}
+	}
+	return errs
+}
+
+// expectationChecker checks the first repository it is called with.
+func expectationChecker(config FPConfig) func(previous, repo *Repository) {
+	var checked int32
+	return func(previous, repo *Repository) {
+		if !atomic.CompareAndSwapInt32(&checked, 0, 1) {
+			return
+		}
+		for _, err := range repo.checkExpected(config.ExpectedToggles) {
+			config.reportError(err)
+		}
+	}
+}
+
+func valueTypeOf(kind variationKind) ValueType {
+	switch kind {
+	case variationBool:
+		return ValueBool
+	case variationString:
+		return ValueString
+	case variationNumber:
+		return ValueNumber
+	}
+	return ValueJson
+}
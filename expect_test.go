@@ -0,0 +1,42 @@
+package featureprobe
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpectedToggles(t *testing.T) {
+	repo, _ := setup(t)
+	server := NewMockServer(repo)
+	defer server.Close()
+
+	var mu sync.Mutex
+	var errs []error
+	fp, err := NewFeatureProbe(server.URL(), "sdk_key",
+		WithRefreshInterval(50),
+		WithExpectedToggles(map[string]ValueType{
+			"bool_toggle":   ValueBool,
+			"string_toggle": ValueNumber,
+			"json_toggle":   ValueJson,
+			"missing":       ValueString,
+		}),
+		WithErrorListener(func(err error) {
+			mu.Lock()
+			errs = append(errs, err)
+			mu.Unlock()
+		}),
+	)
+	assert.Nil(t, err)
+	defer fp.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []error{
+		ExpectationError{Toggle: "missing", Missing: true, Expected: ValueString},
+		ExpectationError{Toggle: "string_toggle", Expected: ValueNumber, Actual: ValueString},
+	}, errs)
+	assert.Equal(t, "expected toggle missing not exist", errs[0].Error())
+	assert.Equal(t, "expected toggle string_toggle to be number, but it is string", errs[1].Error())
+}
@@ -0,0 +1,181 @@
+package featureprobe
+
+import "strings"
+
+// Explanation traces how a toggle was evaluated for a user: every rule tried,
+// each condition with the user value it was matched against, and the result.
+type Explanation struct {
+	Toggle         string           `json:"toggle"`
+	Version        uint64           `json:"version"`
+	Enabled        bool             `json:"enabled"`
+	Rules          []RuleTrace      `json:"rules"`
+	Value          interface{}      `json:"value"`
+	RuleIndex      *int             `json:"ruleIndex"`
+	VariationIndex *int             `json:"variationIndex"`
+	Reason         string           `json:"reason"`
+	Rule           *MatchedRule     `json:"rule,omitempty"`
+	Segments       []MatchedSegment `json:"segments,omitempty"`
+}
+
+// MatchedRule describes the rule an evaluation matched, readable in logs
+// even after rules are reordered in the console and indexes shift.
+type MatchedRule struct {
+	Index      int      `json:"index"`
+	Conditions []string `json:"conditions"`
+	Serve      Serve    `json:"serve"`
+	// segments are the unique ids of the segments the conditions reference
+	segments []string
+}
+
+// MatchedSegment is a segment referenced by the matched rule, and whether the
+// user is in it.
+type MatchedSegment struct {
+	Key      string `json:"key"`
+	Version  uint64 `json:"version"`
+	Contains bool   `json:"contains"`
+}
+
+// RuleTrace lists the conditions of a rule. Rules after the matching one are
+// not evaluated and not traced.
+type RuleTrace struct {
+	Index      int              `json:"index"`
+	Matched    bool             `json:"matched"`
+	Conditions []ConditionTrace `json:"conditions"`
+}
+
+type ConditionTrace struct {
+	Type      string   `json:"type"`
+	Subject   string   `json:"subject,omitempty"`
+	Predicate string   `json:"predicate"`
+	Objects   []string `json:"objects"`
+	UserValue string   `json:"userValue,omitempty"`
+	Matched   bool     `json:"matched"`
+}
+
+// Explain evaluates toggle for user against the current repository without
+// recording an event, and reports how the result was reached.
+func (fp *FeatureProbe) Explain(toggle string, user FPUser) Explanation {
+	return fp.Repo.Load().Explain(toggle, user.withGlobal(fp.Config.GlobalAttributes), fp.Config.Clock)
+}
+
+func (repo *Repository) Explain(toggle string, user FPUser, clock Clock) Explanation {
+	explanation := Explanation{Toggle: toggle, Rules: []RuleTrace{}}
+	t, ok := repo.GetToggle(toggle)
+	if !ok {
+		explanation.Reason = toggleNotExistReason(toggle)
+		return explanation
+	}
+	params := evalParams{
+		User:       user,
+		Repo:       repo,
+		Variations: t.Variations,
+		Key:        t.Key,
+		Clock:      clock,
+	}
+	explanation.Version = t.Version
+	explanation.Enabled = t.Enabled
+
+	if t.Enabled {
+		for i := range t.Rules {
+			trace := t.Rules[i].trace(i, params)
+			explanation.Rules = append(explanation.Rules, trace)
+			if trace.Matched {
+				break
+			}
+		}
+	}
+
+	result, _ := t.detail(params)
+	explanation.Value = result.value
+	explanation.RuleIndex = result.ruleIndexPtr()
+	explanation.VariationIndex = intPtr(result.variationIndex)
+	explanation.Reason = result.reason
+	explanation.Rule = result.rule
+	explanation.Segments = repo.matchedSegments(result.rule, user, clock, nil)
+	return explanation
+}
+
+func newMatchedRule(index int, r *Rule) *MatchedRule {
+	matched := &MatchedRule{Index: index, Conditions: make([]string, len(r.Conditions)), Serve: r.Serve}
+	seen := map[string]bool{}
+	for i, c := range r.Conditions {
+		matched.Conditions[i] = c.summary()
+		if c.Type != "segment" {
+			continue
+		}
+		for _, id := range c.Objects {
+			if !seen[id] {
+				seen[id] = true
+				matched.segments = append(matched.segments, id)
+			}
+		}
+	}
+	return matched
+}
+
+// matchedSegments only looks segments up for details, keeping it off the
+// evaluation path. Remote results carry their segments.
+func (fp *FeatureProbe) matchedSegments(result evalResult, user FPUser) []MatchedSegment {
+	if result.segments != nil {
+		return result.segments
+	}
+	return result.repo.matchedSegments(result.rule, user, fp.Config.Clock, fp.bigSegments)
+}
+
+func (repo *Repository) matchedSegments(rule *MatchedRule, user FPUser, clock Clock, big *bigSegments) []MatchedSegment {
+	if repo == nil || rule == nil || len(rule.segments) == 0 {
+		return nil
+	}
+	params := evalParams{User: user, Repo: repo, Clock: clock, bigSegments: big}
+	segments := make([]MatchedSegment, 0, len(rule.segments))
+	for _, id := range rule.segments {
+		if s, ok := repo.GetSegment(id); ok {
+			segments = append(segments, MatchedSegment{Key: s.Key, Version: s.Version, Contains: s.contains(params)})
+		}
+	}
+	return segments
+}
+
+// matchedRule returns the rule description built by compile, or builds one
+// for a toggle that was not compiled.
+func (t *Toggle) matchedRule(index int) *MatchedRule {
+	if index < len(t.matchedRules) {
+		return t.matchedRules[index]
+	}
+	return newMatchedRule(index, &t.Rules[index])
+}
+
+// summary reads like "city is one of [1, 2]".
+func (c *Condition) summary() string {
+	parts := make([]string, 0, 3)
+	if c.Subject != "" {
+		parts = append(parts, c.Subject)
+	} else {
+		parts = append(parts, c.Type)
+	}
+	parts = append(parts, c.Predicate)
+	if c.Predicate != attributeExists && c.Predicate != attributeNotSet {
+		parts = append(parts, "["+strings.Join(c.Objects, ", ")+"]")
+	}
+	return strings.Join(parts, " ")
+}
+
+func (r *Rule) trace(index int, params evalParams) RuleTrace {
+	trace := RuleTrace{Index: index, Matched: true, Conditions: []ConditionTrace{}}
+	for i := range r.Conditions {
+		c := &r.Conditions[i]
+		ct := ConditionTrace{
+			Type:      c.Type,
+			Subject:   c.Subject,
+			Predicate: c.Predicate,
+			Objects:   c.Objects,
+			Matched:   c.meet(params),
+		}
+		if c.Type != "segment" {
+			ct.UserValue = params.User.Get(c.Subject)
+		}
+		trace.Matched = trace.Matched && ct.Matched
+		trace.Conditions = append(trace.Conditions, ct)
+	}
+	return trace
+}
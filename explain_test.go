@@ -0,0 +1,74 @@
+package featureprobe
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExplain(t *testing.T) {
+	bytes, _ := ioutil.ReadFile("./resources/fixtures/repo.json")
+	repo, err := buildRepository(bytes, nil)
+	assert.Nil(t, err)
+	fp := FeatureProbe{Repo: NewRepositoryStore(repo)}
+	user := NewUser().StableRollout("key11").With("city", "4")
+
+	explanation := fp.Explain("bool_toggle", user)
+	detail := fp.BoolDetail("bool_toggle", user, true)
+	assert.Equal(t, detail.Value, explanation.Value)
+	assert.Equal(t, detail.Reason, explanation.Reason)
+	assert.Equal(t, detail.RuleIndex, explanation.RuleIndex)
+	assert.True(t, explanation.Enabled)
+	assert.NotEmpty(t, explanation.Rules)
+
+	last := explanation.Rules[len(explanation.Rules)-1]
+	if explanation.RuleIndex != nil {
+		assert.True(t, last.Matched)
+		assert.Equal(t, *explanation.RuleIndex, last.Index)
+	}
+	for _, rule := range explanation.Rules[:len(explanation.Rules)-1] {
+		assert.False(t, rule.Matched)
+	}
+}
+
+func TestExplainNotExist(t *testing.T) {
+	fp := FeatureProbe{}
+	explanation := fp.Explain("not_exist", NewUser())
+	assert.Equal(t, "Toggle:[not_exist] not exist", explanation.Reason)
+	assert.Len(t, explanation.Rules, 0)
+}
+
+func TestMatchedRule(t *testing.T) {
+	index := 1
+	toggle := newToggleForTest("toggle", "default")
+	toggle.Variations = []interface{}{"default", "beijing"}
+	toggle.Rules = []Rule{{
+		Serve: Serve{Select: &index},
+		Conditions: []Condition{
+			{Type: "string", Subject: "city", Predicate: "is one of", Objects: []string{"1", "2"}},
+			{Type: "segment", Predicate: "is in", Objects: []string{"seg"}},
+		},
+	}}
+	repo := Repository{Toggles: map[string]Toggle{"toggle": toggle}, Segments: map[string]Segment{
+		"seg": {Key: "seg", Rules: []Rule{{Conditions: []Condition{{Type: "string", Subject: "city", Predicate: "is one of", Objects: []string{"1"}}}}}},
+	}}
+	expected := &MatchedRule{
+		Index:      0,
+		Conditions: []string{"city is one of [1, 2]", "segment is in [seg]"},
+		Serve:      Serve{Select: &index},
+		segments:   []string{"seg"},
+	}
+
+	fp := FeatureProbe{Repo: NewRepositoryStore(&repo)}
+	user := NewUser().With("city", "1")
+	assert.Equal(t, expected, fp.StrDetail("toggle", user, "").Rule)
+	assert.Equal(t, expected, fp.Explain("toggle", user).Rule)
+	segments := []MatchedSegment{{Key: "seg", Version: 0, Contains: true}}
+	assert.Equal(t, segments, fp.StrDetail("toggle", user, "").Segments)
+	assert.Equal(t, segments, fp.Explain("toggle", user).Segments)
+	assert.Nil(t, fp.StrDetail("toggle", NewUser().With("city", "3"), "").Rule)
+
+	repo.compile(nil)
+	assert.True(t, fp.StrDetail("toggle", user, "").Rule == repo.Toggles["toggle"].matchedRules[0])
+}
@@ -0,0 +1,119 @@
+package featureprobe
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// endpointRetryAfter is how long a failed endpoint is skipped before it is
+// tried again, so a recovered primary takes traffic back.
+const endpointRetryAfter = time.Minute
+
+// endpoints is an API url replicated on several servers, tried in order.
+// An endpoint failing with a transport error or a 5xx response is marked
+// unhealthy and skipped by later requests for endpointRetryAfter.
+type endpoints struct {
+	urls     []string
+	clock    Clock
+	mu       sync.Mutex
+	failedAt []time.Time
+}
+
+func newEndpoints(urls []string, clock Clock) *endpoints {
+	return &endpoints{
+		urls:     urls,
+		clock:    clockOrSystem(clock),
+		failedAt: make([]time.Time, len(urls)),
+	}
+}
+
+// WithFallbackUrls adds remote urls tried in order when the remote url, and
+// each fallback before them, is unreachable or failing, for deployments
+// replicated across regions. Toggles and events use the same paths on every
+// url.
+func WithFallbackUrls(urls ...string) Option {
+	return func(fpConfig *FPConfig) {
+		for _, url := range urls {
+			if !strings.HasSuffix(url, "/") {
+				url += "/"
+			}
+			fpConfig.FallbackUrls = append(fpConfig.FallbackUrls, url)
+		}
+	}
+}
+
+// withFallbacks returns url followed by the same path on every fallback.
+func withFallbacks(url string, remoteUrl string, fallbacks []string) []string {
+	urls := []string{url}
+	if !strings.HasPrefix(url, remoteUrl) {
+		return urls
+	}
+	path := strings.TrimPrefix(url, remoteUrl)
+	for _, fallback := range fallbacks {
+		urls = append(urls, fallback+path)
+	}
+	return urls
+}
+
+// do sends the request built for each endpoint until one answers without a
+// server error, preferring healthy endpoints. When every endpoint fails, the
+// last response or error is returned.
+func (e *endpoints) do(client *http.Client, build func(url string) (*http.Request, error)) (*http.Response, error) {
+	order := e.order()
+	var lastErr error
+	for k, i := range order {
+		req, err := build(e.urls[i])
+		if err != nil {
+			return nil, err
+		}
+		resp, err := client.Do(req)
+		if err == nil && resp.StatusCode < http.StatusInternalServerError {
+			e.markHealthy(i)
+			return resp, nil
+		}
+		e.markFailed(i)
+		if err == nil {
+			if k == len(order)-1 {
+				// the caller reports the status of the last endpoint
+				return resp, nil
+			}
+			_ = resp.Body.Close()
+			err = fmt.Errorf("%s responds %s", e.urls[i], resp.Status)
+		}
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
+// order lists healthy endpoints first, then the failed ones, each in
+// configured order.
+func (e *endpoints) order() []int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	now := e.clock.Now()
+	healthy := make([]int, 0, len(e.urls))
+	var failed []int
+	for i, at := range e.failedAt {
+		if !at.IsZero() && now.Sub(at) < endpointRetryAfter {
+			failed = append(failed, i)
+		} else {
+			healthy = append(healthy, i)
+		}
+	}
+	return append(healthy, failed...)
+}
+
+func (e *endpoints) markFailed(i int) {
+	e.mu.Lock()
+	e.failedAt[i] = e.clock.Now()
+	e.mu.Unlock()
+}
+
+func (e *endpoints) markHealthy(i int) {
+	e.mu.Lock()
+	e.failedAt[i] = time.Time{}
+	e.mu.Unlock()
+}
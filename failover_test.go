@@ -0,0 +1,63 @@
+package featureprobe
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFallbackUrls(t *testing.T) {
+	repo, _ := setup(t)
+	primary := NewMockServer(repo)
+	defer primary.Close()
+	fallback := NewMockServer(repo)
+	defer fallback.Close()
+	primary.FailToggles(http.StatusServiceUnavailable, 1)
+	primary.FailEvents(http.StatusServiceUnavailable, 1)
+
+	fp, err := NewFeatureProbe(primary.URL(), "sdk_key", WithFallbackUrls(fallback.URL()), WithRefreshInterval(100))
+	assert.Nil(t, err)
+	user := NewUser().StableRollout("key11").With("city", "4")
+	assert.NotNil(t, fp.BoolDetail("bool_toggle", user, false).Version)
+	fp.Close()
+
+	assert.Equal(t, 1, primary.TogglesRequests())
+	assert.True(t, fallback.TogglesRequests() >= 1)
+	assert.Equal(t, 1, primary.EventsRequests())
+	assert.Equal(t, 1, len(fallback.Events()))
+}
+
+func TestEndpointsHealthTracking(t *testing.T) {
+	primary := NewMockServer(Repository{})
+	defer primary.Close()
+	fallback := NewMockServer(Repository{})
+	defer fallback.Close()
+	clock := NewManualClock(time.Unix(1000, 0))
+	urls := newEndpoints(withFallbacks(primary.URL()+"api/server-sdk/toggles", primary.URL(), []string{fallback.URL()}), clock)
+	client := newHttpClient(1000)
+	get := func() int {
+		resp, err := urls.do(&client, func(url string) (*http.Request, error) {
+			return http.NewRequest(http.MethodGet, url, nil)
+		})
+		assert.Nil(t, err)
+		_ = resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	primary.FailToggles(http.StatusInternalServerError, 1)
+	assert.Equal(t, http.StatusOK, get())
+	assert.Equal(t, http.StatusOK, get())
+	assert.Equal(t, 1, primary.TogglesRequests())
+	assert.Equal(t, 2, fallback.TogglesRequests())
+
+	clock.Advance(endpointRetryAfter)
+	assert.Equal(t, http.StatusOK, get())
+	assert.Equal(t, 2, primary.TogglesRequests())
+	assert.Equal(t, 2, fallback.TogglesRequests())
+
+	primary.FailToggles(http.StatusInternalServerError, 1)
+	fallback.FailToggles(http.StatusBadGateway, 1)
+	assert.Equal(t, http.StatusBadGateway, get())
+}
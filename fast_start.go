@@ -0,0 +1,114 @@
+package featureprobe
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sync/atomic"
+)
+
+// Source is where the value of an evaluation came from, to tell surprising
+// values served during an outage from those of up to date toggles.
+type Source string
+
+const (
+	// SourceNetwork values come from a synced repository, or one set by the
+	// application.
+	SourceNetwork Source = "network"
+	// SourceStale values come from a synced repository while syncs have been
+	// failing for two refresh intervals.
+	SourceStale Source = "stale"
+	// SourceCache values come from the cache loaded at start, before the
+	// first sync replaced it.
+	SourceCache Source = "cache"
+	// SourceBootstrap values come from the repository given with
+	// WithRepository or WithBootstrapRepository, before the first sync
+	// replaced it.
+	SourceBootstrap Source = "bootstrap"
+	// SourceOverride values come from Override, an overrides file, an
+	// environment override or a value injected by a fault.
+	SourceOverride Source = "override"
+	// SourceDefault values are the default.
+	SourceDefault Source = "default"
+)
+
+// WithCacheFile starts the client from a snapshot saved by ExportSnapshot at
+// path: evaluations are served from it at once, without waiting for the
+// first sync, which replaces it in the background. Details tell which source
+// served each evaluation until then. A missing or unreadable cache is
+// reported and the client starts as without one. Projects and environments
+// start without it.
+func WithCacheFile(path string) Option {
+	return func(fpConfig *FPConfig) {
+		fpConfig.CacheFile = path
+	}
+}
+
+type fastStart struct {
+	// first field, so it is 64-bit aligned for atomic access
+	cacheEvaluations uint64
+	cached           *Repository
+}
+
+// loadCache stores the cached repository, reporting whether there was one.
+func (fp *FeatureProbe) loadCache() bool {
+	if fp.Config.CacheFile == "" {
+		return false
+	}
+	data, err := ioutil.ReadFile(fp.Config.CacheFile)
+	if err != nil {
+		fp.Config.reportError(fmt.Errorf("load cache fails: %w", err))
+		return false
+	}
+	repo, err := decodeSnapshot(data, nil)
+	if err != nil {
+		fp.Config.reportError(fmt.Errorf("load cache %s fails: %w", fp.Config.CacheFile, err))
+		return false
+	}
+	fp.fastStart = &fastStart{cached: repo}
+	fp.Repo.Store(repo)
+	return true
+}
+
+func (fp *FeatureProbe) repoSource(repo *Repository) Source {
+	if fp.fastStart != nil && repo == fp.fastStart.cached {
+		return SourceCache
+	}
+	if fp.bootstrapped != nil && repo == fp.bootstrapped {
+		return SourceBootstrap
+	}
+	if fp.syncFailing() {
+		return SourceStale
+	}
+	return SourceNetwork
+}
+
+func (f *fastStart) served() {
+	atomic.AddUint64(&f.cacheEvaluations, 1)
+}
+
+// Fresh reports whether evaluations are no longer served from the cache of
+// WithCacheFile or a bootstrap repository, which is the case once a sync
+// succeeded, or without either.
+func (fp *FeatureProbe) Fresh() bool {
+	repo := fp.Repo.Load()
+	if fp.bootstrapped != nil && repo == fp.bootstrapped {
+		return false
+	}
+	return fp.fastStart == nil || repo != fp.fastStart.cached
+}
+
+// CacheEvaluations is the number of evaluations served from the cache of
+// WithCacheFile.
+func (fp *FeatureProbe) CacheEvaluations() uint64 {
+	if fp.fastStart == nil {
+		return 0
+	}
+	return atomic.LoadUint64(&fp.fastStart.cacheEvaluations)
+}
+
+func (r evalResult) valueSource() Source {
+	if r.source == "" {
+		return SourceDefault
+	}
+	return r.source
+}
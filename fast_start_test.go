@@ -0,0 +1,90 @@
+package featureprobe
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeCacheFile(t *testing.T, toggles map[string]interface{}) string {
+	cached := NewFeatureProbeForTest(toggles)
+	data, err := cached.ExportSnapshot()
+	assert.Nil(t, err)
+	dir, err := ioutil.TempDir("", "cache")
+	assert.Nil(t, err)
+	path := filepath.Join(dir, "cache.json")
+	assert.Nil(t, ioutil.WriteFile(path, data, 0600))
+	return path
+}
+
+func TestFastStartFromCache(t *testing.T) {
+	path := writeCacheFile(t, map[string]interface{}{"toggle": "cached"})
+	defer os.RemoveAll(filepath.Dir(path))
+	synced := newToggleForTest("toggle", "network")
+	synced.Version = 1
+	server := NewMockServer(Repository{Toggles: map[string]Toggle{"toggle": synced}})
+	defer server.Close()
+
+	// the manual clock never ticks, so waiting for the first sync would block
+	fp, err := NewFeatureProbe(server.URL(), "sdk_key", WithClock(NewManualClock(time.Unix(0, 0))), WithCacheFile(path))
+	assert.Nil(t, err)
+	defer fp.Close()
+
+	detail := fp.StrDetail("toggle", NewUser(), "d")
+	assert.Equal(t, "cached", detail.Value)
+	assert.Equal(t, SourceCache, detail.Source)
+	assert.False(t, fp.Fresh())
+	assert.Equal(t, uint64(1), fp.CacheEvaluations())
+	assert.Equal(t, SourceDefault, fp.StrDetail("missing", NewUser(), "d").Source)
+
+	fp.Syncer.fetchRemoteRepo()
+	detail = fp.StrDetail("toggle", NewUser(), "d")
+	assert.Equal(t, "network", detail.Value)
+	assert.Equal(t, SourceNetwork, detail.Source)
+	assert.True(t, fp.Fresh())
+	assert.Equal(t, uint64(1), fp.CacheEvaluations())
+}
+
+func TestFastStartWithoutCache(t *testing.T) {
+	server := NewMockServer(Repository{Toggles: map[string]Toggle{"toggle": newToggleForTest("toggle", "network")}})
+	defer server.Close()
+	var errs []error
+	fp, err := NewFeatureProbe(server.URL(), "sdk_key", WithServerless(true), WithCacheFile("/nonexistent/cache.json"),
+		WithErrorListener(func(err error) { errs = append(errs, err) }))
+	assert.Nil(t, err)
+
+	assert.Len(t, errs, 1)
+	assert.True(t, fp.Fresh())
+	detail := fp.StrDetail("toggle", NewUser(), "d")
+	assert.Equal(t, "network", detail.Value)
+	assert.Equal(t, SourceNetwork, detail.Source)
+	assert.Equal(t, uint64(0), fp.CacheEvaluations())
+}
+
+func TestEvaluationSources(t *testing.T) {
+	clock := NewManualClock(time.Unix(1000, 0))
+	fp := NewFeatureProbeForTest(map[string]interface{}{"toggle": true, "overridden": true})
+	fp.Config.Clock = clock
+	fp.Config.RefreshInterval = 1000
+	fp.Syncer = &Synchronizer{}
+	user := NewUser()
+
+	assert.Equal(t, SourceNetwork, fp.BoolDetail("toggle", user, false).Source)
+	fp.Syncer.lastSynced = unixMillis(clock.Now())
+	clock.Advance(2 * time.Second)
+	assert.Equal(t, SourceNetwork, fp.BoolDetail("toggle", user, false).Source)
+	clock.Advance(time.Millisecond)
+	assert.Equal(t, SourceStale, fp.BoolDetail("toggle", user, false).Source)
+
+	fp.Override("overridden", false)
+	assert.Equal(t, SourceOverride, fp.BoolDetail("overridden", user, true).Source)
+	assert.Equal(t, SourceDefault, fp.BoolDetail("missing", user, true).Source)
+	fp.InjectFault("toggle", FaultStale)
+	assert.Equal(t, SourceDefault, fp.BoolDetail("toggle", user, true).Source)
+	fp.InjectFault("toggle", FaultTypeMismatch)
+	assert.Equal(t, SourceOverride, fp.JsonDetail("toggle", user, true).Source)
+}
@@ -0,0 +1,77 @@
+package featureprobe
+
+import (
+	"sync"
+)
+
+// Fault simulates an SDK failure mode on a test client, so applications can
+// verify their fallback logic.
+type Fault int
+
+const (
+	FaultNone Fault = iota
+	FaultNotInitialized
+	FaultStale
+	FaultTypeMismatch
+	FaultToggleNotFound
+)
+
+// AllToggles applies an injected fault to every toggle.
+const AllToggles = "*"
+
+type faultInjector struct {
+	mu     sync.RWMutex
+	faults map[string]Fault
+}
+
+func newFaultInjector() *faultInjector {
+	return &faultInjector{faults: map[string]Fault{}}
+}
+
+// InjectFault makes every following evaluation of toggle fail with fault,
+// until ClearFaults is called. Intended for clients built by
+// NewFeatureProbeForTest.
+func (fp *FeatureProbe) InjectFault(toggle string, fault Fault) {
+	if fp.faults == nil {
+		fp.faults = newFaultInjector()
+	}
+	fp.faults.mu.Lock()
+	fp.faults.faults[toggle] = fault
+	fp.faults.mu.Unlock()
+}
+
+func (fp *FeatureProbe) ClearFaults() {
+	if fp.faults == nil {
+		return
+	}
+	fp.faults.mu.Lock()
+	fp.faults.faults = map[string]Fault{}
+	fp.faults.mu.Unlock()
+}
+
+func (f *faultInjector) get(toggle string) Fault {
+	if f == nil {
+		return FaultNone
+	}
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if fault, ok := f.faults[toggle]; ok {
+		return fault
+	}
+	return f.faults[AllToggles]
+}
+
+func (f Fault) apply(toggle string, defaultValue interface{}) (interface{}, string) {
+	switch f {
+	case FaultNotInitialized:
+		return defaultValue, "FeatureProbe not initialized"
+	case FaultStale:
+		return defaultValue, staleReason
+	case FaultTypeMismatch:
+		if _, ok := defaultValue.(bool); ok {
+			return "mismatch", "injected type mismatch"
+		}
+		return true, "injected type mismatch"
+	}
+	return defaultValue, toggleNotExistReason(toggle)
+}
@@ -0,0 +1,54 @@
+package featureprobe
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInjectFault(t *testing.T) {
+	fp := NewFeatureProbeForTest(map[string]interface{}{"toggle": true, "name": "red"})
+	user := NewUser()
+
+	fp.InjectFault("toggle", FaultToggleNotFound)
+	detail := fp.BoolDetail("toggle", user, false)
+	assert.Equal(t, false, detail.Value)
+	assert.Equal(t, "Toggle:[toggle] not exist", detail.Reason)
+	assert.Equal(t, "red", fp.StrValue("name", user, "blue"))
+
+	fp.InjectFault("toggle", FaultNotInitialized)
+	detail = fp.BoolDetail("toggle", user, false)
+	assert.Equal(t, false, detail.Value)
+	assert.Equal(t, "FeatureProbe not initialized", detail.Reason)
+
+	fp.InjectFault("toggle", FaultStale)
+	assert.Equal(t, "stale", fp.BoolDetail("toggle", user, false).Reason)
+
+	fp.InjectFault("toggle", FaultTypeMismatch)
+	detail = fp.BoolDetail("toggle", user, false)
+	assert.Equal(t, false, detail.Value)
+	assert.Equal(t, "Value type mismatch", detail.Reason)
+	strDetail := fp.StrDetail("toggle", user, "blue")
+	assert.Equal(t, "blue", strDetail.Value)
+	assert.Equal(t, "Value type mismatch", strDetail.Reason)
+
+	fp.ClearFaults()
+	assert.Equal(t, true, fp.BoolValue("toggle", user, false))
+}
+
+func TestInjectFaultAllToggles(t *testing.T) {
+	fp := NewFeatureProbeForTest(map[string]interface{}{"toggle": true, "name": "red"})
+	user := NewUser()
+
+	fp.InjectFault(AllToggles, FaultNotInitialized)
+	fp.InjectFault("name", FaultNone)
+	assert.Equal(t, false, fp.BoolValue("toggle", user, false))
+	assert.Equal(t, "red", fp.StrValue("name", user, "blue"))
+}
+
+func TestInjectFaultWithoutTestClient(t *testing.T) {
+	fp := FeatureProbe{}
+	fp.ClearFaults()
+	fp.InjectFault("toggle", FaultStale)
+	assert.Equal(t, "stale", fp.NumberDetail("toggle", NewUser(), 1).Reason)
+}
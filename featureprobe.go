@@ -1,9 +1,11 @@
 package featureprobe
 
 import (
-	"fmt"
+	"context"
+	"io"
 	"net"
 	"net/http"
+	"os"
 	"strings"
 	"time"
 )
@@ -12,19 +14,98 @@ var VERSION string = "1.1.0"
 var USER_AGENT string = "Go/" + VERSION
 
 type FeatureProbe struct {
-	Config   FPConfig
-	Repo     *Repository
-	Syncer   *Synchronizer
-	Recorder *EventRecorder
+	Config       FPConfig
+	Repo         *RepositoryStore
+	Syncer       *Synchronizer
+	Recorder     *EventRecorder
+	faults       *faultInjector
+	overrides    *overrideStore
+	coarse       *coarseClock
+	remote       *remoteEvaluator
+	killSwitch   *killSwitch
+	validators   *jsonValidators
+	lifecycle    *lifecycle
+	subscription *subscription
+	comparisons  *comparisons
+	envOverrides map[string]interface{}
+	projects     map[string]*FeatureProbe
+	environments map[string]*FeatureProbe
+	fastStart    *fastStart
+	sinks        *sinkForwarder
+	bigSegments  *bigSegments
+	degradation  *degradation
+	localFile    *fileWatcher
+	bootstrapped *Repository
+}
+
+type FPClient interface {
+	BoolValue(toggle string, user FPUser, defaultValue bool) bool
+	StrValue(toggle string, user FPUser, defaultValue string) string
+	NumberValue(toggle string, user FPUser, defaultValue float64) float64
+	JsonValue(toggle string, user FPUser, defaultValue interface{}) interface{}
+	BoolDetail(toggle string, user FPUser, defaultValue bool) FPBoolDetail
+	StrDetail(toggle string, user FPUser, defaultValue string) FPStrDetail
+	NumberDetail(toggle string, user FPUser, defaultValue float64) FPNumberDetail
+	JsonDetail(toggle string, user FPUser, defaultValue interface{}) FPJsonDetail
 }
 
 type FPConfig struct {
-	RemoteUrl       string
-	TogglesUrl      string
-	EventsUrl       string
-	ServerSdkKey    string
-	RefreshInterval int
-	WaitFirstResp   bool
+	RemoteUrl        string
+	TogglesUrl       string
+	EventsUrl        string
+	ServerSdkKey     string
+	RefreshInterval  int
+	WaitFirstResp    bool
+	Scenarios        *ScenarioRecorder
+	Clock            Clock
+	Serverless       bool
+	RemoteEvaluation bool
+	EvaluationUrl    string
+	FallbackUrls     []string
+	StrictMode       StrictMode
+	ExpectedToggles  map[string]ValueType
+	ErrorListener    func(err error)
+	LogLevel         LogLevel
+	// log prints at LogLevel, shared by the client's components.
+	log *logger
+	// subClient is set for the clients of projects and environments.
+	subClient            bool
+	JsonValidators       map[string]func(data []byte) error
+	DisableTelemetry     bool
+	ChangeListener       func(diff RepositoryDiff)
+	RepositoryHistory    int
+	MaxDataAge           time.Duration
+	Defaults             map[string]interface{}
+	QuietHours           *QuietHours
+	FlushInterval        int
+	ToggleFilter         *ToggleFilter
+	AuthProvider         AuthProvider
+	RequestSigning       bool
+	SigningSecret        string
+	MaxEventPayloadBytes int
+	MismatchListener     func(m Mismatch)
+	Dialer               Dialer
+	OverridesFile        string
+	EnvOverrides         bool
+	Projects             map[string]string
+	SummaryWindow        time.Duration
+	CacheFile            string
+	EventSinks           []EventSink
+	GlobalAttributes     map[string]string
+	Environments         map[string]string
+	BigSegmentStore      BigSegmentStore
+	BigSegmentStaleAfter time.Duration
+	CompositeBucketBy    map[string][]string
+	JSONCodec            *JSONCodec
+	StreamingMode        bool
+	RealtimeMode         bool
+	OfflineMode          bool
+	Repository           *Repository
+	LocalFile            string
+	Bootstrap            io.Reader
+	PersistCache         bool
+	DataStore            DataStore
+	RealtimeUrl          string
 }
 
 type FPBoolDetail struct {
@@ -32,6 +113,10 @@ type FPBoolDetail struct {
 	RuleIndex *int
 	Version   *uint64
 	Reason    string
+	Rule      *MatchedRule
+	Segments  []MatchedSegment
+	Err       error
+	Source    Source
 }
 
 type FPNumberDetail struct {
@@ -39,6 +124,10 @@ type FPNumberDetail struct {
 	RuleIndex *int
 	Version   *uint64
 	Reason    string
+	Rule      *MatchedRule
+	Segments  []MatchedSegment
+	Err       error
+	Source    Source
 }
 
 type FPStrDetail struct {
@@ -46,6 +135,10 @@ type FPStrDetail struct {
 	RuleIndex *int
 	Version   *uint64
 	Reason    string
+	Rule      *MatchedRule
+	Segments  []MatchedSegment
+	Err       error
+	Source    Source
 }
 
 type FPJsonDetail struct {
@@ -53,6 +146,10 @@ type FPJsonDetail struct {
 	RuleIndex *int
 	Version   *uint64
 	Reason    string
+	Rule      *MatchedRule
+	Segments  []MatchedSegment
+	Err       error
+	Source    Source
 }
 
 type Option func(fpConfig *FPConfig)
@@ -81,41 +178,262 @@ func WithWaitFirstResp(wait bool) Option {
 	}
 }
 
+func WithClock(clock Clock) Option {
+	return func(fpConfig *FPConfig) {
+		fpConfig.Clock = clock
+	}
+}
+
+func WithScenarioRecorder(recorder *ScenarioRecorder) Option {
+	return func(fpConfig *FPConfig) {
+		fpConfig.Scenarios = recorder
+	}
+}
+
+// WithServerless runs the client without background goroutines, for
+// platforms like AWS Lambda that freeze between invocations. Toggles are
+// fetched once, on the first evaluation, and events are only sent by
+// FlushAtEnd, which each invocation should call before returning.
+func WithServerless(serverless bool) Option {
+	return func(fpConfig *FPConfig) {
+		fpConfig.Serverless = serverless
+	}
+}
+
+// WithDefaults sets the value served for each toggle whenever it is missing
+// or fails to evaluate, in place of the default given at the call site, so
+// fallbacks are kept in one place.
+func WithDefaults(defaults map[string]interface{}) Option {
+	return func(fpConfig *FPConfig) {
+		if fpConfig.Defaults == nil {
+			fpConfig.Defaults = map[string]interface{}{}
+		}
+		for k, v := range defaults {
+			fpConfig.Defaults[k] = v
+		}
+	}
+}
+
+// WithTelemetry(false) stops all reporting that isn't needed for the toggle
+// access summaries shown in the console, for privacy-restricted deployments.
+// Individual access events are no longer sent; their counters still are.
+func WithTelemetry(enabled bool) Option {
+	return func(fpConfig *FPConfig) {
+		fpConfig.DisableTelemetry = !enabled
+	}
+}
+
+// WithMaxEventPayloadSize splits flushes into several requests of at most
+// bytes each, for gateways limiting request bodies. A single event or counted
+// toggle larger than bytes is still sent. Defaults to
+// DefaultMaxEventPayloadBytes.
+func WithMaxEventPayloadSize(bytes int) Option {
+	return func(fpConfig *FPConfig) {
+		fpConfig.MaxEventPayloadBytes = bytes
+	}
+}
+
+// WithSummaryWindow counts accesses in windows of the given length aligned
+// to the unix epoch, whatever the flush interval, so the summaries of every
+// instance share the same StartTime and EndTime and line up in server side
+// buckets. A flush sends one summary per window it has accesses of. By
+// default a summary spans the accesses of a flush.
+func WithSummaryWindow(window time.Duration) Option {
+	return func(fpConfig *FPConfig) {
+		fpConfig.SummaryWindow = window
+	}
+}
+
+// WithErrorListener receives the errors the SDK finds in the background,
+// which are printed when no listener is set.
+func WithErrorListener(listener func(err error)) Option {
+	return func(fpConfig *FPConfig) {
+		fpConfig.ErrorListener = listener
+	}
+}
+
+func (c FPConfig) reportError(err error) {
+	if c.ErrorListener != nil {
+		c.ErrorListener(err)
+		return
+	}
+	c.log.errorf("%s", err)
+}
+
 func NewTestClient(opts ...Option) (FeatureProbe, error) {
 	return NewFeatureProbe("", "", opts...)
 }
 
 func NewFeatureProbe(remoteUrl, severSdkKey string, opts ...Option) (FeatureProbe, error) {
-	repo := Repository{}
+	repo := NewRepositoryStore(&Repository{})
+	projectsUrl := remoteUrl
+	remoteUrl, socket := unixSocket(remoteUrl)
 	if !strings.HasSuffix(remoteUrl, "/") {
 		remoteUrl += "/"
 	}
 	fpConfig := FPConfig{
-		RemoteUrl:       remoteUrl,
-		TogglesUrl:      remoteUrl + "api/server-sdk/toggles",
-		EventsUrl:       remoteUrl + "api/events",
-		ServerSdkKey:    severSdkKey,
-		RefreshInterval: 2000,
-		WaitFirstResp:   true,
+		RemoteUrl:         remoteUrl,
+		TogglesUrl:        remoteUrl + "api/server-sdk/toggles",
+		EventsUrl:         remoteUrl + "api/events",
+		EvaluationUrl:     remoteUrl + "api/server-sdk/evaluate",
+		RealtimeUrl:       remoteUrl + "realtime",
+		ServerSdkKey:      severSdkKey,
+		RefreshInterval:   2000,
+		WaitFirstResp:     true,
+		RepositoryHistory: DefaultRepositoryHistory,
+		Dialer:            socket,
 	}
 
 	for _, opt := range opts {
 		opt(&fpConfig)
 	}
+	fpConfig.log = newLogger(fpConfig.LogLevel)
 
+	bootstrapped, err := bootstrapRepository(fpConfig)
+	if err != nil {
+		return FeatureProbe{Config: fpConfig}, err
+	}
+	if bootstrapped != nil {
+		repo = NewRepositoryStore(bootstrapped)
+	}
+	repo.historySize = fpConfig.RepositoryHistory
 	timeout := time.Duration(fpConfig.RefreshInterval)
-	eventRecorder := NewEventRecorder(fpConfig.EventsUrl, timeout, fpConfig.ServerSdkKey)
-	eventRecorder.Start()
-
-	toggleSyncer := NewSynchronizer(fpConfig.TogglesUrl, timeout, fpConfig.ServerSdkKey, &repo)
-	toggleSyncer.Start(fpConfig.WaitFirstResp)
+	if fpConfig.FlushInterval <= 0 {
+		fpConfig.FlushInterval = fpConfig.RefreshInterval
+	}
+	eventRecorder := NewEventRecorder(fpConfig.EventsUrl, time.Duration(fpConfig.FlushInterval), fpConfig.ServerSdkKey)
+	eventRecorder.clock = clockOrSystem(fpConfig.Clock)
+	eventRecorder.summariesOnly = fpConfig.DisableTelemetry
+	eventRecorder.quietHours = fpConfig.QuietHours
+	eventRecorder.summaryWindow = int64(fpConfig.SummaryWindow / time.Millisecond)
+	eventRecorder.metadata.Attributes = fpConfig.GlobalAttributes
+	eventRecorder.codec = fpConfig.JSONCodec
+	eventRecorder.log = fpConfig.log
+	if fpConfig.MaxEventPayloadBytes > 0 {
+		eventRecorder.maxPayloadBytes = fpConfig.MaxEventPayloadBytes
+	}
+	eventRecorder.eventsUrls = newEndpoints(withFallbacks(fpConfig.EventsUrl, fpConfig.RemoteUrl, fpConfig.FallbackUrls), fpConfig.Clock)
+	toggleSyncer := NewSynchronizer(fpConfig.TogglesUrl, timeout, fpConfig.ServerSdkKey, repo)
+	toggleSyncer.clock = clockOrSystem(fpConfig.Clock)
+	toggleSyncer.togglesUrls = newEndpoints(withFallbacks(fpConfig.TogglesUrl, fpConfig.RemoteUrl, fpConfig.FallbackUrls), fpConfig.Clock)
+	toggleSyncer.filter = fpConfig.ToggleFilter
+	toggleSyncer.reportError = fpConfig.reportError
+	toggleSyncer.codec = fpConfig.JSONCodec
+	toggleSyncer.log = fpConfig.log
+	validators := newJsonValidators(fpConfig)
+	if validators != nil {
+		toggleSyncer.onUpdate = append(toggleSyncer.onUpdate, func(_, repo *Repository) {
+			validators.validate(repo)
+		})
+	}
+	if fpConfig.ChangeListener != nil {
+		toggleSyncer.onUpdate = append(toggleSyncer.onUpdate, changeNotifier(fpConfig.ChangeListener))
+	}
+	if len(fpConfig.ExpectedToggles) > 0 {
+		toggleSyncer.onUpdate = append(toggleSyncer.onUpdate, expectationChecker(fpConfig))
+	}
+	toggleSyncer.onUpdate = append(toggleSyncer.onUpdate, typeChecker(fpConfig))
+	if fpConfig.PersistCache && fpConfig.CacheFile != "" {
+		toggleSyncer.onUpdate = append(toggleSyncer.onUpdate, cachePersister(fpConfig.CacheFile, fpConfig))
+	}
+	toggleSyncer.store = newDataStoreSource(fpConfig)
+	if toggleSyncer.store != nil {
+		toggleSyncer.onUpdate = append(toggleSyncer.onUpdate, toggleSyncer.store.write)
+	}
+	fp := FeatureProbe{
+		Config:       fpConfig,
+		Repo:         repo,
+		Syncer:       &toggleSyncer,
+		Recorder:     &eventRecorder,
+		overrides:    newOverrideStore(),
+		killSwitch:   &killSwitch{},
+		validators:   validators,
+		lifecycle:    &lifecycle{},
+		subscription: newSubscription(fpConfig.ToggleFilter),
+		comparisons:  newComparisons(),
+		degradation:  newDegradation(clockOrSystem(fpConfig.Clock).Now()),
+		bootstrapped: bootstrapped,
+	}
+	// offline clients reach no event sinks or stores
+	if !fpConfig.OfflineMode {
+		fp.sinks, fp.bigSegments = newSinkForwarder(fpConfig.EventSinks), newBigSegments(fpConfig)
+	}
+	eventRecorder.sinks = fp.sinks
+	projects, err := newSubClients(projectsUrl, "project", fpConfig.Projects, opts)
+	if err != nil {
+		return fp, err
+	}
+	environments, err := newSubClients(projectsUrl, "environment", fpConfig.Environments, opts)
+	if err != nil {
+		for _, project := range projects {
+			project.Close()
+		}
+		return fp, err
+	}
+	fp.projects, fp.environments = projects, environments
+	fp.loadOverridesFile()
+	cached := fp.loadCache()
+	if !cached && bootstrapped == nil && !fpConfig.OfflineMode {
+		cached = fp.loadDataStore()
+	}
+	if fpConfig.EnvOverrides {
+		fp.envOverrides = loadEnvOverrides(os.Environ())
+		for name := range fp.envOverrides {
+			fpConfig.log.infof("toggle %s overridden by %s%s", name, EnvOverridePrefix, name)
+		}
+	}
+	if fpConfig.LocalFile != "" {
+		fp.localFile = newFileWatcher(fpConfig.LocalFile, repo, &toggleSyncer, fpConfig)
+		if err := fp.localFile.load(); err != nil {
+			return fp, err
+		}
+		fp.Syncer = nil
+		if !fpConfig.Serverless {
+			fp.localFile.start(clockOrSystem(fpConfig.Clock), timeout*time.Millisecond)
+		}
+	}
+	if fpConfig.OfflineMode {
+		fp.Syncer, fp.Recorder = nil, nil
+		return fp, nil
+	}
+	if fpConfig.RemoteEvaluation {
+		fp.remote = newRemoteEvaluator(fpConfig.EvaluationUrl, fpConfig.ServerSdkKey, timeout)
+	}
+	useDialer(&toggleSyncer.httpClient, fpConfig.Dialer)
+	if fpConfig.StreamingMode {
+		toggleSyncer.stream = newStreamer(fpConfig.RealtimeUrl)
+	} else if fpConfig.RealtimeMode {
+		toggleSyncer.stream = newSocketIOStreamer(fpConfig.RealtimeUrl)
+	}
+	if toggleSyncer.stream != nil {
+		useDialer(&toggleSyncer.stream.httpClient, fpConfig.Dialer)
+	}
+	useDialer(&eventRecorder.httpClient, fpConfig.Dialer)
+	if fp.remote != nil {
+		useDialer(&fp.remote.httpClient, fpConfig.Dialer)
+	}
+	signer := newRequestSigner(fpConfig)
+	toggleSyncer.signer, eventRecorder.signer = signer, signer
+	if fp.remote != nil {
+		fp.remote.signer = signer
+	}
+	if fpConfig.AuthProvider != nil {
+		toggleSyncer.auth = fpConfig.AuthProvider
+		eventRecorder.auth = fpConfig.AuthProvider
+		if fp.remote != nil {
+			fp.remote.auth = fpConfig.AuthProvider
+		}
+	}
+	if fpConfig.Serverless {
+		return fp, nil
+	}
 
-	return FeatureProbe{
-		Config:   fpConfig,
-		Repo:     &repo,
-		Syncer:   &toggleSyncer,
-		Recorder: &eventRecorder,
-	}, nil
+	eventRecorder.Start()
+	if !fpConfig.RemoteEvaluation && fp.localFile == nil {
+		toggleSyncer.Start(fpConfig.WaitFirstResp && !cached && bootstrapped == nil)
+	}
+	fp.coarse = newCoarseClock(clockOrSystem(fpConfig.Clock), coarseClockResolution)
+	return fp, nil
 }
 
 func newToggleForTest(key string, value interface{}) Toggle {
@@ -138,133 +456,318 @@ func NewFeatureProbeForTest(toggles map[string]interface{}) FeatureProbe {
 	for key, value := range toggles {
 		repo.Toggles[key] = newToggleForTest(key, value)
 	}
+	repo.compile(nil)
 	return FeatureProbe{
-		Repo: &repo,
+		Repo:        NewRepositoryStore(&repo),
+		faults:      newFaultInjector(),
+		overrides:   newOverrideStore(),
+		killSwitch:  &killSwitch{},
+		lifecycle:   &lifecycle{},
+		comparisons: newComparisons(),
+		degradation: newDegradation(time.Now()),
 	}
 }
 
 func (fp *FeatureProbe) BoolValue(toggle string, user FPUser, defaultValue bool) bool {
-	val, _, _, _ := fp.genericDetail(toggle, user, defaultValue)
-	r, ok := val.(bool)
+	result := fp.genericDetail(toggle, user, defaultValue)
+	r, ok := result.boolValue()
 	if !ok {
-		return defaultValue
+		r = defaultValue
+		fp.degradation.count(toggle, DegradedTypeMismatch)
+	}
+	if fp.Config.Scenarios != nil {
+		fp.recordScenario("bool_value", toggle, user, defaultValue, ExpectResult{Value: r})
 	}
 	return r
 }
 
 func (fp *FeatureProbe) StrValue(toggle string, user FPUser, defaultValue string) string {
-	val, _, _, _ := fp.genericDetail(toggle, user, defaultValue)
-	r, ok := val.(string)
+	result := fp.genericDetail(toggle, user, defaultValue)
+	r, ok := result.stringValue()
 	if !ok {
-		return defaultValue
+		r = defaultValue
+		fp.degradation.count(toggle, DegradedTypeMismatch)
+	}
+	if fp.Config.Scenarios != nil {
+		fp.recordScenario("string_value", toggle, user, defaultValue, ExpectResult{Value: r})
 	}
 	return r
 }
 
 func (fp *FeatureProbe) NumberValue(toggle string, user FPUser, defaultValue float64) float64 {
-	val, _, _, _ := fp.genericDetail(toggle, user, defaultValue)
-	i, ok := val.(int)
-	if ok {
-		return float64(i)
-	}
-	f, ok := val.(float64)
+	result := fp.genericDetail(toggle, user, defaultValue)
+	r, ok := result.numberValue()
 	if !ok {
-		return defaultValue
+		r = defaultValue
+		fp.degradation.count(toggle, DegradedTypeMismatch)
 	}
-	return f
+	if fp.Config.Scenarios != nil {
+		fp.recordScenario("number_value", toggle, user, defaultValue, ExpectResult{Value: r})
+	}
+	return r
 }
 
 func (fp *FeatureProbe) JsonValue(toggle string, user FPUser, defaultValue interface{}) interface{} {
-	val, _, _, _ := fp.genericDetail(toggle, user, defaultValue)
-	return val
+	result := fp.genericDetail(toggle, user, defaultValue)
+	if fp.Config.Scenarios != nil {
+		fp.recordScenario("json_value", toggle, user, defaultValue, ExpectResult{Value: result.value})
+	}
+	return result.value
 }
 
-func (fp *FeatureProbe) genericDetail(toggle string, user FPUser, defaultValue interface{}) (interface{}, *int, *uint64, string) {
-	value := defaultValue
-	reason := fmt.Sprintf("Toggle:[%s] not exist", toggle)
-	var ruleIndex *int = nil
-	var version *uint64 = nil
-	var variationIndex *int = nil
+// EvalOnly evaluates toggle like JsonValue but records no access event and
+// takes no timestamp, for hot paths whose exposure is counted elsewhere.
+// Evaluations made through it are not reported to FeatureProbe.
+func (fp *FeatureProbe) EvalOnly(toggle string, user FPUser, defaultValue interface{}) interface{} {
+	result, evaluated := fp.evaluate(toggle, user, defaultValue)
+	if !evaluated {
+		fp.degradation.countResult(toggle, result)
+	}
+	return result.value
+}
 
-	if fp.Repo == nil {
-		return value, ruleIndex, version, reason
+func (fp *FeatureProbe) genericDetail(toggle string, user FPUser, defaultValue interface{}) evalResult {
+	result, evaluated := fp.evaluate(toggle, user, defaultValue)
+	if evaluated {
+		fp.recordAccess(toggle, user, result)
+	} else {
+		fp.degradation.countResult(toggle, result)
 	}
-	t, ok := fp.Repo.Toggles[toggle]
-	if !ok {
-		return value, ruleIndex, version, reason
+	return result
+}
+
+func (fp *FeatureProbe) recordAccess(toggle string, user FPUser, result evalResult) {
+	if fp.sinks != nil {
+		fp.expose(toggle, user, result)
 	}
-	detail, err := t.evalDetail(user, fp.Repo.Segments)
+	if fp.Recorder == nil {
+		return
+	}
+	fp.Recorder.RecordAccess(AccessEvent{
+		Time:    fp.eventTime(),
+		Key:     toggle,
+		Value:   result.value,
+		Index:   result.eventIndex(),
+		Version: result.eventVersion(),
+		Reason:  result.reason,
+	})
+}
+
+func (fp *FeatureProbe) eventTime() int64 {
+	if fp.coarse != nil {
+		return fp.coarse.nowMillis()
+	}
+	return unixMillis(clockOrSystem(fp.Config.Clock).Now())
+}
 
-	variationIndex = detail.VariationIndex
-	ruleIndex = detail.RuleIndex
-	version = detail.Version
-	reason = detail.Reason
+// evaluate reports whether the toggle was evaluated from the repository,
+// rather than answered by a fault, an override or the default.
+func (fp *FeatureProbe) evaluate(toggle string, user FPUser, defaultValue interface{}) (evalResult, bool) {
+	return fp.evaluateShared(toggle, user, defaultValue, nil)
+}
 
-	if err == nil {
-		value = detail.Value
+// preempt answers toggle without evaluating it when the client is closed,
+// forced to defaults, overridden, faulted or not subscribed to it, in that
+// order. Otherwise the result holds defaultValue, for the evaluation to
+// fill in.
+func (fp *FeatureProbe) preempt(toggle string, defaultValue interface{}) (evalResult, bool) {
+	result := evalResult{
+		ruleIndex:      noIndex,
+		variationIndex: noIndex,
+		value:          defaultValue,
+	}
+	if fp.lifecycle.isClosed() {
+		result.reason, result.err = closedReason, ErrClientClosed
+		return result, true
+	}
+	if fp.killSwitch.enabled() {
+		result.reason = ForceDefaultsReason
+		return result, true
 	}
+	if v, ok := fp.envOverride(toggle); ok {
+		result.value, result.reason, result.source = v, EnvOverrideReason, SourceOverride
+		return result, true
+	}
+	if fault := fp.faults.get(toggle); fault != FaultNone {
+		result.value, result.reason = fault.apply(toggle, defaultValue)
+		if fault == FaultTypeMismatch {
+			result.source = SourceOverride
+		}
+		return result, true
+	}
+	if v, ok := fp.overrides.get(toggle); ok {
+		result.value, result.reason, result.source = v, "override", SourceOverride
+		return result, true
+	}
+	if !fp.subscription.allows(toggle, fp.Config) {
+		result.reason = notSubscribedReason(toggle)
+		return result, true
+	}
+	return result, false
+}
 
-	if fp.Recorder != nil {
-		fp.Recorder.RecordAccess(AccessEvent{
-			Time:    time.Now().UnixNano() / 1e6,
-			Key:     toggle,
-			Value:   value,
-			Index:   variationIndex,
-			Version: version,
-			Reason:  reason,
-		})
+// evaluateShared evaluates like evaluate, resolving segments through shared
+// when evaluating several toggles for the same user.
+func (fp *FeatureProbe) evaluateShared(toggle string, user FPUser, defaultValue interface{}, shared *sharedSegments) (evalResult, bool) {
+	if fp.Config.GlobalAttributes != nil {
+		user = user.withGlobal(fp.Config.GlobalAttributes)
 	}
+	if v, ok := fp.Config.Defaults[toggle]; ok {
+		defaultValue = v
+	}
+	result, answered := fp.preempt(toggle, defaultValue)
+	if answered {
+		return result, false
+	}
+	if fp.remote != nil {
+		return fp.evaluateRemote(toggle, user, defaultValue)
+	}
+	if fp.stale() {
+		result.reason = staleReason
+		return result, false
+	}
+	if fp.Config.Serverless && fp.Syncer != nil {
+		fp.Syncer.fetchOnce.Do(fp.Syncer.fetchRemoteRepo)
+	}
+	repo := fp.Repo.Load()
+	if repo == nil {
+		result.reason, result.err = toggleNotExistReason(toggle), ErrNotInitialized
+		return result, false
+	}
+	t, ok := repo.GetToggle(toggle)
+	if !ok {
+		if fp.Config.StrictMode != StrictOff && repo.Toggles != nil {
+			fp.unknownToggle(toggle)
+		}
+		result.reason, result.err = toggleNotExistReason(toggle), lookupError(repo, toggle)
+		return result, false
+	}
+	t.usage.mark()
+	t.usage.readAs(defaultValue)
+	if t.usage.quarantined() {
+		result.reason, result.err = QuarantineReason, toggleError(toggle, ErrQuarantined)
+		return result, false
+	}
+	if t.Archived || t.PlannedEndTime != 0 {
+		fp.checkObsolete(&t)
+	}
+	return fp.evaluateToggle(&t, repo, user, defaultValue, shared.memo(repo))
+}
+
+func (fp *FeatureProbe) evaluateToggle(t *Toggle, repo *Repository, user FPUser, defaultValue interface{}, memo map[string]bool) (result evalResult, evaluated bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			result, evaluated = fp.evaluationPanicked(t, repo, r, defaultValue), false
+		}
+	}()
+	result, err := t.detail(evalParams{
+		User:          user,
+		Repo:          repo,
+		Variations:    t.Variations,
+		Key:           t.Key,
+		Clock:         fp.Config.Clock,
+		segmentMemo:   memo,
+		bigSegments:   fp.bigSegments,
+		compositeKeys: fp.Config.CompositeBucketBy,
+	})
+	if err != nil {
+		result.value, result.err = defaultValue, toggleError(t.Key, err)
+	} else if result.source = fp.repoSource(repo); result.source == SourceCache {
+		fp.fastStart.served()
+		result.reason += " (" + StaleCacheReason + ")"
+	}
+	result.repo = repo
+	if fp.validators.invalid(repo, t.Key, result.variationIndex) {
+		result.value, result.variationIndex, result.reason = defaultValue, noIndex, ValidationReason
+		result.source = ""
+		return result, false
+	}
+	return result, true
+}
 
-	return value, ruleIndex, version, reason
+func toggleNotExistReason(toggle string) string {
+	return "Toggle:[" + toggle + "] not exist"
 }
 
 func (fp *FeatureProbe) BoolDetail(toggle string, user FPUser, defaultValue bool) FPBoolDetail {
-	value, ruleIndex, version, reason := fp.genericDetail(toggle, user, defaultValue)
-	detail := FPBoolDetail{Value: defaultValue, RuleIndex: ruleIndex, Version: version, Reason: reason}
+	result := fp.genericDetail(toggle, user, defaultValue)
+	detail := FPBoolDetail{Value: defaultValue, RuleIndex: result.ruleIndexPtr(), Version: result.versionPtr(), Reason: result.reason, Rule: result.rule, Segments: fp.matchedSegments(result, user), Err: result.err, Source: result.valueSource()}
 
-	val, ok := value.(bool)
+	val, ok := result.boolValue()
 	if !ok {
-		detail.Reason = "Value type mismatch"
-		return detail
+		detail.Reason, detail.Err = "Value type mismatch", toggleError(toggle, ErrTypeMismatch)
+		fp.degradation.count(toggle, DegradedTypeMismatch)
+	} else {
+		detail.Value = val
+	}
+	if fp.Config.Scenarios != nil {
+		fp.recordScenario("bool_detail", toggle, user, defaultValue, detailResult(detail.Value, detail.RuleIndex, detail.Version, detail.Reason))
 	}
-	detail.Value = val
 	return detail
 }
 
 func (fp *FeatureProbe) StrDetail(toggle string, user FPUser, defaultValue string) FPStrDetail {
-	value, ruleIndex, version, reason := fp.genericDetail(toggle, user, defaultValue)
-	detail := FPStrDetail{Value: defaultValue, RuleIndex: ruleIndex, Version: version, Reason: reason}
+	result := fp.genericDetail(toggle, user, defaultValue)
+	detail := FPStrDetail{Value: defaultValue, RuleIndex: result.ruleIndexPtr(), Version: result.versionPtr(), Reason: result.reason, Rule: result.rule, Segments: fp.matchedSegments(result, user), Err: result.err, Source: result.valueSource()}
 
-	val, ok := value.(string)
+	val, ok := result.stringValue()
 	if !ok {
-		detail.Reason = "Value type mismatch"
-		return detail
+		detail.Reason, detail.Err = "Value type mismatch", toggleError(toggle, ErrTypeMismatch)
+		fp.degradation.count(toggle, DegradedTypeMismatch)
+	} else {
+		detail.Value = val
+	}
+	if fp.Config.Scenarios != nil {
+		fp.recordScenario("string_detail", toggle, user, defaultValue, detailResult(detail.Value, detail.RuleIndex, detail.Version, detail.Reason))
 	}
-	detail.Value = val
 	return detail
 }
 
 func (fp *FeatureProbe) NumberDetail(toggle string, user FPUser, defaultValue float64) FPNumberDetail {
-	value, ruleIndex, version, reason := fp.genericDetail(toggle, user, defaultValue)
-	detail := FPNumberDetail{Value: defaultValue, RuleIndex: ruleIndex, Version: version, Reason: reason}
+	result := fp.genericDetail(toggle, user, defaultValue)
+	detail := FPNumberDetail{Value: defaultValue, RuleIndex: result.ruleIndexPtr(), Version: result.versionPtr(), Reason: result.reason, Rule: result.rule, Segments: fp.matchedSegments(result, user), Err: result.err, Source: result.valueSource()}
 
-	val, ok := value.(float64)
+	val, ok := result.numberValue()
 	if !ok {
-		detail.Reason = "Value type mismatch"
-		return detail
+		detail.Reason, detail.Err = "Value type mismatch", toggleError(toggle, ErrTypeMismatch)
+		fp.degradation.count(toggle, DegradedTypeMismatch)
+	} else {
+		detail.Value = val
+	}
+	if fp.Config.Scenarios != nil {
+		fp.recordScenario("number_detail", toggle, user, defaultValue, detailResult(detail.Value, detail.RuleIndex, detail.Version, detail.Reason))
 	}
-	detail.Value = val
 	return detail
 }
 
 func (fp *FeatureProbe) JsonDetail(toggle string, user FPUser, defaultValue interface{}) FPJsonDetail {
-	value, ruleIndex, version, reason := fp.genericDetail(toggle, user, defaultValue)
-	detail := FPJsonDetail{Value: value, RuleIndex: ruleIndex, Version: version, Reason: reason}
+	result := fp.genericDetail(toggle, user, defaultValue)
+	detail := FPJsonDetail{Value: result.value, RuleIndex: result.ruleIndexPtr(), Version: result.versionPtr(), Reason: result.reason, Rule: result.rule, Segments: fp.matchedSegments(result, user), Err: result.err, Source: result.valueSource()}
+	if fp.Config.Scenarios != nil {
+		fp.recordScenario("json_detail", toggle, user, defaultValue, detailResult(detail.Value, detail.RuleIndex, detail.Version, detail.Reason))
+	}
 	return detail
 }
 
+// recordScenario is only called when a recorder is configured, so the
+// expected result is not built on every evaluation.
+func (fp *FeatureProbe) recordScenario(function, toggle string, user FPUser, defaultValue interface{}, expect ExpectResult) {
+	fp.Config.Scenarios.record(fp.Repo.Load(), function, toggle, user, defaultValue, expect)
+}
+
+func detailResult(value interface{}, ruleIndex *int, version *uint64, reason string) ExpectResult {
+	noRuleIndex := ruleIndex == nil
+	return ExpectResult{
+		Value:       value,
+		Reason:      &reason,
+		RuleIndex:   ruleIndex,
+		NoRuleIndex: &noRuleIndex,
+		Version:     version,
+	}
+}
+
 func (fp *FeatureProbe) setRepoForTest(repo Repository) {
-	fp.Repo = &repo
+	fp.Repo = NewRepositoryStore(&repo)
 }
 
 func newHttpClient(timeout time.Duration) http.Client {
@@ -285,14 +788,43 @@ func newHttpClient(timeout time.Duration) http.Client {
 	}
 }
 
+// FlushAtEnd sends the recorded events before returning, or until ctx is done.
+// Serverless clients must call it at the end of every invocation.
+func (fp *FeatureProbe) FlushAtEnd(ctx context.Context) error {
+	if fp.lifecycle.isClosed() {
+		return ErrClientClosed
+	}
+	if fp.Recorder == nil {
+		return nil
+	}
+	return fp.Recorder.flush(ctx)
+}
+
+// Close stops syncing, sends the recorded events and releases the
+// repository, stopping every goroutine and connection of the client. The
+// client can't be used afterwards.
 func (fp *FeatureProbe) Close() {
+	fp.lifecycle.close()
 	if fp.Syncer != nil {
 		fp.Syncer.Stop()
+		fp.Syncer.httpClient.CloseIdleConnections()
 	}
 	if fp.Repo != nil {
-		fp.Repo.Clear()
+		fp.Repo.release()
 	}
 	if fp.Recorder != nil {
 		fp.Recorder.Stop()
+		fp.Recorder.httpClient.CloseIdleConnections()
+	}
+	if fp.remote != nil {
+		fp.remote.httpClient.CloseIdleConnections()
+	}
+	fp.coarse.Stop()
+	fp.localFile.stop()
+	for _, project := range fp.projects {
+		project.Close()
+	}
+	for _, environment := range fp.environments {
+		environment.Close()
 	}
 }
@@ -1,6 +1,7 @@
 package featureprobe
 
 import (
+	"context"
 	"encoding/json"
 	"io/ioutil"
 	"strings"
@@ -254,68 +255,22 @@ func TestCloseClient(t *testing.T) {
 	fp, _ := NewTestClient(WithRefreshInterval(100))
 
 	fp.Close()
-	assert.Equal(t, 0, len(fp.Repo.Toggles))
+	assert.Equal(t, 0, len(fp.Repo.Load().Toggles))
 }
 
 func TestContract(t *testing.T) {
 	bytes, _ := ioutil.ReadFile("./resources/fixtures/server-sdk-specification/spec/toggle_simple_spec.json")
-	var tests ContractTests
-	err := json.Unmarshal(bytes, &tests)
+	tests, err := ParseContractTests(bytes)
 	assert.Equal(t, nil, err)
 
 	for _, scenario := range tests.Tests {
-		t.Log("scenario: ", scenario.Scenario)
 		assert.NotEmpty(t, scenario.Cases)
-
-		fp := FeatureProbe{Repo: &scenario.Fixture}
-
-		for _, Case := range scenario.Cases {
-			t.Log("  case: ", Case.Name)
-			user := NewUser().StableRollout(Case.User.Key)
-			for _, kv := range Case.User.CustomValues {
-				user = user.With(kv.Key, kv.Value)
-			}
-
-			switch Case.Function.Name {
-			case "bool_value":
-				d := Case.Function.Default.(bool)
-				v := fp.BoolValue(Case.Function.Toggle, user, d)
-				assert.Equal(t, Case.ExpectResult.Value, v)
-			case "string_value":
-				d := Case.Function.Default.(string)
-				v := fp.StrValue(Case.Function.Toggle, user, d)
-				assert.Equal(t, Case.ExpectResult.Value, v)
-			case "number_value":
-				d := Case.Function.Default.(float64)
-				v := fp.NumberValue(Case.Function.Toggle, user, d)
-				assert.Equal(t, Case.ExpectResult.Value, v)
-			case "json_value":
-				d := Case.Function.Default
-				v := fp.JsonValue(Case.Function.Toggle, user, d)
-				assert.Equal(t, Case.ExpectResult.Value, v)
-
-			case "bool_detail":
-				d := Case.Function.Default.(bool)
-				v := fp.BoolDetail(Case.Function.Toggle, user, d)
-				assert.Equal(t, Case.ExpectResult.Value, v.Value)
-				assertBoolDetail(t, Case, v)
-			case "string_detail":
-				d := Case.Function.Default.(string)
-				v := fp.StrDetail(Case.Function.Toggle, user, d)
-				assert.Equal(t, Case.ExpectResult.Value, v.Value)
-				assertStrDetail(t, Case, v)
-			case "number_detail":
-				d := Case.Function.Default.(float64)
-				v := fp.NumberDetail(Case.Function.Toggle, user, d)
-				assert.Equal(t, Case.ExpectResult.Value, v.Value)
-				assertNumberDetail(t, Case, v)
-			case "json_detail":
-				d := Case.Function.Default
-				v := fp.JsonDetail(Case.Function.Toggle, user, d)
-				assert.Equal(t, Case.ExpectResult.Value, v.Value)
-				assertJsonDetail(t, Case, v)
-			}
-		}
+	}
+	diffs := RunContractTests(tests, func(fixture Repository) FPClient {
+		return &FeatureProbe{Repo: NewRepositoryStore(&fixture)}
+	})
+	for _, diff := range diffs {
+		t.Error(diff)
 	}
 }
 
@@ -340,109 +295,157 @@ func TestClientOptionDefaultValue(t *testing.T) {
 	assert.Equal(t, 2000, fp.Config.RefreshInterval)
 }
 
-func assertBoolDetail(t *testing.T, Case Case, r FPBoolDetail) {
-	if Case.ExpectResult.Reason != nil {
-		assert.True(t, strings.Contains(r.Reason, *Case.ExpectResult.Reason))
-	}
-	if Case.ExpectResult.RuleIndex != nil {
-		assert.Equal(t, *Case.ExpectResult.RuleIndex, *r.RuleIndex)
-	}
-	if Case.ExpectResult.NoRuleIndex != nil {
-		assert.Equal(t, *Case.ExpectResult.NoRuleIndex, r.RuleIndex == nil)
-	}
-	if Case.ExpectResult.Version != nil {
-		assert.Equal(t, *Case.ExpectResult.Version, *r.Version)
-	}
+func TestClientDefaults(t *testing.T) {
+	fp := NewFeatureProbeForTest(map[string]interface{}{"toggle": true})
+	WithDefaults(map[string]interface{}{"missing": "configured", "toggle": false})(&fp.Config)
+
+	detail := fp.StrDetail("missing", NewUser(), "call site")
+	assert.Equal(t, "configured", detail.Value)
+	assert.Equal(t, "Toggle:[missing] not exist", detail.Reason)
+	assert.Equal(t, "call site", fp.StrValue("other", NewUser(), "call site"))
+	assert.True(t, fp.BoolValue("toggle", NewUser(), false))
+
+	fp.SetForceDefaults(true)
+	assert.False(t, fp.BoolValue("toggle", NewUser(), true))
 }
 
-func assertNumberDetail(t *testing.T, Case Case, r FPNumberDetail) {
-	if Case.ExpectResult.Reason != nil {
-		assert.True(t, strings.Contains(r.Reason, *Case.ExpectResult.Reason))
-	}
-	if Case.ExpectResult.RuleIndex != nil {
-		assert.Equal(t, *Case.ExpectResult.RuleIndex, *r.RuleIndex)
-	}
-	if Case.ExpectResult.NoRuleIndex != nil {
-		assert.Equal(t, *Case.ExpectResult.NoRuleIndex, r.RuleIndex == nil)
-	}
-	if Case.ExpectResult.Version != nil {
-		assert.Equal(t, *Case.ExpectResult.Version, *r.Version)
-	}
+func setupFeatureProbe(t *testing.T) *FeatureProbe {
+	fp, err := NewTestClient(WithRefreshInterval(100))
+	assert.Empty(t, err)
+	return &fp
 }
 
-func assertStrDetail(t *testing.T, Case Case, r FPStrDetail) {
-	if Case.ExpectResult.Reason != nil {
-		assert.True(t, strings.Contains(r.Reason, *Case.ExpectResult.Reason))
-	}
-	if Case.ExpectResult.RuleIndex != nil {
-		assert.Equal(t, *Case.ExpectResult.RuleIndex, *r.RuleIndex)
-	}
-	if Case.ExpectResult.NoRuleIndex != nil {
-		assert.Equal(t, *Case.ExpectResult.NoRuleIndex, r.RuleIndex == nil)
-	}
-	if Case.ExpectResult.Version != nil {
-		assert.Equal(t, *Case.ExpectResult.Version, *r.Version)
-	}
+func TestEvalOnly(t *testing.T) {
+	bytes, _ := ioutil.ReadFile("./resources/fixtures/repo.json")
+	repo, err := buildRepository(bytes, nil)
+	assert.Equal(t, nil, err)
+	recorder := NewEventRecorder("", 1000, "sdk_key")
+	fp := FeatureProbe{Repo: NewRepositoryStore(repo), Recorder: &recorder}
+	user := NewUser().StableRollout("key11").With("city", "4")
+
+	assert.Equal(t, fp.BoolValue("bool_toggle", user, true), fp.EvalOnly("bool_toggle", user, true))
+	assert.Equal(t, "1", fp.EvalOnly("not_exist_toggle", user, "1"))
+	assert.Len(t, recorder.takeEvents(), 1)
 }
 
-func assertJsonDetail(t *testing.T, Case Case, r FPJsonDetail) {
-	if Case.ExpectResult.Reason != nil {
-		assert.True(t, strings.Contains(r.Reason, *Case.ExpectResult.Reason))
-	}
-	if Case.ExpectResult.RuleIndex != nil {
-		assert.Equal(t, *Case.ExpectResult.RuleIndex, *r.RuleIndex)
-	}
-	if Case.ExpectResult.NoRuleIndex != nil {
-		assert.Equal(t, *Case.ExpectResult.NoRuleIndex, r.RuleIndex == nil)
-	}
-	if Case.ExpectResult.Version != nil {
-		assert.Equal(t, *Case.ExpectResult.Version, *r.Version)
+func TestEvaluationDoesNotAllocate(t *testing.T) {
+	bytes, _ := ioutil.ReadFile("./resources/fixtures/repo.json")
+	repo, err := buildRepository(bytes, nil)
+	assert.Equal(t, nil, err)
+	fp := FeatureProbe{Repo: NewRepositoryStore(repo)}
+	user := NewUser().StableRollout("key11").With("city", "4")
+
+	allocs := testing.AllocsPerRun(100, func() {
+		fp.BoolValue("bool_toggle", user, true)
+		fp.StrValue("string_toggle", user, "")
+	})
+	assert.Equal(t, float64(0), allocs)
+}
+
+func setupBenchmark(b *testing.B) *FeatureProbe {
+	bytes, _ := ioutil.ReadFile("./resources/fixtures/repo.json")
+	repo, err := buildRepository(bytes, nil)
+	if err != nil {
+		b.Fatal(err)
 	}
+	recorder := NewEventRecorder("", 1000, "sdk_key")
+	return &FeatureProbe{Repo: NewRepositoryStore(repo), Recorder: &recorder}
 }
 
-func setupFeatureProbe(t *testing.T) *FeatureProbe {
-	fp, err := NewTestClient(WithRefreshInterval(100))
-	assert.Empty(t, err)
-	return &fp
+func BenchmarkBoolValue(b *testing.B) {
+	fp := setupBenchmark(b)
+	user := NewUser().StableRollout("key11").With("city", "4")
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		fp.BoolValue("bool_toggle", user, true)
+	}
 }
 
-type ContractTests struct {
-	Tests []Scenario `json:"tests"`
+func BenchmarkBoolValueWithoutEvents(b *testing.B) {
+	fp := setupBenchmark(b)
+	fp.Recorder = nil
+	user := NewUser().StableRollout("key11").With("city", "4")
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		fp.BoolValue("bool_toggle", user, true)
+	}
 }
 
-type Scenario struct {
-	Scenario string     `json:"scenario"`
-	Cases    []Case     `json:"cases"`
-	Fixture  Repository `json:"fixture"`
+func BenchmarkEvalOnly(b *testing.B) {
+	fp := setupBenchmark(b)
+	user := NewUser().StableRollout("key11").With("city", "4")
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		fp.EvalOnly("bool_toggle", user, true)
+	}
 }
 
-type Case struct {
-	Name         string       `json:"name"`
-	User         User         `json:"user"`
-	Function     Function     `json:"function"`
-	ExpectResult ExpectResult `json:"expectResult"`
+func BenchmarkBoolDetail(b *testing.B) {
+	fp := setupBenchmark(b)
+	user := NewUser().StableRollout("key11").With("city", "1")
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		fp.BoolDetail("bool_toggle", user, true)
+	}
 }
 
-type User struct {
-	Key          string     `json:"key"`
-	CustomValues []KeyValue `json:"customValues"`
+func BenchmarkNumberValue(b *testing.B) {
+	fp := setupBenchmark(b)
+	user := NewUser().StableRollout("key11").With("city", "100")
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		fp.NumberValue("number_toggle", user, 1)
+	}
 }
 
-type Function struct {
-	Name    string      `json:"name"`
-	Toggle  string      `json:"toggle"`
-	Default interface{} `json:"default"`
+func BenchmarkToggleNotExist(b *testing.B) {
+	fp := setupBenchmark(b)
+	user := NewUser().StableRollout("key11")
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		fp.StrValue("not_exist_toggle", user, "1")
+	}
 }
 
-type ExpectResult struct {
-	Value       interface{} `json:"value"`
-	Reason      *string     `json:"reason"`
-	RuleIndex   *int        `json:"ruleIndex"`
-	NoRuleIndex *bool       `json:"noRuleIndex"`
-	Version     *uint64     `json:"version"`
+func TestServerlessMode(t *testing.T) {
+	repo, _ := setup(t)
+	server := NewMockServer(repo)
+	defer server.Close()
+
+	fp, err := NewFeatureProbe(server.URL(), "sdk_key", WithServerless(true))
+	assert.Nil(t, err)
+	defer fp.Close()
+	assert.Nil(t, fp.coarse)
+	assert.Equal(t, 0, server.TogglesRequests())
+
+	user := NewUser().StableRollout("key11").With("city", "4")
+	assert.False(t, fp.BoolValue("bool_toggle", user, true))
+	fp.BoolValue("bool_toggle", user, true)
+	assert.Equal(t, 1, server.TogglesRequests())
+	assert.Equal(t, 0, server.EventsRequests())
+
+	err = fp.FlushAtEnd(context.Background())
+	assert.Nil(t, err)
+	events := server.Events()
+	assert.Len(t, events, 1)
+	assert.Len(t, events[0].Events, 2)
 }
 
-type KeyValue struct {
-	Key   string `json:"key"`
-	Value string `json:"value"`
+func TestFlushAtEndCanceled(t *testing.T) {
+	server := NewMockServer(Repository{})
+	defer server.Close()
+	fp, _ := NewFeatureProbe(server.URL(), "sdk_key", WithServerless(true))
+	defer fp.Close()
+	fp.Recorder.RecordAccess(AccessEvent{Key: "toggle"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.Error(t, fp.FlushAtEnd(ctx))
+	assert.Nil(t, (&FeatureProbe{}).FlushAtEnd(ctx))
 }
@@ -0,0 +1,109 @@
+package featureprobe
+
+import (
+	"net/url"
+	"strings"
+)
+
+// ToggleFilter selects the toggles a service uses, by exact key, by key
+// prefix or by the tags listed in toggle metadata. A toggle matching any key,
+// prefix or tag is kept. Segments are always kept, as kept toggles may
+// reference them.
+type ToggleFilter struct {
+	Keys     []string
+	Prefixes []string
+	Tags     []string
+}
+
+// WithToggleFilter only syncs the toggles matching filter, shrinking memory
+// and payloads for organizations with many toggles. The filter is sent to
+// the toggles API and applied again locally, for servers ignoring it.
+// Other toggles are evaluated as not existing.
+func WithToggleFilter(filter ToggleFilter) Option {
+	return func(fpConfig *FPConfig) {
+		fpConfig.ToggleFilter = &filter
+	}
+}
+
+func (f *ToggleFilter) empty() bool {
+	return f == nil || len(f.Keys) == 0 && len(f.Prefixes) == 0 && len(f.Tags) == 0
+}
+
+// query adds the filter to a toggles request url.
+func (f *ToggleFilter) query(rawUrl string) string {
+	if f.empty() {
+		return rawUrl
+	}
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		return rawUrl
+	}
+	q := u.Query()
+	if len(f.Keys) > 0 {
+		q.Set("keys", strings.Join(f.Keys, ","))
+	}
+	if len(f.Prefixes) > 0 {
+		q.Set("prefixes", strings.Join(f.Prefixes, ","))
+	}
+	if len(f.Tags) > 0 {
+		q.Set("tags", strings.Join(f.Tags, ","))
+	}
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+func (f *ToggleFilter) match(t *Toggle) bool {
+	if f.empty() {
+		return true
+	}
+	if f.matchKey(t.Key) {
+		return true
+	}
+	for _, tag := range toggleTags(t) {
+		for _, wanted := range f.Tags {
+			if tag == wanted {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// matchKey tells whether key is selected by the filter keys or prefixes.
+func (f *ToggleFilter) matchKey(key string) bool {
+	for _, k := range f.Keys {
+		if k == key {
+			return true
+		}
+	}
+	for _, prefix := range f.Prefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// apply removes the toggles not matching from repo.
+func (f *ToggleFilter) apply(repo *Repository) {
+	if f.empty() {
+		return
+	}
+	for key, t := range repo.Toggles {
+		if !f.match(&t) {
+			delete(repo.Toggles, key)
+		}
+	}
+}
+
+// toggleTags reads the "tags" list of toggle metadata.
+func toggleTags(t *Toggle) []string {
+	raw, _ := t.Metadata["tags"].([]interface{})
+	tags := make([]string, 0, len(raw))
+	for _, tag := range raw {
+		if s, ok := tag.(string); ok {
+			tags = append(tags, s)
+		}
+	}
+	return tags
+}
@@ -0,0 +1,39 @@
+package featureprobe
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestToggleFilter(t *testing.T) {
+	tagged := newToggleForTest("tagged", true)
+	tagged.Metadata = map[string]interface{}{"tags": []interface{}{"payments", "web"}}
+	repo := Repository{Toggles: map[string]Toggle{
+		"checkout_new": newToggleForTest("checkout_new", true),
+		"checkout_old": newToggleForTest("checkout_old", true),
+		"search":       newToggleForTest("search", true),
+		"tagged":       tagged,
+	}}
+	server := NewMockServer(repo)
+	defer server.Close()
+
+	fp, err := NewFeatureProbe(server.URL(), "sdk_key", WithToggleFilter(ToggleFilter{Prefixes: []string{"checkout_"}, Tags: []string{"payments"}}))
+	assert.Nil(t, err)
+	defer fp.Close()
+
+	synced := fp.Repo.Load()
+	assert.Len(t, synced.Toggles, 3)
+	assert.NotContains(t, synced.Toggles, "search")
+	assert.False(t, fp.BoolValue("search", NewUser(), false))
+	assert.True(t, fp.BoolValue("tagged", NewUser(), false))
+}
+
+func TestToggleFilterQuery(t *testing.T) {
+	filter := &ToggleFilter{Prefixes: []string{"a_", "b_"}, Tags: []string{"web"}}
+	assert.Equal(t, "http://host/api/server-sdk/toggles?prefixes=a_%2Cb_&tags=web", filter.query("http://host/api/server-sdk/toggles"))
+
+	var none *ToggleFilter
+	assert.Equal(t, "http://host/api/server-sdk/toggles", none.query("http://host/api/server-sdk/toggles"))
+	assert.True(t, none.match(&Toggle{Key: "any"}))
+}
@@ -0,0 +1,54 @@
+// Package fpecho integrates FeatureProbe with Echo, mirroring fpgin: a
+// middleware binds the client and the user of each request to the
+// echo.Context, and helpers evaluate toggles from it.
+package fpecho
+
+import (
+	featureprobe "github.com/featureprobe/server-sdk-go"
+	"github.com/labstack/echo/v4"
+)
+
+const evaluatorKey = "featureprobe.evaluator"
+
+// Middleware builds the user of each request with extractors and binds it to
+// the context, together with client.
+func Middleware(client featureprobe.FPClient, extractors ...featureprobe.UserExtractor) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			r := c.Request()
+			user := featureprobe.UserFromRequest(r, extractors...)
+			c.Set(evaluatorKey, featureprobe.NewEvaluator(client, user))
+			c.SetRequest(r.WithContext(featureprobe.ContextWithUser(r.Context(), user)))
+			return next(c)
+		}
+	}
+}
+
+// Evaluator returns the evaluator bound by Middleware. Without the middleware
+// it is the zero Evaluator, which returns defaults.
+func Evaluator(c echo.Context) featureprobe.Evaluator {
+	if e, ok := c.Get(evaluatorKey).(featureprobe.Evaluator); ok {
+		return e
+	}
+	return featureprobe.Evaluator{}
+}
+
+func User(c echo.Context) (featureprobe.FPUser, bool) {
+	return featureprobe.UserFromContext(c.Request().Context())
+}
+
+func BoolValue(c echo.Context, toggle string, defaultValue bool) bool {
+	return Evaluator(c).BoolValue(toggle, defaultValue)
+}
+
+func StrValue(c echo.Context, toggle string, defaultValue string) string {
+	return Evaluator(c).StrValue(toggle, defaultValue)
+}
+
+func NumberValue(c echo.Context, toggle string, defaultValue float64) float64 {
+	return Evaluator(c).NumberValue(toggle, defaultValue)
+}
+
+func JsonValue(c echo.Context, toggle string, defaultValue interface{}) interface{} {
+	return Evaluator(c).JsonValue(toggle, defaultValue)
+}
@@ -0,0 +1,43 @@
+package fpecho
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	featureprobe "github.com/featureprobe/server-sdk-go"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMiddleware(t *testing.T) {
+	fp := featureprobe.NewFeatureProbeForTest(map[string]interface{}{"toggle": true, "color": "red"})
+
+	e := echo.New()
+	e.Use(Middleware(&fp, featureprobe.KeyFromHeader("X-User-Id")))
+	e.GET("/", func(c echo.Context) error {
+		user, ok := User(c)
+		assert.True(t, ok)
+		assert.Equal(t, "user1", user.Key())
+		assert.True(t, BoolValue(c, "toggle", false))
+		assert.Equal(t, "red", StrValue(c, "color", "blue"))
+		assert.Equal(t, 1.0, NumberValue(c, "not_exist", 1))
+		return c.String(http.StatusOK, "ok")
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-User-Id", "user1")
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestWithoutMiddleware(t *testing.T) {
+	e := echo.New()
+	c := e.NewContext(httptest.NewRequest(http.MethodGet, "/", nil), httptest.NewRecorder())
+
+	assert.True(t, BoolValue(c, "toggle", true))
+	assert.Equal(t, "d", JsonValue(c, "toggle", "d"))
+	_, ok := User(c)
+	assert.False(t, ok)
+}
@@ -0,0 +1,11 @@
+module github.com/featureprobe/server-sdk-go/fpecho
+
+go 1.13
+
+require (
+	github.com/featureprobe/server-sdk-go v1.2.0
+	github.com/labstack/echo/v4 v4.9.0
+	github.com/stretchr/testify v1.7.2
+)
+
+replace github.com/featureprobe/server-sdk-go => ../
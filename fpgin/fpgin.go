@@ -0,0 +1,53 @@
+// Package fpgin integrates FeatureProbe with gin: a middleware binds the
+// client and the user of each request to the gin.Context, and helpers
+// evaluate toggles from it.
+package fpgin
+
+import (
+	featureprobe "github.com/featureprobe/server-sdk-go"
+	"github.com/gin-gonic/gin"
+)
+
+const evaluatorKey = "featureprobe.evaluator"
+
+// Middleware builds the user of each request with extractors and binds it to
+// the context, together with client.
+func Middleware(client featureprobe.FPClient, extractors ...featureprobe.UserExtractor) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := featureprobe.UserFromRequest(c.Request, extractors...)
+		c.Set(evaluatorKey, featureprobe.NewEvaluator(client, user))
+		c.Request = c.Request.WithContext(featureprobe.ContextWithUser(c.Request.Context(), user))
+		c.Next()
+	}
+}
+
+// Evaluator returns the evaluator bound by Middleware. Without the middleware
+// it is the zero Evaluator, which returns defaults.
+func Evaluator(c *gin.Context) featureprobe.Evaluator {
+	if v, ok := c.Get(evaluatorKey); ok {
+		if e, ok := v.(featureprobe.Evaluator); ok {
+			return e
+		}
+	}
+	return featureprobe.Evaluator{}
+}
+
+func User(c *gin.Context) (featureprobe.FPUser, bool) {
+	return featureprobe.UserFromContext(c.Request.Context())
+}
+
+func BoolValue(c *gin.Context, toggle string, defaultValue bool) bool {
+	return Evaluator(c).BoolValue(toggle, defaultValue)
+}
+
+func StrValue(c *gin.Context, toggle string, defaultValue string) string {
+	return Evaluator(c).StrValue(toggle, defaultValue)
+}
+
+func NumberValue(c *gin.Context, toggle string, defaultValue float64) float64 {
+	return Evaluator(c).NumberValue(toggle, defaultValue)
+}
+
+func JsonValue(c *gin.Context, toggle string, defaultValue interface{}) interface{} {
+	return Evaluator(c).JsonValue(toggle, defaultValue)
+}
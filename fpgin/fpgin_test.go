@@ -0,0 +1,46 @@
+package fpgin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	featureprobe "github.com/featureprobe/server-sdk-go"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	fp := featureprobe.NewFeatureProbeForTest(map[string]interface{}{"toggle": true, "color": "red"})
+
+	r := gin.New()
+	r.Use(Middleware(&fp, featureprobe.KeyFromHeader("X-User-Id")))
+	r.GET("/", func(c *gin.Context) {
+		user, ok := User(c)
+		assert.True(t, ok)
+		assert.Equal(t, "user1", user.Key())
+		assert.True(t, BoolValue(c, "toggle", false))
+		assert.Equal(t, "red", StrValue(c, "color", "blue"))
+		assert.Equal(t, 1.0, NumberValue(c, "not_exist", 1))
+		c.String(http.StatusOK, "ok")
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-User-Id", "user1")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestWithoutMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+
+	assert.True(t, BoolValue(c, "toggle", true))
+	assert.Equal(t, "d", JsonValue(c, "toggle", "d"))
+	_, ok := User(c)
+	assert.False(t, ok)
+}
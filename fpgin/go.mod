@@ -0,0 +1,11 @@
+module github.com/featureprobe/server-sdk-go/fpgin
+
+go 1.13
+
+require (
+	github.com/featureprobe/server-sdk-go v1.2.0
+	github.com/gin-gonic/gin v1.7.7
+	github.com/stretchr/testify v1.7.2
+)
+
+replace github.com/featureprobe/server-sdk-go => ../
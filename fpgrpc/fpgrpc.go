@@ -0,0 +1,127 @@
+// Package fpgrpc integrates FeatureProbe with gRPC. Server interceptors build
+// the user of each call from incoming metadata and bind it to the context,
+// and client interceptors forward evaluated toggles as outgoing metadata.
+package fpgrpc
+
+import (
+	"context"
+	"encoding/json"
+
+	featureprobe "github.com/featureprobe/server-sdk-go"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// MetadataExtractor adds what it finds in incoming metadata to the user.
+type MetadataExtractor func(md metadata.MD, user featureprobe.FPUser) featureprobe.FPUser
+
+type evaluatorKey struct{}
+
+func KeyFromMetadata(key string) MetadataExtractor {
+	return func(md metadata.MD, user featureprobe.FPUser) featureprobe.FPUser {
+		if v := md.Get(key); len(v) > 0 && v[0] != "" {
+			return user.StableRollout(v[0])
+		}
+		return user
+	}
+}
+
+func AttrFromMetadata(key string, attr string) MetadataExtractor {
+	return func(md metadata.MD, user featureprobe.FPUser) featureprobe.FPUser {
+		if v := md.Get(key); len(v) > 0 && v[0] != "" {
+			return user.With(attr, v[0])
+		}
+		return user
+	}
+}
+
+func UnaryServerInterceptor(client featureprobe.FPClient, extractors ...MetadataExtractor) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		return handler(bind(ctx, client, extractors), req)
+	}
+}
+
+func StreamServerInterceptor(client featureprobe.FPClient, extractors ...MetadataExtractor) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return handler(srv, &boundStream{ServerStream: ss, ctx: bind(ss.Context(), client, extractors)})
+	}
+}
+
+// UnaryClientInterceptor evaluates toggles with the evaluator bound to the
+// outgoing call's context and sends each value as metadata named
+// prefix + toggle key, so downstream services see the same assignments.
+func UnaryClientInterceptor(prefix string, toggles ...string) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		return invoker(withAssignments(ctx, prefix, toggles), method, req, reply, cc, opts...)
+	}
+}
+
+func StreamClientInterceptor(prefix string, toggles ...string) grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		return streamer(withAssignments(ctx, prefix, toggles), desc, cc, method, opts...)
+	}
+}
+
+// Evaluator returns the evaluator bound by the server interceptors. Without
+// them it is the zero Evaluator, which returns defaults.
+func Evaluator(ctx context.Context) featureprobe.Evaluator {
+	if e, ok := ctx.Value(evaluatorKey{}).(featureprobe.Evaluator); ok {
+		return e
+	}
+	return featureprobe.Evaluator{}
+}
+
+func BoolValue(ctx context.Context, toggle string, defaultValue bool) bool {
+	return Evaluator(ctx).BoolValue(toggle, defaultValue)
+}
+
+func StrValue(ctx context.Context, toggle string, defaultValue string) string {
+	return Evaluator(ctx).StrValue(toggle, defaultValue)
+}
+
+func NumberValue(ctx context.Context, toggle string, defaultValue float64) float64 {
+	return Evaluator(ctx).NumberValue(toggle, defaultValue)
+}
+
+func JsonValue(ctx context.Context, toggle string, defaultValue interface{}) interface{} {
+	return Evaluator(ctx).JsonValue(toggle, defaultValue)
+}
+
+type boundStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *boundStream) Context() context.Context {
+	return s.ctx
+}
+
+func bind(ctx context.Context, client featureprobe.FPClient, extractors []MetadataExtractor) context.Context {
+	md, _ := metadata.FromIncomingContext(ctx)
+	user := featureprobe.NewUser()
+	for _, extract := range extractors {
+		user = extract(md, user)
+	}
+	ctx = featureprobe.ContextWithUser(ctx, user)
+	return context.WithValue(ctx, evaluatorKey{}, featureprobe.NewEvaluator(client, user))
+}
+
+func withAssignments(ctx context.Context, prefix string, toggles []string) context.Context {
+	e := Evaluator(ctx)
+	pairs := make([]string, 0, 2*len(toggles))
+	for _, toggle := range toggles {
+		value := e.JsonValue(toggle, nil)
+		if value == nil {
+			continue
+		}
+		if s, ok := value.(string); ok {
+			pairs = append(pairs, prefix+toggle, s)
+		} else if bytes, err := json.Marshal(value); err == nil {
+			pairs = append(pairs, prefix+toggle, string(bytes))
+		}
+	}
+	if len(pairs) == 0 {
+		return ctx
+	}
+	return metadata.AppendToOutgoingContext(ctx, pairs...)
+}
@@ -0,0 +1,73 @@
+package fpgrpc
+
+import (
+	"context"
+	"testing"
+
+	featureprobe "github.com/featureprobe/server-sdk-go"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+type testStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *testStream) Context() context.Context {
+	return s.ctx
+}
+
+func incoming() context.Context {
+	return metadata.NewIncomingContext(context.Background(), metadata.Pairs("user-id", "user1", "city", "1"))
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	fp := featureprobe.NewFeatureProbeForTest(map[string]interface{}{"toggle": true})
+	interceptor := UnaryServerInterceptor(&fp, KeyFromMetadata("user-id"), AttrFromMetadata("city", "city"))
+
+	_, err := interceptor(incoming(), nil, &grpc.UnaryServerInfo{}, func(ctx context.Context, req interface{}) (interface{}, error) {
+		user, ok := featureprobe.UserFromContext(ctx)
+		assert.True(t, ok)
+		assert.Equal(t, "user1", user.Key())
+		assert.Equal(t, "1", user.Get("city"))
+		assert.True(t, BoolValue(ctx, "toggle", false))
+		return nil, nil
+	})
+	assert.Nil(t, err)
+}
+
+func TestStreamServerInterceptor(t *testing.T) {
+	fp := featureprobe.NewFeatureProbeForTest(map[string]interface{}{"color": "red"})
+	interceptor := StreamServerInterceptor(&fp, KeyFromMetadata("user-id"))
+
+	err := interceptor(nil, &testStream{ctx: incoming()}, &grpc.StreamServerInfo{}, func(srv interface{}, stream grpc.ServerStream) error {
+		assert.Equal(t, "red", StrValue(stream.Context(), "color", "blue"))
+		assert.Equal(t, "user1", Evaluator(stream.Context()).User().Key())
+		return nil
+	})
+	assert.Nil(t, err)
+}
+
+func TestUnaryClientInterceptor(t *testing.T) {
+	fp := featureprobe.NewFeatureProbeForTest(map[string]interface{}{"toggle": true, "color": "red"})
+	ctx := context.WithValue(context.Background(), evaluatorKey{}, featureprobe.NewEvaluator(&fp, featureprobe.NewUser()))
+	interceptor := UnaryClientInterceptor("fp-", "toggle", "color", "not_exist")
+
+	err := interceptor(ctx, "/svc/method", nil, nil, nil, func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		md, ok := metadata.FromOutgoingContext(ctx)
+		assert.True(t, ok)
+		assert.Equal(t, []string{"true"}, md.Get("fp-toggle"))
+		assert.Equal(t, []string{"red"}, md.Get("fp-color"))
+		assert.Len(t, md.Get("fp-not_exist"), 0)
+		return nil
+	})
+	assert.Nil(t, err)
+}
+
+func TestWithoutInterceptor(t *testing.T) {
+	ctx := context.Background()
+	assert.Equal(t, 1.0, NumberValue(ctx, "toggle", 1))
+	assert.Equal(t, "d", JsonValue(ctx, "toggle", "d"))
+}
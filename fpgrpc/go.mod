@@ -0,0 +1,11 @@
+module github.com/featureprobe/server-sdk-go/fpgrpc
+
+go 1.13
+
+require (
+	github.com/featureprobe/server-sdk-go v1.2.0
+	github.com/stretchr/testify v1.7.2
+	google.golang.org/grpc v1.46.2
+)
+
+replace github.com/featureprobe/server-sdk-go => ../
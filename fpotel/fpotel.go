@@ -0,0 +1,64 @@
+// Package fpotel carries the FeatureProbe user across services in
+// OpenTelemetry baggage, so downstream services evaluate toggles with the
+// identity the edge service saw. The edge calls ContextWithUser once, and
+// every service that propagates baggage can rebuild the user with
+// UserFromContext.
+package fpotel
+
+import (
+	"context"
+	"net/url"
+	"strings"
+
+	featureprobe "github.com/featureprobe/server-sdk-go"
+	"go.opentelemetry.io/otel/baggage"
+)
+
+const (
+	// KeyMember is the baggage member holding the user key.
+	KeyMember = "fp.key"
+	// AttrPrefix prefixes the baggage members holding user attributes.
+	AttrPrefix = "fp.attr."
+)
+
+// ContextWithUser adds the key and attributes of user to the baggage of ctx.
+// Values are query-escaped, since baggage values can't hold every character.
+func ContextWithUser(ctx context.Context, user featureprobe.FPUser) (context.Context, error) {
+	b := baggage.FromContext(ctx)
+	members := map[string]string{KeyMember: user.Key()}
+	for k, v := range user.GetAll() {
+		members[AttrPrefix+k] = v
+	}
+	for k, v := range members {
+		m, err := baggage.NewMember(k, url.QueryEscape(v))
+		if err != nil {
+			return ctx, err
+		}
+		if b, err = b.SetMember(m); err != nil {
+			return ctx, err
+		}
+	}
+	return baggage.ContextWithBaggage(ctx, b), nil
+}
+
+// UserFromContext rebuilds the user ContextWithUser put in the baggage of ctx.
+// It reports false when the baggage holds no FeatureProbe user.
+func UserFromContext(ctx context.Context) (featureprobe.FPUser, bool) {
+	user := featureprobe.NewUser()
+	found := false
+	for _, m := range baggage.FromContext(ctx).Members() {
+		value, err := url.QueryUnescape(m.Value())
+		if err != nil {
+			continue
+		}
+		switch {
+		case m.Key() == KeyMember:
+			user = user.StableRollout(value)
+			found = true
+		case strings.HasPrefix(m.Key(), AttrPrefix):
+			user = user.With(strings.TrimPrefix(m.Key(), AttrPrefix), value)
+			found = true
+		}
+	}
+	return user, found
+}
@@ -0,0 +1,31 @@
+package fpotel
+
+import (
+	"context"
+	"testing"
+
+	featureprobe "github.com/featureprobe/server-sdk-go"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/baggage"
+)
+
+func TestUserRoundTrip(t *testing.T) {
+	user := featureprobe.NewUser().StableRollout("user 1").With("city", "New York, NY")
+	ctx, err := ContextWithUser(context.Background(), user)
+	assert.Nil(t, err)
+
+	got, ok := UserFromContext(ctx)
+	assert.True(t, ok)
+	assert.Equal(t, "user 1", got.Key())
+	assert.Equal(t, "New York, NY", got.Get("city"))
+}
+
+func TestUserFromContextIgnoresOtherMembers(t *testing.T) {
+	m, _ := baggage.NewMember("tenant", "acme")
+	b, _ := baggage.New(m)
+	ctx := baggage.ContextWithBaggage(context.Background(), b)
+
+	user, ok := UserFromContext(ctx)
+	assert.False(t, ok)
+	assert.Equal(t, 0, len(user.GetAll()))
+}
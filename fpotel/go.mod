@@ -0,0 +1,18 @@
+module github.com/featureprobe/server-sdk-go/fpotel
+
+go 1.17
+
+require (
+	github.com/featureprobe/server-sdk-go v1.2.0
+	github.com/stretchr/testify v1.7.2
+	go.opentelemetry.io/otel v1.7.0
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/masterminds/semver v1.5.0 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
+
+replace github.com/featureprobe/server-sdk-go => ../
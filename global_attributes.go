@@ -0,0 +1,22 @@
+package featureprobe
+
+// WithGlobalAttributes adds attributes, like region, cluster or service
+// name, to every user evaluated by the client, for targeting on
+// infrastructure without every call site setting them. Attributes of the user
+// take precedence. They are also sent with events, in their sdk metadata.
+func WithGlobalAttributes(attributes map[string]string) Option {
+	return func(fpConfig *FPConfig) {
+		if fpConfig.GlobalAttributes == nil {
+			fpConfig.GlobalAttributes = map[string]string{}
+		}
+		for k, v := range attributes {
+			fpConfig.GlobalAttributes[k] = v
+		}
+	}
+}
+
+// withGlobal sets the attributes the user falls back to, without copying.
+func (u FPUser) withGlobal(global map[string]string) FPUser {
+	u.global = global
+	return u
+}
@@ -0,0 +1,46 @@
+package featureprobe
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func regionToggle() Toggle {
+	select1 := 1
+	toggle := newToggleForTest("toggle", "elsewhere")
+	toggle.Variations = append(toggle.Variations, "eu")
+	toggle.Rules = []Rule{{Serve: Serve{Select: &select1}, Conditions: []Condition{
+		{Type: "string", Subject: "region", Predicate: "is one of", Objects: []string{"eu-west"}},
+	}}}
+	return toggle
+}
+
+func TestGlobalAttributes(t *testing.T) {
+	server := NewMockServer(Repository{Toggles: map[string]Toggle{"toggle": regionToggle()}})
+	defer server.Close()
+	fp, err := NewFeatureProbe(server.URL(), "sdk_key", WithServerless(true),
+		WithGlobalAttributes(map[string]string{"region": "eu-west", "service": "billing"}))
+	assert.Nil(t, err)
+
+	user := NewUser()
+	assert.Equal(t, "eu", fp.StrValue("toggle", user, "d"))
+	assert.Equal(t, "elsewhere", fp.StrValue("toggle", NewUser().With("region", "us-east"), "d"))
+	assert.Empty(t, user.GetAll())
+	assert.True(t, fp.Explain("toggle", user).Rules[0].Matched)
+
+	assert.Nil(t, fp.FlushAtEnd(context.Background()))
+	events := server.Events()
+	assert.Len(t, events, 1)
+	assert.Equal(t, "billing", events[0].Sdk.Attributes["service"])
+}
+
+func TestUserWithGlobal(t *testing.T) {
+	user := NewUser().With("region", "us-east").withGlobal(map[string]string{"region": "eu-west", "cluster": "c1"})
+	assert.Equal(t, "us-east", user.Get("region"))
+	assert.Equal(t, "c1", user.Get("cluster"))
+	assert.True(t, user.Contains("cluster"))
+	assert.False(t, user.Contains("service"))
+	assert.Equal(t, map[string]string{"region": "us-east", "cluster": "c1"}, user.GetAll())
+}
@@ -0,0 +1,59 @@
+package featureprobe
+
+// JsonMapValue evaluates a json toggle whose variations are flat objects of
+// strings. defaultValue is returned when the toggle serves anything else,
+// including an object with a non string field.
+func (fp *FeatureProbe) JsonMapValue(toggle string, user FPUser, defaultValue map[string]string) map[string]string {
+	result := fp.genericDetail(toggle, user, defaultValue)
+	if r, ok := stringMap(result.value); ok {
+		return r
+	}
+	return defaultValue
+}
+
+// JsonSliceValue evaluates a json toggle whose variations are lists of
+// strings. defaultValue is returned when the toggle serves anything else,
+// including a list with a non string item.
+func (fp *FeatureProbe) JsonSliceValue(toggle string, user FPUser, defaultValue []string) []string {
+	result := fp.genericDetail(toggle, user, defaultValue)
+	if r, ok := stringSlice(result.value); ok {
+		return r
+	}
+	return defaultValue
+}
+
+func stringMap(value interface{}) (map[string]string, bool) {
+	switch v := value.(type) {
+	case map[string]string:
+		return v, true
+	case map[string]interface{}:
+		r := make(map[string]string, len(v))
+		for key, field := range v {
+			s, ok := field.(string)
+			if !ok {
+				return nil, false
+			}
+			r[key] = s
+		}
+		return r, true
+	}
+	return nil, false
+}
+
+func stringSlice(value interface{}) ([]string, bool) {
+	switch v := value.(type) {
+	case []string:
+		return v, true
+	case []interface{}:
+		r := make([]string, len(v))
+		for i, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, false
+			}
+			r[i] = s
+		}
+		return r, true
+	}
+	return nil, false
+}
@@ -0,0 +1,37 @@
+package featureprobe
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJsonMapValue(t *testing.T) {
+	fp := NewFeatureProbeForTest(map[string]interface{}{
+		"theme":  map[string]interface{}{"color": "red", "size": "large"},
+		"mixed":  map[string]interface{}{"color": "red", "size": 3.0},
+		"string": "red",
+	})
+	defaultValue := map[string]string{"color": "blue"}
+	user := NewUser()
+
+	assert.Equal(t, map[string]string{"color": "red", "size": "large"}, fp.JsonMapValue("theme", user, defaultValue))
+	assert.Equal(t, defaultValue, fp.JsonMapValue("mixed", user, defaultValue))
+	assert.Equal(t, defaultValue, fp.JsonMapValue("string", user, defaultValue))
+	assert.Equal(t, defaultValue, fp.JsonMapValue("not_exist", user, defaultValue))
+}
+
+func TestJsonSliceValue(t *testing.T) {
+	fp := NewFeatureProbeForTest(map[string]interface{}{
+		"regions": []interface{}{"eu", "us"},
+		"mixed":   []interface{}{"eu", true},
+		"map":     map[string]interface{}{"eu": "1"},
+	})
+	defaultValue := []string{"local"}
+	user := NewUser()
+
+	assert.Equal(t, []string{"eu", "us"}, fp.JsonSliceValue("regions", user, defaultValue))
+	assert.Equal(t, defaultValue, fp.JsonSliceValue("mixed", user, defaultValue))
+	assert.Equal(t, defaultValue, fp.JsonSliceValue("map", user, defaultValue))
+	assert.Equal(t, defaultValue, fp.JsonSliceValue("not_exist", user, defaultValue))
+}
@@ -0,0 +1,34 @@
+package featureprobe
+
+import "sync/atomic"
+
+// ForceDefaultsReason is the reason of every evaluation while force defaults
+// is on.
+const ForceDefaultsReason = "force defaults"
+
+type killSwitch struct {
+	on int32
+}
+
+// SetForceDefaults makes every evaluation return its default, from
+// WithDefaults or the call site, while on, without stopping the client, as an
+// escape hatch when toggle data itself is suspected to be bad. Syncing goes
+// on, so turning it off serves up to date toggles at once.
+func (fp *FeatureProbe) SetForceDefaults(on bool) {
+	if fp.killSwitch == nil {
+		fp.killSwitch = &killSwitch{}
+	}
+	var v int32
+	if on {
+		v = 1
+	}
+	atomic.StoreInt32(&fp.killSwitch.on, v)
+}
+
+func (fp *FeatureProbe) ForceDefaults() bool {
+	return fp.killSwitch.enabled()
+}
+
+func (k *killSwitch) enabled() bool {
+	return k != nil && atomic.LoadInt32(&k.on) == 1
+}
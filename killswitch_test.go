@@ -0,0 +1,36 @@
+package featureprobe
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestForceDefaults(t *testing.T) {
+	fp := NewFeatureProbeForTest(map[string]interface{}{"toggle": true, "color": "red"})
+	fp.Override("color", "blue")
+	user := NewUser()
+
+	fp.SetForceDefaults(true)
+	assert.True(t, fp.ForceDefaults())
+	assert.False(t, fp.BoolValue("toggle", user, false))
+	detail := fp.StrDetail("color", user, "green")
+	assert.Equal(t, "green", detail.Value)
+	assert.Equal(t, ForceDefaultsReason, detail.Reason)
+	assert.Nil(t, detail.Version)
+
+	fp.SetForceDefaults(false)
+	assert.False(t, fp.ForceDefaults())
+	assert.True(t, fp.BoolValue("toggle", user, false))
+	assert.Equal(t, "blue", fp.StrValue("color", user, "green"))
+}
+
+func TestForceDefaultsRecordsNoEvent(t *testing.T) {
+	repo, _ := setup(t)
+	recorder := NewEventRecorder("", 1000, "")
+	fp := FeatureProbe{Repo: NewRepositoryStore(&repo), Recorder: &recorder}
+	fp.SetForceDefaults(true)
+
+	assert.True(t, fp.BoolValue("bool_toggle", NewUser().StableRollout("key11"), true))
+	assert.Empty(t, recorder.takeEvents())
+}
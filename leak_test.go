@@ -0,0 +1,47 @@
+package featureprobe
+
+import (
+	"context"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// settledGoroutines waits for the goroutine count to drop to at most n.
+func settledGoroutines(n int) int {
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		count := runtime.NumGoroutine()
+		if count <= n || time.Now().After(deadline) {
+			return count
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestCloseLeaksNoGoroutines(t *testing.T) {
+	server := NewMockServer(Repository{Toggles: map[string]Toggle{"toggle": newToggleForTest("toggle", true)}})
+	defer server.Close()
+	before := settledGoroutines(0)
+
+	for i := 0; i < 5; i++ {
+		fp, err := NewFeatureProbe(server.URL(), "sdk_key", WithRefreshInterval(50))
+		assert.Nil(t, err)
+		fp.BoolValue("toggle", NewUser(), false)
+		time.Sleep(120 * time.Millisecond)
+		fp.Close()
+	}
+	for i := 0; i < 3; i++ {
+		fp, _ := NewFeatureProbe(server.URL(), "sdk_key", WithServerless(true))
+		fp.BoolValue("toggle", NewUser(), false)
+		_ = fp.FlushAtEnd(context.Background())
+		fp.Close()
+	}
+	fp, _ := NewFeatureProbe(server.URL(), "sdk_key", WithRemoteEvaluation(true))
+	fp.BoolValue("toggle", NewUser(), false)
+	fp.Close()
+
+	assert.Equal(t, before, settledGoroutines(before))
+}
@@ -0,0 +1,31 @@
+package featureprobe
+
+import (
+	"errors"
+	"sync/atomic"
+)
+
+// ErrClientClosed is returned by operations on a client after Close. A closed
+// client can't be restarted; build a new one with NewFeatureProbe.
+var ErrClientClosed = errors.New("FeatureProbe client closed")
+
+// closedReason is the reason of every evaluation after Close.
+const closedReason = "FeatureProbe closed"
+
+type lifecycle struct {
+	closed int32
+}
+
+func (l *lifecycle) close() bool {
+	return l != nil && atomic.CompareAndSwapInt32(&l.closed, 0, 1)
+}
+
+func (l *lifecycle) isClosed() bool {
+	return l != nil && atomic.LoadInt32(&l.closed) == 1
+}
+
+// Closed reports whether Close was called. Evaluations of a closed client
+// return their default with the reason "FeatureProbe closed".
+func (fp *FeatureProbe) Closed() bool {
+	return fp.lifecycle.isClosed()
+}
@@ -0,0 +1,27 @@
+package featureprobe
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClosedClient(t *testing.T) {
+	repo, _ := setup(t)
+	server := NewMockServer(repo)
+	defer server.Close()
+	fp, err := NewFeatureProbe(server.URL(), "sdk_key", WithRefreshInterval(100))
+	assert.Nil(t, err)
+	user := NewUser().StableRollout("key11").With("city", "1")
+	assert.True(t, fp.BoolValue("bool_toggle", user, false))
+	assert.False(t, fp.Closed())
+
+	fp.Close()
+	fp.Close()
+	assert.True(t, fp.Closed())
+	detail := fp.BoolDetail("bool_toggle", user, false)
+	assert.False(t, detail.Value)
+	assert.Equal(t, "FeatureProbe closed", detail.Reason)
+	assert.Equal(t, ErrClientClosed, fp.FlushAtEnd(context.Background()))
+}
@@ -0,0 +1,110 @@
+package featureprobe
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+)
+
+// WithLocalFile serves the toggles payload saved at path, as answered by
+// the toggles API, instead of syncing it, and reloads it whenever the file
+// changes, checked every refresh interval. For running without a
+// FeatureProbe server in development and edge deployments; add
+// WithOfflineMode to send no events either.
+func WithLocalFile(path string) Option {
+	return func(fpConfig *FPConfig) {
+		fpConfig.LocalFile = path
+	}
+}
+
+type fileWatcher struct {
+	path     string
+	repo     *RepositoryStore
+	codec    *JSONCodec
+	onUpdate []func(previous, repo *Repository)
+	report   func(error)
+	ticker   Ticker
+	modTime  time.Time
+	size     int64
+	stopChan chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+func newFileWatcher(path string, repo *RepositoryStore, syncer *Synchronizer, config FPConfig) *fileWatcher {
+	return &fileWatcher{
+		path:     path,
+		repo:     repo,
+		codec:    config.JSONCodec,
+		onUpdate: syncer.onUpdate,
+		report:   config.reportError,
+		stopChan: make(chan struct{}),
+	}
+}
+
+// load reads the file if it changed since last loaded.
+func (w *fileWatcher) load() error {
+	info, err := os.Stat(w.path)
+	if err != nil {
+		return err
+	}
+	if info.ModTime().Equal(w.modTime) && info.Size() == w.size {
+		return nil
+	}
+	data, err := ioutil.ReadFile(w.path)
+	if err != nil {
+		return err
+	}
+	current := w.repo.Load()
+	repo, err := buildRepositoryWith(w.codec, data, current)
+	if err != nil {
+		return fmt.Errorf("load toggles file %s fails: %w", w.path, err)
+	}
+	w.modTime, w.size = info.ModTime(), info.Size()
+	w.repo.Store(repo)
+	for _, f := range w.onUpdate {
+		f(current, repo)
+	}
+	return nil
+}
+
+func (w *fileWatcher) start(clock Clock, interval time.Duration) {
+	w.ticker = clock.NewTicker(interval)
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		for {
+			select {
+			case <-w.stopChan:
+				w.ticker.Stop()
+				return
+			case <-w.ticker.C():
+				if err := w.load(); err != nil {
+					w.report(err)
+				}
+			}
+		}
+	}()
+}
+
+func (w *fileWatcher) stop() {
+	if w == nil {
+		return
+	}
+	w.stopOnce.Do(func() {
+		close(w.stopChan)
+	})
+	w.wg.Wait()
+}
+
+func init() {
+	RegisterPlugin(PluginDataSource, "file", func(params map[string]interface{}) (Option, error) {
+		path, _ := params["path"].(string)
+		if path == "" {
+			return nil, fmt.Errorf("path param required")
+		}
+		return WithLocalFile(path), nil
+	})
+}
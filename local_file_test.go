@@ -0,0 +1,74 @@
+package featureprobe
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeTogglesFile(t *testing.T, path string, value string, version uint64) {
+	toggle := newToggleForTest("toggle", value)
+	toggle.Version = version
+	data, err := json.Marshal(Repository{Toggles: map[string]Toggle{"toggle": toggle}})
+	assert.Nil(t, err)
+	assert.Nil(t, ioutil.WriteFile(path, data, 0644))
+}
+
+func TestLocalFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "featureprobe")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "toggles.json")
+	writeTogglesFile(t, path, "v1", 1)
+
+	clock := NewManualClock(time.Unix(1000, 0))
+	reported := make(chan error, 10)
+	changes := make(chan RepositoryDiff, 10)
+	fp, err := NewFeatureProbe("http://127.0.0.1:1", "sdk_key", WithLocalFile(path), WithOfflineMode(true), WithClock(clock),
+		WithErrorListener(func(err error) { reported <- err }),
+		WithChangeListener(func(diff RepositoryDiff) { changes <- diff }))
+	assert.Nil(t, err)
+	defer fp.Close()
+	assert.Nil(t, fp.Syncer)
+	assert.Equal(t, "v1", fp.StrValue("toggle", NewUser(), "default"))
+
+	writeTogglesFile(t, path, "v2", 2)
+	assert.Nil(t, os.Chtimes(path, time.Now(), time.Now().Add(time.Minute)))
+	clock.Advance(2 * time.Second)
+	waitForValue(fp, "toggle", "v2")
+	assert.Equal(t, "v2", fp.StrValue("toggle", NewUser(), "default"))
+	for i := 0; i < 2; i++ {
+		select {
+		case <-changes:
+		case <-time.After(time.Second):
+			t.Fatal("change not notified")
+		}
+	}
+
+	assert.Nil(t, ioutil.WriteFile(path, []byte("{"), 0644))
+	assert.Nil(t, os.Chtimes(path, time.Now(), time.Now().Add(2*time.Minute)))
+	clock.Advance(2 * time.Second)
+	select {
+	case err := <-reported:
+		assert.Contains(t, err.Error(), "load toggles file")
+	case <-time.After(time.Second):
+		t.Fatal("invalid file not reported")
+	}
+	assert.Equal(t, "v2", fp.StrValue("toggle", NewUser(), "default"))
+}
+
+func TestLocalFileMissing(t *testing.T) {
+	_, err := NewFeatureProbe("http://127.0.0.1:1", "sdk_key", WithLocalFile("missing.json"), WithOfflineMode(true))
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+}
+
+func TestLocalFilePlugin(t *testing.T) {
+	_, err := NewFeatureProbeFromConfig([]byte(`{"dataSource": {"name": "file", "params": {"path": "missing.json"}}}`), WithOfflineMode(true))
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+}
@@ -0,0 +1,62 @@
+package featureprobe
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// LogLevel is the least severe message the SDK prints. Errors sent to an
+// ErrorListener are not printed whatever the level.
+type LogLevel int32
+
+const (
+	// LogLevelInfo prints errors and notices such as active overrides, the
+	// default.
+	LogLevelInfo LogLevel = iota + 1
+	// LogLevelError prints errors only.
+	LogLevelError
+	// LogLevelSilent prints nothing.
+	LogLevelSilent
+)
+
+// WithLogLevel sets the least severe message printed. UpdateConfig can
+// change it on a running client.
+func WithLogLevel(level LogLevel) Option {
+	return func(fpConfig *FPConfig) {
+		fpConfig.LogLevel = level
+	}
+}
+
+// logger prints at or above its level. A nil logger prints everything.
+type logger struct {
+	level int32
+}
+
+func newLogger(level LogLevel) *logger {
+	l := &logger{}
+	l.setLevel(level)
+	return l
+}
+
+func (l *logger) setLevel(level LogLevel) {
+	if level == 0 {
+		level = LogLevelInfo
+	}
+	atomic.StoreInt32(&l.level, int32(level))
+}
+
+func (l *logger) enabled(level LogLevel) bool {
+	return l == nil || LogLevel(atomic.LoadInt32(&l.level)) <= level
+}
+
+func (l *logger) errorf(format string, args ...interface{}) {
+	if l.enabled(LogLevelError) {
+		fmt.Printf(format+"\n", args...)
+	}
+}
+
+func (l *logger) infof(format string, args ...interface{}) {
+	if l.enabled(LogLevelInfo) {
+		fmt.Printf(format+"\n", args...)
+	}
+}
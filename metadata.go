@@ -0,0 +1,48 @@
+package featureprobe
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"os"
+	"runtime"
+)
+
+// SdkMetadata identifies the SDK and the instance sending events, so the
+// platform can attribute traffic and tell instances apart.
+type SdkMetadata struct {
+	Name       string `json:"name"`
+	Version    string `json:"version"`
+	Language   string `json:"language"`
+	Hostname   string `json:"hostname,omitempty"`
+	InstanceId string `json:"instanceId"`
+	// Attributes are the client's global attributes.
+	Attributes map[string]string `json:"attributes,omitempty"`
+}
+
+const sdkName = "server-sdk-go"
+
+// newSdkMetadata describes this process, with an instance id unique to each
+// event recorder.
+func newSdkMetadata() SdkMetadata {
+	hostname, _ := os.Hostname()
+	return SdkMetadata{
+		Name:       sdkName,
+		Version:    VERSION,
+		Language:   "go " + runtime.Version(),
+		Hostname:   hostname,
+		InstanceId: newInstanceId(),
+	}
+}
+
+func newInstanceId() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
+
+// Metadata is the SDK metadata sent with every events payload.
+func (e *EventRecorder) Metadata() SdkMetadata {
+	return e.metadata
+}
@@ -0,0 +1,31 @@
+package featureprobe
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFlushSendsSdkMetadata(t *testing.T) {
+	server := NewMockServer(Repository{})
+	defer server.Close()
+	fp, _ := NewFeatureProbe(server.URL(), "sdk_key", WithServerless(true))
+	fp.Recorder.RecordAccess(AccessEvent{Time: 1, Key: "toggle", Value: true, Reason: "default"})
+	assert.Nil(t, fp.FlushAtEnd(context.Background()))
+
+	events := server.Events()
+	assert.Len(t, events, 1)
+	sdk := events[0].Sdk
+	assert.Equal(t, "server-sdk-go", sdk.Name)
+	assert.Equal(t, VERSION, sdk.Version)
+	assert.Contains(t, sdk.Language, "go")
+	assert.Len(t, sdk.InstanceId, 32)
+	assert.Equal(t, fp.Recorder.Metadata(), sdk)
+}
+
+func TestInstanceIdPerRecorder(t *testing.T) {
+	r1 := NewEventRecorder("http://localhost/api/events", 1000, "sdk_key")
+	r2 := NewEventRecorder("http://localhost/api/events", 1000, "sdk_key")
+	assert.NotEqual(t, r1.Metadata().InstanceId, r2.Metadata().InstanceId)
+}
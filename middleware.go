@@ -0,0 +1,133 @@
+package featureprobe
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+)
+
+// UserExtractor adds what it finds in a request to the user built by the
+// middleware, such as its key or attributes.
+type UserExtractor func(r *http.Request, user FPUser) FPUser
+
+type MiddlewareOption func(*middlewareConfig)
+
+type middlewareConfig struct {
+	extractors   []UserExtractor
+	headerPrefix string
+	toggles      []string
+}
+
+type userContextKey struct{}
+
+func WithUserExtractors(extractors ...UserExtractor) MiddlewareOption {
+	return func(c *middlewareConfig) {
+		c.extractors = append(c.extractors, extractors...)
+	}
+}
+
+// WithAssignmentHeaders evaluates toggles for every request and sets each
+// value as a response header named prefix + toggle key.
+func WithAssignmentHeaders(prefix string, toggles ...string) MiddlewareOption {
+	return func(c *middlewareConfig) {
+		c.headerPrefix = prefix
+		c.toggles = append(c.toggles, toggles...)
+	}
+}
+
+// Middleware builds an FPUser for every request and stores it in the request
+// context, where UserFromContext finds it.
+func Middleware(client FPClient, opts ...MiddlewareOption) func(http.Handler) http.Handler {
+	config := middlewareConfig{}
+	for _, opt := range opts {
+		opt(&config)
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user := UserFromRequest(r, config.extractors...)
+			for _, toggle := range config.toggles {
+				value := client.JsonValue(toggle, user, nil)
+				if value != nil {
+					w.Header().Set(config.headerPrefix+toggle, headerValue(value))
+				}
+			}
+			next.ServeHTTP(w, r.WithContext(ContextWithUser(r.Context(), user)))
+		})
+	}
+}
+
+// UserFromRequest builds a user by running extractors in order.
+func UserFromRequest(r *http.Request, extractors ...UserExtractor) FPUser {
+	user := NewUser()
+	for _, extract := range extractors {
+		user = extract(r, user)
+	}
+	return user
+}
+
+func ContextWithUser(ctx context.Context, user FPUser) context.Context {
+	return context.WithValue(ctx, userContextKey{}, user)
+}
+
+func UserFromContext(ctx context.Context) (FPUser, bool) {
+	user, ok := ctx.Value(userContextKey{}).(FPUser)
+	return user, ok
+}
+
+func KeyFromHeader(header string) UserExtractor {
+	return func(r *http.Request, user FPUser) FPUser {
+		if v := r.Header.Get(header); v != "" {
+			return user.StableRollout(v)
+		}
+		return user
+	}
+}
+
+func KeyFromCookie(name string) UserExtractor {
+	return func(r *http.Request, user FPUser) FPUser {
+		if c, err := r.Cookie(name); err == nil && c.Value != "" {
+			return user.StableRollout(c.Value)
+		}
+		return user
+	}
+}
+
+func AttrFromHeader(header string, attr string) UserExtractor {
+	return func(r *http.Request, user FPUser) FPUser {
+		if v := r.Header.Get(header); v != "" {
+			return user.With(attr, v)
+		}
+		return user
+	}
+}
+
+func AttrFromCookie(name string, attr string) UserExtractor {
+	return func(r *http.Request, user FPUser) FPUser {
+		if c, err := r.Cookie(name); err == nil && c.Value != "" {
+			return user.With(attr, c.Value)
+		}
+		return user
+	}
+}
+
+// AttrsFromClaims adds the claims returned by claims, e.g. read from a token
+// an authentication middleware verified earlier, as user attributes.
+func AttrsFromClaims(claims func(r *http.Request) map[string]string) UserExtractor {
+	return func(r *http.Request, user FPUser) FPUser {
+		for k, v := range claims(r) {
+			user = user.With(k, v)
+		}
+		return user
+	}
+}
+
+func headerValue(value interface{}) string {
+	if s, ok := value.(string); ok {
+		return s
+	}
+	bytes, err := json.Marshal(value)
+	if err != nil {
+		return ""
+	}
+	return string(bytes)
+}
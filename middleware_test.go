@@ -0,0 +1,55 @@
+package featureprobe
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMiddlewareUser(t *testing.T) {
+	fp := NewFeatureProbeForTest(map[string]interface{}{"toggle": true, "color": "red"})
+	var user FPUser
+	var found bool
+	handler := Middleware(&fp,
+		WithUserExtractors(
+			KeyFromHeader("X-User-Id"),
+			AttrFromHeader("X-City", "city"),
+			AttrFromCookie("plan", "plan"),
+			AttrsFromClaims(func(r *http.Request) map[string]string {
+				return map[string]string{"role": "admin"}
+			}),
+		),
+		WithAssignmentHeaders("X-FP-", "toggle", "color", "not_exist"),
+	)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, found = UserFromContext(r.Context())
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-User-Id", "user1")
+	req.Header.Set("X-City", "1")
+	req.AddCookie(&http.Cookie{Name: "plan", Value: "pro"})
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	assert.True(t, found)
+	assert.Equal(t, "user1", user.Key())
+	assert.Equal(t, "1", user.Get("city"))
+	assert.Equal(t, "pro", user.Get("plan"))
+	assert.Equal(t, "admin", user.Get("role"))
+	assert.Equal(t, "true", w.Header().Get("X-FP-toggle"))
+	assert.Equal(t, "red", w.Header().Get("X-FP-color"))
+	assert.Equal(t, "", w.Header().Get("X-FP-not_exist"))
+}
+
+func TestUserFromRequestKeyFromCookie(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(&http.Cookie{Name: "uid", Value: "user2"})
+
+	user := UserFromRequest(req, KeyFromCookie("uid"), KeyFromHeader("X-User-Id"))
+	assert.Equal(t, "user2", user.Key())
+
+	_, ok := UserFromContext(req.Context())
+	assert.False(t, ok)
+}
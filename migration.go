@@ -0,0 +1,123 @@
+package featureprobe
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// MigrationStage is a value served by the string toggle driving a
+// Migration.
+type MigrationStage string
+
+const (
+	// MigrationOff reads and writes the old system only.
+	MigrationOff MigrationStage = "off"
+	// MigrationDualWrite writes both systems and reads the old one.
+	MigrationDualWrite MigrationStage = "dual-write"
+	// MigrationShadowRead also reads the new system, checking it against
+	// the old one, which is still returned.
+	MigrationShadowRead MigrationStage = "shadow-read"
+	// MigrationLive returns the new system, still checked against the old
+	// one, which is still written.
+	MigrationLive MigrationStage = "live"
+	// MigrationComplete reads and writes the new system only.
+	MigrationComplete MigrationStage = "complete"
+)
+
+// Migration moves reads and writes from an old code path to a new one in
+// the stages served by a string toggle, so a data migration can be rolled
+// forward and back from the console. Errors of the path not authoritative
+// at a stage are reported to the error listener instead of returned.
+type Migration struct {
+	Toggle string
+	// Check tells whether the old and new reads agree, reflect.DeepEqual
+	// when not set.
+	Check func(old, new interface{}) bool
+	// OnInconsistent receives the reads failing Check.
+	OnInconsistent func(stage MigrationStage, old, new interface{})
+	fp             *FeatureProbe
+}
+
+// NewMigration returns a migration driven by toggle, whose variations are
+// MigrationStage values. Users it is off for, or serving an unknown stage,
+// use the old path.
+func (fp *FeatureProbe) NewMigration(toggle string) *Migration {
+	return &Migration{Toggle: toggle, fp: fp}
+}
+
+// Stage is the stage of the migration for user.
+func (m *Migration) Stage(user FPUser) MigrationStage {
+	stage := MigrationStage(m.fp.StrValue(m.Toggle, user, string(MigrationOff)))
+	switch stage {
+	case MigrationOff, MigrationDualWrite, MigrationShadowRead, MigrationLive, MigrationComplete:
+		return stage
+	}
+	m.fp.Config.reportError(fmt.Errorf("migration %s serves unknown stage %q", m.Toggle, stage))
+	return MigrationOff
+}
+
+// Read reads with oldRead or newRead, or both, at the stage of user.
+func (m *Migration) Read(user FPUser, oldRead, newRead func() (interface{}, error)) (interface{}, error) {
+	stage := m.Stage(user)
+	switch stage {
+	case MigrationShadowRead:
+		old, err := oldRead()
+		if shadow, shadowErr := newRead(); shadowErr != nil {
+			m.reportError("read", shadowErr)
+		} else if err == nil {
+			m.check(stage, old, shadow)
+		}
+		return old, err
+	case MigrationLive:
+		value, err := newRead()
+		if old, oldErr := oldRead(); oldErr != nil {
+			m.reportError("read", oldErr)
+		} else if err == nil {
+			m.check(stage, old, value)
+		}
+		return value, err
+	case MigrationComplete:
+		return newRead()
+	}
+	return oldRead()
+}
+
+// Write writes with oldWrite or newWrite, or both, at the stage of user. The
+// authoritative path is written first, and the other one only if it succeeds.
+func (m *Migration) Write(user FPUser, oldWrite, newWrite func() error) error {
+	switch m.Stage(user) {
+	case MigrationDualWrite, MigrationShadowRead:
+		if err := oldWrite(); err != nil {
+			return err
+		}
+		if err := newWrite(); err != nil {
+			m.reportError("write", err)
+		}
+		return nil
+	case MigrationLive:
+		if err := newWrite(); err != nil {
+			return err
+		}
+		if err := oldWrite(); err != nil {
+			m.reportError("write", err)
+		}
+		return nil
+	case MigrationComplete:
+		return newWrite()
+	}
+	return oldWrite()
+}
+
+func (m *Migration) check(stage MigrationStage, old, new interface{}) {
+	consistent := m.Check
+	if consistent == nil {
+		consistent = reflect.DeepEqual
+	}
+	if !consistent(old, new) && m.OnInconsistent != nil {
+		m.OnInconsistent(stage, old, new)
+	}
+}
+
+func (m *Migration) reportError(op string, err error) {
+	m.fp.Config.reportError(fmt.Errorf("migration %s %s fails: %w", m.Toggle, op, err))
+}
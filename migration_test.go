@@ -0,0 +1,84 @@
+package featureprobe
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type migrationCalls struct {
+	reads, writes []string
+}
+
+func (c *migrationCalls) read(name string, value interface{}, err error) func() (interface{}, error) {
+	return func() (interface{}, error) {
+		c.reads = append(c.reads, name)
+		return value, err
+	}
+}
+
+func (c *migrationCalls) write(name string, err error) func() error {
+	return func() error {
+		c.writes = append(c.writes, name)
+		return err
+	}
+}
+
+func TestMigrationStages(t *testing.T) {
+	cases := []struct {
+		stage  MigrationStage
+		value  interface{}
+		reads  []string
+		writes []string
+	}{
+		{MigrationOff, "old", []string{"old"}, []string{"old"}},
+		{MigrationDualWrite, "old", []string{"old"}, []string{"old", "new"}},
+		{MigrationShadowRead, "old", []string{"old", "new"}, []string{"old", "new"}},
+		{MigrationLive, "new", []string{"new", "old"}, []string{"new", "old"}},
+		{MigrationComplete, "new", []string{"new"}, []string{"new"}},
+		{"unknown", "old", []string{"old"}, []string{"old"}},
+	}
+	for _, c := range cases {
+		fp := NewFeatureProbeForTest(map[string]interface{}{"migration": string(c.stage)})
+		fp.Config.ErrorListener = func(error) {}
+		var inconsistent []MigrationStage
+		m := fp.NewMigration("migration")
+		m.OnInconsistent = func(stage MigrationStage, old, new interface{}) {
+			inconsistent = append(inconsistent, stage)
+		}
+		calls := &migrationCalls{}
+
+		value, err := m.Read(NewUser(), calls.read("old", "old", nil), calls.read("new", "new", nil))
+		assert.Nil(t, err)
+		assert.Equal(t, c.value, value, c.stage)
+		assert.Equal(t, c.reads, calls.reads, c.stage)
+		assert.Nil(t, m.Write(NewUser(), calls.write("old", nil), calls.write("new", nil)))
+		assert.Equal(t, c.writes, calls.writes, c.stage)
+		if len(c.reads) == 2 {
+			assert.Equal(t, []MigrationStage{c.stage}, inconsistent)
+		} else {
+			assert.Empty(t, inconsistent)
+		}
+	}
+}
+
+func TestMigrationErrors(t *testing.T) {
+	fp := NewFeatureProbeForTest(map[string]interface{}{"migration": string(MigrationShadowRead)})
+	var reported []error
+	fp.Config.ErrorListener = func(err error) { reported = append(reported, err) }
+	m := fp.NewMigration("migration")
+	calls := &migrationCalls{}
+	failure := errors.New("unavailable")
+
+	value, err := m.Read(NewUser(), calls.read("old", "old", nil), calls.read("new", nil, failure))
+	assert.Nil(t, err)
+	assert.Equal(t, "old", value)
+	assert.Nil(t, m.Write(NewUser(), calls.write("old", nil), calls.write("new", failure)))
+	assert.Equal(t, failure, m.Write(NewUser(), calls.write("old", failure), calls.write("new", nil)))
+	assert.Equal(t, []string{"old", "new", "old"}, calls.writes)
+
+	assert.Len(t, reported, 2)
+	assert.EqualError(t, reported[0], "migration migration read fails: unavailable")
+	assert.EqualError(t, reported[1], "migration migration write fails: unavailable")
+}
@@ -0,0 +1,217 @@
+package featureprobe
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+)
+
+const (
+	mockTogglesPath  = "/api/server-sdk/toggles"
+	mockEventsPath   = "/api/events"
+	mockEvaluatePath = "/api/server-sdk/evaluate"
+)
+
+// MockServer is an in-process FeatureProbe server for integration tests. It
+// serves toggles from a fixture repository, collects posted events, and can be
+// scripted to fail or delay responses.
+type MockServer struct {
+	server   *httptest.Server
+	mu       sync.Mutex
+	repo     Repository
+	events   []PackedData
+	failures map[string][]int
+	delay    time.Duration
+	requests map[string]int
+}
+
+func NewMockServer(repo Repository) *MockServer {
+	m := &MockServer{
+		repo:     repo,
+		events:   []PackedData{},
+		failures: map[string][]int{},
+		requests: map[string]int{},
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc(mockTogglesPath, m.handleToggles)
+	mux.HandleFunc(mockEventsPath, m.handleEvents)
+	mux.HandleFunc(mockEvaluatePath, m.handleEvaluate)
+	m.server = httptest.NewServer(mux)
+	return m
+}
+
+// URL returns the remote url to pass to NewFeatureProbe.
+func (m *MockServer) URL() string {
+	return m.server.URL + "/"
+}
+
+func (m *MockServer) SetRepository(repo Repository) {
+	m.mu.Lock()
+	m.repo = repo
+	m.mu.Unlock()
+}
+
+// FailToggles makes the next `times` toggle requests respond with status.
+func (m *MockServer) FailToggles(status int, times int) {
+	m.fail(mockTogglesPath, status, times)
+}
+
+// FailEvents makes the next `times` event requests respond with status.
+func (m *MockServer) FailEvents(status int, times int) {
+	m.fail(mockEventsPath, status, times)
+}
+
+// FailEvaluate makes the next `times` remote evaluation requests respond with status.
+func (m *MockServer) FailEvaluate(status int, times int) {
+	m.fail(mockEvaluatePath, status, times)
+}
+
+// SetDelay delays every response, e.g. to exercise client timeouts.
+func (m *MockServer) SetDelay(delay time.Duration) {
+	m.mu.Lock()
+	m.delay = delay
+	m.mu.Unlock()
+}
+
+func (m *MockServer) Events() []PackedData {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]PackedData{}, m.events...)
+}
+
+func (m *MockServer) TogglesRequests() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.requests[mockTogglesPath]
+}
+
+func (m *MockServer) EvaluateRequests() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.requests[mockEvaluatePath]
+}
+
+func (m *MockServer) EventsRequests() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.requests[mockEventsPath]
+}
+
+func (m *MockServer) Close() {
+	m.server.Close()
+}
+
+func (m *MockServer) fail(path string, status int, times int) {
+	m.mu.Lock()
+	for i := 0; i < times; i++ {
+		m.failures[path] = append(m.failures[path], status)
+	}
+	m.mu.Unlock()
+}
+
+// prepare counts the request, applies the delay and reports a scripted failure.
+func (m *MockServer) prepare(path string) (int, bool) {
+	m.mu.Lock()
+	m.requests[path]++
+	delay := m.delay
+	status, failed := 0, false
+	if f := m.failures[path]; len(f) > 0 {
+		status, failed = f[0], true
+		m.failures[path] = f[1:]
+	}
+	m.mu.Unlock()
+
+	if delay > 0 {
+		time.Sleep(delay)
+	}
+	return status, failed
+}
+
+func (m *MockServer) handleToggles(w http.ResponseWriter, r *http.Request) {
+	if status, failed := m.prepare(mockTogglesPath); failed {
+		w.WriteHeader(status)
+		return
+	}
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	m.mu.Lock()
+	body, err := json.Marshal(m.repo)
+	m.mu.Unlock()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(body)
+}
+
+func (m *MockServer) handleEvents(w http.ResponseWriter, r *http.Request) {
+	if status, failed := m.prepare(mockEventsPath); failed {
+		w.WriteHeader(status)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	var packed []PackedData
+	if err := json.Unmarshal(body, &packed); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	m.mu.Lock()
+	m.events = append(m.events, packed...)
+	m.mu.Unlock()
+	_, _ = w.Write([]byte("{}"))
+}
+
+// handleEvaluate answers remote evaluations from the fixture repository.
+func (m *MockServer) handleEvaluate(w http.ResponseWriter, r *http.Request) {
+	if status, failed := m.prepare(mockEvaluatePath); failed {
+		w.WriteHeader(status)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req RemoteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	user := NewUser().StableRollout(req.User.Key)
+	for k, v := range req.User.Attrs {
+		user = user.With(k, v)
+	}
+
+	m.mu.Lock()
+	repo := m.repo
+	m.mu.Unlock()
+	results := map[string]RemoteResult{}
+	memo := map[string]bool{}
+	for _, toggle := range req.Toggles {
+		results[toggle] = repo.remoteResult(toggle, user, memo)
+	}
+	body, err := json.Marshal(results)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(body)
+}
@@ -0,0 +1,80 @@
+package featureprobe
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMockServerToggles(t *testing.T) {
+	repo, _ := setup(t)
+	server := NewMockServer(repo)
+	defer server.Close()
+
+	repo2 := NewRepositoryStore(&Repository{})
+	synchronizer := NewSynchronizer(server.URL()+"api/server-sdk/toggles", 100, "sdk_key", repo2)
+	synchronizer.Start(true)
+	defer synchronizer.Stop()
+
+	assert.Equal(t, repo, *repo2.Load())
+	assert.True(t, server.TogglesRequests() >= 1)
+}
+
+func TestMockServerScriptedFailure(t *testing.T) {
+	repo, _ := setup(t)
+	server := NewMockServer(repo)
+	defer server.Close()
+	server.FailToggles(http.StatusInternalServerError, 2)
+
+	repo2 := NewRepositoryStore(&Repository{})
+	synchronizer := NewSynchronizer(server.URL()+"api/server-sdk/toggles", 100, "sdk_key", repo2)
+	synchronizer.Start(true)
+	defer synchronizer.Stop()
+
+	assert.Equal(t, 0, len(repo2.Load().Toggles))
+
+	time.Sleep(500 * time.Millisecond)
+	assert.Equal(t, repo, *repo2.Load())
+}
+
+func TestMockServerDelay(t *testing.T) {
+	server := NewMockServer(Repository{})
+	defer server.Close()
+	server.SetDelay(300 * time.Millisecond)
+
+	synchronizer := NewSynchronizer(server.URL()+"api/server-sdk/toggles", 100, "sdk_key", NewRepositoryStore(&Repository{}))
+	start := time.Now()
+	synchronizer.fetchRemoteRepo()
+	assert.True(t, time.Since(start) >= 100*time.Millisecond)
+	assert.Equal(t, 1, server.TogglesRequests())
+}
+
+func TestMockServerEvents(t *testing.T) {
+	repo, _ := setup(t)
+	server := NewMockServer(repo)
+	defer server.Close()
+
+	fp, err := NewFeatureProbe(server.URL(), "sdk_key", WithRefreshInterval(100))
+	assert.NoError(t, err)
+	user := NewUser().StableRollout("key11").With("city", "1")
+	assert.Equal(t, true, fp.BoolValue("bool_toggle", user, false))
+	fp.Close()
+
+	events := server.Events()
+	assert.Equal(t, 1, len(events))
+	assert.Equal(t, 1, len(events[0].Events))
+	assert.Equal(t, "bool_toggle", events[0].Events[0].Key)
+}
+
+func TestMockServerSplitFixture(t *testing.T) {
+	repo, _ := setup(t)
+	bytes, err := json.Marshal(repo)
+	assert.NoError(t, err)
+
+	decoded, err := buildRepository(bytes, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, repo, *decoded)
+}
@@ -0,0 +1,62 @@
+package featureprobe
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// ObsoleteToggleError reports a toggle still evaluated although it is
+// archived or past its planned end time, so it can be cleaned up from code.
+type ObsoleteToggleError struct {
+	Key            string
+	Archived       bool
+	PlannedEndTime time.Time
+}
+
+func (e *ObsoleteToggleError) Error() string {
+	if e.Archived {
+		return fmt.Sprintf("toggle %s is archived but still evaluated", e.Key)
+	}
+	return fmt.Sprintf("toggle %s was planned to end at %s but is still evaluated", e.Key, e.PlannedEndTime.UTC().Format(time.RFC3339))
+}
+
+func (t *Toggle) obsolete(now time.Time) bool {
+	return t.Archived || t.PlannedEndTime != 0 && unixMillis(now) >= t.PlannedEndTime
+}
+
+// checkObsolete counts an evaluation of an obsolete toggle and reports the
+// first one to the error listener.
+func (fp *FeatureProbe) checkObsolete(t *Toggle) {
+	if t.usage == nil || !t.obsolete(clockOrSystem(fp.Config.Clock).Now()) {
+		return
+	}
+	atomic.AddUint64(&t.usage.obsolete, 1)
+	if atomic.CompareAndSwapUint32(&t.usage.warned, 0, 1) {
+		err := &ObsoleteToggleError{Key: t.Key, Archived: t.Archived}
+		if t.PlannedEndTime != 0 {
+			err.PlannedEndTime = time.Unix(0, t.PlannedEndTime*int64(time.Millisecond))
+		}
+		fp.Config.reportError(err)
+	}
+}
+
+// ObsoleteEvaluations returns, for the toggles of the repository evaluated
+// since they were archived or past their planned end, how many times this
+// client evaluated them since.
+func (fp *FeatureProbe) ObsoleteEvaluations() map[string]uint64 {
+	counts := map[string]uint64{}
+	repo := fp.Repo.Load()
+	if repo == nil {
+		return counts
+	}
+	for key, t := range repo.Toggles {
+		if t.usage == nil {
+			continue
+		}
+		if n := atomic.LoadUint64(&t.usage.obsolete); n > 0 {
+			counts[key] = n
+		}
+	}
+	return counts
+}
@@ -0,0 +1,44 @@
+package featureprobe
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestObsoleteToggles(t *testing.T) {
+	archived := newToggleForTest("archived", true)
+	archived.Archived = true
+	ending := newToggleForTest("ending", true)
+	ending.PlannedEndTime = 1000 * 1000
+	server := NewMockServer(Repository{Toggles: map[string]Toggle{
+		"archived": archived,
+		"ending":   ending,
+		"active":   newToggleForTest("active", true),
+	}})
+	defer server.Close()
+
+	clock := NewManualClock(time.Unix(999, 0))
+	var reported []error
+	fp, err := NewFeatureProbe(server.URL(), "sdk_key", WithServerless(true), WithClock(clock), WithErrorListener(func(err error) {
+		reported = append(reported, err)
+	}))
+	assert.Nil(t, err)
+
+	for i := 0; i < 3; i++ {
+		assert.True(t, fp.BoolValue("archived", NewUser(), false))
+		assert.True(t, fp.BoolValue("ending", NewUser(), false))
+		assert.True(t, fp.BoolValue("active", NewUser(), false))
+	}
+	assert.Equal(t, map[string]uint64{"archived": 3}, fp.ObsoleteEvaluations())
+	assert.Len(t, reported, 1)
+	assert.EqualError(t, reported[0], "toggle archived is archived but still evaluated")
+
+	clock.Advance(time.Second)
+	fp.BoolValue("ending", NewUser(), false)
+	fp.BoolValue("ending", NewUser(), false)
+	assert.Equal(t, map[string]uint64{"archived": 3, "ending": 2}, fp.ObsoleteEvaluations())
+	assert.Len(t, reported, 2)
+	assert.EqualError(t, reported[1], "toggle ending was planned to end at 1970-01-01T00:16:40Z but is still evaluated")
+}
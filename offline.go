@@ -0,0 +1,70 @@
+package featureprobe
+
+import "sync/atomic"
+
+// offlineReason is the reason of remote evaluations while offline.
+const offlineReason = "FeatureProbe offline"
+
+// SetOffline stops all network activity while on: toggles are no longer
+// synced and events are held instead of sent, up to the same bound as after
+// failed flushes, and reported once back online. Evaluations go on from the
+// repository in memory. For emergency isolation of outbound traffic. Remote
+// evaluations return their default.
+func (fp *FeatureProbe) SetOffline(offline bool) {
+	var v int32
+	if offline {
+		v = 1
+	}
+	if fp.Syncer != nil {
+		atomic.StoreInt32(&fp.Syncer.offline, v)
+	}
+	if fp.Recorder != nil {
+		atomic.StoreInt32(&fp.Recorder.offline, v)
+	}
+	if fp.remote != nil {
+		atomic.StoreInt32(&fp.remote.offline, v)
+	}
+}
+
+// WithOfflineMode never opens a network connection: toggles are not synced,
+// no events are recorded or forwarded to event sinks, the data store and big
+// segment store are not queried, and evaluations are served from the
+// repository given with WithRepository or WithCacheFile, overrides and
+// defaults. For CI, air-gapped environments and local development.
+func WithOfflineMode(offline bool) Option {
+	return func(fpConfig *FPConfig) {
+		fpConfig.OfflineMode = offline
+	}
+}
+
+// WithRepository serves repo until synced toggles replace it, or for good in
+// offline mode.
+func WithRepository(repo *Repository) Option {
+	return func(fpConfig *FPConfig) {
+		fpConfig.Repository = repo
+	}
+}
+
+// localRepository copies repo, so the caller's is never compiled or shared.
+func localRepository(repo *Repository) *Repository {
+	local := repo.detached()
+	local.SchemaVersion = repo.SchemaVersion
+	local.compile(nil)
+	return &local
+}
+
+// Offline is also true for clients in offline mode, which SetOffline can't
+// bring online.
+func (fp *FeatureProbe) Offline() bool {
+	switch {
+	case fp.Config.OfflineMode:
+		return true
+	case fp.Syncer != nil:
+		return atomic.LoadInt32(&fp.Syncer.offline) == 1
+	case fp.Recorder != nil:
+		return atomic.LoadInt32(&fp.Recorder.offline) == 1
+	case fp.remote != nil:
+		return atomic.LoadInt32(&fp.remote.offline) == 1
+	}
+	return false
+}
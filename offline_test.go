@@ -0,0 +1,132 @@
+package featureprobe
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetOffline(t *testing.T) {
+	repo, _ := setup(t)
+	server := NewMockServer(repo)
+	defer server.Close()
+	fp, err := NewFeatureProbe(server.URL(), "sdk_key", WithServerless(true))
+	assert.Nil(t, err)
+	user := NewUser().StableRollout("key11").With("city", "1")
+	assert.True(t, fp.BoolValue("bool_toggle", user, false))
+
+	fp.SetOffline(true)
+	assert.True(t, fp.Offline())
+	fp.Syncer.fetchRemoteRepo()
+	assert.True(t, fp.BoolValue("bool_toggle", user, false))
+	assert.Nil(t, fp.FlushAtEnd(context.Background()))
+	assert.Equal(t, 1, server.TogglesRequests())
+	assert.Equal(t, 0, server.EventsRequests())
+
+	fp.SetOffline(false)
+	assert.False(t, fp.Offline())
+	assert.Nil(t, fp.FlushAtEnd(context.Background()))
+	events := server.Events()
+	assert.Len(t, events, 1)
+	assert.Len(t, events[0].Events, 2)
+}
+
+func TestSetOfflineBoundsHeldEvents(t *testing.T) {
+	server := NewMockServer(Repository{})
+	defer server.Close()
+	fp, err := NewFeatureProbe(server.URL(), "sdk_key", WithServerless(true))
+	assert.Nil(t, err)
+	version := uint64(1)
+	index := 0
+	event := AccessEvent{Time: 1, Key: "toggle", Value: true, Index: &index, Version: &version}
+
+	fp.SetOffline(true)
+	for i := 0; i < maxRetainedEvents; i++ {
+		fp.Recorder.RecordAccess(event)
+	}
+	assert.Nil(t, fp.FlushAtEnd(context.Background()))
+	for i := 0; i < 5; i++ {
+		fp.Recorder.RecordAccess(event)
+	}
+	assert.Nil(t, fp.FlushAtEnd(context.Background()))
+	assert.Len(t, fp.Recorder.packedData, 1)
+	assert.Len(t, fp.Recorder.packedData[0].Events, maxRetainedEvents)
+	assert.Equal(t, 0, server.EventsRequests())
+
+	fp.SetOffline(false)
+	assert.Nil(t, fp.FlushAtEnd(context.Background()))
+	events := server.Events()
+	assert.Len(t, events, 1)
+	assert.Len(t, events[0].Events, maxRetainedEvents)
+	assert.Equal(t, maxRetainedEvents+5, events[0].Access.Counters["toggle"][0].Count)
+}
+
+func TestSetOfflineRemoteEvaluation(t *testing.T) {
+	repo, _ := setup(t)
+	server := NewMockServer(repo)
+	defer server.Close()
+	fp, _ := NewFeatureProbe(server.URL(), "sdk_key", WithRemoteEvaluation(true), WithServerless(true))
+
+	fp.SetOffline(true)
+	detail := fp.BoolDetail("bool_toggle", NewUser(), true)
+	assert.True(t, detail.Value)
+	assert.Equal(t, "FeatureProbe offline", detail.Reason)
+	assert.Equal(t, 0, server.EvaluateRequests())
+}
+
+func TestOfflineMode(t *testing.T) {
+	toggle := newToggleForTest("toggle", "local")
+	toggle.Rules = []Rule{{Serve: toggle.DefaultServe, Conditions: []Condition{{Type: "number", Subject: "age", Predicate: ">", Objects: []string{"200"}}}}}
+	local := &Repository{Toggles: map[string]Toggle{"toggle": toggle}}
+	start := time.Now()
+	fp, err := NewFeatureProbe("http://127.0.0.1:1", "sdk_key", WithOfflineMode(true), WithRepository(local))
+	assert.Nil(t, err)
+	defer fp.Close()
+	assert.True(t, time.Since(start) < time.Second)
+
+	assert.Nil(t, fp.Syncer)
+	assert.Nil(t, fp.Recorder)
+	assert.True(t, fp.Offline())
+	fp.SetOffline(false)
+	assert.True(t, fp.Offline())
+
+	assert.Equal(t, "local", fp.StrValue("toggle", NewUser(), "default"))
+	assert.Equal(t, "default", fp.StrValue("missing", NewUser(), "default"))
+	assert.Nil(t, fp.FlushAtEnd(context.Background()))
+	assert.Nil(t, local.Toggles["toggle"].usage)
+	assert.Nil(t, local.Toggles["toggle"].Rules[0].Conditions[0].compiled)
+}
+
+func TestWithRepository(t *testing.T) {
+	synced := newToggleForTest("toggle", "synced")
+	synced.Version = 1
+	server := NewMockServer(Repository{Toggles: map[string]Toggle{"toggle": synced}})
+	defer server.Close()
+	clock := NewManualClock(time.Unix(1000, 0))
+
+	local := &Repository{Toggles: map[string]Toggle{"toggle": newToggleForTest("toggle", "local")}}
+	fp, err := NewFeatureProbe(server.URL(), "sdk_key", WithRepository(local), WithWaitFirstResp(false), WithClock(clock))
+	assert.Nil(t, err)
+	defer fp.Close()
+
+	assert.Equal(t, "local", fp.StrValue("toggle", NewUser(), "default"))
+	clock.Advance(2 * time.Second)
+	waitForValue(fp, "toggle", "synced")
+	assert.Equal(t, "synced", fp.StrValue("toggle", NewUser(), "default"))
+}
+
+func TestOfflineModeSkipsStoresAndSinks(t *testing.T) {
+	store := &countingDataStore{InMemoryDataStore: NewInMemoryDataStore()}
+	assert.Nil(t, store.SetToggle(newToggleForTest("toggle", "stored")))
+	fp, err := NewFeatureProbe("http://127.0.0.1:1", "sdk_key", WithOfflineMode(true), WithDataStore(store),
+		WithEventSink(NewWebhookSink("http://127.0.0.1:1")), WithBigSegmentStore(NewRedisBigSegmentStore("127.0.0.1:1", RedisOptions{})))
+	assert.Nil(t, err)
+	defer fp.Close()
+
+	assert.Equal(t, 0, store.reads)
+	assert.Equal(t, "default", fp.StrValue("toggle", NewUser(), "default"))
+	assert.Nil(t, fp.sinks)
+	assert.Nil(t, fp.bigSegments)
+}
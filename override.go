@@ -0,0 +1,42 @@
+package featureprobe
+
+import "sync"
+
+type overrideStore struct {
+	mu     sync.RWMutex
+	values map[string]interface{}
+}
+
+func newOverrideStore() *overrideStore {
+	return &overrideStore{values: map[string]interface{}{}}
+}
+
+// Override makes every evaluation of toggle return value, taking precedence
+// over repository data, until ClearOverride is called.
+func (fp *FeatureProbe) Override(toggle string, value interface{}) {
+	if fp.overrides == nil {
+		fp.overrides = newOverrideStore()
+	}
+	fp.overrides.mu.Lock()
+	fp.overrides.values[toggle] = value
+	fp.overrides.mu.Unlock()
+}
+
+func (fp *FeatureProbe) ClearOverride(toggle string) {
+	if fp.overrides == nil {
+		return
+	}
+	fp.overrides.mu.Lock()
+	delete(fp.overrides.values, toggle)
+	fp.overrides.mu.Unlock()
+}
+
+func (o *overrideStore) get(toggle string) (interface{}, bool) {
+	if o == nil {
+		return nil, false
+	}
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	value, ok := o.values[toggle]
+	return value, ok
+}
@@ -0,0 +1,60 @@
+package featureprobe
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+)
+
+const (
+	// DefaultOverridesFile is loaded from the working directory when it
+	// exists, for developers flipping toggles on their machine.
+	DefaultOverridesFile = ".featureprobe-overrides.json"
+	// OverridesFileEnv names an overrides file to load instead.
+	OverridesFileEnv = "FEATUREPROBE_OVERRIDES_FILE"
+)
+
+// WithOverridesFile loads path, a json object of toggle keys to values, as
+// overrides taking precedence over the remote repository, like Override.
+// Without it, the file named by FEATUREPROBE_OVERRIDES_FILE is loaded, or
+// DefaultOverridesFile when present.
+func WithOverridesFile(path string) Option {
+	return func(fpConfig *FPConfig) {
+		fpConfig.OverridesFile = path
+	}
+}
+
+// overridesFile returns the file to load, and whether it was asked for, in
+// which case it missing is an error.
+func overridesFile(config FPConfig) (string, bool) {
+	if config.OverridesFile != "" {
+		return config.OverridesFile, true
+	}
+	if path := os.Getenv(OverridesFileEnv); path != "" {
+		return path, true
+	}
+	return DefaultOverridesFile, false
+}
+
+func (fp *FeatureProbe) loadOverridesFile() {
+	path, required := overridesFile(fp.Config)
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) && !required {
+		return
+	}
+	if err != nil {
+		fp.Config.reportError(fmt.Errorf("load overrides file fails: %w", err))
+		return
+	}
+	values := map[string]interface{}{}
+	if err := json.Unmarshal(data, &values); err != nil {
+		fp.Config.reportError(fmt.Errorf("load overrides file %s fails: %w", path, err))
+		return
+	}
+	for toggle, value := range values {
+		fp.Override(toggle, value)
+	}
+	// printed so a forgotten local file doesn't go unnoticed
+	fp.Config.log.infof("%d toggles overridden by %s", len(values), path)
+}
@@ -0,0 +1,74 @@
+package featureprobe
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeOverridesFile(t *testing.T, content string) string {
+	dir, err := ioutil.TempDir("", "overrides")
+	assert.Nil(t, err)
+	path := filepath.Join(dir, DefaultOverridesFile)
+	assert.Nil(t, ioutil.WriteFile(path, []byte(content), 0600))
+	return path
+}
+
+func TestOverridesFile(t *testing.T) {
+	server := NewMockServer(Repository{Toggles: map[string]Toggle{"toggle": newToggleForTest("toggle", true)}})
+	defer server.Close()
+	path := writeOverridesFile(t, `{"toggle": false, "missing": "local"}`)
+	defer os.RemoveAll(filepath.Dir(path))
+
+	fp, err := NewFeatureProbe(server.URL(), "sdk_key", WithServerless(true), WithOverridesFile(path))
+	assert.Nil(t, err)
+	assert.False(t, fp.BoolValue("toggle", NewUser(), true))
+	assert.Equal(t, "local", fp.StrValue("missing", NewUser(), "default"))
+
+	fp.ClearOverride("toggle")
+	assert.True(t, fp.BoolValue("toggle", NewUser(), false))
+}
+
+func TestOverridesFileFromEnv(t *testing.T) {
+	path := writeOverridesFile(t, `{"toggle": "env"}`)
+	defer os.RemoveAll(filepath.Dir(path))
+	os.Setenv(OverridesFileEnv, path)
+	defer os.Unsetenv(OverridesFileEnv)
+
+	server := NewMockServer(Repository{})
+	defer server.Close()
+	fp, err := NewFeatureProbe(server.URL(), "sdk_key", WithServerless(true))
+	assert.Nil(t, err)
+	assert.Equal(t, "env", fp.StrValue("toggle", NewUser(), "default"))
+}
+
+func TestOverridesFileMissing(t *testing.T) {
+	var errs []error
+	listener := WithErrorListener(func(err error) { errs = append(errs, err) })
+	server := NewMockServer(Repository{})
+	defer server.Close()
+
+	_, err := NewFeatureProbe(server.URL(), "sdk_key", WithServerless(true), listener)
+	assert.Nil(t, err)
+	assert.Empty(t, errs)
+
+	_, err = NewFeatureProbe(server.URL(), "sdk_key", WithServerless(true), listener, WithOverridesFile("/nonexistent/overrides.json"))
+	assert.Nil(t, err)
+	assert.Len(t, errs, 1)
+}
+
+func TestOverridesFileInvalid(t *testing.T) {
+	path := writeOverridesFile(t, `not json`)
+	defer os.RemoveAll(filepath.Dir(path))
+	var errs []error
+	server := NewMockServer(Repository{})
+	defer server.Close()
+
+	_, err := NewFeatureProbe(server.URL(), "sdk_key", WithServerless(true), WithOverridesFile(path),
+		WithErrorListener(func(err error) { errs = append(errs, err) }))
+	assert.Nil(t, err)
+	assert.Len(t, errs, 1)
+}
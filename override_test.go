@@ -0,0 +1,43 @@
+package featureprobe
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOverride(t *testing.T) {
+	repo, _ := setup(t)
+	fp := setupFeatureProbe(t)
+	fp.setRepoForTest(repo)
+	user := NewUser().StableRollout("key11").With("city", "4")
+
+	assert.Equal(t, false, fp.BoolValue("bool_toggle", user, true))
+
+	fp.Override("bool_toggle", true)
+	detail := fp.BoolDetail("bool_toggle", user, false)
+	assert.Equal(t, true, detail.Value)
+	assert.Equal(t, "override", detail.Reason)
+	assert.Nil(t, detail.Version)
+
+	fp.Override("not_exist_toggle", "red")
+	assert.Equal(t, "red", fp.StrValue("not_exist_toggle", user, "blue"))
+
+	fp.ClearOverride("bool_toggle")
+	assert.Equal(t, false, fp.BoolValue("bool_toggle", user, true))
+}
+
+func TestOverrideTypeMismatch(t *testing.T) {
+	fp := NewFeatureProbeForTest(map[string]interface{}{})
+	fp.Override("toggle", "on")
+	detail := fp.BoolDetail("toggle", NewUser(), false)
+	assert.Equal(t, false, detail.Value)
+	assert.Equal(t, "Value type mismatch", detail.Reason)
+}
+
+func TestOverrideWithoutStore(t *testing.T) {
+	fp := FeatureProbe{}
+	fp.ClearOverride("toggle")
+	fp.Override("toggle", 2.0)
+	assert.Equal(t, 2.0, fp.NumberValue("toggle", NewUser(), 1.0))
+}
@@ -0,0 +1,59 @@
+package featureprobe
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// QuietHours is a daily window, as offsets from midnight in Location (UTC
+// when nil), during which events are buffered instead of sent. A window whose
+// End is before its Start spans midnight.
+type QuietHours struct {
+	Start    time.Duration
+	End      time.Duration
+	Location *time.Location
+}
+
+func WithQuietHours(quietHours QuietHours) Option {
+	return func(fpConfig *FPConfig) {
+		fpConfig.QuietHours = &quietHours
+	}
+}
+
+// PauseEvents buffers access events without sending them until ResumeEvents,
+// e.g. for a maintenance window of the events collector. Close still sends
+// the buffered events.
+func (fp *FeatureProbe) PauseEvents() {
+	if fp.Recorder != nil {
+		atomic.StoreInt32(&fp.Recorder.paused, 1)
+	}
+}
+
+// ResumeEvents sends the buffered events with the next flush.
+func (fp *FeatureProbe) ResumeEvents() {
+	if fp.Recorder != nil {
+		atomic.StoreInt32(&fp.Recorder.paused, 0)
+	}
+}
+
+// holding reports whether periodic flushes are paused or in quiet hours.
+func (e *EventRecorder) holding() bool {
+	if atomic.LoadInt32(&e.paused) == 1 || atomic.LoadInt32(&e.offline) == 1 {
+		return true
+	}
+	return e.quietHours != nil && e.quietHours.contains(e.clock.Now())
+}
+
+func (q *QuietHours) contains(t time.Time) bool {
+	loc := q.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	t = t.In(loc)
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+	offset := t.Sub(midnight)
+	if q.Start <= q.End {
+		return q.Start <= offset && offset < q.End
+	}
+	return offset >= q.Start || offset < q.End
+}
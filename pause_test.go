@@ -0,0 +1,57 @@
+package featureprobe
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func waitForEvents(server *MockServer, n int) {
+	for i := 0; i < 50 && server.EventsRequests() < n; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestPauseEvents(t *testing.T) {
+	server := NewMockServer(Repository{})
+	defer server.Close()
+	clock := NewManualClock(time.Unix(1000, 0))
+	recorder := NewEventRecorder(server.URL()+"api/events", 1000, "sdk_key")
+	recorder.clock = clock
+	fp := FeatureProbe{Recorder: &recorder}
+	recorder.Start()
+
+	fp.PauseEvents()
+	recorder.RecordAccess(AccessEvent{Time: 1, Key: "toggle", Value: true})
+	clock.Advance(time.Second)
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, 0, server.EventsRequests())
+
+	fp.ResumeEvents()
+	clock.Advance(time.Second)
+	waitForEvents(server, 1)
+	assert.Equal(t, 1, server.EventsRequests())
+
+	fp.PauseEvents()
+	recorder.RecordAccess(AccessEvent{Time: 2, Key: "toggle", Value: true})
+	recorder.Stop()
+	assert.Equal(t, 2, server.EventsRequests())
+}
+
+func TestQuietHours(t *testing.T) {
+	day := QuietHours{Start: 2 * time.Hour, End: 4 * time.Hour}
+	assert.False(t, day.contains(time.Date(2022, 1, 1, 1, 59, 0, 0, time.UTC)))
+	assert.True(t, day.contains(time.Date(2022, 1, 1, 2, 0, 0, 0, time.UTC)))
+	assert.False(t, day.contains(time.Date(2022, 1, 1, 4, 0, 0, 0, time.UTC)))
+
+	night := QuietHours{Start: 23 * time.Hour, End: time.Hour, Location: time.FixedZone("UTC+8", 8*3600)}
+	assert.True(t, night.contains(time.Date(2022, 1, 1, 15, 30, 0, 0, time.UTC)))
+	assert.True(t, night.contains(time.Date(2022, 1, 1, 16, 30, 0, 0, time.UTC)))
+	assert.False(t, night.contains(time.Date(2022, 1, 1, 17, 0, 0, 0, time.UTC)))
+
+	recorder := NewEventRecorder("", 1000, "")
+	recorder.clock = NewManualClock(time.Date(2022, 1, 1, 3, 0, 0, 0, time.UTC))
+	recorder.quietHours = &day
+	assert.True(t, recorder.holding())
+}
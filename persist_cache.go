@@ -0,0 +1,55 @@
+package featureprobe
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// StaleCacheReason is appended to the reason of evaluations served from the
+// cache of WithCacheFile or WithPersistentCache, before a sync succeeded.
+const StaleCacheReason = "stale cache"
+
+// WithPersistentCache saves every synced repository to the cache file at
+// path and starts from it like WithCacheFile, so a restart while
+// FeatureProbe is unreachable serves the last known toggles rather than
+// defaults. The cache holds the client's own toggles only, not those of its
+// projects or environments.
+func WithPersistentCache(path string) Option {
+	return func(fpConfig *FPConfig) {
+		fpConfig.CacheFile = path
+		fpConfig.PersistCache = true
+	}
+}
+
+// cachePersister is an onUpdate hook saving repo to path, through a
+// temporary file renamed over it so a crash never leaves a torn cache.
+func cachePersister(path string, config FPConfig) func(previous, repo *Repository) {
+	return func(_, repo *Repository) {
+		if err := writeCache(path, repo, config); err != nil {
+			config.reportError(fmt.Errorf("save cache %s fails: %w", path, err))
+		}
+	}
+}
+
+func writeCache(path string, repo *Repository, config FPConfig) error {
+	data, err := encodeSnapshot(repo, clockOrSystem(config.Clock).Now())
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
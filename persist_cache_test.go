@@ -0,0 +1,73 @@
+package featureprobe
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPersistentCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cache")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "cache.json")
+	server := NewMockServer(Repository{Toggles: map[string]Toggle{"toggle": newToggleForTest("toggle", "network")}})
+
+	fp, err := NewFeatureProbe(server.URL(), "sdk_key", WithClock(NewManualClock(time.Unix(0, 0))), WithWaitFirstResp(false), WithPersistentCache(path))
+	assert.Nil(t, err)
+	fp.Syncer.fetchRemoteRepo()
+	fp.Close()
+	server.Close()
+	files, _ := ioutil.ReadDir(dir)
+	assert.Len(t, files, 1)
+
+	// the remote is gone, so the saved repository is served
+	fp, err = NewFeatureProbe(server.URL(), "sdk_key", WithClock(NewManualClock(time.Unix(0, 0))), WithPersistentCache(path))
+	assert.Nil(t, err)
+	defer fp.Close()
+	fp.Syncer.fetchRemoteRepo()
+	detail := fp.StrDetail("toggle", NewUser(), "d")
+	assert.Equal(t, "network", detail.Value)
+	assert.Equal(t, SourceCache, detail.Source)
+	assert.True(t, strings.HasSuffix(detail.Reason, "("+StaleCacheReason+")"), detail.Reason)
+}
+
+func TestPersistentCacheWriteFails(t *testing.T) {
+	server := NewMockServer(Repository{Toggles: map[string]Toggle{"toggle": newToggleForTest("toggle", "network")}})
+	defer server.Close()
+	var errs []error
+	fp, err := NewFeatureProbe(server.URL(), "sdk_key", WithClock(NewManualClock(time.Unix(0, 0))),
+		WithWaitFirstResp(false), WithPersistentCache("/nonexistent/cache.json"), WithErrorListener(func(err error) { errs = append(errs, err) }))
+	assert.Nil(t, err)
+	defer fp.Close()
+	fp.Syncer.fetchRemoteRepo()
+
+	assert.Len(t, errs, 2)
+	assert.Contains(t, errs[1].Error(), "save cache /nonexistent/cache.json fails")
+	detail := fp.StrDetail("toggle", NewUser(), "d")
+	assert.Equal(t, "network", detail.Value)
+	assert.False(t, strings.Contains(detail.Reason, StaleCacheReason))
+}
+
+func TestPersistentCacheSkipsProjects(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cache")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "cache.json")
+	server := NewMockServer(Repository{Toggles: map[string]Toggle{"toggle": newToggleForTest("toggle", "network")}})
+	defer server.Close()
+
+	fp, err := NewFeatureProbe(server.URL(), "sdk_key", WithClock(NewManualClock(time.Unix(0, 0))),
+		WithWaitFirstResp(false), WithPersistentCache(path), WithProject("billing", "billing_key"))
+	assert.Nil(t, err)
+	defer fp.Close()
+	billing := fp.Project("billing")
+	assert.Equal(t, "", billing.Config.CacheFile)
+	assert.False(t, billing.Config.PersistCache)
+	assert.Equal(t, path, fp.Config.CacheFile)
+}
@@ -0,0 +1,163 @@
+package featureprobe
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// PluginKind groups plugins by what they extend. Names are unique per kind.
+type PluginKind string
+
+const (
+	PluginDataSource PluginKind = "datasource"
+	PluginEventSink  PluginKind = "eventSink"
+	PluginHook       PluginKind = "hook"
+	PluginLogger     PluginKind = "logger"
+)
+
+// PluginFactory builds the option a plugin configures the client with, from
+// the params given to it in a config file.
+type PluginFactory func(params map[string]interface{}) (Option, error)
+
+var plugins = struct {
+	sync.RWMutex
+	factories map[PluginKind]map[string]PluginFactory
+}{factories: map[PluginKind]map[string]PluginFactory{}}
+
+// RegisterPlugin makes factory available to config files under name,
+// usually from the init function of the package implementing it. It panics
+// if the name is already registered for kind, like sql.Register.
+func RegisterPlugin(kind PluginKind, name string, factory PluginFactory) {
+	plugins.Lock()
+	defer plugins.Unlock()
+	if factory == nil {
+		panic("featureprobe: RegisterPlugin factory is nil")
+	}
+	if plugins.factories[kind] == nil {
+		plugins.factories[kind] = map[string]PluginFactory{}
+	}
+	if _, dup := plugins.factories[kind][name]; dup {
+		panic("featureprobe: RegisterPlugin called twice for " + string(kind) + " " + name)
+	}
+	plugins.factories[kind][name] = factory
+}
+
+// Plugins returns the sorted names registered for kind.
+func Plugins(kind PluginKind) []string {
+	plugins.RLock()
+	defer plugins.RUnlock()
+	names := make([]string, 0, len(plugins.factories[kind]))
+	for name := range plugins.factories[kind] {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// PluginConfig references a registered plugin, written in config files as
+// its name alone or as {"name": "redis", "params": {...}}.
+type PluginConfig struct {
+	Name   string                 `json:"name"`
+	Params map[string]interface{} `json:"params,omitempty"`
+}
+
+func (p *PluginConfig) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err == nil {
+		p.Name = name
+		return nil
+	}
+	type plain PluginConfig
+	return json.Unmarshal(data, (*plain)(p))
+}
+
+func (p PluginConfig) option(kind PluginKind) (Option, error) {
+	plugins.RLock()
+	factory, ok := plugins.factories[kind][p.Name]
+	plugins.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%s plugin %q not registered", kind, p.Name)
+	}
+	opt, err := factory(p.Params)
+	if err != nil {
+		return nil, fmt.Errorf("%s plugin %q: %w", kind, p.Name, err)
+	}
+	return opt, nil
+}
+
+// FileConfig is the declarative form of a client, to assemble it from
+// registered plugins in a config file shared across services:
+//
+//	{"remoteUrl": "https://featureprobe.example.com", "serverSdkKey": "...",
+//	 "datasource": "redis", "eventSinks": [{"name": "kafka", "params": {"topic": "access"}}]}
+type FileConfig struct {
+	RemoteUrl    string         `json:"remoteUrl"`
+	ServerSdkKey string         `json:"serverSdkKey"`
+	DataSource   *PluginConfig  `json:"datasource,omitempty"`
+	EventSinks   []PluginConfig `json:"eventSinks,omitempty"`
+	Hooks        []PluginConfig `json:"hooks,omitempty"`
+	Logger       *PluginConfig  `json:"logger,omitempty"`
+}
+
+func ParseFileConfig(data []byte) (FileConfig, error) {
+	var config FileConfig
+	err := json.Unmarshal(data, &config)
+	return config, err
+}
+
+// Options builds the options of the referenced plugins, in the order data
+// source, event sinks, hooks, logger.
+func (c FileConfig) Options() ([]Option, error) {
+	var refs []PluginConfig
+	var kinds []PluginKind
+	if c.DataSource != nil {
+		refs, kinds = append(refs, *c.DataSource), append(kinds, PluginDataSource)
+	}
+	for _, p := range c.EventSinks {
+		refs, kinds = append(refs, p), append(kinds, PluginEventSink)
+	}
+	for _, p := range c.Hooks {
+		refs, kinds = append(refs, p), append(kinds, PluginHook)
+	}
+	if c.Logger != nil {
+		refs, kinds = append(refs, *c.Logger), append(kinds, PluginLogger)
+	}
+	opts := make([]Option, 0, len(refs))
+	for i, p := range refs {
+		opt, err := p.option(kinds[i])
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, opt)
+	}
+	return opts, nil
+}
+
+// NewFeatureProbeFromConfig creates a client from a FileConfig in json, with
+// opts applied after the plugins' options.
+func NewFeatureProbeFromConfig(data []byte, opts ...Option) (FeatureProbe, error) {
+	config, err := ParseFileConfig(data)
+	if err != nil {
+		return FeatureProbe{}, err
+	}
+	pluginOpts, err := config.Options()
+	if err != nil {
+		return FeatureProbe{}, err
+	}
+	return NewFeatureProbe(config.RemoteUrl, config.ServerSdkKey, append(pluginOpts, opts...)...)
+}
+
+func init() {
+	RegisterPlugin(PluginDataSource, "cache", func(params map[string]interface{}) (Option, error) {
+		path, _ := params["path"].(string)
+		if path == "" {
+			return nil, fmt.Errorf("path param required")
+		}
+		return WithCacheFile(path), nil
+	})
+	RegisterPlugin(PluginLogger, "discard", func(map[string]interface{}) (Option, error) {
+		return WithErrorListener(func(error) {}), nil
+	})
+}
@@ -0,0 +1,59 @@
+package featureprobe
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var sinkTopics []interface{}
+
+func init() {
+	RegisterPlugin(PluginEventSink, "test_sink", func(params map[string]interface{}) (Option, error) {
+		if params["topic"] == nil {
+			return nil, errors.New("topic param required")
+		}
+		return func(*FPConfig) { sinkTopics = append(sinkTopics, params["topic"]) }, nil
+	})
+}
+
+func TestPluginConfig(t *testing.T) {
+	sinkTopics = nil
+	assert.Contains(t, Plugins(PluginEventSink), "test_sink")
+	assert.Panics(t, func() {
+		RegisterPlugin(PluginEventSink, "test_sink", func(map[string]interface{}) (Option, error) { return nil, nil })
+	})
+
+	config, err := ParseFileConfig([]byte(`{"remoteUrl": "http://localhost", "serverSdkKey": "sdk_key",
+		"logger": "discard", "eventSinks": [{"name": "test_sink", "params": {"topic": "access"}}]}`))
+	assert.Nil(t, err)
+	assert.Equal(t, "discard", config.Logger.Name)
+	opts, err := config.Options()
+	assert.Nil(t, err)
+	var fpConfig FPConfig
+	for _, opt := range opts {
+		opt(&fpConfig)
+	}
+	assert.Equal(t, []interface{}{"access"}, sinkTopics)
+	assert.NotNil(t, fpConfig.ErrorListener)
+
+	config, _ = ParseFileConfig([]byte(`{"eventSinks": ["test_sink"]}`))
+	_, err = config.Options()
+	assert.EqualError(t, err, `eventSink plugin "test_sink": topic param required`)
+
+	config, _ = ParseFileConfig([]byte(`{"datasource": "redis"}`))
+	_, err = config.Options()
+	assert.EqualError(t, err, `datasource plugin "redis" not registered`)
+}
+
+func TestNewFeatureProbeFromConfig(t *testing.T) {
+	server := NewMockServer(Repository{Toggles: map[string]Toggle{"toggle": newToggleForTest("toggle", true)}})
+	defer server.Close()
+	fp, err := NewFeatureProbeFromConfig([]byte(`{"remoteUrl": "`+server.URL()+`", "serverSdkKey": "sdk_key", "logger": "discard"}`), WithServerless(true))
+	assert.Nil(t, err)
+	assert.True(t, fp.BoolValue("toggle", NewUser(), false))
+
+	_, err = NewFeatureProbeFromConfig([]byte(`{"hooks": ["missing"]}`))
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,101 @@
+package featureprobe
+
+import (
+	"errors"
+	"fmt"
+)
+
+// VariationCount is how many evaluations served a variation, and their share
+// of all evaluations.
+type VariationCount struct {
+	Index int         `json:"index"`
+	Value interface{} `json:"value"`
+	Count int         `json:"count"`
+	Share float64     `json:"share"`
+}
+
+// DistributionPreview is the variations a toggle serves to synthetic users.
+// Errors counts the evaluations that failed, served no variation.
+type DistributionPreview struct {
+	Toggle     string           `json:"toggle"`
+	SampleSize int              `json:"sampleSize"`
+	Variations []VariationCount `json:"variations"`
+	Errors     int              `json:"errors"`
+}
+
+// DistributionPreview evaluates toggle for sampleSize synthetic users, with
+// their key as every attribute splits bucket by and no other attribute,
+// without recording events, to check a rollout configuration before it
+// reaches real users. Rules needing other attributes don't match them.
+func (fp *FeatureProbe) DistributionPreview(toggle string, sampleSize int) (DistributionPreview, error) {
+	if sampleSize <= 0 {
+		return DistributionPreview{}, errors.New("sample size must be positive")
+	}
+	repo := fp.Repo.Load()
+	t, ok := repo.GetToggle(toggle)
+	if !ok {
+		return DistributionPreview{}, lookupError(repo, toggle)
+	}
+	bucketBy := t.bucketByAttributes()
+	counts := newVariationCounts(&t)
+	preview := DistributionPreview{Toggle: toggle, SampleSize: sampleSize}
+	for i := 0; i < sampleSize; i++ {
+		key := fmt.Sprintf("user-%d", i)
+		user := NewUser().StableRollout(key)
+		for _, attr := range bucketBy {
+			user = user.With(attr, key)
+		}
+		result, err := t.detail(evalParams{User: user, Repo: repo, Variations: t.Variations, Key: t.Key, Clock: fp.Config.Clock, compositeKeys: fp.Config.CompositeBucketBy})
+		if err != nil || !counts.add(result.variationIndex) {
+			preview.Errors++
+		}
+	}
+	preview.Variations = counts.shares(sampleSize)
+	return preview, nil
+}
+
+// bucketByAttributes lists the attributes the splits of the toggle bucket by.
+func (t *Toggle) bucketByAttributes() []string {
+	var attrs []string
+	seen := map[string]bool{}
+	add := func(s *Serve) {
+		if s.Split != nil && s.Split.BucketBy != "" && !seen[s.Split.BucketBy] {
+			seen[s.Split.BucketBy] = true
+			attrs = append(attrs, s.Split.BucketBy)
+		}
+	}
+	add(&t.DisabledServe)
+	add(&t.DefaultServe)
+	for i := range t.Rules {
+		add(&t.Rules[i].Serve)
+	}
+	return attrs
+}
+
+type variationCounts []VariationCount
+
+func newVariationCounts(t *Toggle) variationCounts {
+	counts := make(variationCounts, len(t.Variations))
+	for i, v := range t.Variations {
+		counts[i] = VariationCount{Index: i, Value: variationValue(v)}
+	}
+	return counts
+}
+
+func (c variationCounts) add(index int) bool {
+	if index < 0 || index >= len(c) {
+		return false
+	}
+	c[index].Count++
+	return true
+}
+
+func (c variationCounts) shares(total int) []VariationCount {
+	if total == 0 {
+		return c
+	}
+	for i := range c {
+		c[i].Share = float64(c[i].Count) / float64(total)
+	}
+	return c
+}
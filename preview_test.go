@@ -0,0 +1,33 @@
+package featureprobe
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDistributionPreview(t *testing.T) {
+	split := newHalfSplit("email")
+	toggle := Toggle{
+		Key:          "rollout",
+		Enabled:      true,
+		Version:      1,
+		DefaultServe: Serve{Split: &split},
+		Variations:   []interface{}{"a", "b"},
+	}
+	fp := FeatureProbe{Repo: NewRepositoryStore(&Repository{Toggles: map[string]Toggle{"rollout": toggle}})}
+
+	preview, err := fp.DistributionPreview("rollout", 10000)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, preview.Errors)
+	assert.Len(t, preview.Variations, 2)
+	assert.Equal(t, 10000, preview.Variations[0].Count+preview.Variations[1].Count)
+	assert.Equal(t, "b", preview.Variations[1].Value)
+	assert.InDelta(t, 0.5, preview.Variations[0].Share, 0.03)
+
+	_, err = fp.DistributionPreview("not_exist", 10)
+	assert.True(t, errors.Is(err, ErrToggleNotFound))
+	_, err = fp.DistributionPreview("rollout", 0)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,69 @@
+package featureprobe
+
+import "fmt"
+
+// WithProject adds a project whose toggles are synced with its own server sdk
+// key and evaluated through fp.Project(name), for services consuming toggles
+// owned by several teams. Project clients share every other option.
+func WithProject(name, serverSdkKey string) Option {
+	return func(fpConfig *FPConfig) {
+		if fpConfig.Projects == nil {
+			fpConfig.Projects = map[string]string{}
+		}
+		fpConfig.Projects[name] = serverSdkKey
+	}
+}
+
+// withoutSubClients configures a sub client from the options of its parent,
+// dropping the parent's own repository sources: the bootstrap reader was
+// already drained by the parent, and its toggles and cache file are not the
+// sub client's.
+func withoutSubClients(fpConfig *FPConfig) {
+	fpConfig.Projects = nil
+	fpConfig.Environments = nil
+	fpConfig.Bootstrap = nil
+	fpConfig.Repository = nil
+	fpConfig.LocalFile = ""
+	fpConfig.CacheFile = ""
+	fpConfig.PersistCache = false
+	fpConfig.subClient = true
+}
+
+// newSubClients creates a client for each server sdk key of keys, kind being
+// what they are for errors.
+func newSubClients(remoteUrl, kind string, keys map[string]string, opts []Option) (map[string]*FeatureProbe, error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	opts = append(opts[:len(opts):len(opts)], withoutSubClients)
+	clients := make(map[string]*FeatureProbe, len(keys))
+	for name, key := range keys {
+		fp, err := NewFeatureProbe(remoteUrl, key, opts...)
+		if err != nil {
+			for _, client := range clients {
+				client.Close()
+			}
+			return nil, fmt.Errorf("%s %s: %w", kind, name, err)
+		}
+		clients[name] = &fp
+	}
+	return clients, nil
+}
+
+// unknownClient serves the defaults, for a sub client that is not configured.
+func (fp *FeatureProbe) unknownClient(kind, name string) *FeatureProbe {
+	fp.Config.reportError(fmt.Errorf("%s %s not configured", kind, name))
+	unknown := NewFeatureProbeForTest(nil)
+	unknown.Config = fp.Config
+	withoutSubClients(&unknown.Config)
+	return &unknown
+}
+
+// Project returns the client of a project added by WithProject. Toggles of an
+// unknown project are not found, serving their defaults.
+func (fp *FeatureProbe) Project(name string) *FeatureProbe {
+	if project, ok := fp.projects[name]; ok {
+		return project
+	}
+	return fp.unknownClient("project", name)
+}
@@ -0,0 +1,59 @@
+package featureprobe
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProjects(t *testing.T) {
+	repos := map[string]Repository{
+		"main_key":    {Toggles: map[string]Toggle{"toggle": newToggleForTest("toggle", "main")}},
+		"billing_key": {Toggles: map[string]Toggle{"toggle": newToggleForTest("toggle", "billing")}},
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(repos[r.Header.Get("Authorization")])
+	}))
+	defer server.Close()
+
+	var errs []error
+	fp, err := NewFeatureProbe(server.URL, "main_key", WithProject("billing", "billing_key"),
+		WithErrorListener(func(err error) { errs = append(errs, err) }))
+	assert.Nil(t, err)
+
+	assert.Equal(t, "main", fp.StrValue("toggle", NewUser(), "default"))
+	assert.Equal(t, "billing", fp.Project("billing").StrValue("toggle", NewUser(), "default"))
+	assert.Nil(t, fp.Project("billing").projects)
+	assert.Empty(t, errs)
+
+	assert.Equal(t, "default", fp.Project("unknown").StrValue("toggle", NewUser(), "default"))
+	assert.Len(t, errs, 1)
+
+	fp.Close()
+	assert.True(t, fp.Project("billing").Closed())
+}
+
+func TestProjectsWithBootstrapRepository(t *testing.T) {
+	repos := map[string]Repository{
+		"main_key":    {Toggles: map[string]Toggle{"toggle": newToggleForTest("toggle", "main")}},
+		"billing_key": {Toggles: map[string]Toggle{"toggle": newToggleForTest("toggle", "billing")}},
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(repos[r.Header.Get("Authorization")])
+	}))
+	defer server.Close()
+	bootstrap, err := json.Marshal(Repository{Toggles: map[string]Toggle{"toggle": newToggleForTest("toggle", "bootstrap")}})
+	assert.Nil(t, err)
+
+	fp, err := NewFeatureProbe(server.URL, "main_key", WithProject("billing", "billing_key"),
+		WithBootstrapRepository(bootstrap))
+	assert.Nil(t, err)
+	defer fp.Close()
+
+	assert.Equal(t, "bootstrap", fp.StrValue("toggle", NewUser(), "default"))
+	assert.Nil(t, fp.Project("billing").bootstrapped)
+	assert.Equal(t, "billing", fp.Project("billing").StrValue("toggle", NewUser(), "default"))
+}
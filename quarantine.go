@@ -0,0 +1,56 @@
+package featureprobe
+
+import (
+	"sort"
+	"sync/atomic"
+)
+
+// QuarantinePanics is how many panics evaluating a toggle quarantine it:
+// evaluations then answer the default without running its rules, until a
+// new version of the toggle is synced or ReleaseQuarantine is called.
+const QuarantinePanics = 3
+
+// QuarantineReason is the reason of evaluations of a quarantined toggle.
+const QuarantineReason = "toggle quarantined"
+
+// panicked counts a panic evaluating the toggle and tells whether it got
+// quarantined by it.
+func (u *toggleUsage) panicked() bool {
+	return u != nil && atomic.AddUint32(&u.panics, 1) == QuarantinePanics
+}
+
+func (u *toggleUsage) quarantined() bool {
+	return u != nil && atomic.LoadUint32(&u.panics) >= QuarantinePanics
+}
+
+// Quarantined returns the sorted keys of the toggles in the repository
+// quarantined after panicking repeatedly.
+func (fp *FeatureProbe) Quarantined() []string {
+	repo := fp.Repo.Load()
+	if repo == nil {
+		return nil
+	}
+	keys := []string{}
+	for key, t := range repo.Toggles {
+		if t.usage.quarantined() {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// ReleaseQuarantine evaluates toggle again, resetting its panic count. It
+// returns false when the toggle was not quarantined.
+func (fp *FeatureProbe) ReleaseQuarantine(toggle string) bool {
+	repo := fp.Repo.Load()
+	if repo == nil {
+		return false
+	}
+	t, ok := repo.GetToggle(toggle)
+	if !ok || !t.usage.quarantined() {
+		return false
+	}
+	atomic.StoreUint32(&t.usage.panics, 0)
+	return true
+}
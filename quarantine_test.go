@@ -0,0 +1,65 @@
+package featureprobe
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuarantineAfterRepeatedPanics(t *testing.T) {
+	fp := NewFeatureProbeForTest(map[string]interface{}{})
+	var reported []error
+	fp.Config.ErrorListener = func(err error) { reported = append(reported, err) }
+
+	broken := newToggleForTest("toggle", "bad")
+	index := -2
+	broken.DefaultServe = Serve{Select: &index}
+	fp.Repo.Store(&Repository{Toggles: map[string]Toggle{"toggle": broken}})
+	fp.Repo.Load().compile(nil)
+
+	for i := 0; i < QuarantinePanics; i++ {
+		detail := fp.StrDetail("toggle", NewUser(), "default")
+		assert.Equal(t, "default", detail.Value)
+		assert.Equal(t, "internal error", detail.Reason)
+		assert.True(t, errors.Is(detail.Err, ErrInternal))
+	}
+	assert.Len(t, reported, QuarantinePanics)
+	assert.Contains(t, reported[QuarantinePanics-1].Error(), "toggle quarantined")
+	assert.Equal(t, []string{"toggle"}, fp.Quarantined())
+
+	detail := fp.StrDetail("toggle", NewUser(), "default")
+	assert.Equal(t, "default", detail.Value)
+	assert.Equal(t, QuarantineReason, detail.Reason)
+	assert.True(t, errors.Is(detail.Err, ErrQuarantined))
+	assert.Len(t, reported, QuarantinePanics)
+
+	assert.True(t, fp.ReleaseQuarantine("toggle"))
+	assert.False(t, fp.ReleaseQuarantine("toggle"))
+	assert.Empty(t, fp.Quarantined())
+	assert.Equal(t, PanicReason, fp.StrDetail("toggle", NewUser(), "default").Reason)
+}
+
+func TestQuarantineLiftedByNewVersion(t *testing.T) {
+	fp := NewFeatureProbeForTest(map[string]interface{}{})
+	fp.Config.ErrorListener = func(err error) {}
+
+	broken := newToggleForTest("toggle", "bad")
+	index := -2
+	broken.DefaultServe = Serve{Select: &index}
+	previous := &Repository{Toggles: map[string]Toggle{"toggle": broken}}
+	previous.compile(nil)
+	fp.Repo.Store(previous)
+	for i := 0; i < QuarantinePanics; i++ {
+		fp.StrValue("toggle", NewUser(), "default")
+	}
+	assert.Equal(t, []string{"toggle"}, fp.Quarantined())
+
+	fixed := newToggleForTest("toggle", "good")
+	fixed.Version = broken.Version + 1
+	repo := &Repository{Toggles: map[string]Toggle{"toggle": fixed}}
+	repo.compile(previous)
+	fp.Repo.Store(repo)
+	assert.Empty(t, fp.Quarantined())
+	assert.Equal(t, "good", fp.StrValue("toggle", NewUser(), "default"))
+}
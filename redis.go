@@ -0,0 +1,398 @@
+package featureprobe
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultRedisPrefix prefixes the keys the SDK reads from Redis.
+const DefaultRedisPrefix = "featureprobe"
+
+// RedisOptions configures the connection of the Redis backed stores.
+type RedisOptions struct {
+	Password string
+	DB       int
+	// Prefix defaults to DefaultRedisPrefix.
+	Prefix string
+	// Timeout bounds dialing and each command, 3 seconds by default.
+	Timeout time.Duration
+}
+
+// redisError is an error reply of the server.
+type redisError string
+
+func (e redisError) Error() string {
+	return "redis: " + string(e)
+}
+
+// redisClient speaks just enough RESP over a single connection for the
+// SDK's stores, reconnecting after network errors.
+type redisClient struct {
+	addr    string
+	options RedisOptions
+	mu      sync.Mutex
+	conn    net.Conn
+	reader  *bufio.Reader
+}
+
+func newRedisClient(addr string, options RedisOptions) *redisClient {
+	if options.Prefix == "" {
+		options.Prefix = DefaultRedisPrefix
+	}
+	if options.Timeout <= 0 {
+		options.Timeout = 3 * time.Second
+	}
+	return &redisClient{addr: addr, options: options}
+}
+
+func (c *redisClient) key(parts ...string) string {
+	return c.options.Prefix + ":" + strings.Join(parts, ":")
+}
+
+// do sends a command and returns its reply: a string, an int64, nil or a
+// []interface{} of those.
+func (c *redisClient) do(args ...string) (interface{}, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == nil {
+		if err := c.connect(); err != nil {
+			return nil, err
+		}
+	}
+	reply, err := c.roundTrip(args)
+	if _, ok := err.(redisError); err != nil && !ok {
+		c.conn.Close()
+		c.conn = nil
+	}
+	return reply, err
+}
+
+// multi runs commands in a MULTI/EXEC transaction. The connection is
+// dropped on any error, so no transaction is left open on it.
+func (c *redisClient) multi(commands ...[]string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == nil {
+		if err := c.connect(); err != nil {
+			return err
+		}
+	}
+	err := c.transaction(commands)
+	if err != nil {
+		c.conn.Close()
+		c.conn = nil
+	}
+	return err
+}
+
+func (c *redisClient) transaction(commands [][]string) error {
+	if _, err := c.roundTrip([]string{"MULTI"}); err != nil {
+		return err
+	}
+	for _, args := range commands {
+		if _, err := c.roundTrip(args); err != nil {
+			return err
+		}
+	}
+	reply, err := c.roundTrip([]string{"EXEC"})
+	if err != nil {
+		return err
+	}
+	if reply == nil {
+		return errors.New("redis: transaction aborted")
+	}
+	return nil
+}
+
+func (c *redisClient) connect() error {
+	conn, err := net.DialTimeout("tcp", c.addr, c.options.Timeout)
+	if err != nil {
+		return err
+	}
+	c.conn, c.reader = conn, bufio.NewReader(conn)
+	if c.options.Password != "" {
+		if _, err := c.roundTrip([]string{"AUTH", c.options.Password}); err != nil {
+			c.conn.Close()
+			c.conn = nil
+			return err
+		}
+	}
+	if c.options.DB != 0 {
+		if _, err := c.roundTrip([]string{"SELECT", strconv.Itoa(c.options.DB)}); err != nil {
+			c.conn.Close()
+			c.conn = nil
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *redisClient) roundTrip(args []string) (interface{}, error) {
+	if err := c.conn.SetDeadline(time.Now().Add(c.options.Timeout)); err != nil {
+		return nil, err
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := io.WriteString(c.conn, b.String()); err != nil {
+		return nil, err
+	}
+	return readRedisReply(c.reader)
+}
+
+func readRedisReply(r *bufio.Reader) (interface{}, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, errors.New("redis: empty reply")
+	}
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, redisError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		data := make([]byte, n+2)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, err
+		}
+		return string(data[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		items := make([]interface{}, n)
+		for i := range items {
+			if items[i], err = readRedisReply(r); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("redis: unexpected reply %q", line)
+}
+
+func (c *redisClient) close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == nil {
+		return nil
+	}
+	err := c.conn.Close()
+	c.conn = nil
+	return err
+}
+
+// RedisBigSegmentStore reads big segments from Redis, where the set
+// <prefix>:big_segment_include:<user key> holds the keys of the segments the
+// user is included in, and <prefix>:big_segments_synchronized_on the unix
+// millis they were last populated.
+type RedisBigSegmentStore struct {
+	client *redisClient
+}
+
+func NewRedisBigSegmentStore(addr string, options RedisOptions) *RedisBigSegmentStore {
+	return &RedisBigSegmentStore{client: newRedisClient(addr, options)}
+}
+
+func (s *RedisBigSegmentStore) Membership(userKey string) (map[string]bool, error) {
+	reply, err := s.client.do("SMEMBERS", s.client.key("big_segment_include", userKey))
+	if err != nil {
+		return nil, err
+	}
+	items, _ := reply.([]interface{})
+	segments := make(map[string]bool, len(items))
+	for _, item := range items {
+		if key, ok := item.(string); ok {
+			segments[key] = true
+		}
+	}
+	return segments, nil
+}
+
+func (s *RedisBigSegmentStore) LastUpdated() (time.Time, error) {
+	reply, err := s.client.do("GET", s.client.key("big_segments_synchronized_on"))
+	if err != nil {
+		return time.Time{}, err
+	}
+	value, ok := reply.(string)
+	if !ok {
+		return time.Time{}, errors.New("big segments never synchronized")
+	}
+	millis, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(0, millis*int64(time.Millisecond)), nil
+}
+
+func (s *RedisBigSegmentStore) Close() error {
+	return s.client.close()
+}
+
+// RedisDataStore is a DataStore keeping toggles and segments encoded as JSON
+// in the hashes <prefix>:toggles and <prefix>:segments, keyed by toggle and
+// segment key.
+type RedisDataStore struct {
+	client *redisClient
+	// namespace, if set, follows the prefix in keys.
+	namespace string
+}
+
+func NewRedisDataStore(addr string, options RedisOptions) *RedisDataStore {
+	return &RedisDataStore{client: newRedisClient(addr, options)}
+}
+
+func (s *RedisDataStore) GetToggle(key string) (Toggle, bool, error) {
+	var toggle Toggle
+	ok, err := s.get("toggles", key, &toggle)
+	return toggle, ok, err
+}
+
+func (s *RedisDataStore) SetToggle(toggle Toggle) error {
+	return s.set("toggles", toggle.Key, toggle)
+}
+
+func (s *RedisDataStore) AllToggles() (map[string]Toggle, error) {
+	toggles := map[string]Toggle{}
+	err := s.all("toggles", func(key string, data []byte) error {
+		var toggle Toggle
+		if err := json.Unmarshal(data, &toggle); err != nil {
+			return err
+		}
+		toggles[key] = toggle
+		return nil
+	})
+	return toggles, err
+}
+
+func (s *RedisDataStore) GetSegment(key string) (Segment, bool, error) {
+	var segment Segment
+	ok, err := s.get("segments", key, &segment)
+	return segment, ok, err
+}
+
+func (s *RedisDataStore) SetSegment(segment Segment) error {
+	return s.set("segments", segment.Key, segment)
+}
+
+func (s *RedisDataStore) AllSegments() (map[string]Segment, error) {
+	segments := map[string]Segment{}
+	err := s.all("segments", func(key string, data []byte) error {
+		var segment Segment
+		if err := json.Unmarshal(data, &segment); err != nil {
+			return err
+		}
+		segments[key] = segment
+		return nil
+	})
+	return segments, err
+}
+
+// Init replaces both hashes in one transaction, so readers never see
+// toggles and segments of different repositories.
+func (s *RedisDataStore) Init(repo *Repository) error {
+	toggles := []string{"HSET", s.key("toggles")}
+	segments := []string{"HSET", s.key("segments")}
+	snapshot := repo.detached()
+	for key, toggle := range snapshot.Toggles {
+		data, err := json.Marshal(toggle)
+		if err != nil {
+			return err
+		}
+		toggles = append(toggles, key, string(data))
+	}
+	for key, segment := range snapshot.Segments {
+		data, err := json.Marshal(segment)
+		if err != nil {
+			return err
+		}
+		segments = append(segments, key, string(data))
+	}
+	commands := [][]string{{"DEL", s.key("toggles"), s.key("segments")}}
+	// HSET needs at least one field
+	if len(toggles) > 2 {
+		commands = append(commands, toggles)
+	}
+	if len(segments) > 2 {
+		commands = append(commands, segments)
+	}
+	return s.client.multi(commands...)
+}
+
+// Namespace returns a store sharing the connection of s, whose hashes are
+// <prefix>:<namespace>:toggles and <prefix>:<namespace>:segments.
+func (s *RedisDataStore) Namespace(namespace string) DataStore {
+	return &RedisDataStore{client: s.client, namespace: namespace}
+}
+
+func (s *RedisDataStore) key(hash string) string {
+	if s.namespace == "" {
+		return s.client.key(hash)
+	}
+	return s.client.key(s.namespace, hash)
+}
+
+func (s *RedisDataStore) Close() error {
+	return s.client.close()
+}
+
+func (s *RedisDataStore) get(hash, key string, v interface{}) (bool, error) {
+	reply, err := s.client.do("HGET", s.key(hash), key)
+	if err != nil {
+		return false, err
+	}
+	data, ok := reply.(string)
+	if !ok {
+		return false, nil
+	}
+	if err := json.Unmarshal([]byte(data), v); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *RedisDataStore) set(hash, key string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = s.client.do("HSET", s.key(hash), key, string(data))
+	return err
+}
+
+func (s *RedisDataStore) all(hash string, decode func(key string, data []byte) error) error {
+	reply, err := s.client.do("HGETALL", s.key(hash))
+	if err != nil {
+		return err
+	}
+	items, _ := reply.([]interface{})
+	for i := 0; i+1 < len(items); i += 2 {
+		key, _ := items[i].(string)
+		data, _ := items[i+1].(string)
+		if err := decode(key, []byte(data)); err != nil {
+			return fmt.Errorf("decode %s %s fails: %w", hash, key, err)
+		}
+	}
+	return nil
+}
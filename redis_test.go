@@ -0,0 +1,260 @@
+package featureprobe
+
+import (
+	"bufio"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeRedis serves RESP commands with handle, recording them.
+type fakeRedis struct {
+	listener net.Listener
+	handle   func(args []string) string
+	mu       sync.Mutex
+	commands [][]string
+}
+
+func newFakeRedis(t *testing.T, handle func(args []string) string) *fakeRedis {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	f := &fakeRedis{listener: listener, handle: handle}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+	return f
+}
+
+func (f *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for {
+		reply, err := readRedisReply(r)
+		if err != nil {
+			return
+		}
+		items := reply.([]interface{})
+		args := make([]string, len(items))
+		for i, item := range items {
+			args[i] = item.(string)
+		}
+		f.mu.Lock()
+		f.commands = append(f.commands, args)
+		f.mu.Unlock()
+		if _, err := conn.Write([]byte(f.handle(args))); err != nil {
+			return
+		}
+	}
+}
+
+func (f *fakeRedis) received() [][]string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([][]string(nil), f.commands...)
+}
+
+func redisBulk(s string) string {
+	return "$" + strconv.Itoa(len(s)) + "\r\n" + s + "\r\n"
+}
+
+func TestRedisBigSegmentStore(t *testing.T) {
+	server := newFakeRedis(t, func(args []string) string {
+		switch args[0] {
+		case "AUTH", "SELECT":
+			return "+OK\r\n"
+		case "SMEMBERS":
+			if args[1] == "app:big_segment_include:member" {
+				return "*2\r\n" + redisBulk("big") + redisBulk("other")
+			}
+			return "*0\r\n"
+		case "GET":
+			return redisBulk("1000000")
+		}
+		return "-ERR unknown command\r\n"
+	})
+	defer server.listener.Close()
+	store := NewRedisBigSegmentStore(server.listener.Addr().String(), RedisOptions{Password: "secret", DB: 2, Prefix: "app"})
+	defer store.Close()
+
+	segments, err := store.Membership("member")
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]bool{"big": true, "other": true}, segments)
+	segments, err = store.Membership("stranger")
+	assert.Nil(t, err)
+	assert.Empty(t, segments)
+
+	updated, err := store.LastUpdated()
+	assert.Nil(t, err)
+	assert.Equal(t, time.Unix(1000, 0), updated)
+
+	assert.Equal(t, [][]string{
+		{"AUTH", "secret"},
+		{"SELECT", "2"},
+		{"SMEMBERS", "app:big_segment_include:member"},
+		{"SMEMBERS", "app:big_segment_include:stranger"},
+		{"GET", "app:big_segments_synchronized_on"},
+	}, server.received())
+}
+
+func TestRedisErrors(t *testing.T) {
+	server := newFakeRedis(t, func(args []string) string {
+		if args[0] == "GET" {
+			return "$-1\r\n"
+		}
+		return "-WRONGTYPE wrong kind of value\r\n"
+	})
+	defer server.listener.Close()
+	store := NewRedisBigSegmentStore(server.listener.Addr().String(), RedisOptions{})
+	defer store.Close()
+
+	_, err := store.Membership("member")
+	assert.Equal(t, "redis: WRONGTYPE wrong kind of value", err.Error())
+	_, err = store.LastUpdated()
+	assert.Equal(t, "big segments never synchronized", err.Error())
+	assert.Equal(t, "featureprobe:big_segment_include:member", server.received()[0][1])
+
+	_, err = NewRedisBigSegmentStore("127.0.0.1:1", RedisOptions{Timeout: time.Second}).Membership("member")
+	assert.NotNil(t, err)
+}
+
+// redisHashes answers the hash commands of RedisDataStore from memory,
+// queueing the commands of transactions until EXEC.
+func redisHashes() func(args []string) string {
+	hashes := map[string]map[string]string{}
+	var queued [][]string
+	inMulti := false
+	var run func(args []string) string
+	run = func(args []string) string {
+		switch args[0] {
+		case "DEL":
+			for _, key := range args[1:] {
+				delete(hashes, key)
+			}
+			return ":1\r\n"
+		case "HSET":
+			if hashes[args[1]] == nil {
+				hashes[args[1]] = map[string]string{}
+			}
+			for i := 2; i+1 < len(args); i += 2 {
+				hashes[args[1]][args[i]] = args[i+1]
+			}
+			return ":1\r\n"
+		case "HGET":
+			value, ok := hashes[args[1]][args[2]]
+			if !ok {
+				return "$-1\r\n"
+			}
+			return redisBulk(value)
+		case "HGETALL":
+			reply := "*" + strconv.Itoa(2*len(hashes[args[1]])) + "\r\n"
+			for field, value := range hashes[args[1]] {
+				reply += redisBulk(field) + redisBulk(value)
+			}
+			return reply
+		}
+		return "-ERR unknown command\r\n"
+	}
+	return func(args []string) string {
+		switch {
+		case args[0] == "MULTI":
+			inMulti = true
+			return "+OK\r\n"
+		case args[0] == "EXEC":
+			reply := "*" + strconv.Itoa(len(queued)) + "\r\n"
+			for _, command := range queued {
+				reply += run(command)
+			}
+			queued, inMulti = nil, false
+			return reply
+		case inMulti:
+			queued = append(queued, args)
+			return "+QUEUED\r\n"
+		}
+		return run(args)
+	}
+}
+
+func TestRedisDataStore(t *testing.T) {
+	server := newFakeRedis(t, redisHashes())
+	defer server.listener.Close()
+	store := NewRedisDataStore(server.listener.Addr().String(), RedisOptions{Prefix: "app"})
+	defer store.Close()
+
+	toggles, err := store.AllToggles()
+	assert.Nil(t, err)
+	assert.Empty(t, toggles)
+	repo, err := buildRepository([]byte(`{"toggles": {"toggle": {"key": "toggle", "enabled": true, "version": 2,
+		"variations": [{"a": 1}, "b"], "disabledServe": {"select": 1}, "defaultServe": {"select": 0}}},
+		"segments": {"segment": {"key": "segment", "uniqueId": "s", "version": 1, "rules": []}}}`), nil)
+	assert.Nil(t, err)
+	assert.Nil(t, store.Init(repo))
+
+	toggle, ok, err := store.GetToggle("toggle")
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, uint64(2), toggle.Version)
+	assert.Equal(t, map[string]interface{}{"a": float64(1)}, variationValue(toggle.Variations[0]))
+	_, ok, err = store.GetToggle("missing")
+	assert.Nil(t, err)
+	assert.False(t, ok)
+	segment, ok, err := store.GetSegment("segment")
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "s", segment.UniqId)
+
+	assert.Nil(t, store.SetToggle(newToggleForTest("other", true)))
+	toggles, err = store.AllToggles()
+	assert.Nil(t, err)
+	assert.Len(t, toggles, 2)
+	assert.Nil(t, store.SetSegment(Segment{Key: "other"}))
+	segments, err := store.AllSegments()
+	assert.Nil(t, err)
+	assert.Len(t, segments, 2)
+
+	assert.Nil(t, store.Init(&Repository{}))
+	toggles, _ = store.AllToggles()
+	assert.Empty(t, toggles)
+	commands := server.received()
+	assert.Equal(t, []string{"DEL", "app:toggles", "app:segments"}, commands[len(commands)-3])
+	assert.Equal(t, []string{"EXEC"}, commands[len(commands)-2])
+}
+
+func TestRedisDataStoreNamespace(t *testing.T) {
+	server := newFakeRedis(t, redisHashes())
+	defer server.listener.Close()
+	store := NewRedisDataStore(server.listener.Addr().String(), RedisOptions{Prefix: "app"})
+	defer store.Close()
+	billing := store.Namespace("billing")
+
+	assert.Nil(t, billing.SetToggle(newToggleForTest("toggle", true)))
+	_, ok, err := billing.GetToggle("toggle")
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	_, ok, err = store.GetToggle("toggle")
+	assert.Nil(t, err)
+	assert.False(t, ok)
+	assert.Equal(t, "app:billing:toggles", server.received()[0][1])
+}
+
+func TestRedisDataStoreTransactionFails(t *testing.T) {
+	server := newFakeRedis(t, func(args []string) string {
+		if args[0] == "EXEC" {
+			return "*-1\r\n"
+		}
+		return "+OK\r\n"
+	})
+	defer server.listener.Close()
+	store := NewRedisDataStore(server.listener.Addr().String(), RedisOptions{})
+	defer store.Close()
+	assert.EqualError(t, store.Init(&Repository{}), "redis: transaction aborted")
+}
@@ -0,0 +1,254 @@
+package featureprobe
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// RemoteRequest is the body posted to the evaluation endpoint in remote
+// evaluation mode.
+type RemoteRequest struct {
+	User    RemoteUser `json:"user"`
+	Toggles []string   `json:"toggles"`
+}
+
+type RemoteUser struct {
+	Key   string            `json:"key"`
+	Attrs map[string]string `json:"attrs"`
+}
+
+// RemoteResult is the evaluation of one toggle returned by the evaluation
+// endpoint, keyed by toggle in the response. Error is set instead of a value
+// when that toggle could not be evaluated.
+type RemoteResult struct {
+	Value          interface{}      `json:"value"`
+	RuleIndex      *int             `json:"ruleIndex"`
+	VariationIndex *int             `json:"variationIndex"`
+	Version        *uint64          `json:"version"`
+	Reason         string           `json:"reason"`
+	Error          string           `json:"error,omitempty"`
+	Rule           *MatchedRule     `json:"rule,omitempty"`
+	Segments       []MatchedSegment `json:"segments,omitempty"`
+}
+
+type remoteEvaluator struct {
+	offline    int32
+	url        string
+	auth       AuthProvider
+	signer     *requestSigner
+	httpClient http.Client
+}
+
+// WithRemoteEvaluation delegates every evaluation to the evaluation endpoint
+// instead of syncing toggles and evaluating them locally, for services that
+// can't hold the repository in memory or need evaluations to be consistent
+// across instances. Each evaluation costs a round trip.
+func WithRemoteEvaluation(enabled bool) Option {
+	return func(fpConfig *FPConfig) {
+		fpConfig.RemoteEvaluation = enabled
+	}
+}
+
+func WithEvaluationUri(uri string) Option {
+	return func(fpConfig *FPConfig) {
+		fpConfig.EvaluationUrl = fpConfig.RemoteUrl + uri
+	}
+}
+
+func newRemoteEvaluator(url string, auth string, timeout time.Duration) *remoteEvaluator {
+	return &remoteEvaluator{
+		url:        url,
+		auth:       staticAuth(auth),
+		httpClient: newHttpClient(timeout),
+	}
+}
+
+func (r *remoteEvaluator) evaluate(toggles []string, user FPUser) (map[string]RemoteResult, error) {
+	if atomic.LoadInt32(&r.offline) == 1 {
+		return nil, errors.New(offlineReason)
+	}
+	body, err := json.Marshal(RemoteRequest{
+		User:    RemoteUser{Key: user.Key(), Attrs: user.GetAll()},
+		Toggles: toggles,
+	})
+	if err != nil {
+		return nil, err
+	}
+	resp, err := sendAuthorized(r.auth, func(authorization string) (*http.Response, error) {
+		req, err := http.NewRequest(http.MethodPost, r.url, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Add("Authorization", authorization)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Add("User-Agent", USER_AGENT)
+		r.signer.sign(req, body)
+		return r.httpClient.Do(req)
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("remote evaluation fails: %s", resp.Status)
+	}
+	bytes, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	results := map[string]RemoteResult{}
+	if err := json.Unmarshal(bytes, &results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+func (r RemoteResult) evalResult() evalResult {
+	result := evalResult{
+		ruleIndex:      noIndex,
+		variationIndex: noIndex,
+		value:          r.Value,
+		reason:         r.Reason,
+		rule:           r.Rule,
+		segments:       r.Segments,
+		source:         SourceNetwork,
+	}
+	if r.RuleIndex != nil {
+		result.ruleIndex = *r.RuleIndex
+	}
+	if r.VariationIndex != nil {
+		result.variationIndex = *r.VariationIndex
+	}
+	if r.Version != nil {
+		result.version, result.hasVersion = *r.Version, true
+	}
+	return result
+}
+
+// evaluateRemote evaluates toggle through the evaluation endpoint, answering
+// defaultValue with the error as reason when the call or the toggle fails.
+func (fp *FeatureProbe) evaluateRemote(toggle string, user FPUser, defaultValue interface{}) (evalResult, bool) {
+	results, err := fp.remote.evaluate([]string{toggle}, user)
+	if err != nil {
+		return evalResult{
+			ruleIndex:      noIndex,
+			variationIndex: noIndex,
+			value:          defaultValue,
+			reason:         err.Error(),
+			err:            err,
+		}, false
+	}
+	result, err := remoteToggleResult(results, toggle, defaultValue)
+	return result, err == nil
+}
+
+func remoteToggleResult(results map[string]RemoteResult, toggle string, defaultValue interface{}) (evalResult, error) {
+	failed := evalResult{
+		ruleIndex:      noIndex,
+		variationIndex: noIndex,
+		value:          defaultValue,
+	}
+	r, ok := results[toggle]
+	if !ok {
+		failed.reason, failed.err = toggleNotExistReason(toggle), toggleError(toggle, ErrToggleNotFound)
+		return failed, failed.err
+	}
+	if r.Error == toggleNotExistReason(toggle) {
+		failed.reason, failed.err = r.Error, toggleError(toggle, ErrToggleNotFound)
+		return failed, failed.err
+	}
+	if r.Error != "" {
+		failed.reason, failed.err = r.Error, errors.New(r.Error)
+		return failed, failed.err
+	}
+	return r.evalResult(), nil
+}
+
+// BatchResult is the evaluation of one toggle by EvaluateBatch. Err is set,
+// and Detail holds the default value, when that toggle could not be
+// evaluated, remotely or locally.
+type BatchResult struct {
+	Detail EvalDetail
+	Err    error
+}
+
+// EvaluateBatch evaluates toggles for user in a single round trip to the
+// evaluation endpoint in remote evaluation mode, rather than one per toggle.
+// The error is only set when the call itself fails. Without remote
+// evaluation the toggles are evaluated locally.
+func (fp *FeatureProbe) EvaluateBatch(user FPUser, toggles []string) (map[string]BatchResult, error) {
+	batch := make(map[string]BatchResult, len(toggles))
+	var remote []string
+	shared := &sharedSegments{}
+	for _, toggle := range toggles {
+		if fp.remote != nil {
+			result, answered := fp.preempt(toggle, fp.Config.Defaults[toggle])
+			if !answered {
+				remote = append(remote, toggle)
+				continue
+			}
+			fp.degradation.countResult(toggle, result)
+			batch[toggle] = BatchResult{Detail: result.evalDetail(), Err: result.err}
+			continue
+		}
+		result, evaluated := fp.evaluateShared(toggle, user, nil, shared)
+		if evaluated {
+			fp.recordAccess(toggle, user, result)
+		} else {
+			fp.degradation.countResult(toggle, result)
+		}
+		batch[toggle] = BatchResult{Detail: result.evalDetail(), Err: result.err}
+	}
+	if len(remote) == 0 {
+		return batch, nil
+	}
+
+	results, err := fp.remote.evaluate(remote, user.withGlobal(fp.Config.GlobalAttributes))
+	if err != nil {
+		return nil, err
+	}
+	for _, toggle := range remote {
+		result, err := remoteToggleResult(results, toggle, fp.Config.Defaults[toggle])
+		if err == nil {
+			fp.recordAccess(toggle, user, result)
+		} else {
+			fp.degradation.countResult(toggle, result)
+		}
+		batch[toggle] = BatchResult{Detail: result.evalDetail(), Err: err}
+	}
+	return batch, nil
+}
+
+// remoteResult evaluates toggle like the evaluation endpoint does, for the
+// mock server.
+func (repo *Repository) remoteResult(toggle string, user FPUser, memo map[string]bool) RemoteResult {
+	t, ok := repo.GetToggle(toggle)
+	if !ok {
+		return RemoteResult{Error: toggleNotExistReason(toggle)}
+	}
+	result, err := t.detail(evalParams{
+		User:        user,
+		Repo:        repo,
+		Variations:  t.Variations,
+		Key:         t.Key,
+		segmentMemo: memo,
+	})
+	if err != nil {
+		return RemoteResult{Error: err.Error()}
+	}
+	return RemoteResult{
+		Value:          result.value,
+		RuleIndex:      result.ruleIndexPtr(),
+		VariationIndex: intPtr(result.variationIndex),
+		Version:        result.versionPtr(),
+		Reason:         result.reason,
+		Rule:           result.rule,
+		Segments:       repo.matchedSegments(result.rule, user, nil, nil),
+	}
+}
@@ -0,0 +1,133 @@
+package featureprobe
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRemoteEvaluation(t *testing.T) {
+	repo, _ := setup(t)
+	server := NewMockServer(repo)
+	defer server.Close()
+
+	fp, err := NewFeatureProbe(server.URL(), "sdk_key", WithRemoteEvaluation(true))
+	assert.Nil(t, err)
+	defer fp.Close()
+	local := FeatureProbe{Repo: NewRepositoryStore(&repo)}
+
+	user := NewUser().StableRollout("key11").With("city", "4")
+	expected, actual := local.BoolDetail("bool_toggle", user, true), fp.BoolDetail("bool_toggle", user, true)
+	assert.Equal(t, expected.Value, actual.Value)
+	assert.Equal(t, expected.RuleIndex, actual.RuleIndex)
+	assert.Equal(t, expected.Version, actual.Version)
+	assert.Equal(t, expected.Reason, actual.Reason)
+	assert.Equal(t, expected.Rule.Conditions, actual.Rule.Conditions)
+	assert.Equal(t, expected.Segments, actual.Segments)
+	assert.Equal(t, local.JsonValue("json_toggle", user, nil), fp.JsonValue("json_toggle", user, nil))
+	assert.Equal(t, 0, server.TogglesRequests())
+	assert.Equal(t, 2, server.EvaluateRequests())
+
+	detail := fp.StrDetail("not_exist_toggle", user, "d")
+	assert.Equal(t, "d", detail.Value)
+	assert.Equal(t, "Toggle:[not_exist_toggle] not exist", detail.Reason)
+}
+
+func TestRemoteEvaluationFails(t *testing.T) {
+	server := NewMockServer(Repository{})
+	defer server.Close()
+	server.FailEvaluate(http.StatusInternalServerError, 1)
+
+	fp, _ := NewFeatureProbe(server.URL(), "sdk_key", WithRemoteEvaluation(true), WithEvaluationUri("api/server-sdk/evaluate"))
+	defer fp.Close()
+
+	detail := fp.BoolDetail("toggle", NewUser(), true)
+	assert.True(t, detail.Value)
+	assert.Equal(t, "remote evaluation fails: 500 Internal Server Error", detail.Reason)
+}
+
+func TestEvaluateBatch(t *testing.T) {
+	repo, _ := setup(t)
+	server := NewMockServer(repo)
+	defer server.Close()
+
+	fp, _ := NewFeatureProbe(server.URL(), "sdk_key", WithRemoteEvaluation(true))
+	defer fp.Close()
+	fp.Override("string_toggle", "overridden")
+	local := FeatureProbe{Repo: NewRepositoryStore(&repo)}
+
+	user := NewUser().StableRollout("key11").With("city", "4")
+	batch, err := fp.EvaluateBatch(user, []string{"bool_toggle", "number_toggle", "string_toggle", "not_exist_toggle"})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, server.EvaluateRequests())
+	assert.Len(t, batch, 4)
+
+	assert.Nil(t, batch["bool_toggle"].Err)
+	assert.Equal(t, local.BoolDetail("bool_toggle", user, false).Value, batch["bool_toggle"].Detail.Value)
+	assert.Equal(t, local.NumberValue("number_toggle", user, 0), batch["number_toggle"].Detail.Value)
+	assert.Equal(t, "overridden", batch["string_toggle"].Detail.Value)
+	assert.Nil(t, batch["not_exist_toggle"].Detail.Value)
+	assert.True(t, errors.Is(batch["not_exist_toggle"].Err, ErrToggleNotFound))
+
+	server.FailEvaluate(http.StatusInternalServerError, 1)
+	batch, err = fp.EvaluateBatch(user, []string{"bool_toggle"})
+	assert.NotNil(t, err)
+	assert.Nil(t, batch)
+}
+
+func TestEvaluateBatchAfterClose(t *testing.T) {
+	repo, _ := setup(t)
+	server := NewMockServer(repo)
+	defer server.Close()
+	fp, _ := NewFeatureProbe(server.URL(), "sdk_key", WithRemoteEvaluation(true))
+	fp.Close()
+
+	batch, err := fp.EvaluateBatch(NewUser(), []string{"bool_toggle"})
+	assert.Nil(t, err)
+	assert.Equal(t, 0, server.EvaluateRequests())
+	assert.True(t, errors.Is(batch["bool_toggle"].Err, ErrClientClosed))
+	assert.Nil(t, batch["bool_toggle"].Detail.Value)
+}
+
+func TestEvaluateBatchDefaults(t *testing.T) {
+	repo, _ := setup(t)
+	server := NewMockServer(repo)
+	defer server.Close()
+	fp, _ := NewFeatureProbe(server.URL(), "sdk_key", WithRemoteEvaluation(true),
+		WithDefaults(map[string]interface{}{"not_exist_toggle": "configured", "killed_toggle": "configured"}))
+	defer fp.Close()
+
+	batch, err := fp.EvaluateBatch(NewUser(), []string{"not_exist_toggle", "other_missing_toggle"})
+	assert.Nil(t, err)
+	assert.Equal(t, "configured", batch["not_exist_toggle"].Detail.Value)
+	assert.True(t, errors.Is(batch["not_exist_toggle"].Err, ErrToggleNotFound))
+	assert.Nil(t, batch["other_missing_toggle"].Detail.Value)
+
+	fp.SetForceDefaults(true)
+	batch, err = fp.EvaluateBatch(NewUser(), []string{"killed_toggle"})
+	assert.Nil(t, err)
+	assert.Equal(t, "configured", batch["killed_toggle"].Detail.Value)
+	assert.Equal(t, ForceDefaultsReason, batch["killed_toggle"].Detail.Reason)
+}
+
+func TestEvaluateBatchLocal(t *testing.T) {
+	repo, _ := setup(t)
+	fp := FeatureProbe{Repo: NewRepositoryStore(&repo)}
+
+	user := NewUser().StableRollout("key11").With("city", "4")
+	batch, err := fp.EvaluateBatch(user, []string{"bool_toggle"})
+	assert.Nil(t, err)
+	assert.Equal(t, fp.BoolValue("bool_toggle", user, false), batch["bool_toggle"].Detail.Value)
+}
+
+func TestEvaluateBatchLocalMissingToggle(t *testing.T) {
+	fp := NewFeatureProbeForTest(map[string]interface{}{"toggle": "value"})
+
+	batch, err := fp.EvaluateBatch(NewUser(), []string{"toggle", "missing"})
+	assert.Nil(t, err)
+	assert.Nil(t, batch["toggle"].Err)
+	assert.True(t, errors.Is(batch["missing"].Err, ErrToggleNotFound))
+	assert.Equal(t, uint64(1), fp.DegradationReport().ByToggle["missing"][DegradedNotFound])
+}
@@ -0,0 +1,115 @@
+package featureprobe
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// RepositoryStore holds the current Repository snapshot. A stored snapshot is
+// never mutated again; updates swap in a new one atomically, so evaluations
+// never race with synchronization.
+type RepositoryStore struct {
+	value atomic.Value
+	mu    sync.Mutex
+	// history holds up to historySize replaced snapshots, oldest first.
+	history     []*Repository
+	historySize int
+	// version counts the repositories published, guarded by mu.
+	version uint64
+	// unconfirmed is the synced repository an evaluation panic may still
+	// roll back from, until it is replaced or rolled back. Guarded by mu.
+	unconfirmed *Repository
+}
+
+func NewRepositoryStore(repo *Repository) *RepositoryStore {
+	s := &RepositoryStore{}
+	s.Store(repo)
+	return s
+}
+
+func (s *RepositoryStore) Load() *Repository {
+	if s == nil {
+		return nil
+	}
+	repo, _ := s.value.Load().(*Repository)
+	return repo
+}
+
+func (s *RepositoryStore) Store(repo *Repository) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.store(repo)
+}
+
+// storeSynced stores a repository fetched from FeatureProbe, which an
+// evaluation panic may roll back once.
+func (s *RepositoryStore) storeSynced(repo *Repository) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.store(repo)
+	s.unconfirmed = repo
+}
+
+// store must be called with mu held.
+func (s *RepositoryStore) store(repo *Repository) {
+	if previous := s.Load(); previous != nil && s.historySize > 0 {
+		s.history = append(s.history, previous)
+		if len(s.history) > s.historySize {
+			s.history = s.history[len(s.history)-s.historySize:]
+		}
+	}
+	s.publish(repo)
+}
+
+// publish must be called with mu held.
+func (s *RepositoryStore) publish(repo *Repository) {
+	s.value.Store(repo)
+	s.version++
+	s.unconfirmed = nil
+}
+
+// release drops the current repository and its history.
+func (s *RepositoryStore) release() {
+	s.mu.Lock()
+	s.history = nil
+	s.publish(&Repository{})
+	s.mu.Unlock()
+}
+
+// GetToggle, GetSegment and Snapshot are the accessors SDK internals use to
+// read a repository. They need no locking: a snapshot published through a
+// RepositoryStore is never written again.
+func (repo *Repository) GetToggle(key string) (Toggle, bool) {
+	if repo == nil {
+		return Toggle{}, false
+	}
+	t, ok := repo.Toggles[key]
+	return t, ok
+}
+
+func (repo *Repository) GetSegment(key string) (Segment, bool) {
+	if repo == nil {
+		return Segment{}, false
+	}
+	s, ok := repo.Segments[key]
+	return s, ok
+}
+
+// Snapshot returns a copy of the repository whose maps may be modified
+// freely by the caller.
+func (repo *Repository) Snapshot() Repository {
+	snapshot := Repository{
+		Toggles:  map[string]Toggle{},
+		Segments: map[string]Segment{},
+	}
+	if repo == nil {
+		return snapshot
+	}
+	for k, t := range repo.Toggles {
+		snapshot.Toggles[k] = t
+	}
+	for k, s := range repo.Segments {
+		snapshot.Segments[k] = s
+	}
+	return snapshot
+}
@@ -0,0 +1,163 @@
+package featureprobe
+
+import "sort"
+
+// RepositorySnapshot is a read-only view of the repository a client
+// evaluated at one point in time, for tooling. Its accessors return copies,
+// so nothing a caller does affects evaluations.
+type RepositorySnapshot struct {
+	repo    *Repository
+	version uint64
+}
+
+// Snapshot returns the repository the client currently evaluates.
+func (fp *FeatureProbe) Snapshot() RepositorySnapshot {
+	s := fp.Repo
+	if s == nil {
+		return RepositorySnapshot{repo: &Repository{}}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	repo := s.Load()
+	if repo == nil {
+		repo = &Repository{}
+	}
+	return RepositorySnapshot{repo: repo, version: s.version}
+}
+
+// Version increases every time the client replaces its repository, by a sync,
+// an import or a rollback. Equal versions mean the same repository.
+func (s RepositorySnapshot) Version() uint64 {
+	return s.version
+}
+
+// Toggles returns the sorted keys of the toggles.
+func (s RepositorySnapshot) Toggles() []string {
+	keys := make([]string, 0, len(s.repo.Toggles))
+	for k := range s.repo.Toggles {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (s RepositorySnapshot) Toggle(key string) (Toggle, bool) {
+	t, ok := s.repo.GetToggle(key)
+	if !ok {
+		return Toggle{}, false
+	}
+	return t.clone(), true
+}
+
+// Segments returns the sorted unique ids of the segments.
+func (s RepositorySnapshot) Segments() []string {
+	keys := make([]string, 0, len(s.repo.Segments))
+	for k := range s.repo.Segments {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (s RepositorySnapshot) Segment(key string) (Segment, bool) {
+	segment, ok := s.repo.GetSegment(key)
+	if !ok {
+		return Segment{}, false
+	}
+	segment.Rules = cloneRules(segment.Rules)
+	return segment, true
+}
+
+// clone copies the rules and variations of t, decoding object and array
+// variations into the maps and slices encoding/json produces.
+func (t Toggle) clone() Toggle {
+	t.Rules = cloneRules(t.Rules)
+	t.DisabledServe = t.DisabledServe.clone()
+	t.DefaultServe = t.DefaultServe.clone()
+	if t.Variations != nil {
+		variations := make([]interface{}, len(t.Variations))
+		for i, v := range t.Variations {
+			variations[i] = copyJSONValue(variationValue(v))
+		}
+		t.Variations = variations
+	}
+	if t.Prerequisites != nil {
+		t.Prerequisites = append([]Prerequisite{}, t.Prerequisites...)
+	}
+	if t.Metadata != nil {
+		metadata := make(map[string]interface{}, len(t.Metadata))
+		for k, v := range t.Metadata {
+			metadata[k] = v
+		}
+		t.Metadata = metadata
+	}
+	return t
+}
+
+// copyJSONValue deep copies the maps and slices of a decoded JSON value.
+func copyJSONValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			m[k] = copyJSONValue(e)
+		}
+		return m
+	case []interface{}:
+		s := make([]interface{}, len(v))
+		for i, e := range v {
+			s[i] = copyJSONValue(e)
+		}
+		return s
+	}
+	return v
+}
+
+// detached is a copy of repo whose toggles are cloned, for handing outside
+// the SDK.
+func (repo *Repository) detached() Repository {
+	snapshot := repo.Snapshot()
+	for k, t := range snapshot.Toggles {
+		snapshot.Toggles[k] = t.clone()
+	}
+	for k, s := range snapshot.Segments {
+		s.Rules = cloneRules(s.Rules)
+		snapshot.Segments[k] = s
+	}
+	return snapshot
+}
+
+func cloneRules(rules []Rule) []Rule {
+	if rules == nil {
+		return nil
+	}
+	cloned := make([]Rule, len(rules))
+	for i, r := range rules {
+		cloned[i] = Rule{Serve: r.Serve.clone(), Conditions: make([]Condition, len(r.Conditions))}
+		for j, c := range r.Conditions {
+			c.Objects = append([]string(nil), c.Objects...)
+			cloned[i].Conditions[j] = c
+		}
+	}
+	return cloned
+}
+
+func (s Serve) clone() Serve {
+	if s.Select != nil {
+		i := *s.Select
+		s.Select = &i
+	}
+	if s.Split != nil {
+		split := *s.Split
+		split.Distribution = make([][]Range, len(s.Split.Distribution))
+		for i, d := range s.Split.Distribution {
+			split.Distribution[i] = append([]Range(nil), d...)
+		}
+		if split.Remainder != nil {
+			r := *split.Remainder
+			split.Remainder = &r
+		}
+		s.Split = &split
+	}
+	return s
+}
@@ -0,0 +1,60 @@
+package featureprobe
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRepositorySnapshot(t *testing.T) {
+	repo, _ := setup(t)
+	fp := FeatureProbe{Repo: NewRepositoryStore(&repo)}
+	user := NewUser().StableRollout("key11").With("city", "4")
+	before := fp.BoolDetail("bool_toggle", user, false)
+
+	snapshot := fp.Snapshot()
+	assert.Equal(t, uint64(1), snapshot.Version())
+	assert.Contains(t, snapshot.Toggles(), "bool_toggle")
+	assert.Equal(t, []string{"some_segment1-fjoaefjaam"}, snapshot.Segments())
+
+	toggle, ok := snapshot.Toggle("bool_toggle")
+	assert.True(t, ok)
+	toggle.Rules[0].Conditions[0].Objects[0] = "changed"
+	*toggle.DefaultServe.Select = 1
+	toggle.Variations[0] = "changed"
+	segment, ok := snapshot.Segment("some_segment1-fjoaefjaam")
+	assert.True(t, ok)
+	segment.Rules[0].Conditions[0].Objects = nil
+	assert.Equal(t, before, fp.BoolDetail("bool_toggle", user, false))
+
+	_, ok = snapshot.Toggle("not_exist")
+	assert.False(t, ok)
+
+	fp.Repo.Store(&Repository{})
+	assert.Equal(t, uint64(2), fp.Snapshot().Version())
+	assert.Empty(t, fp.Snapshot().Toggles())
+	assert.Contains(t, snapshot.Toggles(), "bool_toggle")
+}
+
+func TestRepositorySnapshotDecodesJSONVariations(t *testing.T) {
+	repo, err := ParseRepository([]byte(`{"toggles": {"json_toggle": {"key": "json_toggle", "enabled": true,
+		"variations": [{"a": [1, 2]}, [3]], "disabledServe": {"select": 1}, "defaultServe": {"select": 0}}}}`))
+	assert.Nil(t, err)
+	fp := FeatureProbe{Repo: NewRepositoryStore(repo)}
+
+	toggle, ok := fp.Snapshot().Toggle("json_toggle")
+	assert.True(t, ok)
+	object, ok := toggle.Variations[0].(map[string]interface{})
+	assert.True(t, ok)
+	array, ok := toggle.Variations[1].([]interface{})
+	assert.True(t, ok)
+	assert.Equal(t, []interface{}{float64(3)}, array)
+	object["a"].([]interface{})[0] = "changed"
+	assert.Equal(t, map[string]interface{}{"a": []interface{}{float64(1), float64(2)}}, fp.JsonValue("json_toggle", NewUser(), nil))
+
+	store := NewInMemoryDataStore()
+	assert.Nil(t, store.Init(repo))
+	stored, _, _ := store.GetToggle("json_toggle")
+	_, ok = stored.Variations[0].(map[string]interface{})
+	assert.True(t, ok)
+}
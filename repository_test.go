@@ -0,0 +1,91 @@
+package featureprobe
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRepositoryStoreNil(t *testing.T) {
+	var store *RepositoryStore
+	assert.Nil(t, store.Load())
+
+	store = NewRepositoryStore(nil)
+	assert.Nil(t, store.Load())
+}
+
+func TestRepositoryStoreSwapDuringEvaluation(t *testing.T) {
+	repo, _ := setup(t)
+	fp := FeatureProbe{Repo: NewRepositoryStore(&Repository{})}
+	user := NewUser().StableRollout("key11").With("city", "1")
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 1000; i++ {
+			next := repo
+			fp.Repo.Store(&next)
+			fp.Repo.Store(&Repository{})
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 1000; i++ {
+			fp.BoolValue("bool_toggle", user, true)
+		}
+	}()
+	wg.Wait()
+}
+
+func TestRepositoryAccessors(t *testing.T) {
+	repo, _ := setup(t)
+
+	toggle, ok := repo.GetToggle("bool_toggle")
+	assert.True(t, ok)
+	assert.Equal(t, "bool_toggle", toggle.Key)
+	_, ok = repo.GetToggle("not_exist_toggle")
+	assert.False(t, ok)
+
+	segment, ok := repo.GetSegment("some_segment1-fjoaefjaam")
+	assert.True(t, ok)
+	assert.Equal(t, "some_segment1", segment.Key)
+
+	var nilRepo *Repository
+	_, ok = nilRepo.GetToggle("bool_toggle")
+	assert.False(t, ok)
+	_, ok = nilRepo.GetSegment("some_segment1-fjoaefjaam")
+	assert.False(t, ok)
+	assert.Equal(t, 0, len(nilRepo.Snapshot().Toggles))
+}
+
+func TestRepositorySnapshotIsCopy(t *testing.T) {
+	repo, _ := setup(t)
+	snapshot := repo.Snapshot()
+	assert.Equal(t, repo, snapshot)
+
+	delete(snapshot.Toggles, "bool_toggle")
+	snapshot.Segments = nil
+	_, ok := repo.GetToggle("bool_toggle")
+	assert.True(t, ok)
+	assert.True(t, len(repo.Segments) > 0)
+}
+
+func TestCloseDuringEvaluation(t *testing.T) {
+	repo, _ := setup(t)
+	fp := NewFeatureProbeForTest(map[string]interface{}{})
+	fp.setRepoForTest(repo)
+	user := NewUser().StableRollout("key11").With("city", "4")
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 1000; i++ {
+			fp.StrValue("string_toggle", user, "1")
+		}
+	}()
+	fp.Close()
+	<-done
+	assert.Equal(t, 0, len(fp.Repo.Load().Toggles))
+}
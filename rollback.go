@@ -0,0 +1,76 @@
+package featureprobe
+
+import "fmt"
+
+// DefaultRepositoryHistory is how many replaced repositories a client keeps
+// for RollbackRepository.
+const DefaultRepositoryHistory = 2
+
+// PanicReason is the reason of evaluations answered with the default because
+// evaluating the toggle panicked.
+const PanicReason = "internal error"
+
+// WithRepositoryHistory keeps the last n replaced repositories in memory for
+// RollbackRepository. Zero disables rollback.
+func WithRepositoryHistory(n int) Option {
+	return func(fpConfig *FPConfig) {
+		fpConfig.RepositoryHistory = n
+	}
+}
+
+// RollbackRepository restores the repository replaced n updates ago, n = 1
+// being the previous one, as a defense against malformed server pushes. The
+// payload rolled back from is not applied again by the synchronizer; the next
+// different one is.
+func (fp *FeatureProbe) RollbackRepository(n int) error {
+	return fp.rollback(n, nil)
+}
+
+// rollback only rolls back from expect, when given, and only while expect is
+// the latest synced repository not rolled back from yet, so panics roll back
+// at most once per synced repository.
+func (fp *FeatureProbe) rollback(n int, expect *Repository) error {
+	s := fp.Repo
+	if s == nil {
+		return ErrNotInitialized
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if expect != nil && (s.Load() != expect || s.unconfirmed != expect) {
+		return fmt.Errorf("repository not rolled back automatically")
+	}
+	if n < 1 || n > len(s.history) {
+		return fmt.Errorf("cannot roll back %d repositories, %d kept", n, len(s.history))
+	}
+	target := s.history[len(s.history)-n]
+	s.history = s.history[:len(s.history)-n]
+	// rejected before publishing, or a sync seeing the target could apply
+	// the current payload again
+	if fp.Syncer != nil {
+		fp.Syncer.rejectCurrent()
+	}
+	s.publish(target)
+	return nil
+}
+
+// evaluationPanicked answers the default for an evaluation of t that
+// panicked with r, rolling back the latest synced repository when the panic
+// happened with it, and
+// quarantining t once it panicked QuarantinePanics times.
+func (fp *FeatureProbe) evaluationPanicked(t *Toggle, repo *Repository, r interface{}, defaultValue interface{}) evalResult {
+	err := fmt.Errorf("evaluating toggle %s panics: %v", t.Key, r)
+	if rollbackErr := fp.rollback(1, repo); rollbackErr == nil {
+		err = fmt.Errorf("%s, repository rolled back", err)
+	}
+	if t.usage.panicked() {
+		err = fmt.Errorf("%s, toggle quarantined", err)
+	}
+	fp.Config.reportError(err)
+	return evalResult{
+		ruleIndex:      noIndex,
+		variationIndex: noIndex,
+		value:          defaultValue,
+		reason:         PanicReason,
+		err:            toggleError(t.Key, ErrInternal),
+	}
+}
@@ -0,0 +1,76 @@
+package featureprobe
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRollbackRepository(t *testing.T) {
+	good := Repository{Toggles: map[string]Toggle{"toggle": newToggleForTest("toggle", "good")}}
+	toggle := newToggleForTest("toggle", "bad")
+	toggle.Version = 1
+	bad := Repository{Toggles: map[string]Toggle{"toggle": toggle}}
+	server := NewMockServer(good)
+	defer server.Close()
+
+	fp, err := NewFeatureProbe(server.URL(), "sdk_key", WithRefreshInterval(20))
+	assert.Nil(t, err)
+	defer fp.Close()
+	assert.Equal(t, "good", fp.StrValue("toggle", NewUser(), ""))
+
+	server.SetRepository(bad)
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, "bad", fp.StrValue("toggle", NewUser(), ""))
+
+	assert.Nil(t, fp.RollbackRepository(1))
+	assert.Equal(t, "good", fp.StrValue("toggle", NewUser(), ""))
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, "good", fp.StrValue("toggle", NewUser(), ""))
+
+	assert.Equal(t, "cannot roll back 2 repositories, 1 kept", fp.RollbackRepository(2).Error())
+}
+
+func TestRollbackOnEvaluationPanic(t *testing.T) {
+	fp := NewFeatureProbeForTest(map[string]interface{}{"toggle": "good"})
+	fp.Repo.historySize = 1
+	var reported []error
+	fp.Config.ErrorListener = func(err error) { reported = append(reported, err) }
+
+	broken := newToggleForTest("toggle", "bad")
+	index := -2
+	broken.DefaultServe = Serve{Select: &index}
+	fp.Repo.storeSynced(&Repository{Toggles: map[string]Toggle{"toggle": broken}})
+
+	detail := fp.StrDetail("toggle", NewUser(), "default")
+	assert.Equal(t, "default", detail.Value)
+	assert.Equal(t, PanicReason, detail.Reason)
+	assert.Len(t, reported, 1)
+	assert.Contains(t, reported[0].Error(), "repository rolled back")
+	assert.Equal(t, "good", fp.StrValue("toggle", NewUser(), "default"))
+}
+
+func TestRollbackOnEvaluationPanicOnce(t *testing.T) {
+	broken := newToggleForTest("toggle", "bad")
+	index := -2
+	broken.DefaultServe = Serve{Select: &index}
+	fp := NewFeatureProbeForTest(map[string]interface{}{})
+	fp.Repo.historySize = 2
+	fp.Config.ErrorListener = func(err error) {}
+	good := &Repository{Toggles: map[string]Toggle{"toggle": broken, "other": newToggleForTest("other", "first")}}
+	fp.Repo.storeSynced(good)
+	second := &Repository{Toggles: map[string]Toggle{"toggle": broken, "other": newToggleForTest("other", "second")}}
+	fp.Repo.storeSynced(second)
+
+	fp.StrValue("toggle", NewUser(), "default")
+	assert.Equal(t, "first", fp.StrValue("other", NewUser(), "default"))
+	fp.StrValue("toggle", NewUser(), "default")
+	assert.Equal(t, "first", fp.StrValue("other", NewUser(), "default"))
+
+	// repositories not synced are never rolled back automatically
+	local := &Repository{Toggles: map[string]Toggle{"toggle": broken}}
+	fp.Repo.Store(local)
+	fp.StrValue("toggle", NewUser(), "default")
+	assert.True(t, local == fp.Repo.Load())
+}
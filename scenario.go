@@ -0,0 +1,134 @@
+package featureprobe
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"sync"
+)
+
+type ContractTests struct {
+	Tests []Scenario `json:"tests"`
+}
+
+type Scenario struct {
+	Scenario string     `json:"scenario"`
+	Cases    []Case     `json:"cases"`
+	Fixture  Repository `json:"fixture"`
+}
+
+type Case struct {
+	Name         string       `json:"name"`
+	User         CaseUser     `json:"user"`
+	Function     CaseFunction `json:"function"`
+	ExpectResult ExpectResult `json:"expectResult"`
+}
+
+type CaseUser struct {
+	Key          string     `json:"key"`
+	CustomValues []KeyValue `json:"customValues"`
+}
+
+type CaseFunction struct {
+	Name    string      `json:"name"`
+	Toggle  string      `json:"toggle"`
+	Default interface{} `json:"default"`
+}
+
+type ExpectResult struct {
+	Value       interface{} `json:"value"`
+	Reason      *string     `json:"reason,omitempty"`
+	RuleIndex   *int        `json:"ruleIndex,omitempty"`
+	NoRuleIndex *bool       `json:"noRuleIndex,omitempty"`
+	Version     *uint64     `json:"version,omitempty"`
+}
+
+type KeyValue struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// ScenarioRecorder captures real evaluations in the server-sdk-specification
+// contract format, so production behavior can be replayed as a regression test.
+// Each toggle is added to the fixture the first time it is evaluated; later
+// evaluations of a different toggle version are skipped to keep the scenario
+// consistent with its fixture.
+type ScenarioRecorder struct {
+	mu       sync.Mutex
+	scenario Scenario
+}
+
+func NewScenarioRecorder(name string) *ScenarioRecorder {
+	return &ScenarioRecorder{
+		scenario: Scenario{
+			Scenario: name,
+			Cases:    []Case{},
+			Fixture: Repository{
+				Toggles:  map[string]Toggle{},
+				Segments: map[string]Segment{},
+			},
+		},
+	}
+}
+
+func (r *ScenarioRecorder) record(repo *Repository, function, toggle string, user FPUser, defaultValue interface{}, expect ExpectResult) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if repo != nil {
+		if t, ok := repo.GetToggle(toggle); ok {
+			recorded, exist := r.scenario.Fixture.Toggles[toggle]
+			if exist && recorded.Version != t.Version {
+				return
+			}
+			r.scenario.Fixture.Toggles[toggle] = t
+		}
+		for k, s := range repo.Snapshot().Segments {
+			if _, exist := r.scenario.Fixture.Segments[k]; !exist {
+				r.scenario.Fixture.Segments[k] = s
+			}
+		}
+	}
+
+	r.scenario.Cases = append(r.scenario.Cases, Case{
+		Name:         fmt.Sprintf("%s %s #%d", function, toggle, len(r.scenario.Cases)),
+		User:         newCaseUser(user),
+		Function:     CaseFunction{Name: function, Toggle: toggle, Default: defaultValue},
+		ExpectResult: expect,
+	})
+}
+
+func (r *ScenarioRecorder) Scenario() Scenario {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s := r.scenario
+	s.Cases = append([]Case{}, r.scenario.Cases...)
+	s.Fixture = r.scenario.Fixture.detached()
+	return s
+}
+
+// WriteFile writes the recorded scenario as a contract test file, in the same
+// layout as the spec/ files of server-sdk-specification.
+func (r *ScenarioRecorder) WriteFile(path string) error {
+	tests := ContractTests{Tests: []Scenario{r.Scenario()}}
+	bytes, err := json.MarshalIndent(tests, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, bytes, 0644)
+}
+
+func newCaseUser(user FPUser) CaseUser {
+	keys := make([]string, 0, len(user.attrs))
+	for k := range user.attrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	values := make([]KeyValue, 0, len(keys))
+	for _, k := range keys {
+		values = append(values, KeyValue{Key: k, Value: user.attrs[k]})
+	}
+	return CaseUser{Key: user.key, CustomValues: values}
+}
@@ -0,0 +1,88 @@
+package featureprobe
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScenarioRecorder(t *testing.T) {
+	repo, _ := setup(t)
+	recorder := NewScenarioRecorder("recorded")
+	fp := FeatureProbe{Repo: NewRepositoryStore(&repo), Config: FPConfig{Scenarios: recorder}}
+
+	user := NewUser().StableRollout("key11").With("city", "1")
+	fp.BoolValue("bool_toggle", user, true)
+	fp.StrDetail("string_toggle", user, "1")
+	fp.NumberDetail("not_exist_toggle", user, 3.0)
+	fp.JsonValue("json_toggle", user, nil)
+
+	scenario := recorder.Scenario()
+	assert.Equal(t, "recorded", scenario.Scenario)
+	assert.Equal(t, 4, len(scenario.Cases))
+	assert.Equal(t, 3, len(scenario.Fixture.Toggles))
+	assert.Equal(t, len(repo.Segments), len(scenario.Fixture.Segments))
+
+	c := scenario.Cases[0]
+	assert.Equal(t, "bool_value", c.Function.Name)
+	assert.Equal(t, "key11", c.User.Key)
+	assert.Equal(t, []KeyValue{{Key: "city", Value: "1"}}, c.User.CustomValues)
+	assert.Equal(t, true, c.ExpectResult.Value)
+	assert.Nil(t, c.ExpectResult.Reason)
+
+	c = scenario.Cases[2]
+	assert.Equal(t, 3.0, c.ExpectResult.Value)
+	assert.True(t, *c.ExpectResult.NoRuleIndex)
+	assert.Nil(t, c.ExpectResult.Version)
+
+	delete(scenario.Fixture.Toggles, "json_toggle")
+	scenario.Fixture.Toggles["bool_toggle"].Rules[0].Conditions[0].Objects[0] = "changed"
+	fixture := recorder.Scenario().Fixture
+	assert.Equal(t, 3, len(fixture.Toggles))
+	assert.NotEqual(t, "changed", fixture.Toggles["bool_toggle"].Rules[0].Conditions[0].Objects[0])
+}
+
+func TestScenarioRecorderSkipsChangedVersion(t *testing.T) {
+	repo, _ := setup(t)
+	recorder := NewScenarioRecorder("recorded")
+	fp := FeatureProbe{Repo: NewRepositoryStore(&repo), Config: FPConfig{Scenarios: recorder}}
+	user := NewUser().StableRollout("key11")
+
+	fp.BoolValue("bool_toggle", user, true)
+	toggle := repo.Toggles["bool_toggle"]
+	toggle.Version += 1
+	repo.Toggles["bool_toggle"] = toggle
+	fp.BoolValue("bool_toggle", user, true)
+
+	assert.Equal(t, 1, len(recorder.Scenario().Cases))
+}
+
+func TestScenarioRecorderWriteFile(t *testing.T) {
+	repo, _ := setup(t)
+	recorder := NewScenarioRecorder("recorded")
+	fp := FeatureProbe{Repo: NewRepositoryStore(&repo), Config: FPConfig{Scenarios: recorder}}
+	user := NewUser().StableRollout("key11").With("city", "4")
+	fp.BoolDetail("bool_toggle", user, true)
+
+	dir, err := ioutil.TempDir("", "scenario")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "recorded_spec.json")
+	assert.NoError(t, recorder.WriteFile(path))
+
+	bytes, _ := ioutil.ReadFile(path)
+	var tests ContractTests
+	assert.NoError(t, json.Unmarshal(bytes, &tests))
+	assert.Equal(t, 1, len(tests.Tests))
+
+	replay := FeatureProbe{Repo: NewRepositoryStore(&tests.Tests[0].Fixture)}
+	c := tests.Tests[0].Cases[0]
+	detail := replay.BoolDetail(c.Function.Toggle, user, c.Function.Default.(bool))
+	assert.Equal(t, c.ExpectResult.Value, detail.Value)
+	assert.Equal(t, *c.ExpectResult.Reason, detail.Reason)
+	assert.Equal(t, *c.ExpectResult.RuleIndex, *detail.RuleIndex)
+}
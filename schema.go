@@ -0,0 +1,128 @@
+package featureprobe
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+	"sync/atomic"
+)
+
+// ValidationReason is the reason of evaluations that fall back to the
+// default because the variation served fails validation.
+const ValidationReason = "variation fails validation"
+
+// ValidationError reports a variation of a toggle that fails the validator
+// registered with WithJsonValidator or WithJsonPrototype.
+type ValidationError struct {
+	Toggle    string
+	Variation int
+	Err       error
+}
+
+func (e ValidationError) Error() string {
+	return fmt.Sprintf("toggle %s variation %d fails validation: %s", e.Toggle, e.Variation, e.Err)
+}
+
+// WithJsonValidator validates every variation of toggle, encoded as JSON,
+// when toggles are synced. Variations failing validation are reported to the
+// error listener and never served: evaluations fall back to the default, so a
+// bad console edit can't crash consumers.
+func WithJsonValidator(toggle string, validate func(data []byte) error) Option {
+	return func(fpConfig *FPConfig) {
+		if fpConfig.JsonValidators == nil {
+			fpConfig.JsonValidators = map[string]func([]byte) error{}
+		}
+		fpConfig.JsonValidators[toggle] = validate
+	}
+}
+
+// WithJsonPrototype validates the variations of toggle by decoding them into
+// a new value of prototype's type, rejecting unknown fields and mismatched
+// types.
+func WithJsonPrototype(toggle string, prototype interface{}) Option {
+	typ := reflect.TypeOf(prototype)
+	if typ != nil && typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	return WithJsonValidator(toggle, func(data []byte) error {
+		if typ == nil {
+			return nil
+		}
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		return decoder.Decode(reflect.New(typ).Interface())
+	})
+}
+
+type jsonValidators struct {
+	validators map[string]func([]byte) error
+	report     func(error)
+	mu         sync.Mutex
+	// checked holds the *checkedRepository of the last repository validated.
+	checked atomic.Value
+}
+
+type checkedRepository struct {
+	repo    *Repository
+	invalid map[string][]bool
+}
+
+func newJsonValidators(config FPConfig) *jsonValidators {
+	if len(config.JsonValidators) == 0 {
+		return nil
+	}
+	return &jsonValidators{validators: config.JsonValidators, report: config.reportError}
+}
+
+// invalid reports whether variation of toggle fails validation in repo.
+func (v *jsonValidators) invalid(repo *Repository, toggle string, variation int) bool {
+	if v == nil || variation == noIndex {
+		return false
+	}
+	if _, ok := v.validators[toggle]; !ok {
+		return false
+	}
+	indexes := v.validate(repo).invalid[toggle]
+	return variation < len(indexes) && indexes[variation]
+}
+
+// validate checks repo once, reporting every failing variation.
+func (v *jsonValidators) validate(repo *Repository) *checkedRepository {
+	if c, ok := v.checked.Load().(*checkedRepository); ok && c.repo == repo {
+		return c
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if c, ok := v.checked.Load().(*checkedRepository); ok && c.repo == repo {
+		return c
+	}
+
+	c := &checkedRepository{repo: repo, invalid: map[string][]bool{}}
+	for toggle, validate := range v.validators {
+		t, ok := repo.GetToggle(toggle)
+		if !ok {
+			continue
+		}
+		indexes := make([]bool, len(t.Variations))
+		for i, variation := range t.Variations {
+			err := validateVariation(variation, validate)
+			if err != nil {
+				indexes[i] = true
+				v.report(ValidationError{Toggle: toggle, Variation: i, Err: err})
+			}
+		}
+		c.invalid[toggle] = indexes
+	}
+	v.checked.Store(c)
+	return c
+}
+
+func validateVariation(variation interface{}, validate func([]byte) error) error {
+	data, err := json.Marshal(variation)
+	if err != nil {
+		return err
+	}
+	return validate(data)
+}
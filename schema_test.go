@@ -0,0 +1,65 @@
+package featureprobe
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type themeConfig struct {
+	Color string `json:"color"`
+	Size  int    `json:"size"`
+}
+
+func TestJsonPrototype(t *testing.T) {
+	repo := Repository{
+		Toggles: map[string]Toggle{
+			"good": newToggleForTest("good", map[string]interface{}{"color": "red", "size": 1}),
+			"bad":  newToggleForTest("bad", map[string]interface{}{"color": "red", "size": "big"}),
+		},
+	}
+	server := NewMockServer(repo)
+	defer server.Close()
+
+	var mu sync.Mutex
+	var errs []error
+	fp, err := NewFeatureProbe(server.URL(), "sdk_key",
+		WithRefreshInterval(50),
+		WithJsonPrototype("good", themeConfig{}),
+		WithJsonPrototype("bad", &themeConfig{}),
+		WithErrorListener(func(err error) {
+			mu.Lock()
+			errs = append(errs, err)
+			mu.Unlock()
+		}),
+	)
+	assert.Nil(t, err)
+	defer fp.Close()
+
+	mu.Lock()
+	assert.Len(t, errs, 1)
+	assert.Equal(t, "bad", errs[0].(ValidationError).Toggle)
+	assert.Equal(t, 0, errs[0].(ValidationError).Variation)
+	mu.Unlock()
+
+	assert.Equal(t, map[string]interface{}{"color": "red", "size": 1.0}, fp.JsonValue("good", NewUser(), nil))
+	detail := fp.JsonDetail("bad", NewUser(), "fallback")
+	assert.Equal(t, "fallback", detail.Value)
+	assert.Equal(t, ValidationReason, detail.Reason)
+}
+
+func TestJsonValidatorWithoutSync(t *testing.T) {
+	fp := NewFeatureProbeForTest(map[string]interface{}{"toggle": "value"})
+	reported := 0
+	config := FPConfig{ErrorListener: func(error) { reported++ }}
+	WithJsonValidator("toggle", func(data []byte) error {
+		return errors.New("rejected")
+	})(&config)
+	fp.validators = newJsonValidators(config)
+
+	assert.Equal(t, "default", fp.StrValue("toggle", NewUser(), "default"))
+	assert.Equal(t, "default", fp.StrValue("toggle", NewUser(), "default"))
+	assert.Equal(t, 1, reported)
+}
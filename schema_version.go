@@ -0,0 +1,78 @@
+package featureprobe
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// MaxSchemaVersion is the newest toggles payload schema this SDK understands.
+// It is sent on every toggles fetch, so the server can answer with a schema
+// old SDKs still read.
+const MaxSchemaVersion = 2
+
+const (
+	schemaVersionHeader  = "X-FeatureProbe-Schema-Version"
+	maxPrerequisiteDepth = 20
+	prerequisiteReason   = "prerequisite not match"
+	defaultSchemaVersion = 1
+)
+
+// Prerequisite requires toggle Key to serve Value for the user before the
+// toggle declaring it evaluates its rules. Otherwise its disabled serve is
+// returned.
+type Prerequisite struct {
+	Key   string      `json:"key"`
+	Value interface{} `json:"value"`
+}
+
+// UnsupportedSchemaError is returned when the server answers with a schema
+// newer than MaxSchemaVersion. The SDK keeps its current toggles until it is
+// upgraded.
+type UnsupportedSchemaError struct {
+	Version int
+}
+
+func (e *UnsupportedSchemaError) Error() string {
+	return fmt.Sprintf("toggles schema version %d is not supported, this SDK reads up to version %d, upgrade it", e.Version, MaxSchemaVersion)
+}
+
+func checkSchemaVersion(repo *Repository) error {
+	if repo.SchemaVersion > MaxSchemaVersion || repo.SchemaVersion < 0 {
+		return &UnsupportedSchemaError{Version: repo.SchemaVersion}
+	}
+	return nil
+}
+
+func (t *Toggle) prerequisitesMet(params evalParams) (bool, error) {
+	if params.depth >= maxPrerequisiteDepth {
+		return false, fmt.Errorf("prerequisites of %s deeper than %d", t.Key, maxPrerequisiteDepth)
+	}
+	for _, p := range t.Prerequisites {
+		var toggle Toggle
+		ok := false
+		if params.Repo != nil {
+			toggle, ok = params.Repo.Toggles[p.Key]
+		}
+		if !ok {
+			return false, fmt.Errorf("prerequisite %w", toggleError(p.Key, ErrToggleNotFound))
+		}
+		result, err := toggle.detail(evalParams{
+			Key:           toggle.Key,
+			User:          params.User,
+			Variations:    toggle.Variations,
+			Repo:          params.Repo,
+			Clock:         params.Clock,
+			segmentMemo:   params.segmentMemo,
+			bigSegments:   params.bigSegments,
+			compositeKeys: params.compositeKeys,
+			depth:         params.depth + 1,
+		})
+		if err != nil {
+			return false, err
+		}
+		if !reflect.DeepEqual(result.value, p.Value) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
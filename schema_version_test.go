@@ -0,0 +1,108 @@
+package featureprobe
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSyncSendsSchemaVersion(t *testing.T) {
+	repo := NewRepositoryStore(&Repository{})
+	synchronizer := NewSynchronizer("https://featureprobe.com/api/toggles", 100, "sdk_key", repo)
+
+	httpmock.ActivateNonDefault(&synchronizer.httpClient)
+	defer httpmock.DeactivateAndReset()
+	var header http.Header
+	httpmock.RegisterResponder("GET", "https://featureprobe.com/api/toggles",
+		func(req *http.Request) (*http.Response, error) {
+			header = req.Header
+			return httpmock.NewStringResponse(200, `{"toggles": {}}`), nil
+		})
+	synchronizer.fetchRemoteRepo()
+	assert.Equal(t, "2", header.Get(schemaVersionHeader))
+	assert.Equal(t, "application/json", header.Get("Accept"))
+}
+
+func TestUnsupportedSchemaVersion(t *testing.T) {
+	_, err := ParseRepository([]byte(`{"schemaVersion": 3, "toggles": {}}`))
+	assert.EqualError(t, err, "toggles schema version 3 is not supported, this SDK reads up to version 2, upgrade it")
+
+	repo, err := ParseRepository([]byte(`{"toggles": {}}`))
+	assert.Nil(t, err)
+	assert.Equal(t, 0, repo.SchemaVersion)
+}
+
+func TestSyncKeepsRepositoryOnUnsupportedSchema(t *testing.T) {
+	previous := &Repository{Toggles: map[string]Toggle{"t": {Key: "t"}}}
+	repo := NewRepositoryStore(previous)
+	synchronizer := NewSynchronizer("https://featureprobe.com/api/toggles", 100, "sdk_key", repo)
+
+	httpmock.ActivateNonDefault(&synchronizer.httpClient)
+	defer httpmock.DeactivateAndReset()
+	httpmock.RegisterResponder("GET", "https://featureprobe.com/api/toggles",
+		httpmock.NewStringResponder(200, `{"schemaVersion": 3, "toggles": {}}`))
+	synchronizer.fetchRemoteRepo()
+	assert.True(t, previous == repo.Load())
+}
+
+const prerequisitesJson = `{
+	"schemaVersion": 2,
+	"toggles": {
+		"parent": {
+			"key": "parent", "enabled": true, "version": 1,
+			"disabledServe": {"select": 0}, "defaultServe": {"select": 1},
+			"rules": [{"serve": {"select": 0}, "conditions": [{"type": "string", "subject": "city", "predicate": "is one of", "objects": ["1"]}]}],
+			"variations": [false, true]
+		},
+		"child": {
+			"key": "child", "enabled": true, "version": 1,
+			"disabledServe": {"select": 0}, "defaultServe": {"select": 1},
+			"rules": [],
+			"variations": ["off", "on"],
+			"prerequisites": [{"key": "parent", "value": true}],
+			"metadata": {"owner": "team"}
+		},
+		"orphan": {
+			"key": "orphan", "enabled": true, "version": 1,
+			"disabledServe": {"select": 0}, "defaultServe": {"select": 1},
+			"rules": [],
+			"variations": ["off", "on"],
+			"prerequisites": [{"key": "missing", "value": true}]
+		},
+		"loop": {
+			"key": "loop", "enabled": true, "version": 1,
+			"disabledServe": {"select": 0}, "defaultServe": {"select": 1},
+			"rules": [],
+			"variations": [false, true],
+			"prerequisites": [{"key": "loop", "value": true}]
+		}
+	}
+}`
+
+func TestPrerequisites(t *testing.T) {
+	repo, err := ParseRepository([]byte(prerequisitesJson))
+	assert.Nil(t, err)
+	fp := FeatureProbe{Repo: NewRepositoryStore(repo)}
+
+	detail := fp.StrDetail("child", NewUser().With("city", "2"), "d")
+	assert.Equal(t, "on", detail.Value)
+	assert.Equal(t, "default", detail.Reason)
+
+	detail = fp.StrDetail("child", NewUser().With("city", "1"), "d")
+	assert.Equal(t, "off", detail.Value)
+	assert.Equal(t, "prerequisite not match", detail.Reason)
+
+	detail = fp.StrDetail("orphan", NewUser(), "d")
+	assert.Equal(t, "d", detail.Value)
+	assert.Equal(t, "prerequisite toggle missing: toggle not found", detail.Reason)
+	assert.True(t, errors.Is(detail.Err, ErrToggleNotFound))
+
+	loop := fp.BoolDetail("loop", NewUser(), false)
+	assert.False(t, loop.Value)
+	assert.Equal(t, "prerequisites of loop deeper than 20", loop.Reason)
+
+	assert.Equal(t, "team", repo.Toggles["child"].Metadata["owner"])
+}
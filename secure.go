@@ -0,0 +1,16 @@
+package featureprobe
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// SecureModeHash signs the key of user with the server SDK key. Backends hand
+// the hash to browser and mobile SDKs along with the user, so the platform
+// can verify the identity was not forged on the client.
+func (fp *FeatureProbe) SecureModeHash(user FPUser) string {
+	mac := hmac.New(sha256.New, []byte(fp.Config.ServerSdkKey))
+	mac.Write([]byte(user.Key()))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,16 @@
+package featureprobe
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSecureModeHash(t *testing.T) {
+	fp := FeatureProbe{Config: FPConfig{ServerSdkKey: "key"}}
+	user := NewUser().StableRollout("The quick brown fox jumps over the lazy dog")
+
+	// HMAC-SHA256 test vector
+	assert.Equal(t, "f7bc83f430538424b13298e6aa6fb143ef4d59a14946175997479dbc2d1a3cd8", fp.SecureModeHash(user))
+	assert.NotEqual(t, fp.SecureModeHash(user), fp.SecureModeHash(NewUser().StableRollout("other")))
+}
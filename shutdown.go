@@ -0,0 +1,54 @@
+package featureprobe
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// HandleShutdownSignals flushes the events of fp, waiting up to timeout, and
+// closes it when the process receives SIGTERM or SIGINT, so the last interval
+// of events is not lost on every deploy. The signal is then raised again for
+// the application's own handling, or the default exit. stop uninstalls the
+// handler, for applications closing fp themselves.
+func HandleShutdownSignals(fp *FeatureProbe, timeout time.Duration) (stop func()) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, os.Interrupt)
+	return handleShutdown(fp, timeout, signals, func() { signal.Stop(signals) }, raise)
+}
+
+func handleShutdown(fp *FeatureProbe, timeout time.Duration, signals <-chan os.Signal, release func(), raise func(os.Signal)) func() {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		select {
+		case sig := <-signals:
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			if err := fp.FlushAtEnd(ctx); err != nil && err != ErrClientClosed {
+				fp.Config.reportError(fmt.Errorf("flush on %s fails: %w", sig, err))
+			}
+			cancel()
+			fp.Close()
+			release()
+			raise(sig)
+		case <-done:
+			release()
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(done) })
+		<-stopped
+	}
+}
+
+func raise(sig os.Signal) {
+	if p, err := os.FindProcess(os.Getpid()); err == nil {
+		_ = p.Signal(sig)
+	}
+}
@@ -0,0 +1,47 @@
+package featureprobe
+
+import (
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShutdownFlushesAndCloses(t *testing.T) {
+	server := NewMockServer(Repository{Toggles: map[string]Toggle{"toggle": newToggleForTest("toggle", true)}})
+	defer server.Close()
+	fp, err := NewFeatureProbe(server.URL(), "sdk_key", WithServerless(true))
+	assert.Nil(t, err)
+	fp.BoolValue("toggle", NewUser(), false)
+
+	signals := make(chan os.Signal, 1)
+	released := false
+	raised := make(chan os.Signal, 1)
+	stop := handleShutdown(&fp, time.Second, signals, func() { released = true }, func(sig os.Signal) { raised <- sig })
+
+	signals <- syscall.SIGTERM
+	assert.Equal(t, syscall.SIGTERM, <-raised)
+	stop()
+	assert.True(t, released)
+	assert.True(t, fp.Closed())
+	assert.Len(t, server.Events(), 1)
+}
+
+func TestShutdownStop(t *testing.T) {
+	fp := NewFeatureProbeForTest(nil)
+	signals := make(chan os.Signal, 1)
+	released := false
+	stop := handleShutdown(&fp, time.Second, signals, func() { released = true }, func(os.Signal) { t.Fail() })
+	stop()
+	stop()
+	assert.True(t, released)
+	assert.False(t, fp.Closed())
+}
+
+func TestHandleShutdownSignalsStop(t *testing.T) {
+	fp := NewFeatureProbeForTest(nil)
+	HandleShutdownSignals(&fp, time.Second)()
+	assert.False(t, fp.Closed())
+}
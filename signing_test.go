@@ -0,0 +1,50 @@
+package featureprobe
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestSigning(t *testing.T) {
+	clock := NewManualClock(time.Unix(1000, 0))
+	signer := newRequestSigner(FPConfig{RequestSigning: true, ServerSdkKey: "sdk_key", Clock: clock})
+
+	var mu sync.Mutex
+	verified := map[string]bool{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		timestamp := r.Header.Get(timestampHeader)
+		expected := signer.signature(timestamp, r.Method, r.URL.RequestURI(), body)
+		mu.Lock()
+		verified[r.URL.Path] = timestamp == "1000000" && r.Header.Get(signatureHeader) == expected
+		mu.Unlock()
+		_, _ = w.Write([]byte(`{"toggles": {}}`))
+	}))
+	defer server.Close()
+
+	fp, err := NewFeatureProbe(server.URL, "sdk_key", WithServerless(true), WithClock(clock), WithRequestSigning(""))
+	assert.Nil(t, err)
+	fp.Syncer.fetchRemoteRepo()
+	fp.Recorder.RecordAccess(AccessEvent{Time: 1, Key: "toggle", Value: true})
+	assert.Nil(t, fp.FlushAtEnd(context.Background()))
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.True(t, verified["/api/server-sdk/toggles"])
+	assert.True(t, verified["/api/events"])
+}
+
+func TestSignatureDependsOnSecret(t *testing.T) {
+	a := newRequestSigner(FPConfig{RequestSigning: true, ServerSdkKey: "sdk_key"})
+	b := newRequestSigner(FPConfig{RequestSigning: true, ServerSdkKey: "sdk_key", SigningSecret: "secret"})
+	assert.NotEqual(t, a.signature("1", "GET", "/", nil), b.signature("1", "GET", "/", nil))
+	assert.NotEqual(t, a.signature("1", "GET", "/", nil), a.signature("2", "GET", "/", nil))
+	assert.Nil(t, newRequestSigner(FPConfig{ServerSdkKey: "sdk_key"}))
+}
@@ -0,0 +1,38 @@
+package featureprobe
+
+// Simulation is the evaluation of a toggle for a list of users, with
+// Details[i] the evaluation for the i-th user, and the variations served to
+// them all. Errors counts the users whose evaluation failed.
+type Simulation struct {
+	Toggle    string           `json:"toggle"`
+	Details   []EvalDetail     `json:"details"`
+	Breakdown []VariationCount `json:"breakdown"`
+	Errors    int              `json:"errors"`
+}
+
+// Simulate evaluates toggle for every user against the current repository
+// without recording events, for audience analysis before a launch.
+func (fp *FeatureProbe) Simulate(toggle string, users []FPUser) (Simulation, error) {
+	repo := fp.Repo.Load()
+	t, ok := repo.GetToggle(toggle)
+	if !ok {
+		return Simulation{}, lookupError(repo, toggle)
+	}
+	counts := newVariationCounts(&t)
+	simulation := Simulation{Toggle: toggle, Details: make([]EvalDetail, len(users))}
+	for i, user := range users {
+		user = user.withGlobal(fp.Config.GlobalAttributes)
+		result, err := t.detail(evalParams{User: user, Repo: repo, Variations: t.Variations, Key: t.Key, Clock: fp.Config.Clock, compositeKeys: fp.Config.CompositeBucketBy})
+		if err != nil {
+			result.value, result.err = nil, toggleError(t.Key, err)
+		} else {
+			result.source = fp.repoSource(repo)
+		}
+		if err != nil || !counts.add(result.variationIndex) {
+			simulation.Errors++
+		}
+		simulation.Details[i] = result.evalDetail()
+	}
+	simulation.Breakdown = counts.shares(len(users))
+	return simulation, nil
+}
@@ -0,0 +1,37 @@
+package featureprobe
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSimulate(t *testing.T) {
+	repo, _ := setup(t)
+	fp := FeatureProbe{Repo: NewRepositoryStore(&repo)}
+	users := []FPUser{
+		NewUser().StableRollout("key11").With("city", "4"),
+		NewUser().StableRollout("key12").With("city", "1"),
+		NewUser().StableRollout("key13").With("city", "1"),
+	}
+
+	simulation, err := fp.Simulate("bool_toggle", users)
+	assert.Nil(t, err)
+	assert.Len(t, simulation.Details, 3)
+	total := 0
+	for i, user := range users {
+		assert.Equal(t, fp.BoolDetail("bool_toggle", user, false).Value, simulation.Details[i].Value)
+	}
+	for _, v := range simulation.Breakdown {
+		total += v.Count
+	}
+	assert.Equal(t, 3-simulation.Errors, total)
+
+	empty, err := fp.Simulate("bool_toggle", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, 0.0, empty.Breakdown[0].Share)
+
+	_, err = fp.Simulate("not_exist", users)
+	assert.True(t, errors.Is(err, ErrToggleNotFound))
+}
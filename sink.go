@@ -0,0 +1,203 @@
+package featureprobe
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ExposureEventName is the name exposures are tracked under, Segment's
+// convention for experiment exposures.
+const ExposureEventName = "Experiment Viewed"
+
+// maxSinkEvents bounds the events buffered for sinks between flushes; newer
+// events are dropped beyond it.
+const maxSinkEvents = 10000
+
+// SinkEvent is an exposure of a user to a toggle value, or a custom event
+// from Track.
+type SinkEvent struct {
+	Event string
+	// UserKey is empty for users without a stable rollout key.
+	UserKey    string
+	Time       int64
+	Properties map[string]interface{}
+}
+
+// EventSink receives the events of every flush, to forward them to an
+// analytics platform.
+type EventSink interface {
+	Send(ctx context.Context, events []SinkEvent) error
+}
+
+// WithEventSink forwards exposures and Track events to sink when events are
+// flushed. Exposures carry the user, so experiments can be joined with
+// product analytics without exporting them from FeatureProbe.
+func WithEventSink(sink EventSink) Option {
+	return func(fpConfig *FPConfig) {
+		fpConfig.EventSinks = append(fpConfig.EventSinks, sink)
+	}
+}
+
+type sinkForwarder struct {
+	sinks  []EventSink
+	mu     sync.Mutex
+	events []SinkEvent
+}
+
+func newSinkForwarder(sinks []EventSink) *sinkForwarder {
+	if len(sinks) == 0 {
+		return nil
+	}
+	return &sinkForwarder{sinks: sinks}
+}
+
+func (f *sinkForwarder) add(event SinkEvent) {
+	if f == nil {
+		return
+	}
+	f.mu.Lock()
+	if len(f.events) < maxSinkEvents {
+		f.events = append(f.events, event)
+	}
+	f.mu.Unlock()
+}
+
+// flush sends the buffered events to every sink, returning the first error.
+// Events are not retried, a sink failing does not hold back the others.
+func (f *sinkForwarder) flush(ctx context.Context) error {
+	if f == nil {
+		return nil
+	}
+	f.mu.Lock()
+	events := f.events
+	f.events = nil
+	f.mu.Unlock()
+	if len(events) == 0 {
+		return nil
+	}
+	var first error
+	for _, sink := range f.sinks {
+		if err := sink.Send(ctx, events); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+func (fp *FeatureProbe) expose(toggle string, user FPUser, result evalResult) {
+	fp.sinks.add(SinkEvent{
+		Event:   ExposureEventName,
+		UserKey: user.key,
+		Time:    fp.eventTime(),
+		Properties: map[string]interface{}{
+			"toggle":         toggle,
+			"value":          result.value,
+			"variationIndex": result.eventIndex(),
+			"version":        result.eventVersion(),
+			"reason":         result.reason,
+		},
+	})
+}
+
+// Track forwards a custom event of user to the sinks of WithEventSink. It
+// does nothing without sinks.
+func (fp *FeatureProbe) Track(user FPUser, event string, properties map[string]interface{}) {
+	if fp.sinks == nil {
+		return
+	}
+	fp.sinks.add(SinkEvent{Event: event, UserKey: user.key, Time: fp.eventTime(), Properties: properties})
+}
+
+// TrackSink sends events in the track format of Segment's batch API, which
+// many analytics platforms and webhooks accept.
+type TrackSink struct {
+	Url string
+	// WriteKey is sent as basic auth user, as Segment expects, if set.
+	WriteKey   string
+	HttpClient *http.Client
+	// AnonymousId identifies users without a stable rollout key.
+	AnonymousId string
+}
+
+const segmentBatchUrl = "https://api.segment.io/v1/batch"
+
+func NewSegmentSink(writeKey string) *TrackSink {
+	return &TrackSink{Url: segmentBatchUrl, WriteKey: writeKey, AnonymousId: "featureprobe"}
+}
+
+func NewWebhookSink(url string) *TrackSink {
+	return &TrackSink{Url: url, AnonymousId: "featureprobe"}
+}
+
+type trackEvent struct {
+	Type        string                 `json:"type"`
+	Event       string                 `json:"event"`
+	UserId      string                 `json:"userId,omitempty"`
+	AnonymousId string                 `json:"anonymousId,omitempty"`
+	Timestamp   string                 `json:"timestamp"`
+	Properties  map[string]interface{} `json:"properties,omitempty"`
+}
+
+func (s *TrackSink) Send(ctx context.Context, events []SinkEvent) error {
+	batch := make([]trackEvent, len(events))
+	for i, e := range events {
+		batch[i] = trackEvent{
+			Type:       "track",
+			Event:      e.Event,
+			UserId:     e.UserKey,
+			Timestamp:  time.Unix(0, e.Time*int64(time.Millisecond)).UTC().Format(time.RFC3339Nano),
+			Properties: e.Properties,
+		}
+		if e.UserKey == "" {
+			batch[i].AnonymousId = s.AnonymousId
+		}
+	}
+	body, err := json.Marshal(map[string]interface{}{"batch": batch})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.Url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", USER_AGENT)
+	if s.WriteKey != "" {
+		req.SetBasicAuth(s.WriteKey, "")
+	}
+	client := s.HttpClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("send events to %s fails: %s", s.Url, resp.Status)
+	}
+	return nil
+}
+
+func init() {
+	RegisterPlugin(PluginEventSink, "segment", func(params map[string]interface{}) (Option, error) {
+		writeKey, _ := params["writeKey"].(string)
+		if writeKey == "" {
+			return nil, fmt.Errorf("writeKey param required")
+		}
+		return WithEventSink(NewSegmentSink(writeKey)), nil
+	})
+	RegisterPlugin(PluginEventSink, "webhook", func(params map[string]interface{}) (Option, error) {
+		url, _ := params["url"].(string)
+		if url == "" {
+			return nil, fmt.Errorf("url param required")
+		}
+		return WithEventSink(NewWebhookSink(url)), nil
+	})
+}
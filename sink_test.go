@@ -0,0 +1,90 @@
+package featureprobe
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type trackServer struct {
+	*httptest.Server
+	mu      sync.Mutex
+	batches [][]trackEvent
+	auth    string
+	status  int
+}
+
+func newTrackServer() *trackServer {
+	s := &trackServer{status: http.StatusOK}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		var payload struct {
+			Batch []trackEvent `json:"batch"`
+		}
+		_ = json.Unmarshal(body, &payload)
+		s.mu.Lock()
+		s.batches = append(s.batches, payload.Batch)
+		s.auth, _, _ = r.BasicAuth()
+		w.WriteHeader(s.status)
+		s.mu.Unlock()
+	}))
+	return s
+}
+
+func TestSegmentSink(t *testing.T) {
+	track := newTrackServer()
+	defer track.Close()
+	server := NewMockServer(Repository{Toggles: map[string]Toggle{"toggle": newToggleForTest("toggle", true)}})
+	defer server.Close()
+	sink := NewSegmentSink("write_key")
+	sink.Url = track.URL
+	fp, err := NewFeatureProbe(server.URL(), "sdk_key", WithServerless(true), WithEventSink(sink))
+	assert.Nil(t, err)
+
+	fp.BoolValue("toggle", NewUser().StableRollout("user_1"), false)
+	fp.BoolValue("missing", NewUser(), false)
+	fp.Track(NewUser(), "Checkout", map[string]interface{}{"amount": 10.0})
+	assert.Nil(t, fp.FlushAtEnd(context.Background()))
+
+	track.mu.Lock()
+	defer track.mu.Unlock()
+	assert.Equal(t, "write_key", track.auth)
+	assert.Len(t, track.batches, 1)
+	batch := track.batches[0]
+	assert.Len(t, batch, 2)
+	assert.Equal(t, "track", batch[0].Type)
+	assert.Equal(t, ExposureEventName, batch[0].Event)
+	assert.Equal(t, "user_1", batch[0].UserId)
+	assert.Equal(t, "toggle", batch[0].Properties["toggle"])
+	assert.Equal(t, true, batch[0].Properties["value"])
+	assert.Equal(t, "Checkout", batch[1].Event)
+	assert.Equal(t, "featureprobe", batch[1].AnonymousId)
+	assert.Equal(t, 10.0, batch[1].Properties["amount"])
+	assert.Len(t, server.Events(), 1)
+}
+
+func TestFailingSinkKeepsReportingEvents(t *testing.T) {
+	track := newTrackServer()
+	track.status = http.StatusInternalServerError
+	defer track.Close()
+	server := NewMockServer(Repository{Toggles: map[string]Toggle{"toggle": newToggleForTest("toggle", true)}})
+	defer server.Close()
+	fp, _ := NewFeatureProbe(server.URL(), "sdk_key", WithServerless(true), WithEventSink(NewWebhookSink(track.URL)))
+
+	fp.BoolValue("toggle", NewUser(), false)
+	assert.Nil(t, fp.FlushAtEnd(context.Background()))
+	assert.Len(t, server.Events(), 1)
+	assert.NotNil(t, NewWebhookSink(track.URL).Send(context.Background(), []SinkEvent{{Event: "e"}}))
+}
+
+func TestTrackWithoutSinks(t *testing.T) {
+	fp := NewFeatureProbeForTest(nil)
+	fp.Track(NewUser(), "event", nil)
+	assert.Nil(t, fp.sinks)
+}
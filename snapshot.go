@@ -0,0 +1,66 @@
+package featureprobe
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// snapshotFormat is the version of the snapshot encoding, raised whenever a
+// change would make older SDKs misread it.
+const snapshotFormat = 1
+
+type snapshot struct {
+	Format     int         `json:"format"`
+	SdkVersion string      `json:"sdkVersion"`
+	ExportedAt int64       `json:"exportedAt"`
+	Repository *Repository `json:"repository"`
+}
+
+// ExportSnapshot encodes the repository the client evaluates, to capture
+// production toggles for staging or to pre-seed new instances with
+// ImportSnapshot.
+func (fp *FeatureProbe) ExportSnapshot() ([]byte, error) {
+	return encodeSnapshot(fp.Repo.Load(), clockOrSystem(fp.Config.Clock).Now())
+}
+
+func encodeSnapshot(repo *Repository, now time.Time) ([]byte, error) {
+	if repo == nil {
+		repo = &Repository{}
+	}
+	return json.Marshal(snapshot{
+		Format:     snapshotFormat,
+		SdkVersion: VERSION,
+		ExportedAt: unixMillis(now),
+		Repository: repo,
+	})
+}
+
+// ImportSnapshot replaces the repository with one exported by ExportSnapshot,
+// refusing snapshots of another format. A running synchronizer replaces it
+// again on its next successful sync.
+func (fp *FeatureProbe) ImportSnapshot(data []byte) error {
+	if fp.Repo == nil {
+		return ErrNotInitialized
+	}
+	repo, err := decodeSnapshot(data, fp.Repo.Load())
+	if err != nil {
+		return err
+	}
+	fp.Repo.Store(repo)
+	return nil
+}
+
+func decodeSnapshot(data []byte, previous *Repository) (*Repository, error) {
+	var s struct {
+		Format     int             `json:"format"`
+		Repository json.RawMessage `json:"repository"`
+	}
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, err
+	}
+	if s.Format != snapshotFormat {
+		return nil, fmt.Errorf("unsupported snapshot format %d, expected %d", s.Format, snapshotFormat)
+	}
+	return buildRepository(s.Repository, previous)
+}
@@ -0,0 +1,30 @@
+package featureprobe
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	repo, _ := setup(t)
+	source := FeatureProbe{Repo: NewRepositoryStore(&repo)}
+	data, err := source.ExportSnapshot()
+	assert.Nil(t, err)
+
+	target := NewFeatureProbeForTest(map[string]interface{}{})
+	assert.Nil(t, target.ImportSnapshot(data))
+
+	user := NewUser().StableRollout("key11").With("city", "4")
+	assert.Equal(t, source.BoolDetail("bool_toggle", user, false), target.BoolDetail("bool_toggle", user, false))
+	assert.Equal(t, source.JsonValue("json_toggle", user, nil), target.JsonValue("json_toggle", user, nil))
+}
+
+func TestImportSnapshotChecksFormat(t *testing.T) {
+	fp := NewFeatureProbeForTest(map[string]interface{}{"toggle": true})
+
+	err := fp.ImportSnapshot([]byte(`{"format": 2, "repository": {"toggles": {}}}`))
+	assert.Equal(t, "unsupported snapshot format 2, expected 1", err.Error())
+	assert.NotNil(t, fp.ImportSnapshot([]byte(`not json`)))
+	assert.True(t, fp.BoolValue("toggle", NewUser(), false))
+}
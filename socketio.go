@@ -0,0 +1,152 @@
+package featureprobe
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+)
+
+// engine.io and socket.io packets, as sent over the polling transport.
+const (
+	engineOpen    = "0"
+	engineClose   = "1"
+	enginePing    = "2"
+	enginePong    = "3"
+	engineMessage = "4"
+	socketConnect = "0"
+	socketEvent   = "2"
+	socketError   = "4"
+	// enginePacketSeparator separates the packets of one polling payload.
+	enginePacketSeparator = "\x1e"
+)
+
+// WithRealtimeMode registers the server SDK key on the socket.io channel of
+// the FeatureProbe server at RealtimeUrl, as the Java and Rust SDKs do, and
+// syncs on every "update" it announces. Like WithStreamingMode, polling only
+// resumes while the channel is down. Socket.io is spoken over its HTTP long
+// polling transport.
+func WithRealtimeMode(enabled bool) Option {
+	return func(fpConfig *FPConfig) {
+		fpConfig.RealtimeMode = enabled
+	}
+}
+
+func newSocketIOStreamer(url string) *streamer {
+	st := newStreamer(url)
+	st.listen = (*streamer).listenSocketIO
+	return st
+}
+
+type engineSession struct {
+	st  *streamer
+	s   *Synchronizer
+	url string
+}
+
+func (st *streamer) listenSocketIO(s *Synchronizer) (bool, error) {
+	key, err := s.auth.Authorization()
+	if err != nil {
+		return false, fmt.Errorf("get authorization fails: %w", err)
+	}
+	defer atomic.StoreInt32(&st.connected, 0)
+	session := &engineSession{st: st, s: s}
+	packets, err := session.poll()
+	if err != nil {
+		return false, err
+	}
+	if len(packets) == 0 || !strings.HasPrefix(packets[0], engineOpen) {
+		return false, fmt.Errorf("socket.io handshake fails: %q", packets)
+	}
+	var open struct {
+		Sid string `json:"sid"`
+	}
+	if err := json.Unmarshal([]byte(packets[0][1:]), &open); err != nil {
+		return false, err
+	}
+	session.url += "&sid=" + url.QueryEscape(open.Sid)
+	if err := session.send(engineMessage + socketConnect); err != nil {
+		return false, err
+	}
+
+	registered := false
+	for {
+		packets, err := session.poll()
+		if err != nil {
+			return registered, err
+		}
+		for _, packet := range packets {
+			switch {
+			case packet == enginePing:
+				if err := session.send(enginePong); err != nil {
+					return registered, err
+				}
+			case packet == engineClose:
+				return registered, errors.New("socket.io closed by server")
+			case strings.HasPrefix(packet, engineMessage+socketConnect) && !registered:
+				register, _ := json.Marshal([]interface{}{"register", map[string]string{"key": key}})
+				if err := session.send(engineMessage + socketEvent + string(register)); err != nil {
+					return registered, err
+				}
+				registered = true
+				atomic.StoreInt32(&st.connected, 1)
+				// changes made while disconnected were not announced
+				st.notify()
+			case strings.HasPrefix(packet, engineMessage+socketError):
+				return registered, fmt.Errorf("socket.io connect fails: %s", packet[2:])
+			case strings.HasPrefix(packet, engineMessage+socketEvent+`["update"`):
+				st.notify()
+			}
+		}
+	}
+}
+
+// poll returns the packets of one long polling request, opening the session
+// on the first one.
+func (e *engineSession) poll() ([]string, error) {
+	if e.url == "" {
+		e.url = strings.TrimSuffix(e.st.url, "/") + "/?EIO=4&transport=polling"
+	}
+	req, err := http.NewRequestWithContext(e.s.context(), http.MethodGet, e.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("User-Agent", USER_AGENT)
+	resp, err := e.st.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("socket.io poll fails: %s", resp.Status)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	return strings.Split(string(body), enginePacketSeparator), nil
+}
+
+func (e *engineSession) send(packet string) error {
+	req, err := http.NewRequestWithContext(e.s.context(), http.MethodPost, e.url, bytes.NewReader([]byte(packet)))
+	if err != nil {
+		return err
+	}
+	req.Header.Add("User-Agent", USER_AGENT)
+	req.Header.Set("Content-Type", "text/plain;charset=UTF-8")
+	resp, err := e.st.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	_, _ = ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("socket.io send fails: %s", resp.Status)
+	}
+	return nil
+}
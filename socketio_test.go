@@ -0,0 +1,99 @@
+package featureprobe
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeSocketIO serves the engine.io polling transport, answering polls with
+// the packets pushed to outgoing.
+type fakeSocketIO struct {
+	*httptest.Server
+	outgoing chan string
+	mu       sync.Mutex
+	received []string
+}
+
+func newFakeSocketIO() *fakeSocketIO {
+	f := &fakeSocketIO{outgoing: make(chan string, 10)}
+	f.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/realtime/" || r.URL.Query().Get("transport") != "polling" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.URL.Query().Get("sid") == "" {
+			_, _ = w.Write([]byte(`0{"sid":"session","pingInterval":25000,"pingTimeout":20000}`))
+			return
+		}
+		if r.Method == http.MethodPost {
+			body, _ := ioutil.ReadAll(r.Body)
+			f.mu.Lock()
+			f.received = append(f.received, string(body))
+			f.mu.Unlock()
+			if string(body) == "40" {
+				f.outgoing <- `40{"sid":"namespace"}`
+			}
+			_, _ = w.Write([]byte("ok"))
+			return
+		}
+		select {
+		case packet := <-f.outgoing:
+			_, _ = w.Write([]byte(packet))
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+			_, _ = w.Write([]byte("6"))
+		}
+	}))
+	return f
+}
+
+func (f *fakeSocketIO) packets() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.received...)
+}
+
+func TestRealtimeMode(t *testing.T) {
+	server := NewMockServer(Repository{Toggles: map[string]Toggle{"toggle": newToggleForTest("toggle", "v1")}})
+	defer server.Close()
+	realtime := newFakeSocketIO()
+	defer realtime.Close()
+
+	fp, err := NewFeatureProbe(server.URL(), "sdk_key", WithRealtimeMode(true), withRealtimeUrl(realtime.URL+"/realtime"),
+		WithRefreshInterval(60000), WithWaitFirstResp(false))
+	assert.Nil(t, err)
+	defer fp.Close()
+
+	waitForValue(fp, "toggle", "v1")
+	assert.Equal(t, "v1", fp.StrValue("toggle", NewUser(), "default"))
+	assert.True(t, fp.Syncer.stream.isConnected())
+	assert.Equal(t, []string{"40", `42["register",{"key":"sdk_key"}]`}, realtime.packets())
+
+	updated := newToggleForTest("toggle", "v2")
+	updated.Version = 2
+	server.SetRepository(Repository{Toggles: map[string]Toggle{"toggle": updated}})
+	realtime.outgoing <- "2\x1e" + `42["update",{}]`
+	waitForValue(fp, "toggle", "v2")
+	assert.Equal(t, "v2", fp.StrValue("toggle", NewUser(), "default"))
+	assert.Equal(t, "3", realtime.packets()[2])
+}
+
+func TestRealtimeModeConnectError(t *testing.T) {
+	realtime := newFakeSocketIO()
+	defer realtime.Close()
+	s := NewSynchronizer("", 1000, "sdk_key", NewRepositoryStore(&Repository{}))
+	st := newSocketIOStreamer(realtime.URL + "/realtime")
+	realtime.outgoing <- `44{"message":"invalid key"}`
+
+	connected, err := st.listen(st, &s)
+	assert.False(t, connected)
+	assert.True(t, strings.Contains(err.Error(), "invalid key"))
+	assert.False(t, st.isConnected())
+}
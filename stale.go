@@ -0,0 +1,45 @@
+package featureprobe
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+const staleReason = "stale"
+
+// WithMaxDataAge makes evaluations serve the default, with reason "stale",
+// once toggles were last synced longer than age ago, for teams who would
+// rather be off than wrongly on while sync is broken for hours.
+func WithMaxDataAge(age time.Duration) Option {
+	return func(fpConfig *FPConfig) {
+		fpConfig.MaxDataAge = age
+	}
+}
+
+// stale reports whether the repository is older than the max data age. A
+// client that never synced has no data to distrust.
+func (fp *FeatureProbe) stale() bool {
+	if fp.Config.MaxDataAge <= 0 || fp.Syncer == nil {
+		return false
+	}
+	synced := atomic.LoadInt64(&fp.Syncer.lastSynced)
+	if synced == 0 {
+		return false
+	}
+	now := unixMillis(clockOrSystem(fp.Config.Clock).Now())
+	return time.Duration(now-synced)*time.Millisecond > fp.Config.MaxDataAge
+}
+
+// syncFailing reports whether syncs failed for the last two refresh
+// intervals, so evaluations are served from stale data.
+func (fp *FeatureProbe) syncFailing() bool {
+	if fp.Syncer == nil || fp.Config.Serverless {
+		return false
+	}
+	interval := fp.refreshInterval()
+	synced := atomic.LoadInt64(&fp.Syncer.lastSynced)
+	if synced == 0 || interval <= 0 {
+		return false
+	}
+	return fp.eventTime()-synced > 2*interval
+}
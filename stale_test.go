@@ -0,0 +1,62 @@
+package featureprobe
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMaxDataAge(t *testing.T) {
+	server := NewMockServer(Repository{Toggles: map[string]Toggle{"toggle": newToggleForTest("toggle", true)}})
+	defer server.Close()
+	clock := NewManualClock(time.Unix(1000, 0))
+	fp, err := NewFeatureProbe(server.URL(), "sdk_key", WithServerless(true), WithClock(clock), WithMaxDataAge(time.Minute))
+	assert.Nil(t, err)
+
+	assert.True(t, fp.BoolValue("toggle", NewUser(), false))
+	clock.Advance(time.Minute)
+	assert.True(t, fp.BoolValue("toggle", NewUser(), false))
+
+	clock.Advance(time.Second)
+	detail := fp.BoolDetail("toggle", NewUser(), false)
+	assert.False(t, detail.Value)
+	assert.Equal(t, "stale", detail.Reason)
+
+	fp.Syncer.fetchRemoteRepo()
+	assert.True(t, fp.BoolValue("toggle", NewUser(), false))
+}
+
+func TestMaxDataAgeBeforeSync(t *testing.T) {
+	fp := NewFeatureProbeForTest(map[string]interface{}{"toggle": true})
+	fp.Config.MaxDataAge = time.Nanosecond
+	assert.True(t, fp.BoolValue("toggle", NewUser(), false))
+}
+
+func TestStaleWhileServerAnswersInvalidRepository(t *testing.T) {
+	server := NewMockServer(Repository{Toggles: map[string]Toggle{"toggle": newToggleForTest("toggle", true)}})
+	defer server.Close()
+	clock := NewManualClock(time.Unix(1000, 0))
+	fp, err := NewFeatureProbe(server.URL(), "sdk_key", WithClock(clock), WithWaitFirstResp(false), WithMaxDataAge(time.Minute))
+	assert.Nil(t, err)
+	defer fp.Close()
+	fp.Syncer.fetchRemoteRepo()
+	assert.Equal(t, SourceNetwork, fp.BoolDetail("toggle", NewUser(), false).Source)
+
+	invalid := newToggleForTest("toggle", false)
+	missing := 5
+	invalid.DefaultServe = Serve{Select: &missing}
+	invalid.Version = 1
+	server.SetRepository(Repository{Toggles: map[string]Toggle{"toggle": invalid}})
+	clock.Advance(5 * time.Second)
+	fp.Syncer.fetchRemoteRepo()
+	detail := fp.BoolDetail("toggle", NewUser(), false)
+	assert.True(t, detail.Value)
+	assert.Equal(t, SourceStale, detail.Source)
+
+	clock.Advance(time.Minute)
+	fp.Syncer.fetchRemoteRepo()
+	detail = fp.BoolDetail("toggle", NewUser(), false)
+	assert.False(t, detail.Value)
+	assert.Equal(t, "stale", detail.Reason)
+}
@@ -0,0 +1,143 @@
+package featureprobe
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	streamRetryDelay    = time.Second
+	streamMaxRetryDelay = 30 * time.Second
+)
+
+// WithStreamingMode listens to toggle changes on the Server-Sent Events
+// stream at RealtimeUrl and syncs as soon as one is announced, instead of
+// waiting for the next refresh. Polling only resumes while the stream is
+// down, until it reconnects.
+func WithStreamingMode(enabled bool) Option {
+	return func(fpConfig *FPConfig) {
+		fpConfig.StreamingMode = enabled
+	}
+}
+
+func WithRealtimeUri(uri string) Option {
+	return func(fpConfig *FPConfig) {
+		fpConfig.RealtimeUrl = fpConfig.RemoteUrl + uri
+	}
+}
+
+// streamer listens to a realtime channel announcing toggle changes, telling
+// the synchronizer loop to fetch through updates so fetches never overlap.
+type streamer struct {
+	connected  int32
+	url        string
+	httpClient http.Client
+	updates    chan struct{}
+	retryDelay time.Duration
+	// listen reads the channel until it drops, reporting whether it
+	// connected.
+	listen func(st *streamer, s *Synchronizer) (bool, error)
+}
+
+func newStreamer(url string) *streamer {
+	return &streamer{
+		url:        url,
+		httpClient: newHttpClient(0),
+		updates:    make(chan struct{}, 1),
+		retryDelay: streamRetryDelay,
+		listen:     (*streamer).listenEventStream,
+	}
+}
+
+func (st *streamer) isConnected() bool {
+	return st != nil && atomic.LoadInt32(&st.connected) == 1
+}
+
+// updated is nil without a stream, which never receives.
+func (st *streamer) updated() <-chan struct{} {
+	if st == nil {
+		return nil
+	}
+	return st.updates
+}
+
+func (st *streamer) notify() {
+	select {
+	case st.updates <- struct{}{}:
+	default:
+	}
+}
+
+// run reconnects with exponential backoff until s stops.
+func (st *streamer) run(s *Synchronizer) {
+	defer st.httpClient.CloseIdleConnections()
+	delay := st.retryDelay
+	for !s.stopped() {
+		connected, err := st.listen(st, s)
+		if s.stopped() {
+			return
+		}
+		s.log.errorf("realtime channel of toggle changes drops: %s", err)
+		if connected {
+			delay = st.retryDelay
+		}
+		select {
+		case <-s.stopChan:
+			return
+		case <-time.After(delay):
+		}
+		if delay *= 2; delay > streamMaxRetryDelay {
+			delay = streamMaxRetryDelay
+		}
+	}
+}
+
+func (st *streamer) listenEventStream(s *Synchronizer) (bool, error) {
+	resp, err := sendAuthorized(s.auth, func(authorization string) (*http.Response, error) {
+		req, err := http.NewRequestWithContext(s.context(), http.MethodGet, st.url, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Add("Authorization", authorization)
+		req.Header.Add("User-Agent", USER_AGENT)
+		req.Header.Set("Accept", "text/event-stream")
+		s.signer.sign(req, nil)
+		return st.httpClient.Do(req)
+	})
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("connect stream fails: %s", resp.Status)
+	}
+
+	atomic.StoreInt32(&st.connected, 1)
+	defer atomic.StoreInt32(&st.connected, 0)
+	// changes made while disconnected were not announced
+	st.notify()
+	reader := bufio.NewReader(resp.Body)
+	pending := false
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return true, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		switch {
+		case line == "":
+			if pending {
+				st.notify()
+				pending = false
+			}
+		case strings.HasPrefix(line, ":"):
+			// comments keep the connection alive
+		default:
+			pending = true
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package featureprobe
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newEventStream(events <-chan string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept") != "text/event-stream" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, ": connected\n\n")
+		w.(http.Flusher).Flush()
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case event := <-events:
+				fmt.Fprint(w, event)
+				w.(http.Flusher).Flush()
+			}
+		}
+	}))
+}
+
+func waitForValue(fp FeatureProbe, toggle string, value string) {
+	for i := 0; i < 100 && fp.StrValue(toggle, NewUser(), "default") != value; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func withRealtimeUrl(url string) Option {
+	return func(fpConfig *FPConfig) {
+		fpConfig.RealtimeUrl = url
+	}
+}
+
+func TestStreamingMode(t *testing.T) {
+	server := NewMockServer(Repository{Toggles: map[string]Toggle{"toggle": newToggleForTest("toggle", "v1")}})
+	defer server.Close()
+	events := make(chan string)
+	stream := newEventStream(events)
+	defer stream.Close()
+
+	fp, err := NewFeatureProbe(server.URL(), "sdk_key", WithStreamingMode(true), withRealtimeUrl(stream.URL),
+		WithRefreshInterval(60000), WithWaitFirstResp(false))
+	assert.Nil(t, err)
+	defer fp.Close()
+
+	waitForValue(fp, "toggle", "v1")
+	assert.Equal(t, "v1", fp.StrValue("toggle", NewUser(), "default"))
+	assert.True(t, fp.Syncer.stream.isConnected())
+
+	updated := newToggleForTest("toggle", "v2")
+	updated.Version = 2
+	server.SetRepository(Repository{Toggles: map[string]Toggle{"toggle": updated}})
+	events <- "event: update\ndata: {}\n\n"
+	waitForValue(fp, "toggle", "v2")
+	assert.Equal(t, "v2", fp.StrValue("toggle", NewUser(), "default"))
+	assert.Equal(t, 2, server.TogglesRequests())
+}
+
+func TestStreamingFallsBackToPolling(t *testing.T) {
+	server := NewMockServer(Repository{Toggles: map[string]Toggle{"toggle": newToggleForTest("toggle", "v1")}})
+	defer server.Close()
+	stream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer stream.Close()
+
+	fp, err := NewFeatureProbe(server.URL(), "sdk_key", WithStreamingMode(true), withRealtimeUrl(stream.URL),
+		WithRefreshInterval(50))
+	assert.Nil(t, err)
+	defer fp.Close()
+	assert.Equal(t, "v1", fp.StrValue("toggle", NewUser(), "default"))
+	assert.False(t, fp.Syncer.stream.isConnected())
+
+	updated := newToggleForTest("toggle", "v2")
+	updated.Version = 2
+	server.SetRepository(Repository{Toggles: map[string]Toggle{"toggle": updated}})
+	waitForValue(fp, "toggle", "v2")
+	assert.Equal(t, "v2", fp.StrValue("toggle", NewUser(), "default"))
+}
+
+func TestWithRealtimeUri(t *testing.T) {
+	config := FPConfig{RemoteUrl: "https://featureprobe.com/"}
+	WithRealtimeUri("stream")(&config)
+	assert.Equal(t, "https://featureprobe.com/stream", config.RealtimeUrl)
+}
@@ -0,0 +1,30 @@
+package featureprobe
+
+// StrictMode sets what evaluating a toggle missing from the synced
+// repository does, so typos in toggle keys are caught before production.
+type StrictMode int
+
+const (
+	StrictOff StrictMode = iota
+	// StrictLog prints an error for every evaluation of a missing toggle.
+	StrictLog
+	// StrictPanic panics, failing the test that evaluates a missing toggle.
+	StrictPanic
+)
+
+func WithStrictMode(mode StrictMode) Option {
+	return func(fpConfig *FPConfig) {
+		fpConfig.StrictMode = mode
+	}
+}
+
+// unknownToggle is only called once toggles were synced, so a client still
+// waiting for its first response is not reported.
+func (fp *FeatureProbe) unknownToggle(toggle string) {
+	switch fp.Config.StrictMode {
+	case StrictLog:
+		fp.Config.log.errorf("error: %s", toggleNotExistReason(toggle))
+	case StrictPanic:
+		panic(toggleNotExistReason(toggle))
+	}
+}
@@ -0,0 +1,35 @@
+package featureprobe
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStrictModePanics(t *testing.T) {
+	fp := NewFeatureProbeForTest(map[string]interface{}{"toggle": true})
+	fp.Config.StrictMode = StrictPanic
+
+	assert.True(t, fp.BoolValue("toggle", NewUser(), false))
+	assert.PanicsWithValue(t, "Toggle:[togle] not exist", func() {
+		fp.BoolValue("togle", NewUser(), false)
+	})
+}
+
+func TestStrictModeBeforeSync(t *testing.T) {
+	fp := FeatureProbe{Repo: NewRepositoryStore(&Repository{})}
+	fp.Config.StrictMode = StrictPanic
+
+	assert.NotPanics(t, func() {
+		assert.False(t, fp.BoolValue("toggle", NewUser(), false))
+	})
+}
+
+func TestStrictModeLog(t *testing.T) {
+	fp := NewFeatureProbeForTest(map[string]interface{}{})
+	WithStrictMode(StrictLog)(&fp.Config)
+
+	detail := fp.StrDetail("togle", NewUser(), "d")
+	assert.Equal(t, "d", detail.Value)
+	assert.Equal(t, "Toggle:[togle] not exist", detail.Reason)
+}
@@ -0,0 +1,49 @@
+package featureprobe
+
+import (
+	"fmt"
+	"sync"
+)
+
+// WithToggleKeys subscribes to exactly the toggles keys, adding them to the
+// toggle filter: only they are synced, notified as changed and counted in
+// events. Evaluating another toggle answers its default and is reported to
+// the error listener, once per toggle, without waiting for a sync.
+func WithToggleKeys(keys ...string) Option {
+	return func(fpConfig *FPConfig) {
+		if fpConfig.ToggleFilter == nil {
+			fpConfig.ToggleFilter = &ToggleFilter{}
+		}
+		fpConfig.ToggleFilter.Keys = append(fpConfig.ToggleFilter.Keys, keys...)
+	}
+}
+
+// subscription knows the subscribed toggles up front, which it can't when
+// the filter selects toggles by tag.
+type subscription struct {
+	filter   *ToggleFilter
+	reported sync.Map
+}
+
+func newSubscription(filter *ToggleFilter) *subscription {
+	if filter == nil || len(filter.Keys) == 0 || len(filter.Tags) > 0 {
+		return nil
+	}
+	return &subscription{filter: filter}
+}
+
+func notSubscribedReason(toggle string) string {
+	return "Toggle:[" + toggle + "] not subscribed"
+}
+
+// allows tells whether toggle is subscribed, reporting it the first time it
+// is not.
+func (s *subscription) allows(toggle string, config FPConfig) bool {
+	if s == nil || s.filter.matchKey(toggle) {
+		return true
+	}
+	if _, reported := s.reported.LoadOrStore(toggle, true); !reported {
+		config.reportError(fmt.Errorf("toggle %s is evaluated but not subscribed", toggle))
+	}
+	return false
+}
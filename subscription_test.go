@@ -0,0 +1,43 @@
+package featureprobe
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestToggleKeys(t *testing.T) {
+	repo := Repository{Toggles: map[string]Toggle{
+		"checkout": newToggleForTest("checkout", true),
+		"search":   newToggleForTest("search", true),
+	}}
+	server := NewMockServer(repo)
+	defer server.Close()
+
+	var reported []error
+	fp, err := NewFeatureProbe(server.URL(), "sdk_key", WithToggleKeys("checkout"), WithErrorListener(func(err error) {
+		reported = append(reported, err)
+	}))
+	assert.Nil(t, err)
+	defer fp.Close()
+
+	assert.Len(t, fp.Repo.Load().Toggles, 1)
+	assert.True(t, fp.BoolValue("checkout", NewUser(), false))
+
+	detail := fp.BoolDetail("search", NewUser(), false)
+	assert.False(t, detail.Value)
+	assert.Equal(t, "Toggle:[search] not subscribed", detail.Reason)
+	fp.BoolValue("search", NewUser(), false)
+	assert.Len(t, reported, 1)
+	assert.EqualError(t, reported[0], "toggle search is evaluated but not subscribed")
+}
+
+func TestSubscriptionNeedsKnownKeys(t *testing.T) {
+	assert.Nil(t, newSubscription(nil))
+	assert.Nil(t, newSubscription(&ToggleFilter{Prefixes: []string{"a_"}}))
+	assert.Nil(t, newSubscription(&ToggleFilter{Keys: []string{"a"}, Tags: []string{"web"}}))
+
+	s := newSubscription(&ToggleFilter{Keys: []string{"a"}, Prefixes: []string{"b_"}})
+	assert.True(t, s.allows("a", FPConfig{}))
+	assert.True(t, s.allows("b_1", FPConfig{}))
+}
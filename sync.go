@@ -1,50 +1,107 @@
 package featureprobe
 
 import (
-	"encoding/json"
-	"fmt"
+	"context"
+	"crypto/sha1"
 	"io/ioutil"
 	"net/http"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 type Synchronizer struct {
-	auth            string
-	togglesUrl      string
+	// lastSynced is the unix millis of the last successful fetch, first so
+	// it is 64-bit aligned for atomic access.
+	lastSynced int64
+	// interval is the refresh interval in milliseconds set by UpdateConfig,
+	// 0 until then.
+	interval        int64
+	offline         int32
+	auth            AuthProvider
+	togglesUrls     *endpoints
 	RefreshInterval time.Duration
-	repository      *Repository
+	repository      *RepositoryStore
 	httpClient      http.Client
 	mu              sync.Mutex
 	startOnce       sync.Once
+	fetchOnce       sync.Once
 	stopOnce        sync.Once
 	stopChan        chan struct{}
-	ticker          *time.Ticker
+	intervalChan    chan time.Duration
+	ticker          Ticker
+	clock           Clock
+	// lastDigest is the hash of the payload lastRepo was built from.
+	lastDigest [sha1.Size]byte
+	lastRepo   *Repository
+	// rejectedDigest is the hash of a payload rolled back from.
+	rejectedDigest [sha1.Size]byte
+	// onUpdate are called in order after a new repository is stored.
+	onUpdate []func(previous, repo *Repository)
+	filter   *ToggleFilter
+	signer   *requestSigner
+	codec    *JSONCodec
+	stream   *streamer
+	log      *logger
+	store    *dataStoreSource
+	// reportError, if set, receives the errors of payloads failing to build
+	// instead of printing them.
+	reportError func(error)
+	// ctx is cancelled by Stop, aborting the fetch in flight.
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
 }
 
-func NewSynchronizer(url string, RefreshInterval time.Duration, auth string, repo *Repository) Synchronizer {
+func NewSynchronizer(url string, RefreshInterval time.Duration, auth string, repo *RepositoryStore) Synchronizer {
+	ctx, cancel := context.WithCancel(context.Background())
 	return Synchronizer{
-		auth:            auth,
-		togglesUrl:      url,
+		ctx:             ctx,
+		cancel:          cancel,
+		auth:            staticAuth(auth),
+		togglesUrls:     newEndpoints([]string{url}, nil),
 		RefreshInterval: RefreshInterval,
 		httpClient:      newHttpClient(RefreshInterval),
 		repository:      repo,
 		stopChan:        make(chan struct{}),
+		intervalChan:    make(chan time.Duration, 1),
+		clock:           systemClock{},
 	}
 }
 
 //TODO: create error message channel?
 func (s *Synchronizer) Start(waitFirstResp ...bool) {
 	s.startOnce.Do(func() {
-		s.ticker = time.NewTicker(s.RefreshInterval * time.Millisecond)
+		s.ticker = s.clock.NewTicker(s.RefreshInterval * time.Millisecond)
 		respChan := make(chan struct{})
 		shouldWait := len(waitFirstResp) == 1 && waitFirstResp[0]
+		if s.stream != nil {
+			s.wg.Add(1)
+			go func() {
+				defer s.wg.Done()
+				s.stream.run(s)
+			}()
+		}
+		s.wg.Add(1)
 		go func() {
+			defer s.wg.Done()
 			for {
 				select {
 				case <-s.stopChan:
+					s.ticker.Stop()
+					s.httpClient.CloseIdleConnections()
 					return
-				case <-s.ticker.C:
+				case interval := <-s.intervalChan:
+					s.ticker.Stop()
+					s.ticker = s.clock.NewTicker(interval * time.Millisecond)
+				case <-s.stream.updated():
+					s.fetchRemoteRepo()
+				case <-s.ticker.C():
+					// changes are announced on the stream while it is up
+					if s.stream.isConnected() && !shouldWait {
+						continue
+					}
 					s.fetchRemoteRepo()
 					if shouldWait {
 						respChan <- struct{}{}
@@ -59,36 +116,178 @@ func (s *Synchronizer) Start(waitFirstResp ...bool) {
 	})
 }
 
+// Stop aborts the fetch in flight and returns once syncing stopped, so no
+// repository is stored afterwards.
 func (s *Synchronizer) Stop() {
 	if s.stopChan != nil {
 		s.stopOnce.Do(func() {
 			close(s.stopChan)
+			if s.cancel != nil {
+				s.cancel()
+			}
 		})
 	}
+	s.wg.Wait()
+}
+
+func (s *Synchronizer) context() context.Context {
+	if s.ctx == nil {
+		return context.Background()
+	}
+	return s.ctx
+}
+
+func (s *Synchronizer) stopped() bool {
+	select {
+	case <-s.stopChan:
+		return true
+	default:
+		return false
+	}
 }
 
 func (s *Synchronizer) fetchRemoteRepo() {
-	req, err := http.NewRequest(http.MethodGet, s.togglesUrl, nil)
+	if s.fetch() || s.store == nil {
+		return
+	}
+	current := s.repository.Load()
+	repo, err := s.store.load(current)
 	if err != nil {
-		fmt.Printf("%s\n", err)
+		s.store.report(err)
 		return
 	}
-	req.Header.Add("Authorization", s.auth)
-	req.Header.Add("User-Agent", USER_AGENT)
+	if repo == nil || s.stopped() {
+		return
+	}
+	s.filter.apply(repo)
+	s.repository.Store(repo)
+	for _, f := range s.onUpdate {
+		f(current, repo)
+	}
+}
+
+// fetch syncs the repository, reporting whether the toggles API answered.
+// A payload rejected or failing to build still counts as an answer: the data
+// store is only read while FeatureProbe is unreachable.
+func (s *Synchronizer) fetch() bool {
+	if atomic.LoadInt32(&s.offline) == 1 {
+		return true
+	}
 	s.mu.Lock()
-	resp, err := s.httpClient.Do(req)
+	resp, err := sendAuthorized(s.auth, func(authorization string) (*http.Response, error) {
+		return s.togglesUrls.do(&s.httpClient, func(url string) (*http.Request, error) {
+			req, err := http.NewRequestWithContext(s.context(), http.MethodGet, s.filter.query(url), nil)
+			if err != nil {
+				return nil, err
+			}
+			req.Header.Add("Authorization", authorization)
+			req.Header.Add("User-Agent", USER_AGENT)
+			req.Header.Set("Accept", "application/json")
+			req.Header.Set(schemaVersionHeader, strconv.Itoa(MaxSchemaVersion))
+			s.signer.sign(req, nil)
+			return req, nil
+		})
+	})
 	s.mu.Unlock()
 	if err != nil {
-		fmt.Printf("%s\n", err)
-		return
+		s.log.errorf("%s", err)
+		return false
 	}
 	defer resp.Body.Close()
 
-	bodyBytes, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		s.log.errorf("fetch toggles fails: %s", resp.Status)
+		return false
+	}
+	bodyBytes, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		s.log.errorf("%s", err)
+		return false
+	}
+	digest := sha1.Sum(bodyBytes)
+	current := s.repository.Load()
 	s.mu.Lock()
-	err = json.Unmarshal(bodyBytes, s.repository)
+	unchanged := current != nil && current == s.lastRepo && digest == s.lastDigest
+	rejected := digest == s.rejectedDigest
 	s.mu.Unlock()
+	if rejected {
+		return true
+	}
+	if unchanged {
+		s.synced()
+		return true
+	}
+	repo, err := buildRepositoryWith(s.codec, bodyBytes, current)
 	if err != nil {
-		fmt.Printf("%s\n", err)
+		s.mu.Lock()
+		s.rejectedDigest = digest
+		s.mu.Unlock()
+		if s.reportError != nil {
+			s.reportError(err)
+		} else {
+			s.log.errorf("%s", err)
+		}
+		return true
+	}
+	s.filter.apply(repo)
+	if s.stopped() {
+		return true
+	}
+	s.repository.storeSynced(repo)
+	s.synced()
+	s.mu.Lock()
+	s.lastDigest, s.lastRepo = digest, repo
+	s.mu.Unlock()
+	for _, f := range s.onUpdate {
+		f(current, repo)
+	}
+	return true
+}
+
+// synced records a fetch whose payload is the repository being served.
+func (s *Synchronizer) synced() {
+	atomic.StoreInt64(&s.lastSynced, unixMillis(clockOrSystem(s.clock).Now()))
+}
+
+// rejectCurrent stops the payload of the current repository from being
+// applied again.
+func (s *Synchronizer) rejectCurrent() {
+	s.mu.Lock()
+	s.rejectedDigest = s.lastDigest
+	s.mu.Unlock()
+}
+
+// ParseRepository decodes a toggles payload, as served by the toggles API or
+// saved from it, into a repository ready for evaluation.
+func ParseRepository(data []byte) (*Repository, error) {
+	return buildRepository(data, nil)
+}
+
+// buildRepository decodes a complete new snapshot, so toggles and segments
+// always come from the same sync and the published one is never touched.
+func buildRepository(body []byte, previous *Repository) (*Repository, error) {
+	return buildRepositoryWith(nil, body, previous)
+}
+
+// buildRepositoryWith decodes the snapshot with codec, or encoding/json when
+// nil.
+func buildRepositoryWith(codec *JSONCodec, body []byte, previous *Repository) (*Repository, error) {
+	var repo Repository
+	if err := codec.decodeRepository(body, &repo); err != nil {
+		return nil, err
+	}
+	if err := checkSchemaVersion(&repo); err != nil {
+		return nil, err
+	}
+	if err := validateRepository(&repo); err != nil {
+		return nil, err
+	}
+	if repo.Toggles == nil {
+		repo.Toggles = map[string]Toggle{}
+	}
+	if repo.Segments == nil {
+		repo.Segments = map[string]Segment{}
 	}
+	repo.compile(previous)
+	return &repo, nil
 }
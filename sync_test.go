@@ -13,8 +13,8 @@ import (
 
 func TestSyncWaitFirstResp(t *testing.T) {
 	repo, jsonStr := setup(t)
-	var repo2 Repository
-	synchronizer := NewSynchronizer("https://featureprobe.com/api/toggles", 1000, "sdk_key", &repo2)
+	repo2 := NewRepositoryStore(&Repository{})
+	synchronizer := NewSynchronizer("https://featureprobe.com/api/toggles", 1000, "sdk_key", repo2)
 
 	httpmock.ActivateNonDefault(&synchronizer.httpClient)
 	httpmock.RegisterResponder("GET", "https://featureprobe.com/api/toggles",
@@ -27,15 +27,15 @@ func TestSyncWaitFirstResp(t *testing.T) {
 
 	assert.True(t, count > 2)
 	synchronizer.mu.Lock() // for go test -race
-	assert.Equal(t, repo, repo2)
+	assert.Equal(t, repo, *repo2.Load())
 	httpmock.DeactivateAndReset()
 	synchronizer.mu.Unlock()
 }
 
 func TestSyncNotWaitFirstResp(t *testing.T) {
 	repo, jsonStr := setup(t)
-	var repo2 Repository
-	synchronizer := NewSynchronizer("https://featureprobe.com/api/toggles", 1000, "sdk_key", &repo2)
+	repo2 := NewRepositoryStore(&Repository{})
+	synchronizer := NewSynchronizer("https://featureprobe.com/api/toggles", 1000, "sdk_key", repo2)
 
 	httpmock.ActivateNonDefault(&synchronizer.httpClient)
 	httpmock.RegisterResponder("GET", "https://featureprobe.com/api/toggles",
@@ -49,14 +49,14 @@ func TestSyncNotWaitFirstResp(t *testing.T) {
 
 	assert.True(t, count > 2)
 	synchronizer.mu.Lock() // for go test -race
-	assert.Equal(t, repo, repo2)
+	assert.Equal(t, repo, *repo2.Load())
 	httpmock.DeactivateAndReset()
 	synchronizer.mu.Unlock()
 }
 
 func TestSyncInvalidJson(t *testing.T) {
-	var repo2 Repository
-	synchronizer := NewSynchronizer("https://featureprobe.com/api/toggles", 100, "sdk_key", &repo2)
+	repo2 := NewRepositoryStore(&Repository{})
+	synchronizer := NewSynchronizer("https://featureprobe.com/api/toggles", 100, "sdk_key", repo2)
 
 	httpmock.RegisterResponder("GET", "https://featureprobe.com/api/toggles",
 		httpmock.NewStringResponder(200, `{ `))
@@ -74,8 +74,8 @@ func TestSyncInvalidJson(t *testing.T) {
 }
 
 func TestSyncInvalidUrl(t *testing.T) {
-	var repo2 Repository
-	synchronizer := NewSynchronizer(string([]byte{1, 2, 3}), 100, "sdk_key", &repo2)
+	repo2 := NewRepositoryStore(&Repository{})
+	synchronizer := NewSynchronizer(string([]byte{1, 2, 3}), 100, "sdk_key", repo2)
 	_, jsonStr := setup(t)
 
 	httpmock.ActivateNonDefault(&synchronizer.httpClient)
@@ -97,5 +97,91 @@ func setup(t *testing.T) (Repository, string) {
 	jsonStr := string(bytes)
 	err := json.Unmarshal(bytes, &repo)
 	assert.Equal(t, nil, err)
+	repo.compile(nil)
 	return repo, jsonStr
 }
+
+func TestSyncErrorStatusKeepsRepository(t *testing.T) {
+	repo, jsonStr := setup(t)
+	repo2 := NewRepositoryStore(&repo)
+	synchronizer := NewSynchronizer("https://featureprobe.com/api/toggles", 100, "sdk_key", repo2)
+
+	httpmock.ActivateNonDefault(&synchronizer.httpClient)
+	httpmock.RegisterResponder("GET", "https://featureprobe.com/api/toggles",
+		httpmock.NewStringResponder(500, `{}`))
+	synchronizer.fetchRemoteRepo()
+	assert.Equal(t, repo, *repo2.Load())
+
+	httpmock.RegisterResponder("GET", "https://featureprobe.com/api/toggles",
+		httpmock.NewStringResponder(200, jsonStr))
+	synchronizer.fetchRemoteRepo()
+	assert.Equal(t, repo, *repo2.Load())
+	httpmock.DeactivateAndReset()
+}
+
+func TestSyncReplacesWholeRepository(t *testing.T) {
+	repo, _ := setup(t)
+	repo2 := NewRepositoryStore(&repo)
+	synchronizer := NewSynchronizer("https://featureprobe.com/api/toggles", 100, "sdk_key", repo2)
+	old := repo2.Load()
+
+	httpmock.ActivateNonDefault(&synchronizer.httpClient)
+	httpmock.RegisterResponder("GET", "https://featureprobe.com/api/toggles",
+		httpmock.NewStringResponder(200, `{"toggles": {}}`))
+	synchronizer.fetchRemoteRepo()
+	httpmock.DeactivateAndReset()
+
+	current := repo2.Load()
+	assert.Equal(t, 0, len(current.Toggles))
+	assert.NotNil(t, current.Segments)
+	assert.Equal(t, 0, len(current.Segments))
+	assert.True(t, len(old.Toggles) > 0)
+	assert.True(t, len(old.Segments) > 0)
+}
+
+func TestSyncSkipsUnchangedPayload(t *testing.T) {
+	_, jsonStr := setup(t)
+	repo := NewRepositoryStore(&Repository{})
+	synchronizer := NewSynchronizer("https://featureprobe.com/api/toggles", 100, "sdk_key", repo)
+
+	httpmock.ActivateNonDefault(&synchronizer.httpClient)
+	httpmock.RegisterResponder("GET", "https://featureprobe.com/api/toggles",
+		httpmock.NewStringResponder(200, jsonStr))
+	synchronizer.fetchRemoteRepo()
+	first := repo.Load()
+	synchronizer.fetchRemoteRepo()
+	assert.True(t, first == repo.Load())
+
+	repo.Store(&Repository{})
+	synchronizer.fetchRemoteRepo()
+	assert.True(t, first != repo.Load())
+	assert.Equal(t, len(first.Toggles), len(repo.Load().Toggles))
+
+	httpmock.RegisterResponder("GET", "https://featureprobe.com/api/toggles",
+		httpmock.NewStringResponder(200, `{"toggles": {}}`))
+	synchronizer.fetchRemoteRepo()
+	assert.Equal(t, 0, len(repo.Load().Toggles))
+	httpmock.DeactivateAndReset()
+}
+
+func TestSyncStopAbortsFetchInFlight(t *testing.T) {
+	server := NewMockServer(Repository{Toggles: map[string]Toggle{"toggle": newToggleForTest("toggle", true)}})
+	defer server.Close()
+	server.SetDelay(time.Second)
+
+	initial := &Repository{}
+	repo := NewRepositoryStore(initial)
+	synchronizer := NewSynchronizer(server.URL()+"api/server-sdk/toggles", 50, "sdk_key", repo)
+	synchronizer.httpClient.Timeout = 0
+	synchronizer.Start()
+	time.Sleep(150 * time.Millisecond)
+	assert.Equal(t, 1, server.TogglesRequests())
+
+	start := time.Now()
+	synchronizer.Stop()
+	assert.True(t, time.Since(start) < 500*time.Millisecond)
+	assert.True(t, initial == repo.Load())
+
+	synchronizer.fetchRemoteRepo()
+	assert.True(t, initial == repo.Load())
+}
@@ -0,0 +1,31 @@
+package featureprobe
+
+import "html/template"
+
+// FuncMap returns template functions evaluating toggles for the bound user:
+//
+//	{{if featureEnabled "new_checkout"}} ... {{end}}
+//	{{featureString "banner_text" "Welcome"}}
+//
+// Functions must exist when a template is parsed, so parse with the zero
+// Evaluator's FuncMap and rebind a clone per render:
+//
+//	t := template.Must(template.New("page").Funcs(Evaluator{}.FuncMap()).Parse(page))
+//	clone, _ := t.Clone()
+//	clone.Funcs(NewEvaluator(fp, user).FuncMap()).Execute(w, data)
+func (e Evaluator) FuncMap() template.FuncMap {
+	return template.FuncMap{
+		"featureEnabled": func(toggle string) bool {
+			return e.BoolValue(toggle, false)
+		},
+		"featureString": func(toggle string, defaultValue string) string {
+			return e.StrValue(toggle, defaultValue)
+		},
+		"featureNumber": func(toggle string, defaultValue float64) float64 {
+			return e.NumberValue(toggle, defaultValue)
+		},
+		"featureJson": func(toggle string) interface{} {
+			return e.JsonValue(toggle, nil)
+		},
+	}
+}
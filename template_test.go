@@ -0,0 +1,27 @@
+package featureprobe
+
+import (
+	"bytes"
+	"html/template"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTemplateFuncMap(t *testing.T) {
+	page := `{{if featureEnabled "toggle"}}on{{else}}off{{end}} {{featureString "color" "blue"}} {{featureNumber "size" 3}}`
+	tmpl := template.Must(template.New("page").Funcs(Evaluator{}.FuncMap()).Parse(page))
+
+	fp := NewFeatureProbeForTest(map[string]interface{}{"toggle": true, "color": "red"})
+	clone, err := tmpl.Clone()
+	assert.Nil(t, err)
+	var out bytes.Buffer
+	err = clone.Funcs(NewEvaluator(&fp, NewUser()).FuncMap()).Execute(&out, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "on red 3", out.String())
+
+	out.Reset()
+	err = tmpl.Execute(&out, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "off blue 3", out.String())
+}
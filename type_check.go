@@ -0,0 +1,75 @@
+package featureprobe
+
+import (
+	"sort"
+	"sync/atomic"
+)
+
+func (u *toggleUsage) readAs(defaultValue interface{}) {
+	if u != nil && atomic.LoadUint32(&u.read) == 0 {
+		atomic.CompareAndSwapUint32(&u.read, 0, uint32(valueTypeOfDefault(defaultValue))+1)
+	}
+}
+
+// readType returns the type the toggle was first evaluated as, if it was.
+func (u *toggleUsage) readType() (ValueType, bool) {
+	if u == nil {
+		return ValueJson, false
+	}
+	read := atomic.LoadUint32(&u.read)
+	return ValueType(read - 1), read != 0
+}
+
+// warnType reports whether the toggle being actual, not the type it is read
+// as, was not reported yet. It is reported again if the type changes.
+func (u *toggleUsage) warnType(actual ValueType, mismatch bool) bool {
+	var warned uint32
+	if mismatch {
+		warned = uint32(actual) + 1
+	}
+	return atomic.SwapUint32(&u.typeWarned, warned) != warned && mismatch
+}
+
+func valueTypeOfDefault(defaultValue interface{}) ValueType {
+	switch defaultValue.(type) {
+	case bool:
+		return ValueBool
+	case string:
+		return ValueString
+	case float64:
+		return ValueNumber
+	}
+	return ValueJson
+}
+
+// typeChecker reports, after each sync, toggles whose variations are not of
+// the type WithExpectedToggles registers or, for the others, the type of the
+// default they were first evaluated with. A toggle changed from bool to
+// string in the console is warned about once, rather than on every
+// evaluation serving its default.
+func typeChecker(config FPConfig) func(previous, repo *Repository) {
+	return func(_, repo *Repository) {
+		keys := make([]string, 0, len(repo.Toggles))
+		for k := range repo.Toggles {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			t := repo.Toggles[k]
+			if t.usage == nil {
+				continue
+			}
+			expected, ok := config.ExpectedToggles[k]
+			if !ok {
+				expected, ok = t.usage.readType()
+			}
+			if !ok || expected == ValueJson {
+				continue
+			}
+			actual := valueTypeOf(kindOfVariations(t.Variations))
+			if t.usage.warnType(actual, actual != expected) {
+				config.reportError(ExpectationError{Toggle: k, Expected: expected, Actual: actual})
+			}
+		}
+	}
+}
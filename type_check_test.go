@@ -0,0 +1,67 @@
+package featureprobe
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTypeMismatchWarnings(t *testing.T) {
+	toggle := func(version uint64, value interface{}) Repository {
+		tg := newToggleForTest("toggle", value)
+		tg.Version = version
+		unread := newToggleForTest("unread", true)
+		unread.Version = version
+		return Repository{Toggles: map[string]Toggle{"toggle": tg, "unread": unread}}
+	}
+	server := NewMockServer(toggle(1, true))
+	defer server.Close()
+	var errs []error
+	fp, err := NewFeatureProbe(server.URL(), "sdk_key", WithServerless(true),
+		WithErrorListener(func(err error) { errs = append(errs, err) }))
+	assert.Nil(t, err)
+
+	assert.True(t, fp.BoolValue("toggle", NewUser(), false))
+	assert.Empty(t, errs)
+
+	server.SetRepository(toggle(2, "on"))
+	fp.Syncer.fetchRemoteRepo()
+	assert.Equal(t, []error{ExpectationError{Toggle: "toggle", Expected: ValueBool, Actual: ValueString}}, errs)
+
+	server.SetRepository(toggle(3, "off"))
+	fp.Syncer.fetchRemoteRepo()
+	assert.Len(t, errs, 1)
+
+	server.SetRepository(toggle(4, false))
+	fp.Syncer.fetchRemoteRepo()
+	server.SetRepository(toggle(5, "on"))
+	fp.Syncer.fetchRemoteRepo()
+	assert.Len(t, errs, 2)
+}
+
+func TestExpectedTypeChangedAfterFirstSync(t *testing.T) {
+	repo := Repository{Toggles: map[string]Toggle{"toggle": newToggleForTest("toggle", 1.0)}}
+	server := NewMockServer(repo)
+	defer server.Close()
+	var errs []error
+	fp, err := NewFeatureProbe(server.URL(), "sdk_key", WithServerless(true),
+		WithExpectedToggles(map[string]ValueType{"toggle": ValueNumber}),
+		WithErrorListener(func(err error) { errs = append(errs, err) }))
+	assert.Nil(t, err)
+	fp.Syncer.fetchRemoteRepo()
+	assert.Empty(t, errs)
+
+	changed := newToggleForTest("toggle", true)
+	changed.Version = 1
+	server.SetRepository(Repository{Toggles: map[string]Toggle{"toggle": changed}})
+	fp.Syncer.fetchRemoteRepo()
+	assert.Equal(t, []error{ExpectationError{Toggle: "toggle", Expected: ValueNumber, Actual: ValueBool}}, errs)
+}
+
+func TestValueTypeOfDefault(t *testing.T) {
+	assert.Equal(t, ValueBool, valueTypeOfDefault(true))
+	assert.Equal(t, ValueString, valueTypeOfDefault(""))
+	assert.Equal(t, ValueNumber, valueTypeOfDefault(1.0))
+	assert.Equal(t, ValueJson, valueTypeOfDefault(nil))
+	assert.Equal(t, ValueJson, valueTypeOfDefault(map[string]interface{}{}))
+}
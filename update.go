@@ -0,0 +1,84 @@
+package featureprobe
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// WithFlushInterval sets how often events are sent, in milliseconds. It
+// defaults to the refresh interval.
+func WithFlushInterval(interval int) Option {
+	return func(fpConfig *FPConfig) {
+		fpConfig.FlushInterval = interval
+	}
+}
+
+// UpdateConfig applies the refresh and flush intervals and the log level of
+// opts to the running client, so operators can slow down or speed up syncing
+// and event delivery, or quiet its output, under incident conditions without
+// a restart. Other options only take effect in NewFeatureProbe and are
+// ignored. Config keeps the values the client was created with.
+func (fp *FeatureProbe) UpdateConfig(opts ...Option) {
+	// applied to an empty config, so only the fields opts set are non-zero
+	var config FPConfig
+	for _, opt := range opts {
+		opt(&config)
+	}
+	if config.RefreshInterval > 0 && int64(config.RefreshInterval) != fp.refreshInterval() && fp.Syncer != nil {
+		fp.Syncer.setInterval(time.Duration(config.RefreshInterval))
+	}
+	if config.FlushInterval > 0 && int64(config.FlushInterval) != fp.flushInterval() && fp.Recorder != nil {
+		fp.Recorder.setInterval(time.Duration(config.FlushInterval))
+	}
+	if config.LogLevel != 0 && fp.Config.log != nil {
+		fp.Config.log.setLevel(config.LogLevel)
+	}
+}
+
+// refreshInterval is the refresh interval in milliseconds currently used.
+func (fp *FeatureProbe) refreshInterval() int64 {
+	if fp.Syncer != nil {
+		if interval := atomic.LoadInt64(&fp.Syncer.interval); interval > 0 {
+			return interval
+		}
+	}
+	return int64(fp.Config.RefreshInterval)
+}
+
+// flushInterval is the flush interval in milliseconds currently used.
+func (fp *FeatureProbe) flushInterval() int64 {
+	if fp.Recorder != nil {
+		if interval := atomic.LoadInt64(&fp.Recorder.interval); interval > 0 {
+			return interval
+		}
+	}
+	return int64(fp.Config.FlushInterval)
+}
+
+// setInterval replaces the pending interval, if any, with interval.
+func setInterval(intervalChan chan time.Duration, interval time.Duration) {
+	if intervalChan == nil {
+		return
+	}
+	for {
+		select {
+		case intervalChan <- interval:
+			return
+		default:
+		}
+		select {
+		case <-intervalChan:
+		default:
+		}
+	}
+}
+
+func (s *Synchronizer) setInterval(interval time.Duration) {
+	atomic.StoreInt64(&s.interval, int64(interval))
+	setInterval(s.intervalChan, interval)
+}
+
+func (e *EventRecorder) setInterval(interval time.Duration) {
+	atomic.StoreInt64(&e.interval, int64(interval))
+	setInterval(e.intervalChan, interval)
+}
@@ -0,0 +1,95 @@
+package featureprobe
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpdateConfig(t *testing.T) {
+	repo, _ := setup(t)
+	server := NewMockServer(repo)
+	defer server.Close()
+	clock := NewManualClock(time.Unix(1000, 0))
+	fp, err := NewFeatureProbe(server.URL(), "sdk_key", WithClock(clock), WithWaitFirstResp(false), WithRefreshInterval(60000))
+	assert.Nil(t, err)
+	defer fp.Close()
+	assert.Equal(t, 60000, fp.Config.FlushInterval)
+
+	fp.UpdateConfig(WithRefreshInterval(1000), WithFlushInterval(2000), WithServerless(true))
+	assert.Equal(t, int64(1000), fp.refreshInterval())
+	assert.Equal(t, int64(2000), fp.flushInterval())
+	assert.Equal(t, 60000, fp.Config.RefreshInterval)
+	assert.False(t, fp.Config.Serverless)
+
+	for i := 0; i < 50 && server.TogglesRequests() == 0; i++ {
+		clock.Advance(time.Second)
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.True(t, server.TogglesRequests() > 0)
+	assert.True(t, clock.Now().Before(time.Unix(1000, 0).Add(time.Minute)))
+}
+
+func TestSetIntervalReplacesPending(t *testing.T) {
+	intervals := make(chan time.Duration, 1)
+	setInterval(intervals, 1)
+	setInterval(intervals, 2)
+	assert.Equal(t, time.Duration(2), <-intervals)
+}
+
+func TestUpdateConfigWhileEvaluating(t *testing.T) {
+	server := NewMockServer(Repository{Toggles: map[string]Toggle{"toggle": newToggleForTest("toggle", true)}})
+	defer server.Close()
+	fp, err := NewFeatureProbe(server.URL(), "sdk_key", WithClock(NewManualClock(time.Unix(1000, 0))), WithWaitFirstResp(false))
+	assert.Nil(t, err)
+	defer fp.Close()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			fp.BoolValue("toggle", NewUser(), false)
+		}
+	}()
+	for i := 1; i <= 100; i++ {
+		fp.UpdateConfig(WithRefreshInterval(1000+i), WithFlushInterval(1000+i))
+	}
+	<-done
+	assert.Equal(t, int64(1100), fp.refreshInterval())
+	assert.Equal(t, int64(1100), fp.flushInterval())
+}
+
+func TestUpdateConfigKeepsOtherUpdates(t *testing.T) {
+	server := NewMockServer(Repository{})
+	defer server.Close()
+	fp, err := NewFeatureProbe(server.URL(), "sdk_key", WithClock(NewManualClock(time.Unix(1000, 0))),
+		WithWaitFirstResp(false), WithRefreshInterval(5000), WithFlushInterval(60000))
+	assert.Nil(t, err)
+	defer fp.Close()
+
+	fp.UpdateConfig(WithRefreshInterval(7000))
+	fp.UpdateConfig(WithFlushInterval(100))
+	assert.Equal(t, int64(7000), fp.refreshInterval())
+	assert.Equal(t, int64(100), fp.flushInterval())
+	fp.UpdateConfig(WithRefreshInterval(8000))
+	assert.Equal(t, int64(8000), fp.refreshInterval())
+	assert.Equal(t, int64(100), fp.flushInterval())
+}
+
+func TestUpdateLogLevel(t *testing.T) {
+	fp, err := NewFeatureProbe("http://127.0.0.1:1", "sdk_key", WithOfflineMode(true), WithLogLevel(LogLevelError))
+	assert.Nil(t, err)
+	defer fp.Close()
+	assert.False(t, fp.Config.log.enabled(LogLevelInfo))
+	assert.True(t, fp.Config.log.enabled(LogLevelError))
+
+	fp.UpdateConfig(WithLogLevel(LogLevelSilent))
+	assert.False(t, fp.Config.log.enabled(LogLevelError))
+	fp.UpdateConfig(WithRefreshInterval(1000))
+	assert.False(t, fp.Config.log.enabled(LogLevelError))
+	assert.Equal(t, LogLevelError, fp.Config.LogLevel)
+
+	var unset *logger
+	assert.True(t, unset.enabled(LogLevelInfo))
+}
@@ -0,0 +1,51 @@
+package featureprobe
+
+import (
+	"sort"
+	"sync/atomic"
+)
+
+// toggleUsage records whether a toggle was evaluated by this client. It is
+// carried over by key when toggles are synced, whatever their version.
+type toggleUsage struct {
+	// obsolete counts evaluations while the toggle was archived or past its
+	// planned end, first so it is 64-bit aligned for atomic access.
+	obsolete  uint64
+	evaluated uint32
+	warned    uint32
+	// read is 1 + the ValueType of the first evaluation's default, or 0
+	read uint32
+	// typeWarned is 1 + the mismatched ValueType last reported, or 0
+	typeWarned uint32
+	// panics counts the panics evaluating the toggle since its version
+	// changed, see QuarantinePanics
+	panics uint32
+}
+
+func (u *toggleUsage) mark() {
+	if u != nil && atomic.LoadUint32(&u.evaluated) == 0 {
+		atomic.StoreUint32(&u.evaluated, 1)
+	}
+}
+
+func (u *toggleUsage) marked() bool {
+	return u != nil && atomic.LoadUint32(&u.evaluated) == 1
+}
+
+// NeverEvaluated returns the sorted keys of the toggles in the repository
+// this client has never evaluated since it started, which are candidates
+// for cleanup once every instance agrees.
+func (fp *FeatureProbe) NeverEvaluated() []string {
+	repo := fp.Repo.Load()
+	if repo == nil {
+		return nil
+	}
+	keys := []string{}
+	for key, t := range repo.Toggles {
+		if !t.usage.marked() {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
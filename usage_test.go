@@ -0,0 +1,30 @@
+package featureprobe
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNeverEvaluated(t *testing.T) {
+	fp := NewFeatureProbeForTest(map[string]interface{}{"a": true, "b": "x", "c": 1.0})
+	assert.Equal(t, []string{"a", "b", "c"}, fp.NeverEvaluated())
+
+	fp.BoolValue("a", NewUser(), false)
+	fp.EvalOnly("c", NewUser(), nil)
+	assert.Equal(t, []string{"b"}, fp.NeverEvaluated())
+}
+
+func TestNeverEvaluatedAcrossSyncs(t *testing.T) {
+	repo, _ := setup(t)
+	data, _ := json.Marshal(repo)
+	first, _ := buildRepository(data, nil)
+	fp := FeatureProbe{Repo: NewRepositoryStore(first)}
+	fp.BoolValue("bool_toggle", NewUser(), false)
+
+	second, _ := buildRepository(data, first)
+	fp.Repo.Store(second)
+	assert.NotContains(t, fp.NeverEvaluated(), "bool_toggle")
+	assert.Contains(t, fp.NeverEvaluated(), "string_toggle")
+}
@@ -8,6 +8,8 @@ import (
 type FPUser struct {
 	key   string
 	attrs map[string]string
+	// global are the client's global attributes, read when attrs lacks one
+	global map[string]string
 }
 
 func NewUser() FPUser {
@@ -39,9 +41,31 @@ func (u FPUser) With(key string, value string) FPUser {
 }
 
 func (u FPUser) GetAll() map[string]string {
-	return u.attrs
+	if len(u.global) == 0 {
+		return u.attrs
+	}
+	all := make(map[string]string, len(u.global)+len(u.attrs))
+	for k, v := range u.global {
+		all[k] = v
+	}
+	for k, v := range u.attrs {
+		all[k] = v
+	}
+	return all
 }
 
 func (u FPUser) Get(key string) string {
-	return u.attrs[key]
+	if v, ok := u.attrs[key]; ok || u.global == nil {
+		return v
+	}
+	return u.global[key]
+}
+
+// Contains tells whether the user has attribute key, even if empty.
+func (u FPUser) Contains(key string) bool {
+	if _, ok := u.attrs[key]; ok {
+		return true
+	}
+	_, ok := u.global[key]
+	return ok
 }
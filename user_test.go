@@ -10,6 +10,8 @@ func TestFPUser(t *testing.T) {
 	var user = NewUser().StableRollout("uniqueUserKey")
 	user.With("city", "1").With("os", "linux")
 	assert.Equal(t, "1", user.Get("city"))
+	assert.True(t, user.Contains("os"))
+	assert.False(t, user.Contains("plan"))
 	assert.Equal(t, 2, len(user.GetAll()))
 	assert.Equal(t, "uniqueUserKey", user.Key())
 }
@@ -0,0 +1,110 @@
+package featureprobe
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// InvalidRepositoryError is returned for toggles payloads that would fail at
+// evaluation time, e.g. serving a variation out of bounds. The synchronizer
+// rejects them and keeps its current toggles. Errors holds a ToggleError for
+// each problem found, sorted by toggle.
+type InvalidRepositoryError struct {
+	Errors []error
+}
+
+func (e *InvalidRepositoryError) Error() string {
+	messages := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		messages[i] = err.Error()
+	}
+	return "invalid repository: " + strings.Join(messages, "; ")
+}
+
+var conditionTypes = map[string]bool{
+	"string":   true,
+	"segment":  true,
+	"datetime": true,
+	"semver":   true,
+	"number":   true,
+}
+
+// validateRepository checks the serves and conditions of every toggle and
+// segment of repo.
+func validateRepository(repo *Repository) error {
+	var errs []error
+	keys := make([]string, 0, len(repo.Toggles))
+	for key := range repo.Toggles {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		t := repo.Toggles[key]
+		for _, problem := range t.problems() {
+			errs = append(errs, toggleError(key, problem))
+		}
+	}
+	keys = keys[:0]
+	for key := range repo.Segments {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		for _, problem := range conditionProblems(repo.Segments[key].Rules) {
+			errs = append(errs, fmt.Errorf("segment %s: %w", key, problem))
+		}
+	}
+	if len(errs) > 0 {
+		return &InvalidRepositoryError{Errors: errs}
+	}
+	return nil
+}
+
+func (t *Toggle) problems() []error {
+	var problems []error
+	count := len(t.Variations)
+	if err := t.DisabledServe.problem(count); err != nil {
+		problems = append(problems, fmt.Errorf("disabled serve %w", err))
+	}
+	if err := t.DefaultServe.problem(count); err != nil {
+		problems = append(problems, fmt.Errorf("default serve %w", err))
+	}
+	for i, r := range t.Rules {
+		if err := r.Serve.problem(count); err != nil {
+			problems = append(problems, fmt.Errorf("rule %d serve %w", i, err))
+		}
+	}
+	return append(problems, conditionProblems(t.Rules)...)
+}
+
+func (s *Serve) problem(variations int) error {
+	switch {
+	case s.Select != nil:
+		if *s.Select < 0 || *s.Select >= variations {
+			return fmt.Errorf("selects variation %d of %d", *s.Select, variations)
+		}
+	case s.Split != nil:
+		if len(s.Split.Distribution) > variations {
+			return fmt.Errorf("splits between %d variations of %d", len(s.Split.Distribution), variations)
+		}
+		if r := s.Split.Remainder; r != nil && (*r < 0 || *r >= variations) {
+			return fmt.Errorf("remainder is variation %d of %d", *r, variations)
+		}
+	default:
+		return fmt.Errorf("is missing")
+	}
+	return nil
+}
+
+func conditionProblems(rules []Rule) []error {
+	var problems []error
+	for i, r := range rules {
+		for j, c := range r.Conditions {
+			if !conditionTypes[c.Type] {
+				problems = append(problems, fmt.Errorf("rule %d condition %d has unknown type %q", i, j, c.Type))
+			}
+		}
+	}
+	return problems
+}
@@ -0,0 +1,77 @@
+package featureprobe
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+const invalidRepositoryJson = `{
+  "toggles": {
+    "b_toggle": {
+      "key": "b_toggle", "enabled": true, "version": 1,
+      "disabledServe": {"select": 0},
+      "defaultServe": {"select": 2},
+      "rules": [{"serve": {}, "conditions": [{"type": "regex", "subject": "city", "predicate": "is one of", "objects": ["1"]}]}],
+      "variations": [false, true]
+    },
+    "a_toggle": {
+      "key": "a_toggle", "enabled": true, "version": 1,
+      "disabledServe": {"select": 0},
+      "defaultServe": {"split": {"distribution": [[[0, 5000]], [[5000, 10000]]], "remainder": -1}},
+      "variations": [false, true]
+    },
+    "valid": {
+      "key": "valid", "enabled": true, "version": 1,
+      "disabledServe": {"select": 0},
+      "defaultServe": {"split": {"distribution": [[[0, 5000]], [[5000, 10000]]]}},
+      "variations": [false, true]
+    }
+  },
+  "segments": {
+    "segment": {"key": "segment", "rules": [{"conditions": [{"type": "list"}]}]}
+  }
+}`
+
+func TestValidateRepository(t *testing.T) {
+	_, err := ParseRepository([]byte(invalidRepositoryJson))
+	var invalid *InvalidRepositoryError
+	assert.True(t, errors.As(err, &invalid))
+	var messages []string
+	for _, e := range invalid.Errors {
+		messages = append(messages, e.Error())
+	}
+	assert.Equal(t, []string{
+		"toggle a_toggle: default serve remainder is variation -1 of 2",
+		"toggle b_toggle: default serve selects variation 2 of 2",
+		"toggle b_toggle: rule 0 serve is missing",
+		`toggle b_toggle: rule 0 condition 0 has unknown type "regex"`,
+		`segment segment: rule 0 condition 0 has unknown type "list"`,
+	}, messages)
+
+	var toggleErr *ToggleError
+	assert.True(t, errors.As(invalid.Errors[0], &toggleErr))
+	assert.Equal(t, "a_toggle", toggleErr.Toggle)
+}
+
+func TestSyncRejectsInvalidRepository(t *testing.T) {
+	repo, _ := setup(t)
+	store := NewRepositoryStore(&repo)
+	synchronizer := NewSynchronizer("https://featureprobe.com/api/toggles", 100, "sdk_key", store)
+	var reported []error
+	synchronizer.reportError = func(err error) { reported = append(reported, err) }
+
+	httpmock.ActivateNonDefault(&synchronizer.httpClient)
+	httpmock.RegisterResponder("GET", "https://featureprobe.com/api/toggles",
+		httpmock.NewStringResponder(200, invalidRepositoryJson))
+	synchronizer.fetchRemoteRepo()
+	synchronizer.fetchRemoteRepo()
+	httpmock.DeactivateAndReset()
+
+	assert.Equal(t, repo, *store.Load())
+	assert.Len(t, reported, 1)
+	var invalid *InvalidRepositoryError
+	assert.True(t, errors.As(reported[0], &invalid))
+}
@@ -0,0 +1,191 @@
+package featureprobe
+
+import (
+	"bytes"
+	"encoding/json"
+	"sync"
+)
+
+type variationKind int
+
+const (
+	variationJson variationKind = iota
+	variationBool
+	variationString
+	variationNumber
+)
+
+// typedVariations holds the variations of a toggle decoded by type at sync
+// time. A toggle whose variations all share a type is read without type
+// assertions, and reading it as another type is a mismatch whichever
+// variation is served. Mixed variations are left as JSON.
+type typedVariations struct {
+	kind    variationKind
+	bools   []bool
+	strings []string
+	numbers []float64
+}
+
+func newTypedVariations(variations []interface{}) *typedVariations {
+	typed := &typedVariations{kind: kindOfVariations(variations)}
+	switch typed.kind {
+	case variationBool:
+		typed.bools = make([]bool, len(variations))
+		for i, v := range variations {
+			typed.bools[i] = v.(bool)
+		}
+	case variationString:
+		typed.strings = make([]string, len(variations))
+		for i, v := range variations {
+			typed.strings[i] = v.(string)
+		}
+	case variationNumber:
+		typed.numbers = make([]float64, len(variations))
+		for i, v := range variations {
+			typed.numbers[i], _ = toFloat64(v)
+		}
+	}
+	return typed
+}
+
+func kindOfVariations(variations []interface{}) variationKind {
+	if len(variations) == 0 {
+		return variationJson
+	}
+	kind := kindOfValue(variations[0])
+	for _, v := range variations[1:] {
+		if kindOfValue(v) != kind {
+			return variationJson
+		}
+	}
+	return kind
+}
+
+func kindOfValue(value interface{}) variationKind {
+	switch value.(type) {
+	case bool:
+		return variationBool
+	case string:
+		return variationString
+	case float64, int:
+		return variationNumber
+	}
+	return variationJson
+}
+
+func toFloat64(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case int:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
+
+// typedIndex reports whether the result can be read from typed variations:
+// it was served from a toggle with homogeneous variations. Overrides, faults
+// and defaults fall back to asserting the value.
+func (r evalResult) typedIndex() bool {
+	return r.typed != nil && r.typed.kind != variationJson && r.variationIndex != noIndex
+}
+
+func (r evalResult) boolValue() (bool, bool) {
+	if !r.typedIndex() {
+		v, ok := r.value.(bool)
+		return v, ok
+	}
+	if r.typed.kind != variationBool {
+		return false, false
+	}
+	return r.typed.bools[r.variationIndex], true
+}
+
+func (r evalResult) stringValue() (string, bool) {
+	if !r.typedIndex() {
+		v, ok := r.value.(string)
+		return v, ok
+	}
+	if r.typed.kind != variationString {
+		return "", false
+	}
+	return r.typed.strings[r.variationIndex], true
+}
+
+func (r evalResult) numberValue() (float64, bool) {
+	if !r.typedIndex() {
+		return toFloat64(r.value)
+	}
+	if r.typed.kind != variationNumber {
+		return 0, false
+	}
+	return r.typed.numbers[r.variationIndex], true
+}
+
+// lazyJSON is an object or array variation kept encoded until an evaluation
+// serves it, since most services never read their large JSON toggles.
+type lazyJSON struct {
+	raw   json.RawMessage
+	codec *JSONCodec
+	once  sync.Once
+	value interface{}
+}
+
+func (l *lazyJSON) get() interface{} {
+	l.once.Do(func() {
+		if err := l.codec.unmarshal(l.raw, &l.value); err != nil {
+			l.value = nil
+		}
+	})
+	return l.value
+}
+
+func (l *lazyJSON) MarshalJSON() ([]byte, error) {
+	return l.raw, nil
+}
+
+func (t *Toggle) UnmarshalJSON(data []byte) error {
+	type toggle Toggle
+	raw := struct {
+		*toggle
+		Variations []json.RawMessage `json:"variations"`
+	}{toggle: (*toggle)(t)}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	variations, err := decodeVariations(nil, raw.Variations)
+	t.Variations = variations
+	return err
+}
+
+// decodeVariations decodes scalar variations with codec, and keeps object
+// and array ones for lazyJSON to decode with it when served.
+func decodeVariations(codec *JSONCodec, raws []json.RawMessage) ([]interface{}, error) {
+	if raws == nil {
+		return nil, nil
+	}
+	variations := make([]interface{}, len(raws))
+	for i, r := range raws {
+		trimmed := bytes.TrimSpace(r)
+		if len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') {
+			// compacted, so an encoded repository decodes to an equal one
+			var compact bytes.Buffer
+			if err := json.Compact(&compact, trimmed); err != nil {
+				return nil, err
+			}
+			variations[i] = &lazyJSON{raw: compact.Bytes(), codec: codec}
+			continue
+		}
+		if err := codec.unmarshal(r, &variations[i]); err != nil {
+			return nil, err
+		}
+	}
+	return variations, nil
+}
+
+func variationValue(v interface{}) interface{} {
+	if l, ok := v.(*lazyJSON); ok {
+		return l.get()
+	}
+	return v
+}
@@ -0,0 +1,83 @@
+package featureprobe
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTypedVariationsKind(t *testing.T) {
+	assert.Equal(t, variationBool, newTypedVariations([]interface{}{true, false}).kind)
+	assert.Equal(t, variationString, newTypedVariations([]interface{}{"a", "b"}).kind)
+	assert.Equal(t, variationNumber, newTypedVariations([]interface{}{1.5, 2}).kind)
+	assert.Equal(t, variationJson, newTypedVariations([]interface{}{"a", 1.0}).kind)
+	assert.Equal(t, variationJson, newTypedVariations([]interface{}{map[string]interface{}{}}).kind)
+	assert.Equal(t, variationJson, newTypedVariations(nil).kind)
+}
+
+func TestTypedVariationsValues(t *testing.T) {
+	typed := newTypedVariations([]interface{}{1.5, 2})
+	assert.Equal(t, []float64{1.5, 2}, typed.numbers)
+
+	result := evalResult{variationIndex: 1, value: 2, typed: typed}
+	n, ok := result.numberValue()
+	assert.True(t, ok)
+	assert.Equal(t, 2.0, n)
+
+	_, ok = result.boolValue()
+	assert.False(t, ok)
+	_, ok = result.stringValue()
+	assert.False(t, ok)
+}
+
+func TestTypedVariationsFallbackToValue(t *testing.T) {
+	result := evalResult{variationIndex: noIndex, value: true, typed: newTypedVariations([]interface{}{"a"})}
+	b, ok := result.boolValue()
+	assert.True(t, ok)
+	assert.True(t, b)
+
+	result = evalResult{variationIndex: 0, value: "a", typed: newTypedVariations([]interface{}{"a", 1.0})}
+	s, ok := result.stringValue()
+	assert.True(t, ok)
+	assert.Equal(t, "a", s)
+}
+
+func TestCompileDecodesVariations(t *testing.T) {
+	repo := Repository{
+		Toggles: map[string]Toggle{
+			"bool": {Key: "bool", Variations: []interface{}{true, false}},
+		},
+		Segments: map[string]Segment{},
+	}
+	repo.compile(nil)
+	assert.Equal(t, []bool{true, false}, repo.Toggles["bool"].typed.bools)
+
+	next := Repository{
+		Toggles: map[string]Toggle{
+			"bool": {Key: "bool", Variations: []interface{}{true, false}},
+		},
+		Segments: map[string]Segment{},
+	}
+	next.compile(&repo)
+	assert.True(t, repo.Toggles["bool"].typed == next.Toggles["bool"].typed)
+}
+
+func TestLazyJSONVariations(t *testing.T) {
+	var toggle Toggle
+	err := json.Unmarshal([]byte(`{"key": "t", "variations": [{"a": [1, 2]}, [true], "s", 1.5, null]}`), &toggle)
+	assert.Nil(t, err)
+
+	lazy, ok := toggle.Variations[0].(*lazyJSON)
+	assert.True(t, ok)
+	assert.Nil(t, lazy.value)
+	assert.Equal(t, map[string]interface{}{"a": []interface{}{1.0, 2.0}}, variationValue(toggle.Variations[0]))
+	assert.Equal(t, []interface{}{true}, variationValue(toggle.Variations[1]))
+	assert.Equal(t, "s", toggle.Variations[2])
+	assert.Equal(t, 1.5, toggle.Variations[3])
+	assert.Nil(t, toggle.Variations[4])
+
+	bytes, err := json.Marshal(toggle.Variations)
+	assert.Nil(t, err)
+	assert.Equal(t, `[{"a":[1,2]},[true],"s",1.5,null]`, string(bytes))
+}